@@ -1,5 +1,24 @@
 package main
 
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/illbjorn/fstr"
+	"github.com/illbjorn/skal/internal/skal"
+	"github.com/illbjorn/skal/internal/skal/exec"
+	"github.com/illbjorn/skal/internal/skal/manifest"
+	"github.com/illbjorn/skal/internal/skal/mod"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/target"
+	"github.com/illbjorn/skal/internal/skal/vfs"
+)
+
 var cmds = make(map[string]cmd)
 
 type cmd interface {
@@ -7,3 +26,222 @@ type cmd interface {
 	ParseFlags()
 	Exec() error
 }
+
+// Parses the provided command-line args with FlagSet `fs`, allowing flags and
+// positional args to be interleaved (e.g. `skal c ./main.sk --watch`). Args
+// following a `--` are all positional.
+//
+// The positional args are returned in their original order. An invalid flag
+// has been reported by the FlagSet, with the usage, and exits.
+func parseFlags(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(0)
+			}
+			os.Exit(2)
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional
+		}
+
+		// '--'
+		if i := len(args) - len(rest) - 1; i >= 0 && args[i] == "--" {
+			return append(positional, rest...)
+		}
+
+		// Stash the positional arg and continue parsing past it.
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// A repeatable string flag (e.g. `--decl a.skd --decl b.skd`).
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// Prints the diagnostics reported by a compile in format `format`. Errors
+// which aren't diagnostics (I/O, runtime errors) are reported alongside them.
+// Text output is followed by a summary if the compile failed.
+func report(format sklog.Format, diags *sklog.Diagnostics, err error) {
+	list := diags.List()
+
+	serr := new(sklog.Error)
+	rerr := new(exec.Error)
+	isDiag := errors.As(err, &serr)
+	switch {
+	case err == nil, isDiag:
+	case errors.As(err, &rerr):
+		list = append(list, rerr.Diagnostic)
+	default:
+		list = append(list, sklog.NewCompilerEvent(
+			sklog.MsgTypeCompilerError, sklog.LevelError).Str(err.Error()).Diagnostic())
+	}
+
+	if werr := sklog.Write(os.Stdout, format, list); werr != nil {
+		println("ERROR: Failed to write diagnostics. Inner error:", werr.Error()+".")
+	}
+
+	if format != sklog.FormatText || !isDiag {
+		return
+	}
+
+	sklog.NewCompilerEvent(sklog.MsgTypeCompilerError, sklog.LevelError).
+		AddF(
+			"Compilation failed with {n} error(s).",
+			"n", strconv.Itoa(diags.Errors()),
+		).
+		Send()
+	for _, d := range list {
+		if d.Code != "" {
+			fmt.Println("For more information about a diagnostic, run 'skal explain <code>'.")
+			break
+		}
+	}
+}
+
+// A `--diagnostics-format` flag.
+type formatFlag sklog.Format
+
+func (f *formatFlag) String() string {
+	return string(*f)
+}
+
+func (f *formatFlag) Set(v string) error {
+	format, err := sklog.ParseFormat(v)
+	if err != nil {
+		return err
+	}
+
+	*f = formatFlag(format)
+	return nil
+}
+
+// A `--target` flag.
+type targetFlag string
+
+func (f *targetFlag) String() string {
+	return string(*f)
+}
+
+func (f *targetFlag) Set(v string) error {
+	t, ok := target.Lookup(v)
+	if !ok {
+		return fmt.Errorf("unknown target '%s', expected one of: %s", v, strings.Join(target.Names(), ", "))
+	}
+
+	*f = targetFlag(t.Name)
+	return nil
+}
+
+// The `--no-cache` and `--verbose` flags.
+type cacheFlags struct {
+	noCache bool
+	verbose bool
+}
+
+func (f *cacheFlags) define(fs *flag.FlagSet) {
+	fs.BoolVar(&f.noCache, "no-cache", false, "")
+	fs.BoolVar(&f.verbose, "verbose", false, "")
+	fs.BoolVar(&f.verbose, "v", false, "")
+}
+
+// Configures a compile to cache module output in the `skal` directory of the
+// user's cache directory (e.g. `~/.cache/skal`). The cache is disabled if
+// there is no user cache directory.
+func (f *cacheFlags) apply(opts *skal.Options) {
+	if f.noCache {
+		return
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return
+	}
+
+	opts.CacheDir = filepath.Join(dir, "skal")
+	if f.verbose {
+		opts.CacheStats = new(skal.CacheStats)
+	}
+}
+
+// Prints the cache statistics of a compile, if they were requested.
+func reportCache(stats *skal.CacheStats) {
+	if stats == nil {
+		return
+	}
+
+	println(fstr.Pairs(
+		"Cache: {hits} hit(s), {misses} miss(es), {stored} stored ({dir}).",
+		"hits", strconv.Itoa(stats.Hits),
+		"misses", strconv.Itoa(stats.Misses),
+		"stored", strconv.Itoa(stats.Stored),
+		"dir", stats.Dir,
+	))
+}
+
+// Loads the project manifest nearest to directory `dir`, if there is one. An
+// invalid manifest is reported in format `format` and exits.
+func loadManifest(dir string, format sklog.Format) *manifest.Manifest {
+	diags := sklog.NewDiagnostics()
+	m := manifest.Find(vfs.New(nil), dir, diags)
+	if err := diags.Err(); err != nil {
+		report(format, diags, err)
+		os.Exit(1)
+	}
+
+	// Warnings
+	if list := diags.List(); len(list) > 0 {
+		_ = sklog.Write(os.Stdout, format, list)
+	}
+
+	return m
+}
+
+// Loads the project manifest nearest to directory `dir` (see loadManifest),
+// checking its dependencies are vendored as pinned by the lockfile. Problems
+// are reported in format `format` and exit.
+func loadProject(dir string, format sklog.Format) *manifest.Manifest {
+	m := loadManifest(dir, format)
+	if m == nil {
+		return nil
+	}
+
+	diags := sklog.NewDiagnostics()
+	mod.Verify(m, diags)
+	if err := diags.Err(); err != nil {
+		report(format, diags, err)
+		os.Exit(1)
+	}
+
+	return m
+}
+
+// Configures a compile with the settings of project manifest `m` (if any) and
+// the environment: the module search roots of the manifest then those of
+// SKAL_PATH, and the manifest's target unless one was given.
+func applyProject(m *manifest.Manifest, opts *skal.Options) {
+	if m != nil {
+		opts.Paths = append(opts.Paths, m.Roots()...)
+		if opts.Target == "" {
+			opts.Target = m.Target
+		}
+	}
+
+	for _, dir := range filepath.SplitList(os.Getenv("SKAL_PATH")) {
+		if dir != "" {
+			opts.Paths = append(opts.Paths, dir)
+		}
+	}
+}
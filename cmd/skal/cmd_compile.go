@@ -5,6 +5,7 @@ import (
 	"crypto"
 	"encoding/binary"
 	"flag"
+	"fmt"
 	"io/fs"
 	"os"
 	"os/signal"
@@ -13,6 +14,8 @@ import (
 	"time"
 
 	"github.com/illbjorn/skal/internal/skal"
+	"github.com/illbjorn/skal/internal/skal/manifest"
+	"github.com/illbjorn/skal/internal/skal/sklog"
 )
 
 func init() {
@@ -24,57 +27,106 @@ func init() {
 var _ cmd = &cmdCompile{}
 
 type cmdCompile struct {
+	builds []build
+	args   []string
+	opts   skal.Options
+	format formatFlag
+	watch  bool
+	cache  cacheFlags
+	perf   perfFlags
+	target targetFlag
+}
+
+// An entrypoint to compile, and the path its output is written to.
+type build struct {
 	input  string
 	output string
-	watch  bool
 }
 
 func (cmd *cmdCompile) ParseArgs() {
-	// Expect 2-3 args.
-	switch len(os.Args) {
-	case 3: // Just the input path.
-		cmd.input = os.Args[2]
-		cmd.output = strings.Replace(cmd.input, filepath.Ext(cmd.input), ".lua", 1)
+	// Expect 0-2 positional args.
+	var m *manifest.Manifest
+	switch len(cmd.args) {
+	case 0: // The entrypoints of the project manifest.
+		m = loadProject(".", sklog.Format(cmd.format))
+		if m == nil || len(m.Entrypoints) == 0 {
+			println("ERROR: No input path given, and no " + manifest.Name + " declaring entrypoints was found.")
+			os.Exit(1)
+		}
+		for _, input := range m.EntryPaths() {
+			cmd.builds = append(cmd.builds, build{input: input, output: m.OutputPath(input)})
+		}
 
-	case 4: // Input and output paths.
-		cmd.input = os.Args[2]
-		cmd.output = os.Args[3]
+	case 1: // Just the input path.
+		input := cmd.args[0]
+		output := strings.Replace(input, filepath.Ext(input), ".lua", 1)
+		cmd.builds = []build{{input: input, output: output}}
+		m = loadProject(filepath.Dir(input), sklog.Format(cmd.format))
+
+	case 2: // Input and output paths.
+		cmd.builds = []build{{input: cmd.args[0], output: cmd.args[1]}}
+		m = loadProject(filepath.Dir(cmd.args[0]), sklog.Format(cmd.format))
 
 	default:
-		for i, arg := range os.Args {
-			println(i, ":", arg)
-		}
-		println(len(os.Args))
 		println(helpText)
 		os.Exit(1)
 	}
+
+	applyProject(m, &cmd.opts)
 }
 
 func (cmd *cmdCompile) ParseFlags() {
+	fs := flag.NewFlagSet("compile", flag.ContinueOnError)
+	fs.Usage = func() { println(helpText) }
+
 	//--
 	// Define flags
-	watch := flag.Bool("watch", false, "")
+	var decls stringsFlag
+	fs.Var(&decls, "decl", "")
+	cmd.format = formatFlag(sklog.FormatText)
+	fs.Var(&cmd.format, "diagnostics-format", "")
+	fs.BoolVar(&cmd.watch, "watch", false, "")
+	fs.BoolVar(&cmd.watch, "w", false, "")
+	fs.BoolVar(&cmd.opts.DumpAST, "dump-ast", false, "")
+	fs.BoolVar(&cmd.opts.DumpAST, "d", false, "")
+	fs.Var(&cmd.target, "target", "")
+	fs.BoolVar(&cmd.opts.Split, "split", false, "")
+	fs.BoolVar(&cmd.opts.Lib, "lib", false, "")
+	fs.BoolVar(&cmd.opts.Optimize, "O", false, "")
+	fs.BoolVar(&cmd.opts.Minify, "minify", false, "")
+	fs.BoolVar(&cmd.opts.Comments, "comments", false, "")
+	cmd.cache.define(fs)
+	cmd.perf.define(fs)
 
 	// Parse
-	flag.Parse()
+	cmd.args = parseFlags(fs, os.Args[2:])
 
 	//--
 	// Assign flags
-	cmd.watch = *watch
+	cmd.opts.Decls = decls
+	cmd.opts.Target = string(cmd.target)
 }
 
 func (cmd *cmdCompile) Exec() error {
+	format := sklog.Format(cmd.format)
+
+	// Profile
+	if err := cmd.perf.start(); err != nil {
+		report(format, sklog.NewDiagnostics(), err)
+		return err
+	}
+	defer cmd.perf.stop()
+
 	// Compile!
 	if cmd.watch {
-		watchCompile(cmd.input, cmd.output)
-	} else {
-		compile(cmd.input, cmd.output)
+		watchCompile(cmd.builds, cmd.opts, cmd.cache, &cmd.perf, format)
+		return nil
 	}
 
-	return nil
+	return compileAll(cmd.builds, cmd.opts, cmd.cache, &cmd.perf, format)
 }
 
-func watchCompile(input, output string) {
+func watchCompile(builds []build, opts skal.Options, cache cacheFlags, perf *perfFlags, format sklog.Format) {
 	done := make(chan os.Signal, 2)
 	signal.Notify(done, os.Interrupt)
 
@@ -84,23 +136,63 @@ func watchCompile(input, output string) {
 		case <-done:
 			return
 		case <-time.After(200 * time.Millisecond):
-			nh := hash(input)
+			var nh []byte
+			for _, b := range builds {
+				nh = append(nh, hash(b.input)...)
+			}
 			if !bytes.Equal(nh, h) {
-				compile(input, output)
+				_ = compileAll(builds, opts, cache, perf, format)
 				h = nh
 			}
 		}
 	}
 }
 
-func compile(input, output string) {
+// Compiles each of `builds`, returning the first error.
+func compileAll(builds []build, opts skal.Options, cache cacheFlags, perf *perfFlags, format sklog.Format) error {
+	var first error
+	for _, b := range builds {
+		opts := opts
+		cache.apply(&opts)
+		perf.apply(&opts)
+		if err := compile(b.input, b.output, opts, perf, format); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+func compile(input, output string, opts skal.Options, perf *perfFlags, format sklog.Format) error {
+	opts.Diagnostics = sklog.NewDiagnostics()
+	if opts.CacheStats != nil {
+		opts.CacheStats = new(skal.CacheStats)
+	}
+
+	// Create the output directory, e.g. the manifest's output.
+	err := os.MkdirAll(filepath.Dir(output), 0755)
+	if err != nil {
+		err = fmt.Errorf("failed to create output directory '%s': %w", filepath.Dir(output), err)
+	}
+
 	start := time.Now()
-	skal.Compile(input, output)
+	if err == nil {
+		err = skal.Compile(input, output, opts)
+	}
 	dur := time.Since(start)
+
+	report(format, opts.Diagnostics, err)
+	if err != nil {
+		return err
+	}
+
 	println("Compile Time:", dur.String())
+	reportCache(opts.CacheStats)
+	perf.report(opts.Perf)
+	return nil
 }
 
-// TODO: Currently this just walks the source directory and hashes all .sk files
+// TODO: Currently this just walks the source directory and hashes all .sk(d) files
 // We should eventually instead actually parse the provided source file,
 // recursively enumerate all imports and watch those.
 func hash(input string) []byte {
@@ -112,7 +204,8 @@ func hash(input string) []byte {
 	err := filepath.WalkDir(
 		dir, func(path string, item fs.DirEntry, _ error) error {
 			// Rule out non-Skal files.
-			if item.IsDir() || filepath.Ext(item.Name()) != ".sk" {
+			ext := filepath.Ext(item.Name())
+			if item.IsDir() || (ext != ".sk" && ext != ".skd") {
 				return nil
 			}
 
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/illbjorn/skal/internal/skal"
+	"github.com/illbjorn/skal/internal/skal/exec"
+	"github.com/illbjorn/skal/internal/skal/manifest"
+	"github.com/illbjorn/skal/internal/skal/sklog"
 )
 
 func init() {
@@ -17,37 +23,89 @@ func init() {
 var _ cmd = &cmdExec{}
 
 type cmdExec struct {
-	input string
+	input  string
+	args   []string
+	opts   skal.Options
+	format formatFlag
+	cache  cacheFlags
+	perf   perfFlags
 }
 
 func (cmd *cmdExec) ParseArgs() {
-	// Expect 2-3 args.
-	switch len(os.Args) {
-	case 3: // Just the input path.
-		cmd.input = os.Args[2]
+	// Expect 0-2 positional args.
+	var m *manifest.Manifest
+	switch len(cmd.args) {
+	case 0: // The first entrypoint of the project manifest.
+		m = loadProject(".", sklog.Format(cmd.format))
+		if m == nil || len(m.Entrypoints) == 0 {
+			println("ERROR: No input path given, and no " + manifest.Name + " declaring entrypoints was found.")
+			os.Exit(1)
+		}
+		cmd.input = m.EntryPaths()[0]
 
-	case 4: // Input and output paths.
-		cmd.input = os.Args[2]
+	case 1, 2: // Input path, optionally followed by an (ignored) output path.
+		cmd.input = cmd.args[0]
+		m = loadProject(filepath.Dir(cmd.input), sklog.Format(cmd.format))
 
 	default:
-		for i, arg := range os.Args {
-			println(i, ":", arg)
-		}
-		println(len(os.Args))
 		println(helpText)
 		os.Exit(1)
 	}
+
+	applyProject(m, &cmd.opts)
 }
 
-func (cmd *cmdExec) ParseFlags() {}
+func (cmd *cmdExec) ParseFlags() {
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	fs.Usage = func() { println(helpText) }
+
+	//--
+	// Define flags
+	var decls stringsFlag
+	fs.Var(&decls, "decl", "")
+	cmd.format = formatFlag(sklog.FormatText)
+	fs.Var(&cmd.format, "diagnostics-format", "")
+	fs.BoolVar(&cmd.opts.Optimize, "O", false, "")
+	fs.BoolVar(&cmd.opts.Minify, "minify", false, "")
+	fs.BoolVar(&cmd.opts.Comments, "comments", false, "")
+	cmd.cache.define(fs)
+	cmd.perf.define(fs)
+
+	// Parse
+	cmd.args = parseFlags(fs, os.Args[2:])
+
+	//--
+	// Assign flags
+	cmd.opts.Decls = decls
+}
 
 func (cmd *cmdExec) Exec() error {
-	fmt.Println("Compiling and running:", cmd.input)
+	if sklog.Format(cmd.format) == sklog.FormatText {
+		fmt.Println("Compiling and running:", cmd.input)
+	}
+	cmd.opts.Diagnostics = sklog.NewDiagnostics()
+	// Scripts run by the CLI are trusted with all the access of the user.
+	cmd.opts.Libs = exec.LibAll
+	cmd.cache.apply(&cmd.opts)
+	cmd.perf.apply(&cmd.opts)
+	if err := cmd.perf.start(); err != nil {
+		report(sklog.Format(cmd.format), cmd.opts.Diagnostics, err)
+		return err
+	}
+	defer cmd.perf.stop()
+
 	start := time.Now()
-	skal.CompileAndRun(cmd.input)
+	err := skal.Run(context.Background(), cmd.input, cmd.opts)
 	dur := time.Since(start)
 
+	report(sklog.Format(cmd.format), cmd.opts.Diagnostics, err)
+	if err != nil {
+		return err
+	}
+
 	println("Runtime:", dur.String())
+	reportCache(cmd.opts.CacheStats)
+	cmd.perf.report(cmd.opts.Perf)
 
 	return nil
 }
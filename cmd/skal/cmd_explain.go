@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/sklog"
+)
+
+func init() {
+	cmd := new(cmdExplain)
+	cmds["explain"] = cmd
+}
+
+var _ cmd = &cmdExplain{}
+
+type cmdExplain struct {
+	code string
+	args []string
+}
+
+func (cmd *cmdExplain) ParseArgs() {
+	// Expect 0-1 positional args.
+	switch len(cmd.args) {
+	case 0: // List all codes.
+
+	case 1: // The code to explain.
+		cmd.code = cmd.args[0]
+
+	default:
+		println(helpText)
+		os.Exit(1)
+	}
+}
+
+func (cmd *cmdExplain) ParseFlags() {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.Usage = func() { println(helpText) }
+
+	// Parse
+	cmd.args = parseFlags(fs, os.Args[2:])
+}
+
+func (cmd *cmdExplain) Exec() error {
+	// List
+	if cmd.code == "" {
+		for _, code := range sklog.Codes() {
+			title, _ := sklog.Title(code)
+			fmt.Println(code + "  " + title)
+		}
+		return nil
+	}
+
+	// Explain
+	explain, ok := sklog.Explain(cmd.code)
+	if !ok {
+		err := fmt.Errorf("unknown diagnostic code '%s'", cmd.code)
+		println("ERROR:", err.Error()+". Run 'skal explain' to list all codes.")
+		return err
+	}
+
+	code := strings.ToUpper(cmd.code)
+	title, _ := sklog.Title(code)
+	fmt.Println(code + ": " + title)
+	fmt.Println()
+	fmt.Println(explain)
+	return nil
+}
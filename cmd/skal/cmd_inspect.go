@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/illbjorn/skal/internal/skal"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+)
+
+func init() {
+	cmd := new(cmdInspect)
+	cmds["inspect"] = cmd
+}
+
+var _ cmd = &cmdInspect{}
+
+type cmdInspect struct {
+	stage  string
+	input  string
+	args   []string
+	opts   skal.Options
+	format formatFlag
+	json   bool
+}
+
+func (cmd *cmdInspect) ParseArgs() {
+	// Expect 2 positional args.
+	if len(cmd.args) != 2 {
+		println(helpText)
+		os.Exit(1)
+	}
+	cmd.stage, cmd.input = cmd.args[0], cmd.args[1]
+
+	applyProject(loadProject(filepath.Dir(cmd.input), sklog.Format(cmd.format)), &cmd.opts)
+}
+
+func (cmd *cmdInspect) ParseFlags() {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	fs.Usage = func() { println(helpText) }
+
+	//--
+	// Define flags
+	var decls stringsFlag
+	fs.Var(&decls, "decl", "")
+	cmd.format = formatFlag(sklog.FormatText)
+	fs.Var(&cmd.format, "diagnostics-format", "")
+	fs.BoolVar(&cmd.json, "json", false, "")
+
+	// Parse
+	cmd.args = parseFlags(fs, os.Args[2:])
+
+	//--
+	// Assign flags
+	cmd.opts.Decls = decls
+}
+
+func (cmd *cmdInspect) Exec() error {
+	cmd.opts.Diagnostics = sklog.NewDiagnostics()
+
+	// The symbols resolved are printed ahead of the names which failed to.
+	tree, err := skal.Inspect(cmd.input, cmd.stage, cmd.opts)
+	if tree != nil {
+		var werr error
+		if cmd.json {
+			werr = tree.JSON(os.Stdout)
+		} else {
+			werr = tree.Text(os.Stdout)
+		}
+		if werr != nil {
+			println("ERROR: Failed to write the "+cmd.stage+". Inner error:", werr.Error()+".")
+			return werr
+		}
+	}
+	if err != nil {
+		report(sklog.Format(cmd.format), cmd.opts.Diagnostics, err)
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/illbjorn/skal/internal/skal/manifest"
+	"github.com/illbjorn/skal/internal/skal/mod"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+)
+
+func init() {
+	cmds["mod"] = new(cmdMod)
+}
+
+var _ cmd = &cmdMod{}
+
+type cmdMod struct {
+	sub    string
+	args   []string
+	format formatFlag
+}
+
+func (cmd *cmdMod) ParseArgs() {
+	// Expect a subcommand and its args.
+	if len(cmd.args) == 0 {
+		println(helpText)
+		os.Exit(1)
+	}
+	cmd.sub, cmd.args = cmd.args[0], cmd.args[1:]
+
+	switch {
+	// 'add' Source [Name]
+	case cmd.sub == "add" && (len(cmd.args) == 1 || len(cmd.args) == 2):
+
+	// 'tidy', 'vendor'
+	case (cmd.sub == "tidy" || cmd.sub == "vendor") && len(cmd.args) == 0:
+
+	default:
+		println(helpText)
+		os.Exit(1)
+	}
+}
+
+func (cmd *cmdMod) ParseFlags() {
+	fs := flag.NewFlagSet("mod", flag.ContinueOnError)
+	fs.Usage = func() { println(helpText) }
+
+	//--
+	// Define flags
+	cmd.format = formatFlag(sklog.FormatText)
+	fs.Var(&cmd.format, "diagnostics-format", "")
+
+	// Parse
+	cmd.args = parseFlags(fs, os.Args[2:])
+}
+
+func (cmd *cmdMod) Exec() error {
+	m := loadManifest(".", sklog.Format(cmd.format))
+	if m == nil {
+		err := fmt.Errorf("no %s found, create one to declare dependencies", manifest.Name)
+		report(sklog.Format(cmd.format), nil, err)
+		return err
+	}
+
+	var err error
+	diags := sklog.NewDiagnostics()
+	switch cmd.sub {
+	// 'add'
+	case "add":
+		var name string
+		if len(cmd.args) == 2 {
+			name = cmd.args[1]
+		}
+		err = mod.Add(m, cmd.args[0], name, diags, os.Stdout)
+
+	// 'tidy'
+	case "tidy":
+		err = mod.Tidy(m, diags, os.Stdout)
+
+	// 'vendor'
+	case "vendor":
+		err = mod.Vendor(m, diags, os.Stdout)
+	}
+
+	if err != nil {
+		report(sklog.Format(cmd.format), diags, err)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/illbjorn/skal/internal/skal"
+	"github.com/illbjorn/skal/internal/skal/manifest"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/vfs"
+)
+
+// Imports are looked up in the directory of the entrypoint, the manifest's
+// `paths`, its `deps/`, then SKAL_PATH.
+func TestRootOrder(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, src string) string {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	write("proj/skal.toml", "paths = ['lib']\n")
+	main := write("proj/main.sk", "import 'util'\nprint(which())\n")
+	roots := []struct{ name, path string }{
+		{"entry", "proj/util.sk"},
+		{"paths", "proj/lib/util.sk"},
+		{"deps", "proj/deps/util.sk"},
+		{"SKAL_PATH", "env/util.sk"},
+	}
+	for _, root := range roots {
+		write(root.path, "pub fn which() { return '"+root.name+"' }\n")
+	}
+	t.Setenv("SKAL_PATH", filepath.Join(dir, "env"))
+
+	// Each root shadows those after it.
+	for _, root := range roots {
+		diags := sklog.NewDiagnostics()
+		m := manifest.Find(vfs.New(nil), filepath.Dir(main), diags)
+		if m == nil {
+			t.Fatalf("manifest not found: %v", diags.Err())
+		}
+
+		out := new(bytes.Buffer)
+		opts := skal.Options{Stdout: out}
+		applyProject(m, &opts)
+		if err := skal.Run(context.Background(), main, opts); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != root.name+"\n" {
+			t.Errorf("imported from %q, want %s", got, root.name)
+		}
+
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(root.path))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -18,13 +18,59 @@ To compile a script:
 
   {green}skal c ./main.sk{reset}
 
+To compile the entrypoints of the project manifest ({green}skal.toml{reset}):
+
+  {green}skal c{reset}
+
 Commands:
   compile, c       Compile a Skal script.
+  exec, e          Compile and run a Skal script, with access to all host
+                   libraries (os, io, debug, package, http).
+  explain [code]   Print the long-form help of a diagnostic code (e.g. SK0102).
+  inspect <stage> <path>
+                   Print a stage of the pipeline for a Skal script as an
+                   indented tree (or JSON with --json): tokens, ast, typeset
+                   or symbols.
+  mod add <source> [name]
+                   Vendor a dependency (a directory or .tar, .tar.gz or .tgz
+                   archive) into deps/, declare it in skal.toml and pin it in
+                   skal.lock.
+  mod tidy         Pin the dependencies skal.toml declares, remove the rest.
+  mod vendor       Copy every pinned dependency into deps/ from its source.
 
 Options:
-  --with-perf, -p  Produce performance measurement output after a compile.
-	--dump-ast,  -d  Serialize the built AST to JSON and write to file.
+  --with-perf, -p  Produce performance measurement output after a compile:
+	                 the time and allocations of each phase, per file and in
+	                 total. --with-perf=json prints JSON.
+	--cpuprofile <path>
+	                 Write a pprof CPU profile of the compile to <path>.
+	--memprofile <path>
+	                 Write a pprof allocation profile of the compile to <path>.
+	--dump-ast,  -d  Serialize the built AST to JSON and write to file
+	                 (<output>.ast.json).
 	--watch,     -w  Watch the targeted source file and recompile on change.
+	--decl <path>    Load extern declarations from a .skd file or directory.
+	--target <name>  Lua runtime to compile for: lua5.1 (default), lua5.2,
+	                 lua5.3, lua5.4, luajit or luau.
+	--split          Compile each module to its own .lua file, loaded with
+	                 'require', rather than bundling them into one.
+	--lib            Compile a module returning the entrypoint's pub symbols,
+	                 rather than an app.
+	-O               Optimize: fold constants, remove dead code, inline small
+	                 fns and enum members, cache host fn lookups in locals.
+	--minify         Rename locals to short names, drop comments and
+	                 whitespace. Source maps still apply, and record the
+	                 original names of renamed locals.
+	--comments       Keep source comments in the output, those of pub fns and
+	                 structs as LuaLS doc comments.
+	--no-cache       Compile every module, ignoring and not writing the cache.
+	                 Module output is otherwise cached in the user cache
+	                 directory (e.g. ~/.cache/skal), evicting entries unused
+	                 for 30 days and the least recently used past 256 MiB.
+	--verbose,   -v  Report cache statistics after a compile.
+	--diagnostics-format <text|json|sarif>
+	                 Output format of compiler diagnostics (default: text).
+	--json           Print 'skal inspect' output as JSON.
 	--help,      -h  How you got here!
 `,
 	"cyan", "\033[0m",
@@ -48,10 +94,10 @@ func main() {
 		println(helpText)
 		os.Exit(1)
 	} else {
-		called.ParseArgs()
 		called.ParseFlags()
+		called.ParseArgs()
+		// Failures have already been reported.
 		if err := called.Exec(); err != nil {
-			println(helpText)
 			os.Exit(1)
 		}
 	}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/illbjorn/skal/internal/skal"
+)
+
+// The `--with-perf`, `--cpuprofile` and `--memprofile` flags.
+type perfFlags struct {
+	format     perfFormat
+	cpuProfile string
+	memProfile string
+	cpu        *os.File
+}
+
+func (f *perfFlags) define(fs *flag.FlagSet) {
+	fs.Var(&f.format, "with-perf", "")
+	fs.Var(&f.format, "p", "")
+	fs.StringVar(&f.cpuProfile, "cpuprofile", "", "")
+	fs.StringVar(&f.memProfile, "memprofile", "", "")
+}
+
+// Configures a compile to take performance measurements, if requested.
+func (f *perfFlags) apply(opts *skal.Options) {
+	if f.format != "" {
+		opts.Perf = new(skal.Perf)
+	}
+}
+
+// Starts the CPU profile, if requested.
+func (f *perfFlags) start() error {
+	if f.cpuProfile == "" {
+		return nil
+	}
+
+	cpu, err := os.Create(f.cpuProfile)
+	if err == nil {
+		err = pprof.StartCPUProfile(cpu)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start CPU profile '%s': %w", f.cpuProfile, err)
+	}
+	f.cpu = cpu
+
+	return nil
+}
+
+// Stops the CPU profile and writes the allocation profile, if requested.
+func (f *perfFlags) stop() {
+	if f.cpu != nil {
+		pprof.StopCPUProfile()
+		if err := f.cpu.Close(); err != nil {
+			println("ERROR: Failed to write CPU profile. Inner error:", err.Error()+".")
+		}
+	}
+
+	if f.memProfile == "" {
+		return
+	}
+	mem, err := os.Create(f.memProfile)
+	if err == nil {
+		runtime.GC()
+		err = pprof.Lookup("allocs").WriteTo(mem, 0)
+		if cerr := mem.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		println("ERROR: Failed to write memory profile. Inner error:", err.Error()+".")
+	}
+}
+
+// Prints the performance measurements of a compile, if they were requested.
+func (f *perfFlags) report(perf *skal.Perf) {
+	if perf == nil {
+		return
+	}
+
+	var err error
+	if f.format == perfJSONFormat {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(perf)
+	} else {
+		err = perfTable(perf)
+	}
+	if err != nil {
+		println("ERROR: Failed to write performance measurements. Inner error:", err.Error()+".")
+	}
+}
+
+// Prints the measurements of `perf` as a table of phases, then a table of
+// source files.
+//
+//	Phase    Time     Allocs  Bytes
+//	lex      62.1µs   1210    84.2 KiB
+//	...
+//
+//	File     Tokens  Nodes  imports  lex     parse   ...  Allocs  Bytes
+//	main.sk  212     301    8.2µs    62.1µs  40.3µs  ...  2433    160.1 KiB
+func perfTable(perf *skal.Perf) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	// Phases
+	var total skal.Measure
+	fmt.Fprintln(w, "Phase\tTime\tAllocs\tBytes")
+	for _, p := range skal.Phases() {
+		m, ok := perf.Total[p]
+		if !ok {
+			continue
+		}
+		total = skal.Measure{Time: total.Time + m.Time, Allocs: total.Allocs + m.Allocs, Bytes: total.Bytes + m.Bytes}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", p, duration(m.Time), m.Allocs, size(m.Bytes))
+	}
+	fmt.Fprintf(w, "total\t%s\t%d\t%s\n", duration(total.Time), total.Allocs, size(total.Bytes))
+	fmt.Fprintln(w)
+
+	// Source files
+	var phases []skal.Phase
+	for _, p := range skal.Phases() {
+		for _, file := range perf.Files {
+			if _, ok := file.Phases[p]; ok {
+				phases = append(phases, p)
+				break
+			}
+		}
+	}
+	fmt.Fprint(w, "File\tTokens\tNodes")
+	for _, p := range phases {
+		fmt.Fprint(w, "\t"+p.String())
+	}
+	fmt.Fprintln(w, "\tAllocs\tBytes")
+	for _, file := range perf.Files {
+		path := file.Path
+		if file.Cached {
+			path += " (cached)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d", path, file.Tokens, file.Nodes)
+
+		var allocs, bytes uint64
+		for _, p := range phases {
+			m := file.Phases[p]
+			allocs, bytes = allocs+m.Allocs, bytes+m.Bytes
+			fmt.Fprint(w, "\t"+duration(m.Time))
+		}
+		fmt.Fprintf(w, "\t%d\t%s\n", allocs, size(bytes))
+	}
+
+	return w.Flush()
+}
+
+func duration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+
+	return d.Round(100 * time.Nanosecond).String()
+}
+
+// Produces the size of `n` bytes in the largest unit it reaches.
+func size(n uint64) string {
+	switch {
+	case n >= 1<<20:
+		return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + " MiB"
+	case n >= 1<<10:
+		return strconv.FormatFloat(float64(n)/(1<<10), 'f', 1, 64) + " KiB"
+	default:
+		return strconv.FormatUint(n, 10) + " B"
+	}
+}
+
+// A `--with-perf` flag: `table` (the default, for `--with-perf` alone) or
+// `json`.
+type perfFormat string
+
+const (
+	perfTableFormat perfFormat = "table"
+	perfJSONFormat  perfFormat = "json"
+)
+
+func (f *perfFormat) String() string {
+	return string(*f)
+}
+
+func (f *perfFormat) Set(v string) error {
+	switch v {
+	case "true", string(perfTableFormat):
+		*f = perfTableFormat
+	case string(perfJSONFormat):
+		*f = perfJSONFormat
+	case "false":
+		*f = ""
+	default:
+		return fmt.Errorf("unknown performance output format '%s', expected table or json", v)
+	}
+
+	return nil
+}
+
+// Allows `--with-perf` to be given without a value.
+func (f *perfFormat) IsBoolFlag() bool {
+	return true
+}
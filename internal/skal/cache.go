@@ -0,0 +1,302 @@
+package skal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/illbjorn/skal/internal/skal/decl"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/srcmap"
+)
+
+// Bumped whenever the layout of cache entries changes.
+const cacheVersion = "5"
+
+// Bounds of the cache, enforced whenever entries are written: entries unused
+// for cacheMaxAge are evicted, then the least recently used until the cache
+// fits cacheMaxSize.
+const (
+	cacheMaxAge  = 30 * 24 * time.Hour
+	cacheMaxSize = 256 << 20
+)
+
+// CacheStats counts the modules of a compilation served from and written to
+// the cache.
+type CacheStats struct {
+	Dir string
+	// Modules whose output was reused.
+	Hits int
+	// Modules which were compiled.
+	Misses int
+	// Modules whose output was written to the cache.
+	Stored int
+}
+
+// An on-disk cache of per-module output, which may be shared by any number of
+// projects.
+//
+// Entries are keyed by the absolute path and content of a module, and hold its
+// summary (see `summarize`) along with its emitted Lua. The Lua is only reused while the
+// environment it was compiled in is unchanged: the declarations, the surface of
+// every module it (transitively) imports and the names of all globals.
+type cache struct {
+	dir string
+	// Hash of the declarations modules are compiled against.
+	decls string
+	stats *CacheStats
+}
+
+type cacheEntry struct {
+	Summary summary
+	// Identifies the environment the Lua was compiled in.
+	DepKey   string
+	Lua      []byte
+	Mappings []srcmap.Mapping
+	Names    []srcmap.Name
+	// Warnings reported for the module, replayed on reuse.
+	Diagnostics []sklog.Diagnostic
+}
+
+// Opens the cache rooted at `dir`, returning nil (a disabled cache) if `dir` is
+// empty. Statistics are recorded to `stats`, if set.
+func openCache(dir string, j *job, stats *CacheStats) *cache {
+	if dir == "" {
+		return nil
+	}
+
+	if stats == nil {
+		stats = new(CacheStats)
+	}
+	stats.Dir = dir
+
+	h := sha256.New()
+	_, _ = h.Write([]byte(j.Target.Name + "\n" + strconv.FormatBool(j.Split) + "\n" + strconv.FormatBool(j.Lib) + "\n" + strconv.FormatBool(j.Optimize) + "\n" + strconv.FormatBool(j.Comments) + "\n"))
+	hashDecls(h, j.Decls.Symbols)
+
+	return &cache{
+		dir:   dir,
+		decls: hex.EncodeToString(h.Sum(nil)),
+		stats: stats,
+	}
+}
+
+func hashDecls(h hash.Hash, syms []*decl.Symbol) {
+	for _, sym := range syms {
+		_, _ = h.Write([]byte(sym.Signature() + "\n"))
+		hashDecls(h, sym.Members)
+	}
+}
+
+// Identifies the running compiler, so entries written by another build of the
+// compiler are never reused.
+var compilerID = sync.OnceValue(func() string {
+	h := sha256.New()
+	_, _ = h.Write([]byte(cacheVersion))
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		_, _ = h.Write([]byte(info.Main.Version))
+		for _, s := range info.Settings {
+			if strings.HasPrefix(s.Key, "vcs.") {
+				_, _ = h.Write([]byte(s.Key + "=" + s.Value))
+			}
+		}
+	}
+
+	// Development builds share a version, the executable itself tells them apart.
+	if exe, err := os.Executable(); err == nil {
+		if stat, err := os.Stat(exe); err == nil {
+			_, _ = h.Write([]byte(strconv.FormatInt(stat.Size(), 10)))
+			_, _ = h.Write([]byte(stat.ModTime().String()))
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+})
+
+func (c *cache) path(f *srcFile) string {
+	path := f.Path
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	h := sha256.New()
+	_, _ = h.Write([]byte(compilerID()))
+	_, _ = h.Write([]byte(path + "\x00"))
+	_, _ = h.Write([]byte(f.Content))
+
+	return filepath.Join(c.dir, hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+// Loads the entries of source files, nil where a file has none.
+func (c *cache) load(files []*srcFile) []*cacheEntry {
+	entries := make([]*cacheEntry, len(files))
+	if c == nil {
+		return entries
+	}
+
+	now := time.Now()
+	for i, f := range files {
+		path := c.path(f)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		entry := new(cacheEntry)
+		if json.Unmarshal(b, entry) == nil {
+			entries[i] = entry
+			// Note the use, entries are evicted least recently used first.
+			_ = os.Chtimes(path, now, now)
+		}
+	}
+
+	return entries
+}
+
+// Writes the entry of a source file. Failing to write the cache doesn't fail
+// the compile, the entry is skipped.
+func (c *cache) store(f *srcFile, entry *cacheEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return
+	}
+
+	// Write then rename, so concurrent compiles never read a partial entry.
+	tmp, err := os.CreateTemp(c.dir, "entry-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(f))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+
+	c.stats.Stored++
+}
+
+// Evicts the entries unused for cacheMaxAge, then the least recently used
+// entries until the cache fits cacheMaxSize. Failing to evict an entry leaves
+// it in place.
+func (c *cache) trim() {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	type entry struct {
+		path string
+		size int64
+		used time.Time
+	}
+
+	var entries []entry
+	var size int64
+	for _, de := range dirEntries {
+		info, err := de.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		e := entry{filepath.Join(c.dir, de.Name()), info.Size(), info.ModTime()}
+		if time.Since(e.used) > cacheMaxAge {
+			_ = os.Remove(e.path)
+			continue
+		}
+		entries = append(entries, e)
+		size += e.size
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].used.Before(entries[j].used)
+	})
+	for _, e := range entries {
+		if size <= cacheMaxSize {
+			break
+		}
+		if os.Remove(e.path) == nil {
+			size -= e.size
+		}
+	}
+}
+
+// Computes the environment key of each source file from the summaries of all
+// files. Files are identified by path, `imports` is the number of leading files
+// which are imports.
+func (c *cache) depKeys(files []*srcFile, sums []summary, imports int) []string {
+	if c == nil {
+		return nil
+	}
+
+	byPath := make(map[string]int, len(files))
+	for i, f := range files {
+		byPath[f.Path] = i
+	}
+
+	// A new global anywhere may shadow a host global, or collide with an
+	// existing one.
+	var globals []string
+	for _, sum := range sums {
+		globals = append(globals, sum.Globals...)
+	}
+	sort.Strings(globals)
+
+	keys := make([]string, len(files))
+	for i, f := range files {
+		h := sha256.New()
+		_, _ = h.Write([]byte(c.decls))
+		_, _ = h.Write([]byte(strconv.FormatBool(i < imports)))
+		_, _ = h.Write([]byte(strings.Join(globals, ",")))
+
+		// Surfaces of all imported modules, in path order.
+		seen := make(map[string]bool)
+		transitive(f, byPath, files, seen)
+		paths := make([]string, 0, len(seen))
+		for path := range seen {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			_, _ = h.Write([]byte(path + "=" + sums[byPath[path]].Surface + "\n"))
+		}
+
+		keys[i] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return keys
+}
+
+// Collects the paths of all modules transitively imported by `f`.
+func transitive(f *srcFile, byPath map[string]int, files []*srcFile, seen map[string]bool) {
+	for _, imp := range f.Imports {
+		path := imp.Module
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if i, ok := byPath[path]; ok {
+			transitive(files[i], byPath, files, seen)
+		}
+	}
+}
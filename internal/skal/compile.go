@@ -1,143 +1,594 @@
 package skal
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/illbjorn/skal/internal/skal/decl"
 	"github.com/illbjorn/skal/internal/skal/emit"
 	"github.com/illbjorn/skal/internal/skal/exec"
+	"github.com/illbjorn/skal/internal/skal/exec/stdlib"
+	"github.com/illbjorn/skal/internal/skal/flow"
 	"github.com/illbjorn/skal/internal/skal/lex"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/minify"
+	"github.com/illbjorn/skal/internal/skal/opt"
 	"github.com/illbjorn/skal/internal/skal/parse"
+	"github.com/illbjorn/skal/internal/skal/resolve"
 	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/srcmap"
+	"github.com/illbjorn/skal/internal/skal/target"
 	"github.com/illbjorn/skal/internal/skal/typeset"
+	"github.com/illbjorn/skal/internal/skal/vfs"
 	"github.com/illbjorn/skal/pkg/formatter"
 )
 
-func Compile(inputPath, outputPath string) {
-	// Entrypoint I/O
-	// Read the 'main' File.
-	b, err := os.ReadFile(inputPath)
+// Compile compiles the Skal source file at `inputPath` (and its imports),
+// writing the Lua output to `outputPath` and its source map to
+// `outputPath`.map. If any errors are reported, nothing is written and an error
+// listing every diagnostic is returned.
+//
+// If opts.Split is set, the entrypoint is written to `outputPath` and each
+// imported module to its own file alongside it (see BuildModules). If
+// opts.DumpAST is set, the parse tree of the entrypoint is written to
+// `outputPath`.ast.json.
+func Compile(inputPath, outputPath string, opts Options) error {
+	if opts.Split {
+		mods, err := BuildModules(context.Background(), inputPath, opts)
+		if err != nil {
+			return err
+		}
+
+		dir := filepath.Dir(outputPath)
+		for i, mod := range mods {
+			path := filepath.Join(dir, filepath.FromSlash(mod.Path))
+			if i == len(mods)-1 {
+				path = outputPath
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("failed to create output directory '%s': %w", filepath.Dir(path), err)
+			}
+			if err := writeOutput(path, mod.Lua, mod.SourceMap); err != nil {
+				return err
+			}
+		}
+
+		return dumpAST(inputPath, outputPath, opts)
+	}
+
+	compiled, m, err := Build(context.Background(), inputPath, opts)
 	if err != nil {
-		sklog.CFatalF(
-			"Failed to read input File: '{path}', with error: {err}.",
-			"path", inputPath,
-			"err", err.Error(),
-		)
-	}
-
-	// Open a writable stream to the output File.
-	outFile, err := os.OpenFile(
-		outputPath,
-		os.O_CREATE|os.O_TRUNC|os.O_WRONLY,
-		0600)
+		return err
+	}
+	if err := writeOutput(outputPath, compiled, m); err != nil {
+		return err
+	}
+
+	return dumpAST(inputPath, outputPath, opts)
+}
+
+// Writes the parse tree of the entrypoint alongside output `outputPath`, if
+// requested.
+func dumpAST(inputPath, outputPath string, opts Options) error {
+	if !opts.DumpAST {
+		return nil
+	}
+
+	return DumpAST(inputPath, outputPath+".ast.json", opts)
+}
+
+// Writes Lua output `compiled` to `outputPath`, and its source map `m` to
+// `outputPath`.map.
+func writeOutput(outputPath string, compiled []byte, m *srcmap.Map) error {
+	// Write the output File.
+	if err := os.WriteFile(outputPath, compiled, 0600); err != nil {
+		return fmt.Errorf("failed to write output file '%s': %w", outputPath, err)
+	}
+
+	// Write the source map.
+	m.File = filepath.Base(outputPath)
+	b, err := m.JSON()
+	if err == nil {
+		err = os.WriteFile(outputPath+".map", b, 0600)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write source map '%s': %w", outputPath+".map", err)
+	}
+
+	return nil
+}
+
+// Build compiles the Skal source file at `inputPath` (and its imports),
+// returning the Lua output and its source map. If any errors are reported, an
+// error listing every diagnostic is returned.
+func Build(ctx context.Context, inputPath string, opts Options) ([]byte, *srcmap.Map, error) {
+	return build(ctx, inputPath, opts, false)
+}
+
+// Run compiles the Skal source file at `inputPath` (and its imports) and
+// executes the result. If any errors are reported, nothing is executed and an
+// error listing every diagnostic is returned.
+//
+// Runtime errors are returned as an *exec.Error, located in the Skal source.
+func Run(ctx context.Context, inputPath string, opts Options) error {
+	compiled, m, err := build(ctx, inputPath, opts, true)
 	if err != nil {
-		sklog.CFatalF(
-			"Failed to open writable stream to output File with error: {err}.",
-			"err", err.Error(),
-		)
+		return err
+	}
+
+	err = exec.Run(ctx, string(compiled), m, opts.stdout(), opts.Libs)
+
+	// Quote the source line the error was raised from.
+	rerr := new(exec.Error)
+	if errors.As(err, &rerr) && rerr.Diagnostic.File != "" {
+		rerr.Diagnostic.Src = srcLine(vfs.New(opts.FS), rerr.Diagnostic.File, rerr.Diagnostic.Line)
+	}
+
+	return err
+}
+
+func build(ctx context.Context, inputPath string, opts Options, runtime bool) ([]byte, *srcmap.Map, error) {
+	j, units, err := buildUnits(ctx, inputPath, opts, runtime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		compiled []byte
+		m        *srcmap.Map
+	)
+	j.Perf.measure(PhaseBundle, "", func() {
+		compiled, m = bundle(j, units)
+		if j.Minify {
+			compiled = minify.Minify(compiled, m, j.Diags)
+		}
+	})
+	if err := j.Diags.Err(); err != nil {
+		return nil, nil, err
 	}
-	defer func(outFile *os.File) {
-		if err := outFile.Close(); err != nil {
-			println("ERROR: Failed to close output file", outFile.Name()+".")
-			println("Inner error:", err.Error()+".")
+
+	return compiled, m, nil
+}
+
+// Compiles the Skal source file at `inputPath` (and its imports), producing the
+// output of each module.
+func buildUnits(ctx context.Context, inputPath string, opts Options, runtime bool) (*job, []unit, error) {
+	diags := opts.diagnostics()
+
+	// The runtime is Lua 5.1, whatever the target.
+	t := target.Lua51
+	if opts.Target != "" && !runtime {
+		var ok bool
+		if t, ok = target.Lookup(opts.Target); !ok {
+			return nil, nil, fmt.Errorf(
+				"unknown target '%s', expected one of: %s",
+				opts.Target, strings.Join(target.Names(), ", "))
 		}
-	}(outFile)
+	}
 
-	// Init the job.
-	j := &job{
-		Main: &srcFile{
-			Path:    inputPath,
-			Content: string(b),
-		},
-		OutputPath: outputPath,
+	// Assemble the job.
+	j := newJob(inputPath, opts, diags)
+	j.Target = t
+	j.Split = opts.Split && !runtime
+	j.Lib = opts.Lib && !runtime
+	j.Optimize = opts.Optimize
+	j.Minify = opts.Minify
+	j.Comments = opts.Comments
+
+	// The runtime libraries are available to executed scripts.
+	if runtime {
+		j.Decls.Merge(stdlib.Decls)
 	}
+	j.Cache = openCache(opts.CacheDir, j, opts.CacheStats)
 
-	// Assemble all imported modules.
-	j = getImports(j)
+	// Compile!
+	units := compileJob(ctx, j)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if err := diags.Err(); err != nil {
+		return nil, nil, err
+	}
 
-	// Write the basic env header.
-	_, _ = outFile.Write(tmplHeader)
+	return j, units, nil
+}
 
-	// Init the formatter we'll use to produce the formatted output.
-	// We'll reset this underlying buffer between files to avoid some unnecessary
-	// reallocation.
-	emt := formatter.NewFormatter()
+// Reads line `line` of source file `path`, if it can be read.
+func srcLine(fsys vfs.FS, path string, line int) string {
+	b, err := fsys.ReadFile(path)
+	if err != nil {
+		return ""
+	}
 
-	// Process source files.
-	for inFile := range j.Gen() {
-		_, _ = outFile.Write(compileFile(inFile, emt))
+	lines := strings.Split(string(b), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
 	}
 
-	// Write the basic env footer.
-	_, _ = outFile.Write(tmplFooter)
+	return strings.TrimRight(lines[line-1], "\r")
 }
 
-func CompileAndRun(inputPath string) {
+// Reads the entrypoint and assembles all imported modules.
+func newJob(inputPath string, opts Options, diags *sklog.Diagnostics) *job {
+	j := &job{
+		Main:  &srcFile{Path: inputPath},
+		Decls: opts.decls(diags),
+		Diags: diags,
+		FS:    vfs.New(opts.FS),
+		Roots: append([]string{filepath.Dir(inputPath)}, opts.Paths...),
+		Perf:  opts.Perf,
+	}
+
 	// Entrypoint I/O
 	// Read the 'main' File.
-	b, err := os.ReadFile(inputPath)
+	var (
+		b   []byte
+		err error
+	)
+	j.Perf.measure(PhaseImports, inputPath, func() {
+		b, err = j.FS.ReadFile(inputPath)
+	})
 	if err != nil {
-		sklog.CFatalF(
-			"Failed to read input File: '{path}', with error: {err}.",
-			"path", inputPath,
-			"err", err.Error(),
-		)
+		sklog.NewCompilerEvent(sklog.MsgTypeCompilerError, sklog.LevelFatal).
+			To(diags).
+			WithCode(sklog.CodeReadFailed).
+			AddF(
+				"Failed to read input File: '{path}', with error: {err}.",
+				"path", inputPath,
+				"err", err.Error(),
+			).
+			Send()
+		return j
 	}
+	j.Main.Content = string(b)
 
-	// Init the job.
-	j := &job{
-		Main: &srcFile{
-			Path:    inputPath,
-			Content: string(b),
-		},
+	// Assemble all imported modules.
+	return getImports(j)
+}
+
+// The compiled output of a single source file.
+type unit struct {
+	File     *srcFile
+	Lua      []byte
+	Mappings []srcmap.Mapping
+	// Locals of the module standing in for Skal names (see `opt.Cached`).
+	Names []srcmap.Name
+	// Names of the globals the module declares (see `summary`).
+	Globals []string
+	// Names of the `pub` symbols the module declares.
+	Pubs []string
+}
+
+// Compiles all source files of a job, producing the output of each in
+// dependency order.
+// (Lex -> Parse -> Typeset) -> Resolve -> Flow -> [Optimize] -> Emit
+//
+// Each phase runs over every source file, reporting as many problems as it can
+// find. Later phases are skipped once errors have been reported, or if `ctx` is
+// canceled.
+//
+// Every phase but resolve (which links modules together) runs concurrently
+// across modules. Diagnostics and output are reassembled in dependency order,
+// so the result doesn't depend on scheduling.
+//
+// If the job has a cache, modules whose source and environment are unchanged
+// since they were cached only take part in resolve (through their summary),
+// their cached output is used as is.
+func compileJob(ctx context.Context, j *job) []unit {
+	files := j.Files()
+	imports := len(j.Imports)
+	entries := j.Cache.load(files)
+
+	// Typeset all source files without a cache entry.
+	mods := make([]*resolve.Module, len(files))
+	each(ctx, j.Perf.workers(), len(files), j.Diags, func(i int, diags *sklog.Diagnostics) {
+		if entries[i] == nil {
+			j.Perf.measure(PhaseTypeset, files[i].Path, func() {
+				mods[i] = newModule(files[i], typesetFile(files[i], j.Decls, diags))
+			})
+		}
+	})
+	if j.Diags.Errors() > 0 || ctx.Err() != nil {
+		return nil
 	}
 
-	// Assemble all imported modules.
-	j = getImports(j)
+	// Reuse the cache entries whose environment is unchanged, typesetting their
+	// summary. Stale entries are typeset from source. Split modules and
+	// libraries export the names of their summary.
+	sums := make([]summary, len(files))
+	if j.Cache != nil || j.Split || j.Lib {
+		for i := range files {
+			if entries[i] != nil {
+				sums[i] = entries[i].Summary
+			} else {
+				sums[i] = summarize(mods[i].Set)
+			}
+		}
+	}
+	keys := j.Cache.depKeys(files, sums, imports)
+	cached := make([]bool, len(files))
+	each(ctx, j.Perf.workers(), len(files), j.Diags, func(i int, diags *sklog.Diagnostics) {
+		// Files without an entry were typeset (and measured) above.
+		if entries[i] == nil {
+			return
+		}
+		file := files[i]
+		if entries[i].DepKey == keys[i] {
+			cached[i] = true
+			j.Perf.cached(files[i].Path)
+			file = &srcFile{Path: files[i].Path, Content: entries[i].Summary.Src}
+		}
+		j.Perf.measure(PhaseTypeset, files[i].Path, func() {
+			mods[i] = newModule(files[i], typesetFile(file, j.Decls, diags))
+		})
+	})
+	if j.Diags.Errors() > 0 || ctx.Err() != nil {
+		return nil
+	}
 
-	// Write the basic env header.
-	compiled := tmplHeader
+	// Resolve names across all modules, then check the control flow of each.
+	// Warnings of cached modules were reported against their summary, they're
+	// replaced by the warnings cached with them.
+	resolved := sklog.NewDiagnostics()
+	j.Perf.measure(PhaseResolve, "", func() {
+		resolve.Resolve(j.Decls, resolved, mods...)
+	})
+	for _, d := range resolved.List() {
+		if d.IsError() || !cachedPath(files, cached, d.File) {
+			j.Diags.Add(d)
+		}
+	}
+	each(ctx, j.Perf.workers(), len(mods), j.Diags, func(i int, diags *sklog.Diagnostics) {
+		if !cached[i] {
+			j.Perf.measure(PhaseFlow, mods[i].Path, func() {
+				flow.Check(mods[i].Set, diags)
+			})
+			return
+		}
+		for _, d := range entries[i].Diagnostics {
+			diags.Add(d)
+		}
+	})
+	if j.Diags.Errors() > 0 || ctx.Err() != nil {
+		return nil
+	}
 
-	// Init the formatter we'll use to produce the formatted output.
-	// We'll reset this underlying buffer between files to avoid some unnecessary
-	// reallocation.
-	emt := formatter.NewFormatter()
+	// Optimize source files, recording the host fns cached in locals.
+	cachedFns := make([]map[string]string, len(mods))
+	if j.Optimize {
+		each(ctx, j.Perf.workers(), len(mods), j.Diags, func(i int, _ *sklog.Diagnostics) {
+			if !cached[i] {
+				j.Perf.measure(PhaseOptimize, mods[i].Path, func() {
+					mods[i].Set = opt.Optimize(mods[i].Set)
+				})
+				cachedFns[i] = opt.Cached(mods[i].Set)
+			}
+		})
+	}
 
-	// Process source files.
-	for inFile := range j.Gen() {
-		compiled = append(compiled, compileFile(inFile, emt)...)
+	// Emit source files.
+	emitted := make([][]byte, len(mods))
+	mappings := make([][]srcmap.Mapping, len(mods))
+	names := make([][]srcmap.Name, len(mods))
+	each(ctx, j.Perf.workers(), len(mods), j.Diags, func(i int, diags *sklog.Diagnostics) {
+		if cached[i] {
+			emitted[i], mappings[i], names[i] = entries[i].Lua, entries[i].Mappings, entries[i].Names
+			return
+		}
+		j.Perf.measure(PhaseEmit, mods[i].Path, func() {
+			emitted[i], mappings[i] = emit.Emit(mods[i].Set, formatter.NewFormatter(), emit.Options{
+				Path:        mods[i].Path,
+				Import:      i < imports && !j.Split,
+				Split:       j.Split,
+				Target:      j.Target,
+				Comments:    j.Comments,
+				Diagnostics: diags,
+			})
+		})
+		names[i] = localNames(cachedFns[i], emitted[i])
+	})
+	if j.Diags.Errors() > 0 || ctx.Err() != nil {
+		return nil
 	}
 
-	// Write the basic env footer.
-	compiled = append(compiled, tmplFooter...)
+	// Cache the modules compiled.
+	if j.Cache != nil {
+		for i, f := range files {
+			if cached[i] {
+				j.Cache.stats.Hits++
+				continue
+			}
+			j.Cache.stats.Misses++
+			j.Cache.store(f, &cacheEntry{
+				Summary:     sums[i],
+				DepKey:      keys[i],
+				Lua:         emitted[i],
+				Mappings:    mappings[i],
+				Names:       names[i],
+				Diagnostics: warnings(j.Diags, f.Path),
+			})
+		}
+		if j.Cache.stats.Stored > 0 {
+			j.Cache.trim()
+		}
+	}
 
-	exec.Exec(string(compiled))
+	units := make([]unit, len(files))
+	for i, f := range files {
+		units[i] = unit{File: f, Lua: emitted[i], Mappings: mappings[i], Names: names[i],
+			Globals: sums[i].Globals, Pubs: sums[i].Pubs}
+	}
+
+	return units
+}
+
+// Produces the names of the locals `locals` (by their Skal name) of a module,
+// visible to all of its Lua `lua`.
+func localNames(locals map[string]string, lua []byte) []srcmap.Name {
+	out := make([]srcmap.Name, 0, len(locals))
+	lines := bytes.Count(lua, []byte("\n")) + 1
+	for name, src := range locals {
+		out = append(out, srcmap.Name{Line: 1, EndLine: lines, Name: name, SrcName: src})
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Name < out[b].Name })
+
+	return out
 }
 
-// Compile a single provided File.
-// Lex -> Parse -> Typeset -> Emit
-func compileFile(inFile *srcFile, emt *formatter.Formatter) []byte {
+// Bundles the output of all modules into a single Lua file, producing the file
+// and its source map.
+func bundle(j *job, units []unit) ([]byte, *srcmap.Map) {
+	// Write the basic env header, the modules, then the basic env footer.
+	header, footer := tmplHeader, tmplFooter
+	switch {
+	case j.Lib && j.Target.Setfenv:
+		header, footer = tmplLibHeader, tmplLibFooter
+	case j.Lib:
+		header, footer = tmplLibHeaderEnv, tmplLibFooterEnv
+	case !j.Target.Setfenv && j.Minify:
+		header, footer = tmplHeaderMinEnv, tmplFooterEnv
+	case !j.Target.Setfenv:
+		header, footer = tmplHeaderEnv, tmplFooterEnv
+	case j.Minify:
+		header = tmplHeaderMin
+	}
+	compiled := append([]byte{}, header...)
+	m := srcmap.New()
+	for _, u := range units {
+		offset := bytes.Count(compiled, []byte("\n"))
+		m.Add(offset, bytes.Count(u.Lua, []byte("\n"))+1, u.Mappings)
+		m.AddNames(offset, u.Names)
+		compiled = append(compiled, u.Lua...)
+	}
+	compiled = append(compiled, footer...)
+
+	// Libraries export the `pub` symbols of the entrypoint.
+	if j.Lib {
+		compiled = append(compiled, exports(units[len(units)-1].Pubs)...)
+	}
+
+	return compiled, m
+}
+
+// Produces the statement returning the table of exported globals `names` from
+// the module environment.
+func exports(names []string) string {
+	out := "\n-- Export the module's symbols.\nreturn {"
+	for _, name := range names {
+		out += "\n  " + name + " = __ENV__." + name + ","
+	}
+	if len(names) > 0 {
+		out += "\n"
+	}
+
+	return out + "}\n"
+}
+
+func newModule(f *srcFile, set typeset.TypeSet) *resolve.Module {
+	return &resolve.Module{
+		Path:    f.Path,
+		Imports: f.Imports,
+		Set:     set,
+	}
+}
+
+// Indicates whether `path` is that of a cached module.
+func cachedPath(files []*srcFile, cached []bool, path string) bool {
+	for i, f := range files {
+		if f.Path == path {
+			return cached[i]
+		}
+	}
+
+	return false
+}
+
+// Lists the warnings reported for the source file at `path`.
+func warnings(diags *sklog.Diagnostics, path string) []sklog.Diagnostic {
+	var out []sklog.Diagnostic
+	for _, d := range diags.List() {
+		if d.File == path && !d.IsError() {
+			out = append(out, d)
+		}
+	}
+
+	return out
+}
+
+// Runs `fn` for each of `n` modules across a pool of `workers`. Each call
+// reports to its own collector, which are merged into `diags` in module order
+// once all calls return. Remaining modules are skipped if `ctx` is canceled.
+func each(ctx context.Context, workers, n int, diags *sklog.Diagnostics, fn func(i int, diags *sklog.Diagnostics)) {
+	collected := make([]*sklog.Diagnostics, n)
+	for i := range collected {
+		collected[i] = sklog.NewDiagnostics()
+	}
+
+	work := make(chan int)
+	wg := new(sync.WaitGroup)
+	for range min(n, workers) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if ctx.Err() == nil {
+					call(i, collected[i], fn)
+				}
+			}
+		}()
+	}
+
+	for i := range n {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	diags.Merge(collected...)
+}
+
+// Calls `fn`, recording a bailout rather than letting it crash the worker.
+func call(i int, diags *sklog.Diagnostics, fn func(i int, diags *sklog.Diagnostics)) {
+	defer func() { diags.Catch(recover()) }()
+	fn(i, diags)
+}
+
+// Typesets a single provided File.
+// Lex -> Parse -> Typeset
+func typesetFile(inFile *srcFile, decls *decl.Set, diags *sklog.Diagnostics) typeset.TypeSet {
 	// Ignore empty files.
 	if len(inFile.Content) == 0 {
-		return nil
+		return typeset.NewTypeSet()
 	}
 
-	// Reset buffers.
-	defer emt.Reset()
+	// Files are parsed collecting imports, summaries are not.
+	tree := inFile.Tree
+	if tree == nil {
+		// Lex
+		tokenCollection := lex.Lex(inFile.Path, string(inFile.Content), diags)
 
-	// Lex
-	tokenCollection := lex.Lex(inFile.Path, string(inFile.Content))
-
-	// Parse
-	tree := parse.Parse(tokenCollection, nil, nil)
+		// Parse
+		tree = parse.Parse(tokenCollection)
+	}
 
 	// Typeset
-	set := typeset.Typeset(tree)
+	set := typeset.Typeset(tree, decls, diags)
 
-	// Emit
-	compiled := emit.Emit(set, inFile.Path, inFile.Import, emt)
+	// The imports collected lead the module.
+	imports := typeset.NewTypeSet()
+	for _, imp := range inFile.Imports {
+		imports.Add(imp, imp.Alias, token.Import)
+	}
+	set.Members = append(imports.Members, set.Members...)
 
-	// Write the compiled code.
-	return compiled
+	return set
 }
 
 var (
@@ -146,8 +597,6 @@ var (
 local __ENV__ = { __index = _G }
 -- Set the metatable.
 setmetatable(__ENV__, __ENV__)
--- DEBUG: Export the app environment table.
-_G._ENV_ = __ENV__
 -- Open the app function.
 local function __LOAD__()`)
 
@@ -159,4 +608,50 @@ end
 setfenv(__LOAD__, __ENV__)
 -- Launch the application.
 __LOAD__()`)
+
+	// The header and footer of targets without `setfenv` (Lua 5.2+), where the
+	// app function receives its environment as its `_ENV` upvalue.
+	tmplHeaderEnv = []byte(`-- Create the app environment.
+local __ENV__ = { __index = _G }
+-- Set the metatable.
+setmetatable(__ENV__, __ENV__)
+-- Open the app function.
+local function __LOAD__(_ENV)`)
+
+	tmplFooterEnv = []byte(`
+end
+-- Launch the application, within the app environment table.
+__LOAD__(__ENV__)`)
+
+	// The headers of minified apps, which create the app environment in a
+	// single statement.
+	tmplHeaderMin = []byte(`local __ENV__ = setmetatable({}, { __index = _G })
+local function __LOAD__()`)
+
+	tmplHeaderMinEnv = []byte(`local __ENV__ = setmetatable({}, { __index = _G })
+local function __LOAD__(_ENV)`)
+
+	// The header and footer of libraries. The library runs within an
+	// environment of its own, leaving the global environment untouched.
+	tmplLibHeader = []byte(`-- Create the library environment.
+local __ENV__ = setmetatable({}, { __index = _G })
+-- Open the library function.
+local function __LOAD__()`)
+
+	tmplLibFooter = []byte(`
+end
+-- Set the library function's environment to the library environment table.
+setfenv(__LOAD__, __ENV__)
+-- Load the library.
+__LOAD__()`)
+
+	tmplLibHeaderEnv = []byte(`-- Create the library environment.
+local __ENV__ = setmetatable({}, { __index = _G })
+-- Open the library function.
+local function __LOAD__(_ENV)`)
+
+	tmplLibFooterEnv = []byte(`
+end
+-- Load the library, within the library environment table.
+__LOAD__(__ENV__)`)
 )
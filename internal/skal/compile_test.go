@@ -0,0 +1,61 @@
+package skal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Builds `testdata/lib` as a library, comparing the output to
+// `testdata/lib/main.lua`.
+func TestLib(t *testing.T) {
+	dir := filepath.Join("testdata", "lib")
+	got, _, err := Build(context.Background(), "main.sk", Options{FS: os.DirFS(dir), Lib: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "main.lua"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Only the pub symbols of the entry file are exported, not those of its
+	// imports or its private globals.
+	l := lua.NewState()
+	defer l.Close()
+	if err := l.DoString(string(got)); err != nil {
+		t.Fatal(err)
+	}
+	exported, ok := l.Get(-1).(*lua.LTable)
+	if !ok {
+		t.Fatalf("returned %s, want a table", l.Get(-1).Type())
+	}
+	var names []string
+	exported.ForEach(func(k, v lua.LValue) {
+		if v != lua.LNil {
+			names = append(names, k.String())
+		}
+	})
+	sort.Strings(names)
+	if want := []string{"Level", "Meter", "make", "version"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("exported %v, want %v", names, want)
+	}
+
+	// Exported fns run in the library's environment, which doesn't leak into
+	// the host's globals.
+	l.SetGlobal("lib", exported)
+	if err := l.DoString(`local m = lib.make(); m:set(9); return m.value, clamp, count`); err != nil {
+		t.Fatal(err)
+	}
+	if value, clamp, count := l.Get(-3), l.Get(-2), l.Get(-1); value != lua.LNumber(2) || clamp != lua.LNil || count != lua.LNil {
+		t.Errorf("got value %s, clamp %s, count %s, want 2 and nil globals", value, clamp, count)
+	}
+}
@@ -0,0 +1,234 @@
+package decl
+
+import (
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+)
+
+// Kind describes what a declared extern symbol is.
+type Kind uint8
+
+const (
+	KindFn    Kind = iota + 1 // A host Lua function.
+	KindTable                 // A host Lua table (e.g. `string`, `math`).
+	KindValue                 // A host Lua non-callable value (e.g. `math.pi`).
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindFn:
+		return "fn"
+	case KindTable:
+		return "table"
+	case KindValue:
+		return "value"
+	default:
+		return ""
+	}
+}
+
+// Symbol is a single declared extern: a fn, a table or a plain value.
+type Symbol struct {
+	Token   token.Token `json:"-"`
+	Parent  *Symbol     `json:"-"`
+	Name    string      `json:"name"`
+	Type    string      `json:"type,omitempty"` // Value type, or fn return type.
+	File    string      `json:"file,omitempty"`
+	Params  []*Param    `json:"params,omitempty"`
+	Members []*Symbol   `json:"members,omitempty"`
+	Kind    Kind        `json:"kind"`
+}
+
+// Param is a single declared extern fn parameter.
+type Param struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Vararg   bool   `json:"vararg,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// Path produces the fully qualified, dot-delimited name of the symbol (e.g.
+// `string.format`).
+func (s *Symbol) Path() string {
+	if s.Parent == nil {
+		return s.Name
+	}
+
+	return s.Parent.Path() + "." + s.Name
+}
+
+// Member looks up a direct member of a table symbol by name.
+func (s *Symbol) Member(name string) *Symbol {
+	for _, m := range s.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// Signature produces a human readable rendering of the symbol, suitable for
+// diagnostics and hover info.
+//
+// Examples:
+//
+//	fn string.format(fmt: str, ...args) str
+//	fn string.sub(s: str, i: int, [j: int]) str
+//	math.pi: number
+//	table math
+func (s *Symbol) Signature() string {
+	switch s.Kind {
+	case KindFn:
+		out := new(strings.Builder)
+		out.WriteString("fn " + s.Path() + "(")
+		for i, p := range s.Params {
+			if p.Optional {
+				out.WriteString("[")
+			}
+			if p.Vararg {
+				out.WriteString("...")
+			}
+			out.WriteString(p.Name)
+			if p.Type != "" {
+				out.WriteString(": " + p.Type)
+			}
+			if p.Optional {
+				out.WriteString("]")
+			}
+			if i < len(s.Params)-1 {
+				out.WriteString(", ")
+			}
+		}
+		out.WriteString(")")
+		if s.Type != "" {
+			out.WriteString(" " + s.Type)
+		}
+		return out.String()
+
+	case KindTable:
+		return "table " + s.Path()
+
+	default:
+		if s.Type == "" {
+			return s.Path()
+		}
+		return s.Path() + ": " + s.Type
+	}
+}
+
+// NewSet produces an empty declaration Set.
+func NewSet() *Set {
+	return &Set{}
+}
+
+// Set is a collection of top-level declared extern symbols, typically merged
+// from one or more declaration (`.skd`) files.
+type Set struct {
+	Symbols []*Symbol
+}
+
+// Add adds top-level symbols to the set. Tables declared more than once (for
+// example across multiple declaration files) are merged.
+func (set *Set) Add(syms ...*Symbol) *Set {
+	for _, sym := range syms {
+		i := set.index(sym.Name)
+		if i < 0 || set.Symbols[i].Kind != KindTable || sym.Kind != KindTable {
+			set.Symbols = append(set.Symbols, sym)
+			continue
+		}
+
+		// Merge into a copy of the existing table, sets may share symbols (such as
+		// the bundled standard library) and we don't want to mutate those.
+		merged := *set.Symbols[i]
+		merged.Members = append(
+			append([]*Symbol{}, merged.Members...),
+			sym.Members...)
+		set.Symbols[i] = &merged
+	}
+
+	return set
+}
+
+// Merge adds all symbols of the provided sets to this set.
+func (set *Set) Merge(sets ...*Set) *Set {
+	for _, s := range sets {
+		if s == nil {
+			continue
+		}
+		set.Add(s.Symbols...)
+	}
+
+	return set
+}
+
+// Get retrieves a top-level symbol by name.
+func (set *Set) Get(name string) *Symbol {
+	if set == nil {
+		return nil
+	}
+
+	if i := set.index(name); i >= 0 {
+		return set.Symbols[i]
+	}
+
+	return nil
+}
+
+func (set *Set) index(name string) int {
+	for i, sym := range set.Symbols {
+		if sym.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Lookup resolves a symbol by its reference path (e.g. `string`, `format`).
+//
+// If the path can't be fully resolved, the deepest symbol found is returned
+// along with `false`.
+func (set *Set) Lookup(path ...string) (*Symbol, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+
+	sym := set.Get(path[0])
+	if sym == nil {
+		return nil, false
+	}
+
+	for _, name := range path[1:] {
+		member := sym.Member(name)
+		if member == nil {
+			return sym, false
+		}
+		sym = member
+	}
+
+	return sym, true
+}
+
+// Fns produces the names of all top-level declared fns.
+func (set *Set) Fns() []string {
+	var out []string
+	for _, sym := range set.Symbols {
+		if sym.Kind == KindFn {
+			out = append(out, sym.Name)
+		}
+	}
+
+	return out
+}
+
+// Names produces the names of all top-level declared symbols.
+func (set *Set) Names() []string {
+	out := make([]string, 0, len(set.Symbols))
+	for _, sym := range set.Symbols {
+		out = append(out, sym.Name)
+	}
+
+	return out
+}
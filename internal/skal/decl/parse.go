@@ -0,0 +1,224 @@
+package decl
+
+import (
+	"path/filepath"
+
+	"github.com/illbjorn/skal/internal/skal/lex"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/vfs"
+)
+
+// Ext is the file extension of Skal declaration files.
+const Ext = ".skd"
+
+// Load reads and parses a declaration file from `fsys`. If `path` points to a
+// directory, all declaration files directly inside it are loaded. Problems are
+// reported to `diags`.
+func Load(fsys vfs.FS, path string, diags *sklog.Diagnostics) *Set {
+	stat, err := fsys.Stat(path)
+	if err != nil {
+		loadError(diags, "Failed to read declaration path: '{path}', with error: {err}.", path, err)
+		return NewSet()
+	}
+
+	// File
+	if !stat.IsDir() {
+		b, err := fsys.ReadFile(path)
+		if err != nil {
+			loadError(diags, "Failed to read declaration file: '{path}', with error: {err}.", path, err)
+			return NewSet()
+		}
+
+		return Parse(path, string(b), diags)
+	}
+
+	// Directory
+	entries, err := fsys.ReadDir(path)
+	if err != nil {
+		loadError(diags, "Failed to read declaration directory: '{path}', with error: {err}.", path, err)
+		return NewSet()
+	}
+
+	set := NewSet()
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != Ext {
+			continue
+		}
+		set.Merge(Load(fsys, filepath.Join(path, entry.Name()), diags))
+	}
+
+	return set
+}
+
+// Parse parses the source text of a declaration file.
+//
+// Declaration files use a subset of the Skal syntax:
+//
+//	# A global fn.
+//	fn tostring(v) str
+//
+//	# A global table, containing fns and values. Optional params are
+//	# bracketed.
+//	extern math {
+//	  pi: number
+//	  fn random([m: int], [n: int]) number
+//	}
+//
+// Problems are reported to `diags`, parsing stops at the first error.
+func Parse(path, src string, diags *sklog.Diagnostics) *Set {
+	tc := lex.Lex(path, src, diags)
+	set := NewSet()
+	defer func() { diags.Catch(recover()) }()
+
+	for !tc.NTT(token.EOF) {
+		set.Add(parseDecl(tc, nil))
+	}
+
+	return set
+}
+
+func loadError(diags *sklog.Diagnostics, msg, path string, err error) {
+	sklog.NewCompilerEvent(sklog.MsgTypeCompilerError, sklog.LevelFatal).
+		To(diags).
+		WithCode(sklog.CodeDeclFailed).
+		AddF(msg, "path", path, "err", err.Error()).
+		Send()
+}
+
+func parseDecl(tc *token.Collection, parent *Symbol) *Symbol {
+	switch tc.LA().Type() {
+	// 'fn'
+	case token.Fn:
+		return parseFnDecl(tc, parent)
+
+	// 'extern'
+	case token.Extern:
+		return parseTableDecl(tc, parent)
+
+	// ID
+	default:
+		return parseValueDecl(tc, parent)
+	}
+}
+
+func parseFnDecl(tc *token.Collection, parent *Symbol) *Symbol {
+	// 'fn'
+	tc.AdvT(token.Fn)
+
+	// ID
+	id := tc.AdvT(token.ID)
+	fn := &Symbol{
+		Token:  id,
+		Parent: parent,
+		Name:   id.Value(),
+		File:   id.File(),
+		Kind:   KindFn,
+	}
+
+	// '('
+	tc.AdvT(token.ParenOpen)
+
+	// Params
+	for !tc.NTT(token.ParenClose) {
+		param := new(Param)
+
+		// OPTIONAL: '['
+		if _, ok := tc.AdvIf(token.BrackOpen); ok {
+			param.Optional = true
+		}
+
+		// OPTIONAL: '...'
+		if _, ok := tc.AdvIf(token.Spread); ok {
+			param.Vararg = true
+		}
+
+		// ID
+		param.Name = tc.AdvT(token.ID).Value()
+
+		// OPTIONAL: Type hint.
+		if _, ok := tc.AdvIf(token.Colon); ok {
+			param.Type = parseTypeHint(tc)
+		}
+
+		// ']'
+		if param.Optional {
+			tc.AdvT(token.BrackClose)
+		}
+
+		fn.Params = append(fn.Params, param)
+
+		// OPTIONAL: ','
+		if _, ok := tc.AdvIf(token.Comma); !ok {
+			break
+		}
+	}
+
+	// ')'
+	tc.AdvT(token.ParenClose)
+
+	// OPTIONAL: Return type hint.
+	// The return type must appear on the same line as the fn declaration,
+	// otherwise we'd consume the name of a following value declaration.
+	if tc.NTT(token.Str, token.Int, token.Bool, token.Fn, token.ID) &&
+		tc.LA().LineStart() == id.LineStart() {
+		fn.Type = parseTypeHint(tc)
+	}
+
+	return fn
+}
+
+func parseTableDecl(tc *token.Collection, parent *Symbol) *Symbol {
+	// 'extern'
+	tc.AdvT(token.Extern)
+
+	// ID
+	id := tc.AdvT(token.ID)
+	table := &Symbol{
+		Token:  id,
+		Parent: parent,
+		Name:   id.Value(),
+		File:   id.File(),
+		Kind:   KindTable,
+	}
+
+	// '{'
+	tc.AdvT(token.BraceOpen)
+
+	// Members
+	for !tc.NTT(token.BraceClose) {
+		table.Members = append(table.Members, parseDecl(tc, table))
+	}
+
+	// '}'
+	tc.AdvT(token.BraceClose)
+
+	return table
+}
+
+func parseValueDecl(tc *token.Collection, parent *Symbol) *Symbol {
+	// ID
+	id := tc.AdvT(token.ID)
+
+	// ':'
+	tc.AdvT(token.Colon)
+
+	return &Symbol{
+		Token:  id,
+		Parent: parent,
+		Name:   id.Value(),
+		File:   id.File(),
+		Type:   parseTypeHint(tc),
+		Kind:   KindValue,
+	}
+}
+
+func parseTypeHint(tc *token.Collection) string {
+	return tc.AdvOneOfT(
+		token.ID,
+		token.Str,
+		token.Int,
+		token.Bool,
+		token.Fn,
+	).Value()
+}
@@ -0,0 +1,244 @@
+package emit
+
+import (
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+	"github.com/illbjorn/skal/pkg/formatter"
+)
+
+/*------------------------------------------------------------------------------
+ * Comments
+ *----------------------------------------------------------------------------*/
+
+// Produces the comments leading the line of statement `v` (with token `tk`),
+// each on a line of its own, and notes the comment ending the line to follow
+// the first line of the statement (marked `n`). The comments of a line are
+// emitted by its first statement.
+//
+// The comments directly above a `pub` fn or struct are its doc comment, which
+// is emitted as a LuaLS annotation block:
+//
+//	# Deals damage to a unit.         ---Deals damage to a unit.
+//	pub fn hit(u, n: int) {       ->  ---@param u any
+//	                                  ---@param n integer
+//	                                  function hit(u, n)
+func (e *emitter) comments(v any, tk token.Token, n int) string {
+	if !e.keepComments {
+		return ""
+	}
+	tr, skipped := e.line(tk)
+	if tr == nil {
+		return skipped
+	}
+	e.emitted(tr)
+
+	if tr.Trailing != nil {
+		e.notes[n] = comment("--", tr.Trailing.Text)
+	}
+
+	// Doc comment
+	doc := len(tr.Leading)
+	annotations := annotate(v)
+	if annotations != nil {
+		for line := tr.Line - 1; doc > 0 && tr.Leading[doc-1].Line == line; line-- {
+			doc--
+		}
+	}
+
+	f := formatter.NewFormatter()
+	f.Str(skipped)
+	for i, c := range tr.Leading {
+		prefix := "--"
+		if i >= doc {
+			prefix = "---"
+		}
+		f.Str(e.stack.Indent()).Str(comment(prefix, c.Text)).Newline()
+	}
+	if doc < len(tr.Leading) {
+		for _, a := range annotations {
+			f.Str(e.stack.Indent()).Str("---" + a).Newline()
+		}
+	}
+
+	return f.String()
+}
+
+// Produces the comments of the line of `tk`, of a node which isn't marked (e.g.
+// an enum member), each on a line of its own.
+func (e *emitter) attach(tk token.Token) string {
+	if !e.keepComments || tk == nil {
+		return ""
+	}
+	tr, skipped := e.line(tk)
+	if tr == nil {
+		return skipped
+	}
+
+	return skipped + e.loose(tr)
+}
+
+// Produces the comments of the line of `defer` token `tk`, unindented, to be
+// emitted with the deferred statement. The comments of the lines preceding it
+// are produced in place (`skipped`).
+func (e *emitter) deferred(tk token.Token) (skipped string, comments []string) {
+	if !e.keepComments || tk == nil {
+		return "", nil
+	}
+	tr, skipped := e.line(tk)
+	if tr == nil {
+		return skipped, nil
+	}
+	e.emitted(tr)
+
+	return skipped, lines(tr)
+}
+
+// Produces the trivia of the line of `tk`, unless it has none or it's been
+// emitted, and the comments not yet emitted of the lines preceding it.
+func (e *emitter) line(tk token.Token) (tr *token.Trivia, skipped string) {
+	tr = tk.Trivia()
+	switch {
+	case tr == nil || e.trivia[tr]:
+		return nil, ""
+
+	// The trivia of an earlier line.
+	case tr.Line != tk.LineStart():
+		return nil, e.flush(tr)
+	}
+
+	return tr, e.flush(tr.Prev)
+}
+
+// Produces the comments not yet emitted of the lines up to that of trivia `tr`,
+// in source order. These are the comments of lines no statement is marked on:
+// those within a statement, or of statements emitting nothing.
+func (e *emitter) flush(tr *token.Trivia) string {
+	var skipped []*token.Trivia
+	for ; tr != nil && !e.trivia[tr]; tr = tr.Prev {
+		skipped = append(skipped, tr)
+	}
+
+	f := formatter.NewFormatter()
+	for i := len(skipped) - 1; i >= 0; i-- {
+		f.Str(e.loose(skipped[i]))
+	}
+
+	return f.String()
+}
+
+// Produces the comments following the last line emitted, those ending the
+// source.
+func (e *emitter) rest() string {
+	if e.last == nil {
+		return ""
+	}
+
+	f := formatter.NewFormatter()
+	for next := e.last.Next; next != nil; next = next.Next {
+		if !e.trivia[next] {
+			f.Str(e.loose(next))
+		}
+	}
+
+	return f.String()
+}
+
+// Produces the comments of trivia `tr`, each on a line of its own.
+func (e *emitter) loose(tr *token.Trivia) string {
+	e.emitted(tr)
+
+	f := formatter.NewFormatter()
+	for _, c := range lines(tr) {
+		f.Str(e.stack.Indent()).Str(c).Newline()
+	}
+
+	return f.String()
+}
+
+// Produces the Lua line comments of trivia `tr`, in source order.
+func lines(tr *token.Trivia) []string {
+	out := make([]string, 0, len(tr.Leading)+1)
+	for _, c := range tr.Leading {
+		out = append(out, comment("--", c.Text))
+	}
+	if tr.Trailing != nil {
+		out = append(out, comment("--", tr.Trailing.Text))
+	}
+
+	return out
+}
+
+// Notes the comments of trivia `tr` have been emitted.
+func (e *emitter) emitted(tr *token.Trivia) {
+	e.trivia[tr] = true
+	if e.last == nil || tr.Line > e.last.Line {
+		e.last = tr
+	}
+}
+
+// Produces a Lua line comment of `text`. Text opening a long bracket is spaced
+// from the prefix, `--[[` opens a block comment.
+func comment(prefix, text string) string {
+	if strings.HasPrefix(text, "[[") || strings.HasPrefix(text, "[=") {
+		return prefix + " " + text
+	}
+
+	return prefix + text
+}
+
+// Produces the LuaLS annotations of a `pub` fn or struct, or nil for other
+// statements.
+func annotate(v any) []string {
+	switch v := v.(type) {
+	// 'fn'
+	case *typeset.Fn:
+		if !v.Pub() || v.RefsLen() > 1 {
+			return nil
+		}
+		out := []string{}
+		for _, arg := range v.Args {
+			name := arg.Ref()
+			if arg.Vararg {
+				name = "..."
+			}
+			out = append(out, "@param "+name+" "+luaType(arg.TypeHint))
+		}
+		if v.ReturnType != "" {
+			out = append(out, "@return "+luaType(v.ReturnType))
+		}
+		return out
+
+	// 'struct'
+	case *typeset.Struct:
+		if !v.Pub() {
+			return nil
+		}
+		out := []string{"@class " + v.Ref()}
+		for _, field := range v.Fields {
+			out = append(out, "@field "+field.Ref()+" any")
+		}
+		return out
+	}
+
+	return nil
+}
+
+// Produces the LuaLS type of Skal type hint `hint`.
+func luaType(hint string) string {
+	switch hint {
+	case "":
+		return "any"
+	case token.Int.String():
+		return "integer"
+	case token.Str.String():
+		return "string"
+	case token.Bool.String():
+		return "boolean"
+	case token.Fn.String():
+		return "function"
+	default:
+		return hint
+	}
+}
@@ -3,38 +3,96 @@ package emit
 import (
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/illbjorn/skal/internal/skal/lex/token"
 	"github.com/illbjorn/skal/internal/skal/lua"
 	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/srcmap"
+	"github.com/illbjorn/skal/internal/skal/target"
 	"github.com/illbjorn/skal/internal/skal/typeset"
 	"github.com/illbjorn/skal/pkg/formatter"
 )
 
-func Emit(
-	ctc typeset.TypeSet,
-	path string,
-	isImport bool,
-	f *formatter.Formatter,
-) []byte {
+// The state of emitting a single module.
+type emitter struct {
+	// Indentation and deferrals of the enclosing scopes.
+	stack Stack
+	// Source positions of the statements emitted (see `mark`).
+	marks []srcmap.Mapping
+	// The enclosing loops, innermost last.
+	loops  []loop
+	target target.Target
+	diags  *sklog.Diagnostics
+	// Source comments are emitted (see `comments`).
+	keepComments bool
+	// The trivia of the lines whose comments have been emitted, and the last of
+	// the source.
+	trivia map[*token.Trivia]bool
+	last   *token.Trivia
+	// The comment ending the first line of a statement, by its mark.
+	notes map[int]string
+}
+
+// Options holds the settings of emitting a single module.
+type Options struct {
+	// Path of the module's source file.
+	Path string
+	// The module is an import bundled with the entrypoint, it's wrapped in a
+	// `do` block.
+	Import bool
+	// The module is compiled to a file of its own, which requires the modules
+	// it imports. Aliased modules are bound by the file's header.
+	Split bool
+	// The Lua runtime the output targets.
+	Target target.Target
+	// The comments of the source are kept.
+	Comments bool
+	// Receives the problems reported.
+	Diagnostics *sklog.Diagnostics
+}
+
+// Emit writes the Lua source of a typeset module to `f`, returning it along
+// with the mapping of its lines back to the statements they were emitted from.
+// Problems are reported to `opts.Diagnostics`, an internal error abandons the
+// module.
+//
+// Emit holds no shared state, modules may be emitted concurrently.
+func Emit(ctc typeset.TypeSet, f *formatter.Formatter, opts Options) (out []byte, mappings []srcmap.Mapping) {
 	if len(ctc.Members) == 0 {
-		return nil
+		return nil, nil
+	}
+
+	defer func() {
+		if opts.Diagnostics.Catch(recover()) {
+			out, mappings = nil, nil
+		}
+	}()
+
+	e := &emitter{
+		stack:        newStack(),
+		target:       opts.Target,
+		diags:        opts.Diagnostics,
+		keepComments: opts.Comments,
+		trivia:       make(map[*token.Trivia]bool),
+		notes:        make(map[int]string),
 	}
 
 	// If we're processing an import, wrap it in a `do` block.
 	// This allows us to enforce "cross-module" visibility boundaries.
-	if isImport {
+	if opts.Import {
 		// 'do'
-		f.Newline().Str(stack.Indent()).Str("do")
+		f.Newline().Str(e.stack.Indent()).Str("do")
 
 		// Indicate the source File name as a line comment after the `do` block
 		// open.
-		baseName := filepath.Base(path)
+		baseName := filepath.Base(opts.Path)
 		baseName = strings.Replace(baseName, filepath.Ext(baseName), "", -1)
 		f.Str(" -- FILE: " + baseName)
 
-		stack.Push()
+		e.stack.Push()
 	}
 
 	for _, o := range ctc.Members {
@@ -43,105 +101,153 @@ func Emit(
 		case token.For:
 			s := o.Value.(*typeset.For)
 			f.Newline().
-				Str(emitFor(s))
+				Str(e.mark(s)).
+				Str(e.emitFor(s))
 
 		// 'enum'
 		case token.Enum:
 			s := o.Value.(*typeset.Enum)
 			f.Newline().
-				Str(emitEnum(s))
+				Str(e.mark(s)).
+				Str(e.emitEnum(s))
 
 		// 'struct'
 		case token.Struct:
 			s := o.Value.(*typeset.Struct)
 			f.Newline().
-				Str(emitStruct(s))
+				Str(e.mark(s)).
+				Str(e.emitStruct(s))
 
 		// Bind
 		case token.Bind:
 			s := o.Value.(*typeset.Bind)
 			f.Newline().
-				Str(emitBind(s))
+				Str(e.mark(s)).
+				Str(e.emitBind(s))
 
 		// 'fn'
 		case token.Fn:
 			s := o.Value.(*typeset.Fn)
 			f.Newline().
-				Str(emitFn(s))
+				Str(e.mark(s)).
+				Str(e.emitFn(s))
 
 		// Call
 		case token.Call:
 			s := o.Value.(*typeset.Call)
 			f.Newline().
-				Str(emitCall(s, false, false))
+				Str(e.mark(s)).
+				Str(e.emitCall(s, false, false))
 
 		// Rebind
 		case token.Rebind:
 			s := o.Value.(*typeset.Bind)
 			f.Newline().
-				Str(emitBind(s))
+				Str(e.mark(s)).
+				Str(e.emitBind(s))
 
 		// 'extern'
 		case token.Extern:
 			s := o.Value.([]*typeset.External)
-			f.Str(emitExtern(s))
+			f.Str(e.emitExtern(s))
+
+		// 'import'
+		case token.Import:
+			s := o.Value.(*typeset.Import)
+			if s.Alias != "" && !opts.Split {
+				f.Newline().
+					Str(e.mark(s)).
+					Str(e.emitImport(s))
+			}
 
 		// 'if'
 		case token.If:
 			s := o.Value.(*typeset.If)
 			f.Newline().
-				Str(emitConditional(s))
+				Str(e.mark(s)).
+				Str(e.emitConditional(s))
 
 		default:
 			sklog.UnexpectedType("emit member", o.Type.String())
 		}
 	}
 
+	// Comments following the final statement.
+	if rest := e.rest(); rest != "" {
+		f.Newline().Str(strings.TrimSuffix(rest, "\n"))
+	}
+
 	// If we're processing an import, close the open `do` block.
-	if isImport {
-		stack.Pop()
+	if opts.Import {
+		e.stack.Pop()
 		f.Newline().
-			Str(stack.Indent()).
+			Str(e.stack.Indent()).
 			Str("end")
 	}
 
 	// Return the compiled code.
-	return f.Bytes()
+	return e.unmark(f.Bytes())
+}
+
+/*------------------------------------------------------------------------------
+ * Import
+ *----------------------------------------------------------------------------*/
+
+// Binds an aliased module to a table of its globals. Whole and selective
+// imports emit nothing, the globals are already in the environment.
+func (e *emitter) emitImport(imp *typeset.Import) string {
+	names := make([]string, 0, len(imp.Exports))
+	for name := range imp.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	f := formatter.NewFormatter()
+	f.Str(e.stack.Indent()).
+		Str("local " + imp.Alias + " = {")
+	for _, name := range names {
+		f.Newline().
+			Str(e.stack.Indent() + "  ").
+			Str(name + " = " + name + ",")
+	}
+	if len(names) > 0 {
+		f.Newline().Str(e.stack.Indent())
+	}
+
+	return f.Str("}").String()
 }
 
 /*------------------------------------------------------------------------------
  * Extern
  *----------------------------------------------------------------------------*/
 
-func emitExtern(ext []*typeset.External) string {
+func (e *emitter) emitExtern(ext []*typeset.External) string {
 	f := formatter.NewFormatter()
 
 	// For fancy output where each list member's assignment operator is aligned
-	// we first process the externals, identifying the longest member and building
-	// a slice of [`id`, `Value`] slices.
+	// we first identify the longest alias.
 	var longest int
-	exts := make([][2]string, len(ext))
-	for i, s := range ext {
+	for _, s := range ext {
 		if l := len(s.Alias); l > longest {
 			longest = len(s.Alias)
 		}
-		exts[i] = [2]string{s.Alias, s.Ref()}
 	}
 
-	// Now we emit using each [2]string{}'s `0` index as the whitespace offset
+	// Now we emit using the length of each alias as the whitespace offset
 	// between the ID and the `=` operator. Then we append the actual aliased
-	// Value (`1` index) at the end.
-	for _, ext := range exts {
-		spaces := strings.Repeat(" ", longest-len(ext[0]))
+	// Value at the end.
+	for _, s := range ext {
+		spaces := strings.Repeat(" ", longest-len(s.Alias))
 		f.Newline().
-			Str(stack.Indent()).
+			Str(e.attach(s.Token())).
+			Str(e.stack.Indent()).
 			// Alias
-			Str(ext[0]).
+			Str(s.Alias).
 			Str(spaces).
 			// ' = '
 			Str(" = ").
 			// External
-			Str(ext[1])
+			Str(s.Ref())
 	}
 
 	return f.String()
@@ -164,7 +270,7 @@ var tmplStructNoCon = `
 {in}{ref}.__index = {ref}
 `
 
-func emitStruct(nstruct *typeset.Struct) string {
+func (e *emitter) emitStruct(nstruct *typeset.Struct) string {
 	f := formatter.NewFormatter()
 
 	// Local
@@ -179,17 +285,17 @@ func emitStruct(nstruct *typeset.Struct) string {
 		f.Str(
 			pairs(
 				tmplStruct,
-				"in", stack.Indent(),
+				"in", e.stack.Indent(),
 				"local", local,
 				"ref", nstruct.Ref(),
-				"constructor", emitStructConstructor(nstruct),
+				"constructor", e.emitStructConstructor(nstruct),
 			))
 	} else {
 		// Produce without constructor.
 		f.Str(
 			pairs(
 				tmplStructNoCon,
-				"in", stack.Indent(),
+				"in", e.stack.Indent(),
 				"local", local,
 				"ref", nstruct.Ref(),
 			))
@@ -198,7 +304,8 @@ func emitStruct(nstruct *typeset.Struct) string {
 	// Methods
 	for _, method := range nstruct.Methods {
 		f.Newline().
-			Str(emitMethod(nstruct, method))
+			Str(e.attach(method.Token())).
+			Str(e.emitMethod(nstruct, method))
 	}
 
 	return f.String()
@@ -218,23 +325,23 @@ var tmplStructDefaultConstructorInstance = `
 
 var tmplStructDefaultConstructorField = "{in}{ref} = {ref}"
 
-func emitStructConstructor(nstruct *typeset.Struct) string {
-	stack.Push() // <-- Constructor fn scope.
+func (e *emitter) emitStructConstructor(nstruct *typeset.Struct) string {
+	e.stack.Push() // <-- Constructor fn scope.
 
 	// Assemble comma-delimited args to use as constructor function args.
 	args := formatter.NewFormatter()
 	// Assemble 'reference = reference' field pairs for default table assignments.
 	fields := formatter.NewFormatter()
-	stack.Push() // <-- Constructor fn instance member scope.
+	e.stack.Push() // <-- Constructor fn instance member scope.
 	for i, f := range nstruct.Fields {
 		// Write the argument.
 		args.Str(f.Ref())
 
 		// Format and write the field initializer.
-		fields.Str(
+		fields.Str(e.attach(f.Token())).Str(
 			pairs(
 				tmplStructDefaultConstructorField,
-				"in", stack.Indent(),
+				"in", e.stack.Indent(),
 				"ref", f.Ref(),
 			))
 
@@ -243,19 +350,19 @@ func emitStructConstructor(nstruct *typeset.Struct) string {
 			fields.Str(",\n")
 		}
 	}
-	stack.Pop() // Constructor fn instance member scope. --!>
+	e.stack.Pop() // Constructor fn instance member scope. --!>
 
 	// Typeset the constructed instance.
 	instance := pairs(
 		tmplStructDefaultConstructorInstance,
-		"in", stack.Indent(),
+		"in", e.stack.Indent(),
 		"fields", fields.String(),
 	)
-	stack.Pop() // Constructor fn scope. --!>
+	e.stack.Pop() // Constructor fn scope. --!>
 
 	return pairs(
 		tmplStructDefaultConstructor,
-		"in", stack.Indent(),
+		"in", e.stack.Indent(),
 		"ref", nstruct.Ref(),
 		"args", args.String(),
 		"instance", instance,
@@ -267,7 +374,7 @@ var tmplMethod = `
 {in}end
 `
 
-func emitMethod(nstruct *typeset.Struct, fn *typeset.Fn) string {
+func (e *emitter) emitMethod(nstruct *typeset.Struct, fn *typeset.Fn) string {
 	// Args
 	args := formatter.NewFormatter()
 	var varArgName string
@@ -295,11 +402,11 @@ func emitMethod(nstruct *typeset.Struct, fn *typeset.Fn) string {
 
 	return pairs(
 		tmplMethod,
-		"in", stack.Indent(),
+		"in", e.stack.Indent(),
 		"struct", nstruct.ID(),
 		"fn", id,
 		"args", args.String(),
-		"block", emitFnBlock(fn, varArgName),
+		"block", e.emitFnBlock(fn, varArgName),
 	)
 }
 
@@ -312,7 +419,7 @@ var tmplEnum = `
 {in}}
 `
 
-func emitEnum(enum *typeset.Enum) string {
+func (e *emitter) emitEnum(enum *typeset.Enum) string {
 	f := formatter.NewFormatter()
 
 	// Local
@@ -322,7 +429,7 @@ func emitEnum(enum *typeset.Enum) string {
 	}
 
 	// Members
-	members := enumMembers(enum.Members)
+	members := e.enumMembers(enum.Members)
 
 	return f.Str(
 		pairs(
@@ -330,15 +437,15 @@ func emitEnum(enum *typeset.Enum) string {
 			"local", local,
 			"ref", enum.Ref(),
 			"members", members,
-			"in", stack.Indent(),
+			"in", e.stack.Indent(),
 		)).String()
 }
 
 var tmplEnumMember = "{in}{id} = {Value}"
 
-func enumMembers(members []*typeset.EnumMember) string {
-	stack.Push()
-	defer stack.Pop()
+func (e *emitter) enumMembers(members []*typeset.EnumMember) string {
+	e.stack.Push()
+	defer e.stack.Pop()
 
 	// Members
 	f := formatter.NewFormatter()
@@ -348,10 +455,10 @@ func enumMembers(members []*typeset.EnumMember) string {
 			value = "'" + value + "'"
 		}
 
-		f.Newline().Str(
+		f.Newline().Str(e.attach(m.Token())).Str(
 			pairs(
 				tmplEnumMember,
-				"in", stack.Indent(),
+				"in", e.stack.Indent(),
 				"id", m.Ref(),
 				"Value", value,
 			))
@@ -373,7 +480,7 @@ var tmplFn = `
 {in}end
 `
 
-func emitFn(fn *typeset.Fn) string {
+func (e *emitter) emitFn(fn *typeset.Fn) string {
 	// Local
 	var local string
 	if !fn.Pub() && fn.RefsLen() <= 1 && !fn.Constructor {
@@ -410,11 +517,11 @@ func emitFn(fn *typeset.Fn) string {
 
 	return pairs(
 		tmplFn,
-		"in", stack.Indent(),
+		"in", e.stack.Indent(),
 		"local", local,
 		"ref", ref,
 		"args", args.String(),
-		"block", emitFnBlock(fn, varArgName),
+		"block", e.emitFnBlock(fn, varArgName),
 	)
 }
 
@@ -432,17 +539,22 @@ var (
 	selfRepl = regexp.MustCompile(`\bself\b`)
 )
 
-func emitFnBlock(fn *typeset.Fn, vararg string) string {
+func (e *emitter) emitFnBlock(fn *typeset.Fn, vararg string) string {
 	f := formatter.NewFormatter()
-	stack.Push()
-	defer stack.Pop()
+	e.stack.Push()
+	defer e.stack.Pop()
+
+	// A `continue` can't cross an fn boundary, so the body sees no loops.
+	loops := e.loops
+	e.loops = nil
+	defer func() { e.loops = loops }()
 
 	// If the Fn is an overridden constructor, create the boilerplate
 	// `_instance_`. Also set a formatter hook to replace all occurrences of
 	// `this` in the constructor to the boilerplate `_instance_` object we create.
 	if fn.Constructor {
 		f.Newline().
-			Str(stack.Indent()).
+			Str(e.stack.Indent()).
 			Str(tmplDefaultConInstance)
 
 		// Set the hook to replace `this` references (see comment above).
@@ -462,22 +574,21 @@ func emitFnBlock(fn *typeset.Fn, vararg string) string {
 				pairs(
 					tmplVarArg,
 					"ref", vararg,
-					"in", stack.Indent(),
+					"in", e.stack.Indent(),
 				))
 	}
 
 	// Block
-	stack.Pop() // Scoping is inverted here since `emitBlock` scopes as well.
+	e.stack.Pop() // Scoping is inverted here since `emitBlock` scopes as well.
 	f.Str(
-		emitBlock(fn.Block),
+		e.emitBlock(fn.Block),
 	)
-	stack.Push() // Scoping is inverted here since `emitBlock` scopes as well.
+	e.stack.Push() // Scoping is inverted here since `emitBlock` scopes as well.
 
 	// Defers
-	for _, d := range stack.s[stack.i] {
+	for _, d := range e.stack.s[e.stack.i] {
 		f.Newline().
-			Str(stack.Indent()).
-			Str(d)
+			Str(e.stack.Emit(d))
 	}
 
 	return f.String()
@@ -487,7 +598,7 @@ var tmplFnA = `
 function({args}) return {stmt} end
 `
 
-func emitAnonFn(fn *typeset.Fn) string {
+func (e *emitter) emitAnonFn(fn *typeset.Fn) string {
 	// Args
 	args := formatter.NewFormatter()
 	for i, arg := range fn.Args {
@@ -500,7 +611,7 @@ func emitAnonFn(fn *typeset.Fn) string {
 	}
 
 	// Block
-	v := emitValues(fn.Values)
+	v := e.emitValues(fn.Values)
 
 	return formatter.NewFormatter().
 		Str(
@@ -515,34 +626,42 @@ func emitAnonFn(fn *typeset.Fn) string {
  * Statements
  *----------------------------------------------------------------------------*/
 
-func emitStatement(stmt *typeset.Statement, isDefer bool) string {
+func (e *emitter) emitStatement(stmt *typeset.Statement, isDefer bool) string {
 	switch stmt.StmtType {
 	// 'defer'
 	case token.Defer:
+		// The comments of the `defer` lead the deferred statement, wherever it's
+		// emitted.
+		skipped, comments := e.deferred(stmt.Token())
 		for d := range stmt.Defers() {
-			stack.Add(emitStatement(d, true))
+			e.stack.Add(comments, e.emitStatement(d, true))
+			comments = nil
 		}
-		return ""
+		return strings.TrimSuffix(skipped, "\n")
 
 	// Call
 	case token.Call:
-		return emitCall(stmt.Call, false, isDefer)
+		return e.emitCall(stmt.Call, false, isDefer)
 
 	// Bind | Rebind
 	case token.Bind, token.Rebind:
-		return emitBind(stmt.Bind)
+		return e.emitBind(stmt.Bind)
 
 	// 'fn'
 	case token.Fn:
-		return emitFn(stmt.Fn)
+		return e.emitFn(stmt.Fn)
 
 	// 'for'
 	case token.For:
-		return emitFor(stmt.For)
+		return e.emitFor(stmt.For)
 
 	// 'if'
 	case token.If:
-		return emitConditional(stmt.If)
+		return e.emitConditional(stmt.If)
+
+	// 'continue'
+	case token.Continue:
+		return e.emitContinue()
 
 	// Reference
 	case token.Ref:
@@ -552,22 +671,21 @@ func emitStatement(stmt *typeset.Statement, isDefer bool) string {
 	case token.Ret:
 		f := formatter.NewFormatter()
 		// When we hit a `return`, unwind and emit any queued `defer`s.
-		for d := range stack.Unwind() {
+		for d := range e.stack.Unwind() {
 			// Don't add a newline to the first member.
 			if d.current > 0 {
 				f.Newline()
 			}
-			f.Str(stack.Indent()).
-				Str(d.stmt)
+			f.Str(e.stack.Emit(d))
 		}
 
 		// If we actually had defers to unwind, we need a newline between the final
 		// deferred statement and the return statement.
-		if stack.defersTotal > 0 {
+		if e.stack.defersTotal > 0 {
 			f.Newline()
 		}
 
-		f.Str(stack.Indent()).
+		f.Str(e.stack.Indent()).
 			Str("return")
 
 		// A minute detail, but by checking the length here we avoid emitting bare
@@ -577,7 +695,7 @@ func emitStatement(stmt *typeset.Statement, isDefer bool) string {
 		}
 
 		f.Str(
-			emitValues(stmt.Values),
+			e.emitValues(stmt.Values),
 		)
 
 		return f.String()
@@ -595,23 +713,37 @@ func emitStatement(stmt *typeset.Statement, isDefer bool) string {
  * Values
  *----------------------------------------------------------------------------*/
 
-func emitValues(values []*typeset.Value) string {
+// Translates reference `ref` to its Lua form on the target.
+func (e *emitter) translate(ref string) string {
+	ref = lua.Translate(ref)
+	if ref == "unpack" || ref == "table.unpack" {
+		return e.target.Unpack
+	}
+
+	return ref
+}
+
+func (e *emitter) emitValues(values []*typeset.Value) string {
+	if e.lowers(values) {
+		return e.emitExpr(typeset.ParseExpr(values))
+	}
+
 	f := formatter.NewFormatter()
 
 	for _, v := range values {
 		f.Str(
-			emitValue(v),
+			e.emitValue(v),
 		)
 	}
 
 	return f.String()
 }
 
-func emitValue(value *typeset.Value) string {
+func (e *emitter) emitValue(value *typeset.Value) string {
 	switch value.ValueType {
 	// List literal.
 	case token.ListL:
-		return emitList(value.List)
+		return e.emitList(value.List)
 
 	// '[]'
 	case token.List:
@@ -619,19 +751,19 @@ func emitValue(value *typeset.Value) string {
 
 	// Anonymous fn
 	case token.Fn:
-		return emitAnonFn(value.Fn)
+		return e.emitAnonFn(value.Fn)
 
 	// Value group
 	case token.ValueGroup:
-		return "(" + emitValues(value.Group) + ")"
+		return "(" + e.emitValues(value.Group) + ")"
 
 	// Call
 	case token.Call:
-		return emitCall(value.Call, true, false)
+		return e.emitCall(value.Call, true, false)
 
 	// Reference
 	case token.Ref:
-		return lua.Translate(value.Ref())
+		return e.translate(value.Ref())
 
 	// StrL
 	case token.StrL:
@@ -639,6 +771,7 @@ func emitValue(value *typeset.Value) string {
 
 	// IntL
 	case token.IntL:
+		e.checkInt(value)
 		return value.IntL
 
 	// BoolL
@@ -654,7 +787,8 @@ func emitValue(value *typeset.Value) string {
 		return "not "
 
 	// Operators
-	case token.MathOperator, token.ComparisonOperator, token.ConcatOperator, token.LogicOperator:
+	case token.MathOperator, token.BitwiseOperator, token.ComparisonOperator,
+		token.ConcatOperator, token.LogicOperator:
 		return " " + lua.Translate(value.Op) + " "
 
 	default:
@@ -663,16 +797,16 @@ func emitValue(value *typeset.Value) string {
 	}
 }
 
-func emitList(list []*typeset.Value) string {
+func (e *emitter) emitList(list []*typeset.Value) string {
 	f := formatter.NewFormatter()
 
 	f.Str("{")
-	stack.Push()
+	e.stack.Push()
 	for i, listVal := range list {
 		f.Newline().
-			Str(stack.Indent()).
+			Str(e.stack.Indent()).
 			Str(
-				emitValue(listVal),
+				e.emitValue(listVal),
 			)
 
 		// Comma delimit all but the last list member.
@@ -680,9 +814,9 @@ func emitList(list []*typeset.Value) string {
 			f.Str(",")
 		}
 	}
-	stack.Pop()
+	e.stack.Pop()
 
-	return f.Newline().Str(stack.Indent()).Str("}").String()
+	return f.Newline().Str(e.stack.Indent()).Str("}").String()
 }
 
 /*------------------------------------------------------------------------------
@@ -699,7 +833,7 @@ var tmplForIn = `
 {in}{end}
 `
 
-func emitFor(nfor *typeset.For) string {
+func (e *emitter) emitFor(nfor *typeset.For) string {
 	// Identify the template based on whether it's a `for k, v in` or
 	// `for i = n, n` format loop.
 	var tmpl string
@@ -710,24 +844,140 @@ func emitFor(nfor *typeset.For) string {
 	}
 
 	// Prepare the iterator(s).
-	iterators := forIterators(nfor)
+	iterators := e.forIterators(nfor)
 
 	// Prepare the iterable(s).
-	iterables := forIterables(nfor)
+	iterables := e.forIterables(nfor)
 
 	// Emit all contained statements.
-	block := emitBlock(nfor.Block)
+	block := e.emitLoopBlock(nfor.Block)
 
 	return pairs(
 		tmpl,
-		"in", stack.Indent(),
+		"in", e.stack.Indent(),
 		"iterators", iterators,
 		"iterables", iterables,
 		"block", block,
 	)
 }
 
-func forIterators(iterators *typeset.For) string {
+/*------------------------------------------------------------------------------
+ * Continue
+ *----------------------------------------------------------------------------*/
+
+// A loop enclosing the statements being emitted.
+type loop struct {
+	// Stack depth of the loop body.
+	depth int
+	mode  target.Continue
+	// The label `continue` jumps to, for ContinueGoto.
+	label string
+}
+
+// Emits the body of a loop, arranging for any `continue` it holds per the
+// target:
+//
+//	for ... do           for ... do           for ... do
+//	  repeat               ...                  ...
+//	    ...                goto continue        continue
+//	    break              ...                  ...
+//	    ...                ::continue::       end
+//	  until true         end
+//	end
+func (e *emitter) emitLoopBlock(block []*typeset.Statement) string {
+	if !continues(block) {
+		// Even without a `continue`, the body is a loop for those nested within.
+		e.loops = append(e.loops, loop{depth: e.stack.i + 1, mode: target.ContinueNative})
+		defer func() { e.loops = e.loops[:len(e.loops)-1] }()
+		return e.emitBlock(block)
+	}
+
+	l := loop{depth: e.stack.i + 1, mode: e.target.Continue}
+
+	// Lua permits nothing following a `return`, including a label.
+	if l.mode == target.ContinueGoto && block[len(block)-1].StmtType == token.Ret {
+		l.mode = target.ContinueRepeat
+	}
+
+	// Labels of enclosing loops remain visible, and Lua 5.4 rejects shadowing
+	// them.
+	l.label = "continue"
+	if n := len(e.loops); n > 0 {
+		l.label += "_" + strconv.Itoa(n+1)
+	}
+
+	e.loops = append(e.loops, l)
+	defer func() { e.loops = e.loops[:len(e.loops)-1] }()
+
+	in := strings.Repeat(" ", l.depth*2)
+	switch l.mode {
+	case target.ContinueGoto:
+		return e.emitBlock(block) + "\n" + in + "::" + l.label + "::"
+
+	case target.ContinueRepeat:
+		e.stack.Push()
+		e.loops[len(e.loops)-1].depth++
+		body := e.emitBlock(block)
+		e.stack.Pop()
+		return "\n" + in + "repeat" + body + "\n" + in + "until true"
+
+	default:
+		return e.emitBlock(block)
+	}
+}
+
+func (e *emitter) emitContinue() string {
+	l := e.loops[len(e.loops)-1]
+
+	// Unwind the `defer`s queued within the loop body.
+	f := formatter.NewFormatter()
+	for i := e.stack.i; i >= l.depth; i-- {
+		for _, d := range e.stack.s[i] {
+			f.Str(e.stack.Emit(d)).Newline()
+		}
+	}
+
+	f.Str(e.stack.Indent())
+	switch l.mode {
+	case target.ContinueGoto:
+		f.Str("goto " + l.label)
+	case target.ContinueRepeat:
+		f.Str("break")
+	default:
+		f.Str("continue")
+	}
+
+	return f.String()
+}
+
+// Indicates whether a loop body holds a `continue` targeting the loop.
+func continues(block []*typeset.Statement) bool {
+	for _, stmt := range block {
+		switch stmt.StmtType {
+		// 'continue'
+		case token.Continue:
+			return true
+
+		// 'if'
+		case token.If:
+			if continues(stmt.If.Block) {
+				return true
+			}
+			for _, elif := range stmt.If.Elifs {
+				if continues(elif.Block) {
+					return true
+				}
+			}
+			if stmt.If.Else != nil && continues(stmt.If.Else.Block) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (e *emitter) forIterators(iterators *typeset.For) string {
 	f := formatter.NewFormatter()
 
 	// Stringify the iterator(s).
@@ -741,7 +991,7 @@ func forIterators(iterators *typeset.For) string {
 	return f.String()
 }
 
-func forIterables(iterables *typeset.For) string {
+func (e *emitter) forIterables(iterables *typeset.For) string {
 	f := formatter.NewFormatter()
 
 	// Stringify the iterable(s).
@@ -764,26 +1014,26 @@ func forIterables(iterables *typeset.For) string {
  * If
  *----------------------------------------------------------------------------*/
 
-func emitConditional(cond *typeset.If) string {
+func (e *emitter) emitConditional(cond *typeset.If) string {
 	f := formatter.NewFormatter()
 
 	// If
-	if v := emitIf(cond); v != "" {
+	if v := e.emitIf(cond); v != "" {
 		f.Str(v)
 	}
 
 	// Elifs
-	if v := emitElifs(cond.Elifs); v != "" {
+	if v := e.emitElifs(cond.Elifs); v != "" {
 		f.Str(v)
 	}
 
 	// Else
-	if v := emitElse(cond.Else); v != "" {
+	if v := e.emitElse(cond.Else); v != "" {
 		f.Str(v)
 	}
 
 	// Close the conditional.
-	f.Newline().Str(stack.Indent()).Str("end")
+	f.Newline().Str(e.stack.Indent()).Str("end")
 
 	return f.String()
 }
@@ -791,16 +1041,16 @@ func emitConditional(cond *typeset.If) string {
 var tmplIf = `
 {in}if {conds} then{block}`
 
-func emitIf(nif *typeset.If) string {
+func (e *emitter) emitIf(nif *typeset.If) string {
 	// Conditions
-	conds := emitConditions(nif.Conditions)
+	conds := e.emitConditions(nif.Conditions)
 
 	// Block
-	block := emitBlock(nif.Block)
+	block := e.emitBlock(nif.Block)
 
 	return pairs(
 		tmplIf,
-		"in", stack.Indent(),
+		"in", e.stack.Indent(),
 		"conds", conds,
 		"block", block,
 	)
@@ -810,7 +1060,7 @@ var tmplElif = `
 {in}elseif {conds} then{block}
 `
 
-func emitElifs(elifs []*typeset.Elif) string {
+func (e *emitter) emitElifs(elifs []*typeset.Elif) string {
 	if len(elifs) == 0 {
 		return ""
 	}
@@ -821,9 +1071,9 @@ func emitElifs(elifs []*typeset.Elif) string {
 		f.Newline().Str(
 			pairs(
 				tmplElif,
-				"in", stack.Indent(),
-				"conds", emitConditions(s.Conditions),
-				"block", emitBlock(s.Block),
+				"in", e.stack.Indent(),
+				"conds", e.emitConditions(s.Conditions),
+				"block", e.emitBlock(s.Block),
 			))
 	}
 
@@ -834,7 +1084,7 @@ var tmplElse = `
 {in}else{block}
 `
 
-func emitElse(nelse *typeset.Else) string {
+func (e *emitter) emitElse(nelse *typeset.Else) string {
 	if nelse == nil {
 		return ""
 	}
@@ -843,42 +1093,47 @@ func emitElse(nelse *typeset.Else) string {
 	f.Newline().Str(
 		pairs(
 			tmplElse,
-			"in", stack.Indent(),
-			"block", emitBlock(nelse.Block),
+			"in", e.stack.Indent(),
+			"block", e.emitBlock(nelse.Block),
 		))
 
 	return f.String()
 }
 
-func emitConditions(conds []*typeset.Value) string {
-	f := formatter.NewFormatter()
-
-	for _, c := range conds {
-		f.Str(emitValue(c))
-	}
-
-	return f.String()
+func (e *emitter) emitConditions(conds []*typeset.Value) string {
+	return e.emitValues(conds)
 }
 
 /*------------------------------------------------------------------------------
  * Blocks
  *----------------------------------------------------------------------------*/
 
-func emitBlock(block []*typeset.Statement) string {
+func (e *emitter) emitBlock(block []*typeset.Statement) string {
 	f := formatter.NewFormatter()
-	stack.Push()
-	defer stack.Pop()
+	e.stack.Push()
+	defer e.stack.Pop()
 
-	// Emit all contained statements.
+	// Emit all contained statements. A statement is marked before those nested
+	// within it, it leads them on its line.
 	for _, v := range block {
-		if stmt := emitStatement(v, false); stmt != "" {
-			f.Newline().Str(emitStatement(v, false))
+		var mark string
+		if v.StmtType != token.Defer {
+			mark = e.mark(v)
+		}
+		if stmt := e.emitStatement(v, false); stmt != "" {
+			f.Newline().Str(mark).Str(stmt)
 		}
 	}
 
+	// A trailing `return` has already unwound the defers, and Lua permits nothing
+	// after it.
+	if len(block) > 0 && block[len(block)-1].StmtType == token.Ret {
+		return f.String()
+	}
+
 	// Write any defers at the close of the block.
-	for _, d := range stack.s[stack.i] {
-		f.Newline().Str(stack.Indent()).Str(d)
+	for _, d := range e.stack.s[e.stack.i] {
+		f.Newline().Str(e.stack.Emit(d))
 	}
 
 	return f.String()
@@ -895,9 +1150,9 @@ var tmplBind = `
 {in}{local}{ref} = {Value}
 `
 
-func emitBind(bind *typeset.Bind) string {
+func (e *emitter) emitBind(bind *typeset.Bind) string {
 	// Indentation.
-	indent := stack.Indent()
+	indent := e.stack.Indent()
 
 	// Handle access scoping.
 	// Binding is not declared pub.
@@ -929,9 +1184,7 @@ func emitBind(bind *typeset.Bind) string {
 
 	// Prepare the values.
 	values := formatter.NewFormatter()
-	for _, v := range bind.Values {
-		values.Str(emitValue(v))
-	}
+	values.Str(e.emitValues(bind.Values))
 
 	// String it all together.
 	return formatter.NewFormatter().Str(
@@ -952,7 +1205,7 @@ var tmplCall = `
 {in}{ref}({args}){newline}
 `
 
-func emitCall(call *typeset.Call, value bool, isDefer bool) string {
+func (e *emitter) emitCall(call *typeset.Call, value bool, isDefer bool) string {
 	if call.Ref() == "" && len(call.Args) == 0 {
 		return ""
 	}
@@ -961,28 +1214,28 @@ func emitCall(call *typeset.Call, value bool, isDefer bool) string {
 	var indent string
 	var newline string
 	if !value && !isDefer {
-		indent = stack.Indent()
+		indent = e.stack.Indent()
 		newline = "\n"
 	}
 
 	// Reference
+	// Fields of host tables (e.g. `string.format`) and modules are plain fns,
+	// everything else with a path is a method call.
 	var ref string
-	if call.RefsLen() > 1 {
+	if call.RefsLen() > 1 && !call.Symbol().HostTable() && !call.Symbol().Module() {
 		ref = call.MethodRef()
 	} else {
 		ref = call.Ref()
 	}
-	ref = lua.Translate(ref)
+	ref = e.translate(ref)
 
 	// Args
 	args := formatter.NewFormatter()
 	for i, arg := range call.Args {
-		for _, v := range arg.Values {
-			if arg.Spread {
-				args.Str("...")
-			} else {
-				args.Str(emitValue(v))
-			}
+		if arg.Spread {
+			args.Str("...")
+		} else {
+			args.Str(e.emitValues(arg.Values))
 		}
 		if i < len(call.Args)-1 {
 			args.Str(", ")
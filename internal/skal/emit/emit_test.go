@@ -0,0 +1,70 @@
+package emit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/illbjorn/skal/internal/skal/lex"
+	"github.com/illbjorn/skal/internal/skal/lua"
+	"github.com/illbjorn/skal/internal/skal/parse"
+	"github.com/illbjorn/skal/internal/skal/resolve"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+	"github.com/illbjorn/skal/pkg/formatter"
+)
+
+// Emits each `testdata/<name>.sk` fixture, comparing the output to
+// `testdata/<name>.lua`.
+func TestFixtures(t *testing.T) {
+	fixtures := []struct {
+		name string
+		opts Options
+	}{
+		{name: "comments", opts: Options{Comments: true}},
+	}
+
+	for _, fx := range fixtures {
+		t.Run(fx.name, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("testdata", fx.name+".sk"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(filepath.Join("testdata", fx.name+".lua"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := emitFixture(t, fx.name+".sk", string(src), fx.opts)
+			if got != string(want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func emitFixture(t *testing.T, path, src string, opts Options) string {
+	t.Helper()
+
+	diags := sklog.NewDiagnostics()
+	opts.Path, opts.Diagnostics = path, diags
+
+	var out []byte
+	func() {
+		defer func() { diags.Catch(recover()) }()
+		tree := parse.Parse(lex.Lex(path, src, diags))
+		set := typeset.Typeset(tree, lua.Stdlib, diags)
+		mod := &resolve.Module{Path: path, Set: set}
+		resolve.Resolve(lua.Stdlib, diags, mod)
+		if diags.Errors() > 0 {
+			return
+		}
+		out, _ = Emit(mod.Set, formatter.NewFormatter(), opts)
+	}()
+	if err := diags.Err(); err != nil {
+		t.Fatalf("emit: %s", err)
+	}
+
+	return strings.TrimPrefix(string(out), "\n") + "\n"
+}
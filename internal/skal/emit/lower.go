@@ -0,0 +1,128 @@
+package emit
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+/*------------------------------------------------------------------------------
+ * Operator Lowering
+ *----------------------------------------------------------------------------*/
+
+// The fns of the bitwise library lowered operators call.
+var bitFns = map[string]string{
+	token.BitAnd.String(): "band",
+	token.BitOr.String():  "bor",
+	token.BitXor.String(): "bxor",
+	token.Shl.String():    "lshift",
+	token.Shr.String():    "rshift",
+}
+
+// Indicates whether a run of values holds an operator the target lacks.
+//
+// Values are held as a flat run of operands and operators, which is emitted
+// as-is. Where the target lacks an operator, the run is first parsed into an
+// expression tree (see `typeset.ParseExpr`), so the operator's operands can be
+// passed to the fn it's lowered to.
+//
+//	a + b & c  ->  bit.band(a + b, c)
+func (e *emitter) lowers(values []*typeset.Value) bool {
+	for _, v := range values {
+		switch {
+		case v.ValueType == token.BitwiseOperator && !e.target.Bitwise:
+			return true
+		case v.Op == token.FloorDiv.String() && !e.target.FloorDiv:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *emitter) emitExpr(x *typeset.Expr) string {
+	switch {
+	case x == nil:
+		return ""
+
+	// Operand
+	case x.Op == nil:
+		return e.emitValue(x.Value)
+
+	// Unary operator
+	case x.LHS == nil:
+		return e.emitValue(x.Op) + e.emitExpr(x.RHS)
+	}
+
+	lhs, rhs := e.emitExpr(x.LHS), e.emitExpr(x.RHS)
+	switch {
+	// '&' | '|' | '~' | '<<' | '>>'
+	case x.Op.ValueType == token.BitwiseOperator && !e.target.Bitwise:
+		if e.target.BitLib == "" {
+			e.noBitwise(x.Op)
+		}
+		return e.target.BitLib + "." + bitFns[x.Op.Op] + "(" + lhs + ", " + rhs + ")"
+
+	// '//'
+	case x.Op.Op == token.FloorDiv.String() && !e.target.FloorDiv:
+		return "math.floor(" + lhs + " / " + rhs + ")"
+	}
+
+	return lhs + e.emitValue(x.Op) + rhs
+}
+
+/*------------------------------------------------------------------------------
+ * Target Checks
+ *----------------------------------------------------------------------------*/
+
+// The largest integer a double represents exactly.
+var maxExactInt = new(big.Int).Lsh(big.NewInt(1), 53)
+
+// Reports integer literals the target can't represent exactly.
+func (e *emitter) checkInt(value *typeset.Value) {
+	if e.target.Integers {
+		return
+	}
+
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(value.IntL, "-"), 10)
+	if !ok || n.Cmp(maxExactInt) <= 0 {
+		return
+	}
+
+	e.event(sklog.CodeIntPrecision, sklog.LevelWarn, value).
+		AddF(
+			"Integer literal {n} exceeds the precision of numbers on target {target}, it will be rounded.",
+			"n", value.IntL,
+			"target", e.target.Name,
+		).
+		Send()
+}
+
+func (e *emitter) noBitwise(op *typeset.Value) {
+	e.event(sklog.CodeNoBitwise, sklog.LevelError, op).
+		AddF(
+			"Bitwise operator '{op}' is unavailable on target {target}.",
+			"op", op.Op,
+			"target", e.target.Name,
+		).
+		Send()
+}
+
+func (e *emitter) event(code, level string, v *typeset.Value) *sklog.CompilerEvent {
+	mtype := sklog.MsgTypeEmitError
+	if level == sklog.LevelWarn {
+		mtype = sklog.MsgTypeEmitWarning
+	}
+
+	ev := sklog.NewCompilerEvent(mtype, level).
+		To(e.diags).
+		WithCode(code)
+	if tk := v.Token(); tk != nil {
+		ev.WithSourceHint(tk.SrcLine(), tk.File(), tk.LineStart(), tk.ColumnStart(), tk.ColumnEnd())
+	}
+
+	return ev
+}
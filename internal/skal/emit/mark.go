@@ -0,0 +1,76 @@
+package emit
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/srcmap"
+)
+
+// Statements are emitted as strings, so their final line isn't known until the
+// module is complete. Each statement is prefixed with a marker holding its
+// index in `emitter.marks`, markers are stripped from the completed module.
+const markDelim = '\x00'
+
+// Produces the marker of statement `v`, led by its comments (see `comments`),
+// or nothing if `v` has no source position.
+func (e *emitter) mark(v any) string {
+	n, ok := v.(interface{ Token() token.Token })
+	if !ok || n.Token() == nil {
+		return ""
+	}
+
+	tk := n.Token()
+	e.marks = append(e.marks, srcmap.Mapping{
+		Source:  tk.File(),
+		SrcLine: tk.LineStart(),
+		SrcCol:  tk.ColumnStart(),
+	})
+
+	i := len(e.marks) - 1
+	return e.comments(v, tk, i) + string(markDelim) + strconv.Itoa(i) + string(markDelim)
+}
+
+// Strips the markers of module output `out`, producing the mapping of each
+// marked line. Where a line holds more than one statement, the first is used.
+// The trailing comments of the statements marked end the line.
+func (e *emitter) unmark(out []byte) ([]byte, []srcmap.Mapping) {
+	var mappings []srcmap.Mapping
+	clean := make([]byte, 0, len(out))
+
+	line := 1
+	var notes []string
+	for i := 0; i < len(out); i++ {
+		switch out[i] {
+		case '\n':
+			if len(notes) > 0 {
+				clean = append(clean, " "+strings.Join(notes, " ")...)
+				notes = notes[:0]
+			}
+			line++
+
+		case markDelim:
+			end := i + 1 + bytes.IndexByte(out[i+1:], markDelim)
+			n, _ := strconv.Atoi(string(out[i+1 : end]))
+			if note, ok := e.notes[n]; ok {
+				notes = append(notes, note)
+			}
+			if len(mappings) == 0 || mappings[len(mappings)-1].Line != line {
+				mapping := e.marks[n]
+				mapping.Line = line
+				mappings = append(mappings, mapping)
+			}
+			i = end
+			continue
+		}
+
+		clean = append(clean, out[i])
+	}
+	if len(notes) > 0 {
+		clean = append(clean, " "+strings.Join(notes, " ")...)
+	}
+
+	return clean, mappings
+}
@@ -4,11 +4,12 @@ import (
 	"strings"
 )
 
-// TODO: Eliminate package level state!
-var stack = Stack{i: 1, s: make([][]string, 10)}
+func newStack() Stack {
+	return Stack{i: 1, s: make([][]deferral, 10)}
+}
 
 type Stack struct {
-	s             [][]string
+	s             [][]deferral
 	i             int
 	defersTotal   int
 	defersInScope int
@@ -18,10 +19,10 @@ func (s *Stack) Indent() string {
 	return strings.Repeat(" ", s.i*2)
 }
 
-func (s *Stack) Add(deferral string) {
+func (s *Stack) Add(comments []string, stmt string) {
 	s.defersInScope++
 	s.defersTotal++
-	s.s[s.i] = append([]string{deferral}, s.s[s.i]...)
+	s.s[s.i] = append([]deferral{{stmt: stmt, comments: comments}}, s.s[s.i]...)
 }
 
 func (s *Stack) Push() {
@@ -30,7 +31,7 @@ func (s *Stack) Push() {
 }
 
 func (s *Stack) Pop() {
-	s.s[s.i] = make([]string, 0)
+	s.s[s.i] = make([]deferral, 0)
 	if s.i > 0 {
 		s.i--
 	}
@@ -40,9 +41,23 @@ func (s *Stack) Pop() {
 }
 
 type deferral struct {
-	stmt    string
-	current int
-	total   int
+	stmt     string
+	comments []string
+	current  int
+	total    int
+}
+
+// Produces deferral `d` at the current indentation, led by its comments.
+func (s *Stack) Emit(d deferral) string {
+	in := s.Indent()
+
+	var b strings.Builder
+	for _, c := range d.comments {
+		b.WriteString(in + c + "\n")
+	}
+	b.WriteString(in + d.stmt)
+
+	return b.String()
 }
 
 func (s *Stack) Unwind() chan deferral {
@@ -60,7 +75,8 @@ func (s *Stack) Unwind() chan deferral {
 			}
 
 			for _, v := range s.s[i] {
-				ch <- deferral{v, current, total}
+				v.current, v.total = current, total
+				ch <- v
 				current++
 			}
 		}
@@ -0,0 +1,49 @@
+  -- Host fns.
+  -- Clock.
+  -- seconds
+  now = os.time
+  -- Directions.
+  local Dir = {
+    -- Upward.
+    -- up
+    UP = 1,
+    DOWN = 2
+  }
+  --- A point.
+  ---@class Point
+  ---@field x any
+  ---@field y any
+  Point = {}
+  setmetatable(Point, Point)
+  Point.__index = Point
+  function Point:__call(x, y)
+    return setmetatable({
+      -- Horizontal.
+      x = x,
+      -- vertical
+      y = y
+    }, self)
+  end
+  -- Moves the point.
+  function Point:move(dx)
+    self.x = self.x + dx
+  end
+  local function work()
+    print('work') -- busy
+    -- Cleanup.
+    -- always
+    print('done')
+  end
+  work()
+  local function early(x)
+    if x then
+      -- on return
+      print('bye')
+      return 1
+    end
+    -- on return
+    print('bye')
+    return 2
+  end
+  early(true)
+  -- Trailing.
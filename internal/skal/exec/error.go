@@ -0,0 +1,182 @@
+package exec
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/srcmap"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Error is a runtime error raised by an executed script.
+type Error struct {
+	// The error, positioned at the Skal statement which raised it. Stack holds
+	// the traceback of the script, as Skal positions.
+	Diagnostic sklog.Diagnostic
+}
+
+func (e *Error) Error() string {
+	msg := e.Diagnostic.Message
+	if e.Diagnostic.File != "" {
+		msg = e.Diagnostic.File + ":" + strconv.Itoa(e.Diagnostic.Line) + ":" +
+			strconv.Itoa(e.Diagnostic.ColStart) + ": " + msg
+	}
+
+	if len(e.Diagnostic.Stack) > 0 {
+		msg += "\nstack traceback:\n\t" + strings.Join(e.Diagnostic.Stack, "\n\t")
+	}
+
+	return msg
+}
+
+// The chunk name of the compiled script. Chunks the script loads itself (e.g.
+// with `loadstring`) are named `<string>`, their positions aren't mapped.
+const chunk = "<skal>"
+
+// Matches a position within the compiled script (e.g. `<skal>:12`).
+var chunkPos = regexp.MustCompile(`<skal>:(\d+)`)
+
+// Matches the position of an error loading the compiled script (e.g.
+// `<skal> line:12(column:3)`).
+var loadPos = regexp.MustCompile(`^<skal> line:(\d+)\(column:\d+\)`)
+
+// Matches the name of the fn of a frame (e.g. `in function 'roll'`).
+var frameFn = regexp.MustCompile(`function '([^']+)'`)
+
+// Matches the fn named by an error raised by a host fn called with a bad
+// argument (e.g. `bad argument #1 to floor`).
+var badArg = regexp.MustCompile(`^(bad argument #\d+ to )([\w.]+)`)
+
+// The function wrapping the compiled script (see `tmplHeader`).
+const loadFn = "function '__LOAD__'"
+
+// Converts an error raised by a script to an *Error, rewriting the Lua
+// positions it holds to Skal positions through source map `m`.
+func locate(err error, m *srcmap.Map) error {
+	aerr, ok := err.(*lua.ApiError)
+	if !ok {
+		return err
+	}
+	switch aerr.Type {
+	case lua.ApiErrorSyntax:
+		return locateLoad(aerr, m)
+	case lua.ApiErrorRun:
+	default:
+		return err
+	}
+
+	ev := sklog.NewCompilerEvent(sklog.MsgTypeRuntimeError, sklog.LevelError)
+
+	// The message is prefixed with the position which raised it.
+	msg := aerr.Object.String()
+	at, ok := srcmap.Mapping{}, false
+	line := 0
+	if loc := chunkPos.FindStringSubmatchIndex(msg); loc != nil && loc[0] == 0 {
+		line = atoi(msg[loc[2]:loc[3]])
+		at, ok = m.Lookup(line)
+		msg = strings.TrimPrefix(msg[loc[1]:], ": ")
+	}
+
+	// Traceback
+	var stack []string
+	frames := strings.Split(aerr.StackTrace, "\n")[1:]
+	for i, frame := range frames {
+		frame = strings.TrimSpace(frame)
+
+		// Frames of Go functions hold nothing useful.
+		if frame == "[G]: ?" {
+			continue
+		}
+
+		// Frames of compiler boilerplate are dropped.
+		loc := chunkPos.FindStringSubmatch(frame)
+		if loc != nil {
+			mapping, mapped := m.Lookup(atoi(loc[1]))
+			if !mapped {
+				continue
+			}
+
+			// Errors raised without a position are placed at the innermost frame.
+			if !ok {
+				at, ok, line = mapping, true, atoi(loc[1])
+			}
+		}
+
+		frame = strings.Replace(frame, loadFn, "main chunk", 1)
+		frame = rename(frame, frames[i+1:], m)
+		stack = append(stack, rewrite(frame, m))
+	}
+
+	if ok {
+		ev.WithSourceHint("", at.Source, at.SrcLine, at.SrcCol, at.SrcCol+1)
+	}
+	// The fn is named as it was called, at the line raising the error.
+	msg = badArg.ReplaceAllStringFunc(msg, func(arg string) string {
+		sub := badArg.FindStringSubmatch(arg)
+		return sub[1] + m.Original(line, sub[2])
+	})
+	d := ev.Str(rewrite(msg, m)).Diagnostic()
+	d.Stack = stack
+
+	return &Error{Diagnostic: d}
+}
+
+// Converts an error loading the compiled script (a syntax error the compiler
+// let through) to an *Error, at the Skal statement of the Lua line it was
+// raised at.
+//
+//	<skal> line:12(column:3) near 'end':   syntax error
+func locateLoad(aerr *lua.ApiError, m *srcmap.Map) error {
+	ev := sklog.NewCompilerEvent(sklog.MsgTypeRuntimeError, sklog.LevelError)
+
+	msg := aerr.Object.String()
+	if loc := loadPos.FindStringSubmatchIndex(msg); loc != nil {
+		if at, ok := m.Lookup(atoi(msg[loc[2]:loc[3]])); ok {
+			ev.WithSourceHint("", at.Source, at.SrcLine, at.SrcCol, at.SrcCol+1)
+		}
+		msg = msg[loc[1]:]
+	}
+
+	// Failed to load the compiled script: near 'end': syntax error
+	return &Error{Diagnostic: ev.AddF(
+		"Failed to load the compiled script: {err}",
+		"err", strings.Join(strings.Fields(msg), " "),
+	).Diagnostic()}
+}
+
+// Rewrites the positions within the compiled script held by `s`.
+func rewrite(s string, m *srcmap.Map) string {
+	return chunkPos.ReplaceAllStringFunc(s, func(pos string) string {
+		mapping, ok := m.Lookup(atoi(chunkPos.FindStringSubmatch(pos)[1]))
+		if !ok {
+			return pos
+		}
+
+		return mapping.String()
+	})
+}
+
+// Restores the Skal name of the fn of `frame`, renamed by `--minify` or cached
+// in a local by `-O`. The name is that the fn was called by, so is looked up at the line of the calling
+// frame, the first of `callers`.
+func rename(frame string, callers []string, m *srcmap.Map) string {
+	if len(callers) == 0 {
+		return frame
+	}
+	loc := chunkPos.FindStringSubmatch(callers[0])
+	if loc == nil {
+		return frame
+	}
+
+	return frameFn.ReplaceAllStringFunc(frame, func(fn string) string {
+		name := frameFn.FindStringSubmatch(fn)[1]
+		return "function '" + m.Original(atoi(loc[1]), name) + "'"
+	})
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
@@ -0,0 +1,150 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/illbjorn/skal/internal/skal/srcmap"
+)
+
+// A compiled script: `roll` raises an error when called by the main chunk.
+const script = `local function __LOAD__()
+  local function roll(n)
+    local _math_floor = math.floor
+    return _math_floor(n)
+  end
+  roll(%s)
+end
+__LOAD__()`
+
+// The source map of `script`, its module body mapped to main.sk.
+func scriptMap() *srcmap.Map {
+	m := srcmap.New()
+	m.Add(1, 5, []srcmap.Mapping{
+		{Line: 1, Source: "main.sk", SrcLine: 1, SrcCol: 1},
+		{Line: 3, Source: "main.sk", SrcLine: 2, SrcCol: 10},
+		{Line: 5, Source: "main.sk", SrcLine: 5, SrcCol: 1},
+	})
+	m.AddNames(1, []srcmap.Name{{Line: 1, EndLine: 5, Name: "_math_floor", SrcName: "math.floor"}})
+
+	return m
+}
+
+func run(t *testing.T, lua string, m *srcmap.Map) *Error {
+	t.Helper()
+
+	err := Run(context.Background(), lua, m, io.Discard, 0)
+	rerr := new(Error)
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Run() = %v, want an *Error", err)
+	}
+
+	return rerr
+}
+
+func TestRunError(t *testing.T) {
+	cases := []struct {
+		name      string
+		arg       string
+		line, col int
+		msg       string
+		stack     []string
+	}{
+		{
+			name: "positioned",
+			arg:  "nil .. 'x'",
+			line: 5, col: 1,
+			msg: "cannot perform concat operation between nil and string",
+		},
+		{
+			// Host fns raise without a position, the error is placed at the
+			// innermost frame of the script. The fn is named as it was in Skal.
+			name: "host fn",
+			arg:  "'x'",
+			line: 2, col: 10,
+			msg: "bad argument #1 to math.floor (number expected, got string)",
+			stack: []string{
+				"[G]: in function 'math.floor'",
+				"main.sk:2:10: in function 'roll'",
+				"main.sk:5:1: in main chunk",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := run(t, strings.Replace(script, "%s", c.arg, 1), scriptMap()).Diagnostic
+			if d.File != "main.sk" || d.Line != c.line || d.ColStart != c.col {
+				t.Errorf("at %s:%d:%d, want main.sk:%d:%d", d.File, d.Line, d.ColStart, c.line, c.col)
+			}
+			if d.Message != c.msg {
+				t.Errorf("message = %q, want %q", d.Message, c.msg)
+			}
+			if c.stack != nil && !reflect.DeepEqual(d.Stack, c.stack) {
+				t.Errorf("stack = %q, want %q", d.Stack, c.stack)
+			}
+		})
+	}
+}
+
+func TestRunErrorMinified(t *testing.T) {
+	// `roll` renamed to `a`, the cached `_math_floor` to `b`.
+	lua := `local function __LOAD__()
+local function a(c)local b=math.floor
+return b(c)end
+a('x')
+end __LOAD__()`
+	m := srcmap.New()
+	m.Add(1, 3, []srcmap.Mapping{
+		{Line: 1, Source: "main.sk", SrcLine: 1, SrcCol: 1},
+		{Line: 2, Source: "main.sk", SrcLine: 2, SrcCol: 10},
+		{Line: 3, Source: "main.sk", SrcLine: 5, SrcCol: 1},
+	})
+	m.Names = []srcmap.Name{
+		{Line: 2, EndLine: 4, Name: "a", SrcName: "roll"},
+		{Line: 2, EndLine: 3, Name: "b", SrcName: "math.floor"},
+	}
+
+	d := run(t, lua, m).Diagnostic
+	want := []string{
+		"[G]: in function 'math.floor'",
+		"main.sk:2:10: in function 'roll'",
+		"main.sk:5:1: in main chunk",
+	}
+	if !reflect.DeepEqual(d.Stack, want) {
+		t.Errorf("stack = %q, want %q", d.Stack, want)
+	}
+	if want := "bad argument #1 to math.floor (number expected, got string)"; d.Message != want {
+		t.Errorf("message = %q, want %q", d.Message, want)
+	}
+}
+
+func TestRunLoadError(t *testing.T) {
+	lua := strings.Replace(script, "return _math_floor(n)", "return = n", 1)
+
+	d := run(t, lua, scriptMap()).Diagnostic
+	if d.File != "main.sk" || d.Line != 2 || d.ColStart != 10 {
+		t.Errorf("at %s:%d:%d, want main.sk:2:10", d.File, d.Line, d.ColStart)
+	}
+	if want := "Failed to load the compiled script: near '=': syntax error"; d.Message != want {
+		t.Errorf("message = %q, want %q", d.Message, want)
+	}
+}
+
+func TestRunErrorUnmapped(t *testing.T) {
+	// Positions of chunks loaded by the script aren't mapped.
+	lua := `local f = loadstring("error('inner')")
+f()`
+
+	d := run(t, lua, srcmap.New()).Diagnostic
+	if d.File != "" {
+		t.Errorf("at %s:%d:%d, want no position", d.File, d.Line, d.ColStart)
+	}
+	if want := "<string>:1: inner"; d.Message != want {
+		t.Errorf("message = %q, want %q", d.Message, want)
+	}
+}
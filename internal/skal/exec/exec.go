@@ -1,21 +1,145 @@
 package exec
 
 import (
+	"context"
+	"io"
+	"strings"
+
 	"github.com/illbjorn/skal/internal/skal/exec/stdlib"
-	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/srcmap"
 	lua "github.com/yuin/gopher-lua"
 )
 
-func Exec(f string) {
+// Libs selects the host libraries opened for an executed script, beyond those
+// which can't reach outside of it: the base library (less `dofile` and
+// `loadfile`), `string`, `table`, `math`, `coroutine`, the time fns of `os`
+// (`clock`, `date`, `difftime`, `time`), `io.write` and the Skal `conv`
+// library.
+type Libs uint8
+
+const (
+	LibOS      Libs = 1 << iota // All of `os`: env, files, processes and `os.exit`.
+	LibIO                       // All of `io`, along with `dofile` and `loadfile`.
+	LibDebug                    // `debug`.
+	LibPackage                  // `require` and `package`, loading Lua modules from disk.
+	LibHTTP                     // The Skal `http` library.
+
+	// Every library, the script has all the access of the host process.
+	LibAll = LibOS | LibIO | LibDebug | LibPackage | LibHTTP
+)
+
+// Run runs a compiled Lua script, returning any runtime error. The script may
+// use the libraries `libs` selects. The output of `print` and `io.write` is
+// written to `stdout`, and the script is stopped if `ctx` is canceled.
+//
+// Errors raised by the script, and errors loading it, are returned as an
+// *Error, located in the Skal source through source map `m`.
+func Run(ctx context.Context, f string, m *srcmap.Map, stdout io.Writer, libs Libs) error {
 	// Init Lua VM.
-	l := lua.NewState()
+	l := lua.NewState(lua.Options{SkipOpenLibs: true})
 	defer l.Close()
+	l.SetContext(ctx)
+	open(l, libs)
+
+	// Redirect output.
+	l.SetGlobal("print", l.NewFunction(printer(stdout)))
+	iolib, ok := l.GetGlobal("io").(*lua.LTable)
+	if !ok {
+		iolib = l.NewTable()
+		l.SetGlobal("io", iolib)
+	}
+	iolib.RawSetString("write", l.NewFunction(writer(stdout)))
 
 	// Load Stdlib.
-	stdlib.Load(l)
+	stdlib.Load(l, libs&LibHTTP != 0)
 
 	// Execute script.
-	if err := l.DoString(f); err != nil {
-		sklog.CFatal(err.Error())
+	fn, err := l.Load(strings.NewReader(f), chunk)
+	if err != nil {
+		return locate(err, m)
+	}
+	l.Push(fn)
+
+	return locate(l.PCall(0, lua.MultRet, nil), m)
+}
+
+// Opens the host libraries selected by `libs`.
+func open(l *lua.LState, libs Libs) {
+	type lib struct {
+		name string
+		fn   lua.LGFunction
+		on   bool
+	}
+
+	for _, lib := range []lib{
+		{lua.LoadLibName, lua.OpenPackage, libs&LibPackage != 0},
+		{lua.BaseLibName, lua.OpenBase, true},
+		{lua.TabLibName, lua.OpenTable, true},
+		{lua.IoLibName, lua.OpenIo, libs&LibIO != 0},
+		{lua.OsLibName, lua.OpenOs, true},
+		{lua.StringLibName, lua.OpenString, true},
+		{lua.MathLibName, lua.OpenMath, true},
+		{lua.DebugLibName, lua.OpenDebug, libs&LibDebug != 0},
+		{lua.CoroutineLibName, lua.OpenCoroutine, true},
+	} {
+		if !lib.on {
+			continue
+		}
+		l.Push(l.NewFunction(lib.fn))
+		l.Push(lua.LString(lib.name))
+		l.Call(1, 0)
+	}
+
+	// The base library opens `require` and `module`, which need the package
+	// library.
+	if libs&LibPackage == 0 {
+		l.SetGlobal("require", lua.LNil)
+		l.SetGlobal("module", lua.LNil)
+	}
+	if libs&LibIO == 0 {
+		l.SetGlobal("dofile", lua.LNil)
+		l.SetGlobal("loadfile", lua.LNil)
+	}
+
+	// Only the time fns of `os`.
+	if libs&LibOS == 0 {
+		full := l.GetGlobal(lua.OsLibName).(*lua.LTable)
+		os := l.NewTable()
+		for _, name := range []string{"clock", "date", "difftime", "time"} {
+			os.RawSetString(name, full.RawGetString(name))
+		}
+		l.SetGlobal(lua.OsLibName, os)
+	}
+}
+
+// Replaces `print`: arguments are converted with `tostring`, separated by tabs
+// and followed by a newline.
+func printer(w io.Writer) lua.LGFunction {
+	return func(l *lua.LState) int {
+		out := new(strings.Builder)
+		for i := 1; i <= l.GetTop(); i++ {
+			if i > 1 {
+				out.WriteByte('\t')
+			}
+			out.WriteString(l.ToStringMeta(l.Get(i)).String())
+		}
+		out.WriteByte('\n')
+
+		if _, err := io.WriteString(w, out.String()); err != nil {
+			l.RaiseError("print: %s", err.Error())
+		}
+		return 0
+	}
+}
+
+// Replaces `io.write`: string and number arguments are written as-is.
+func writer(w io.Writer) lua.LGFunction {
+	return func(l *lua.LState) int {
+		for i := 1; i <= l.GetTop(); i++ {
+			if _, err := io.WriteString(w, l.CheckString(i)); err != nil {
+				l.RaiseError("io.write: %s", err.Error())
+			}
+		}
+		return 0
 	}
 }
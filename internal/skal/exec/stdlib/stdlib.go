@@ -1,20 +1,24 @@
 package stdlib
 
 import (
+	_ "embed"
+
+	"github.com/illbjorn/skal/internal/skal/decl"
 	"github.com/illbjorn/skal/internal/skal/exec/stdlib/conv"
 	"github.com/illbjorn/skal/internal/skal/exec/stdlib/http"
 	lua "github.com/yuin/gopher-lua"
 )
 
-type loader func(l *lua.LState)
+//go:embed stdlib.skd
+var stdlibDecl string
 
-var libLoaders = []loader{
-	http.Load,
-	conv.Load,
-}
+// Decls holds the declarations of the runtime libraries loaded by `Load`.
+var Decls = decl.Parse("stdlib.skd", stdlibDecl, nil)
 
-func Load(l *lua.LState) {
-	for _, loader := range libLoaders {
-		loader(l)
+// Load loads the runtime libraries, `http` only if `network` is set.
+func Load(l *lua.LState, network bool) {
+	conv.Load(l)
+	if network {
+		http.Load(l)
 	}
 }
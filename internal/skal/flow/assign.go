@@ -0,0 +1,171 @@
+package flow
+
+import (
+	"sort"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+// The assignment state of `let x;` declarations at a point in the graph.
+type state struct {
+	// Declarations unassigned on at least one path.
+	maybe map[*typeset.Symbol]bool
+	// Declarations unassigned on every path.
+	must map[*typeset.Symbol]bool
+}
+
+func newState() *state {
+	return &state{
+		maybe: make(map[*typeset.Symbol]bool),
+		must:  make(map[*typeset.Symbol]bool),
+	}
+}
+
+func (s *state) copy() *state {
+	c := newState()
+	for sym := range s.maybe {
+		c.maybe[sym] = true
+	}
+	for sym := range s.must {
+		c.must[sym] = true
+	}
+
+	return c
+}
+
+func (s *state) equal(o *state) bool {
+	return sameSet(s.maybe, o.maybe) && sameSet(s.must, o.must)
+}
+
+func sameSet(a, b map[*typeset.Symbol]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for sym := range a {
+		if !b[sym] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Applies the effects of a single item.
+func (s *state) apply(it *item) {
+	for _, sym := range it.decls {
+		s.maybe[sym] = true
+		s.must[sym] = true
+	}
+	for _, sym := range it.writes {
+		delete(s.maybe, sym)
+		delete(s.must, sym)
+	}
+}
+
+// A read of a declaration which is unassigned on some (or every) path.
+type unassigned struct {
+	use
+	always bool
+}
+
+// Finds reads of `let x;` declarations which may not have been assigned.
+func (g *graph) unassigned() []unassigned {
+	// Solve the block entry states.
+	in := make(map[*block]*state)
+	in[g.entry] = newState()
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, b := range g.blocks {
+			if b == g.entry || !b.reachable {
+				continue
+			}
+
+			// Join the exit states of all visited predecessors.
+			var joined *state
+			for _, pred := range b.preds {
+				if in[pred] == nil {
+					continue
+				}
+
+				out := in[pred].copy()
+				for _, it := range pred.items {
+					out.apply(it)
+				}
+
+				if joined == nil {
+					joined = out
+					continue
+				}
+
+				for sym := range out.maybe {
+					joined.maybe[sym] = true
+				}
+				for sym := range joined.must {
+					if !out.must[sym] {
+						delete(joined.must, sym)
+					}
+				}
+			}
+
+			if joined == nil {
+				continue
+			}
+
+			if in[b] == nil || !in[b].equal(joined) {
+				in[b] = joined
+				changed = true
+			}
+		}
+	}
+
+	// Walk each block, checking reads against the state at that point.
+	var found []unassigned
+	for _, b := range g.blocks {
+		if in[b] == nil {
+			continue
+		}
+
+		s := in[b].copy()
+		for _, it := range b.items {
+			for _, u := range it.reads {
+				if s.maybe[u.sym] {
+					found = append(found, unassigned{u, s.must[u.sym]})
+				}
+			}
+
+			s.apply(it)
+		}
+	}
+
+	// Report the first read of each declaration, in source order.
+	sort.SliceStable(found, func(i, j int) bool {
+		return before(found[i].tk, found[j].tk)
+	})
+
+	var out []unassigned
+	seen := make(map[*typeset.Symbol]bool)
+	for _, u := range found {
+		if !seen[u.sym] {
+			seen[u.sym] = true
+			out = append(out, u)
+		}
+	}
+
+	return out
+}
+
+// Indicates whether token `a` appears before token `b`.
+func before(a, b token.Token) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	if a.LineStart() != b.LineStart() {
+		return a.LineStart() < b.LineStart()
+	}
+
+	return a.ColumnStart() < b.ColumnStart()
+}
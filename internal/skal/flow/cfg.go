@@ -0,0 +1,317 @@
+package flow
+
+import (
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+/*------------------------------------------------------------------------------
+ * Graph
+ *----------------------------------------------------------------------------*/
+
+// A control-flow graph over a single fn body (or module top-level).
+type graph struct {
+	entry  *block
+	exit   *block
+	blocks []*block
+	// The block control falls into at the end of the body, without a `return`.
+	end *block
+	// Statement sequences, in source order.
+	seqs [][]*item
+	// `continue` statements outside of any loop.
+	strays []*item
+}
+
+// A basic block: a straight-line run of items, entered only at the top.
+type block struct {
+	items []*item
+	succs []*block
+	preds []*block
+	// Set by `reach`.
+	reachable bool
+}
+
+// A single evaluated item within a block: a statement, or the condition
+// values (or iterables) of a branch.
+type item struct {
+	stmt  *typeset.Statement
+	reads []use
+	// Symbols assigned by the item.
+	writes []*typeset.Symbol
+	// Symbols brought into scope unassigned by the item (`let x;`).
+	decls []*typeset.Symbol
+	block *block
+}
+
+// A read of a symbol.
+type use struct {
+	sym *typeset.Symbol
+	tk  token.Token
+}
+
+func (g *graph) newBlock() *block {
+	b := new(block)
+	g.blocks = append(g.blocks, b)
+	return b
+}
+
+func edge(from, to *block) {
+	from.succs = append(from.succs, to)
+	to.preds = append(to.preds, from)
+}
+
+// Marks all blocks reachable from the entry block.
+func (g *graph) reach() {
+	work := []*block{g.entry}
+	g.entry.reachable = true
+
+	for len(work) > 0 {
+		b := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for _, succ := range b.succs {
+			if !succ.reachable {
+				succ.reachable = true
+				work = append(work, succ)
+			}
+		}
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Builder
+ *----------------------------------------------------------------------------*/
+
+// Builds the control-flow graph of a block of statements. Fns declared within
+// the block are passed to `nested`, they have graphs of their own.
+func build(stmts []*typeset.Statement, nested func(*typeset.Fn)) *graph {
+	g := new(graph)
+	g.entry = g.newBlock()
+	g.exit = g.newBlock()
+
+	b := &builder{g: g, nested: nested}
+	g.end = b.statements(g.entry, stmts)
+	edge(g.end, g.exit)
+
+	g.reach()
+	return g
+}
+
+type builder struct {
+	g      *graph
+	nested func(*typeset.Fn)
+	// Headers of the enclosing loops, innermost last.
+	loops []*block
+}
+
+// Adds a sequence of statements starting in block `cur`, returning the block
+// control reaches at the end of the sequence.
+func (b *builder) statements(cur *block, stmts []*typeset.Statement) *block {
+	var seq []*item
+	for _, stmt := range stmts {
+		var it *item
+		cur, it = b.statement(cur, stmt)
+		seq = append(seq, it)
+	}
+	b.g.seqs = append(b.g.seqs, seq)
+
+	return cur
+}
+
+// Adds a single statement to block `cur`, returning the block control reaches
+// after the statement and the item marking its start.
+func (b *builder) statement(cur *block, stmt *typeset.Statement) (*block, *item) {
+	it := b.add(cur, &item{stmt: stmt})
+
+	switch stmt.StmtType {
+	// 'return'
+	case token.Ret:
+		it.reads = reads(nil, stmt.Values...)
+		edge(cur, b.g.exit)
+
+		// Anything following is unreachable.
+		return b.g.newBlock(), it
+
+	// 'continue'
+	case token.Continue:
+		if len(b.loops) == 0 {
+			b.g.strays = append(b.g.strays, it)
+			return cur, it
+		}
+		edge(cur, b.loops[len(b.loops)-1])
+
+		// Anything following is unreachable.
+		return b.g.newBlock(), it
+
+	// 'if'
+	case token.If:
+		return b.conditional(cur, it, stmt.If), it
+
+	// 'for'
+	case token.For:
+		return b.forLoop(cur, it, stmt.For), it
+
+	// Call
+	case token.Call:
+		it.reads = call(nil, stmt.Call)
+
+	// Bind | Rebind
+	case token.Bind, token.Rebind:
+		bind(it, stmt.Bind)
+
+	// 'fn'
+	case token.Fn:
+		b.nested(stmt.Fn)
+		if sym := stmt.Fn.Symbol(); sym != nil && stmt.Fn.RefsLen() == 1 {
+			it.writes = append(it.writes, sym)
+		}
+
+	// 'defer'
+	// Deferred statements run at fn exit, they're not tracked.
+	case token.Defer:
+		for d := range stmt.Defers() {
+			if d.StmtType == token.Fn {
+				b.nested(d.Fn)
+			}
+		}
+	}
+
+	return cur, it
+}
+
+func (b *builder) add(cur *block, it *item) *item {
+	it.block = cur
+	cur.items = append(cur.items, it)
+	return it
+}
+
+func (b *builder) conditional(cur *block, it *item, nif *typeset.If) *block {
+	join := b.g.newBlock()
+
+	// If
+	it.reads = reads(nil, nif.Conditions...)
+	then := b.g.newBlock()
+	edge(cur, then)
+	edge(b.statements(then, nif.Block), join)
+
+	// Elifs
+	// Each elif's conditions are evaluated when the previous conditions fail.
+	for _, elif := range nif.Elifs {
+		next := b.g.newBlock()
+		edge(cur, next)
+		cur = next
+		b.add(cur, &item{reads: reads(nil, elif.Conditions...)})
+
+		then := b.g.newBlock()
+		edge(cur, then)
+		edge(b.statements(then, elif.Block), join)
+	}
+
+	// Else
+	if nif.Else != nil {
+		nelse := b.g.newBlock()
+		edge(cur, nelse)
+		edge(b.statements(nelse, nif.Else.Block), join)
+	} else {
+		edge(cur, join)
+	}
+
+	return join
+}
+
+func (b *builder) forLoop(cur *block, it *item, nfor *typeset.For) *block {
+	// Iterables are evaluated once, ahead of the loop.
+	for _, iterable := range nfor.Iterables {
+		if sym := iterable.Symbol(); sym != nil {
+			it.reads = append(it.reads, use{sym, iterable.Token()})
+		}
+	}
+
+	// The loop header is where each iteration begins, the body may run any
+	// number of times (including none).
+	head := b.g.newBlock()
+	edge(cur, head)
+
+	body := b.g.newBlock()
+	edge(head, body)
+	b.loops = append(b.loops, head)
+	edge(b.statements(body, nfor.Block), head)
+	b.loops = b.loops[:len(b.loops)-1]
+
+	after := b.g.newBlock()
+	edge(head, after)
+
+	return after
+}
+
+/*------------------------------------------------------------------------------
+ * Reads and Writes
+ *----------------------------------------------------------------------------*/
+
+func bind(it *item, nbind *typeset.Bind) {
+	it.reads = reads(nil, nbind.Values...)
+
+	for _, b := range nbind.Binds {
+		sym := b.Symbol()
+		if sym == nil {
+			continue
+		}
+
+		switch {
+		// Assignment to a field reads the root.
+		// a.b = 1
+		case b.RefsLen() > 1:
+			it.reads = append(it.reads, use{sym, b.Token()})
+
+		// Declaration only.
+		// let x;
+		case !nbind.Rebind && len(nbind.Values) == 0:
+			it.decls = append(it.decls, sym)
+
+		default:
+			it.writes = append(it.writes, sym)
+		}
+	}
+}
+
+// Collects the symbols read by a set of values.
+func reads(out []use, values ...*typeset.Value) []use {
+	for _, v := range values {
+		switch v.ValueType {
+		// Reference
+		case token.Ref:
+			if sym := v.Symbol(); sym != nil {
+				out = append(out, use{sym, v.Token()})
+			}
+
+		// Call
+		case token.Call:
+			out = call(out, v.Call)
+
+		// Value group
+		case token.ValueGroup:
+			out = reads(out, v.Group...)
+
+		// List literal
+		case token.ListL:
+			out = reads(out, v.List...)
+
+		// Anonymous fns are evaluated when called, their reads aren't tracked.
+		case token.Fn:
+		}
+	}
+
+	return out
+}
+
+func call(out []use, c *typeset.Call) []use {
+	if sym := c.Symbol(); sym != nil {
+		out = append(out, use{sym, c.Token()})
+	}
+
+	for _, arg := range c.Args {
+		out = reads(out, arg.Values...)
+	}
+
+	return out
+}
@@ -0,0 +1,197 @@
+// Package flow analyzes the control flow of resolved modules.
+package flow
+
+import (
+	"github.com/illbjorn/fstr"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+// Check builds a control-flow graph for the top-level statements and every fn
+// body of a resolved module, reporting:
+//
+//   - Statements which can never run. Statements directly following a
+//     `return` or `continue` are errors (Lua rejects them), others are
+//     warnings.
+//   - `continue` statements outside of a loop.
+//   - Fns with a declared return type which may reach the end of their body,
+//     or a bare `return`, without returning a value.
+//   - Reads of `let x;` declarations which are unassigned on some path.
+//
+// Problems are reported to `diags`, the number of errors reported is returned.
+func Check(set typeset.TypeSet, diags *sklog.Diagnostics) int {
+	c := &checker{diags: diags}
+	c.graph(topLevel(set), nil)
+
+	for len(c.fns) > 0 {
+		fn := c.fns[0]
+		c.fns = c.fns[1:]
+		c.graph(fn.Block, fn)
+	}
+
+	return c.errors
+}
+
+type checker struct {
+	diags *sklog.Diagnostics
+	// Fns pending analysis.
+	fns    []*typeset.Fn
+	errors int
+}
+
+// Adapts the top-level members of a module into statements.
+func topLevel(set typeset.TypeSet) []*typeset.Statement {
+	var stmts []*typeset.Statement
+	for _, member := range set.Members {
+		switch v := member.Value.(type) {
+		case *typeset.Bind:
+			stmtType := token.Bind
+			if v.Rebind {
+				stmtType = token.Rebind
+			}
+			stmts = append(stmts,
+				&typeset.Statement{SkalType: v, StmtType: stmtType, Bind: v})
+
+		case *typeset.Fn:
+			stmts = append(stmts,
+				&typeset.Statement{SkalType: v, StmtType: token.Fn, Fn: v})
+
+		case *typeset.Call:
+			stmts = append(stmts,
+				&typeset.Statement{SkalType: v, StmtType: token.Call, Call: v})
+
+		case *typeset.If:
+			stmts = append(stmts,
+				&typeset.Statement{SkalType: v, StmtType: token.If, If: v})
+
+		case *typeset.For:
+			stmts = append(stmts,
+				&typeset.Statement{SkalType: v, StmtType: token.For, For: v})
+
+		// Struct methods have their own graphs.
+		case *typeset.Struct:
+			for _, method := range v.Methods {
+				stmts = append(stmts,
+					&typeset.Statement{SkalType: method, StmtType: token.Fn, Fn: method})
+			}
+		}
+	}
+
+	return stmts
+}
+
+// Analyzes a single body, `fn` is nil for module top-level.
+func (c *checker) graph(body []*typeset.Statement, fn *typeset.Fn) {
+	g := build(body, func(nested *typeset.Fn) {
+		c.fns = append(c.fns, nested)
+	})
+
+	c.unreachable(g)
+	c.strays(g)
+	if fn != nil && fn.ReturnType != "" {
+		c.returns(g, fn)
+	}
+	c.assignments(g)
+}
+
+/*------------------------------------------------------------------------------
+ * Checks
+ *----------------------------------------------------------------------------*/
+
+func (c *checker) unreachable(g *graph) {
+	for _, seq := range g.seqs {
+		for i, it := range seq {
+			// Statements starting unreachable sequences are reported with the
+			// sequence enclosing them.
+			if it.block.reachable || i == 0 || !seq[i-1].block.reachable {
+				continue
+			}
+
+			if t := seq[i-1].stmt.StmtType; t == token.Ret || t == token.Continue {
+				c.errorf(sklog.CodeUnreachableAfterRet, it.stmt.Token(),
+					"Unreachable code, statements can't follow '{ret}'.",
+					"ret", t.String())
+			} else {
+				c.warnf(sklog.CodeUnreachable, it.stmt.Token(), "Unreachable code.")
+			}
+			break
+		}
+	}
+}
+
+func (c *checker) strays(g *graph) {
+	for _, it := range g.strays {
+		c.errorf(sklog.CodeStrayContinue, it.stmt.Token(),
+			"'{continue}' outside of a loop.",
+			"continue", token.Continue.String())
+	}
+}
+
+func (c *checker) returns(g *graph, fn *typeset.Fn) {
+	// Bare returns.
+	for _, b := range g.blocks {
+		for _, it := range b.items {
+			if b.reachable && it.stmt != nil &&
+				it.stmt.StmtType == token.Ret && len(it.stmt.Values) == 0 {
+				c.errorf(
+					sklog.CodeMissingReturnValue,
+					it.stmt.Token(),
+					"Fn '{fn}' is declared to return {type}, but returns no value.",
+					"fn", fn.Ref(),
+					"type", fn.ReturnType,
+				)
+			}
+		}
+	}
+
+	// Falling off the end.
+	if g.end.reachable {
+		c.errorf(
+			sklog.CodeMissingReturn,
+			fn.Token(),
+			"Fn '{fn}' is declared to return {type}, but may reach the end of its body without returning.",
+			"fn", fn.Ref(),
+			"type", fn.ReturnType,
+		)
+	}
+}
+
+func (c *checker) assignments(g *graph) {
+	for _, u := range g.unassigned() {
+		if u.always {
+			c.errorf(sklog.CodeUnassigned, u.tk, "'{name}' is read before it is assigned.", "name", u.sym.Name)
+			continue
+		}
+
+		c.warnf(sklog.CodeMaybeUnassigned, u.tk, "'{name}' may be read before it is assigned on some paths.",
+			"name", u.sym.Name)
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Reporting
+ *----------------------------------------------------------------------------*/
+
+func (c *checker) errorf(code string, tk token.Token, msg string, pairs ...string) {
+	c.errors++
+	c.report(code, sklog.MsgTypeFlowError, sklog.LevelError, tk, fstr.Pairs(msg, pairs...))
+}
+
+func (c *checker) warnf(code string, tk token.Token, msg string, pairs ...string) {
+	c.report(code, sklog.MsgTypeFlowWarning, sklog.LevelWarn, tk, fstr.Pairs(msg, pairs...))
+}
+
+func (c *checker) report(code, mtype, level string, tk token.Token, msg string) {
+	ev := sklog.NewCompilerEvent(mtype, level).To(c.diags).WithCode(code)
+	if tk != nil {
+		ev.WithSourceHint(
+			tk.SrcLine(),
+			tk.File(),
+			tk.LineStart(),
+			tk.ColumnStart(),
+			tk.ColumnEnd(),
+		)
+	}
+	ev.Str(msg).Send()
+}
@@ -0,0 +1,98 @@
+package flow_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/illbjorn/skal/internal/skal/flow"
+	"github.com/illbjorn/skal/internal/skal/lex"
+	"github.com/illbjorn/skal/internal/skal/lua"
+	"github.com/illbjorn/skal/internal/skal/parse"
+	"github.com/illbjorn/skal/internal/skal/resolve"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+// A diagnostic expected of a fixture, at `file:line:col`.
+type diag struct {
+	code string
+	pos  string
+}
+
+// Checks each `testdata/<name>.sk` fixture, comparing the diagnostics reported
+// to those expected.
+func TestFixtures(t *testing.T) {
+	fixtures := []struct {
+		name string
+		want []diag
+	}{
+		{
+			// Statements following a 'return' or 'continue' are errors, others
+			// which can't run are warnings.
+			name: "unreachable",
+			want: []diag{
+				{sklog.CodeUnreachableAfterRet, "unreachable.sk:3:3"},
+				{sklog.CodeUnreachableAfterRet, "unreachable.sk:9:5"},
+				{sklog.CodeUnreachable, "unreachable.sk:19:3"},
+				{sklog.CodeStrayContinue, "unreachable.sk:23:3"},
+			},
+		},
+		{
+			// Only fns declaring a return type must return a value. A loop body
+			// may never run.
+			name: "returns",
+			want: []diag{
+				{sklog.CodeMissingReturnValue, "returns.sk:3:5"},
+				{sklog.CodeMissingReturn, "returns.sk:8:4"},
+				{sklog.CodeMissingReturn, "returns.sk:14:4"},
+			},
+		},
+		{
+			name: "unassigned",
+			want: []diag{
+				{sklog.CodeUnassigned, "unassigned.sk:3:9"},
+				{sklog.CodeMaybeUnassigned, "unassigned.sk:11:9"},
+				{sklog.CodeMaybeUnassigned, "unassigned.sk:29:9"},
+			},
+		},
+	}
+
+	for _, fx := range fixtures {
+		t.Run(fx.name, func(t *testing.T) {
+			var got []diag
+			for _, d := range checkFixture(t, fx.name+".sk").List() {
+				got = append(got, diag{d.Code, fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.ColStart)})
+			}
+			if !reflect.DeepEqual(got, fx.want) {
+				t.Errorf("got %v\nwant %v", got, fx.want)
+			}
+		})
+	}
+}
+
+// Resolves and checks a `testdata` module, returning the diagnostics reported.
+func checkFixture(t *testing.T, path string) *sklog.Diagnostics {
+	t.Helper()
+
+	src, err := os.ReadFile(filepath.Join("testdata", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags := sklog.NewDiagnostics()
+	func() {
+		defer func() { diags.Catch(recover()) }()
+		tree := parse.Parse(lex.Lex(path, string(src), diags))
+		set := typeset.Typeset(tree, lua.Stdlib, diags)
+		resolve.Resolve(lua.Stdlib, diags, &resolve.Module{Path: path, Set: set})
+		if diags.Errors() > 0 {
+			t.Fatalf("resolve: %s", diags.Err())
+		}
+		flow.Check(set, diags)
+	}()
+
+	return diags
+}
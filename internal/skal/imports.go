@@ -1,128 +1,280 @@
 package skal
 
 import (
-	"bytes"
-	"errors"
-	"io"
-	"os"
+	"context"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/illbjorn/fstr"
+	"github.com/illbjorn/skal/internal/skal/decl"
+	"github.com/illbjorn/skal/internal/skal/lex"
 	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/parse"
 	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
 )
 
 func getImports(j *job) *job {
-	// Identify the root directory.
-	root := filepath.Dir(j.Main.Path)
+	// Collect the imports breadth-first, from the entrypoint. The files of each
+	// level are lexed and parsed concurrently, their imports then read in order.
+	g := &graph{root: j.Roots[0], files: map[string]*srcFile{j.Main.Path: j.Main}}
+	level := []*srcFile{j.Main}
+	for len(level) > 0 {
+		each(context.Background(), j.Perf.workers(), len(level), j.Diags, func(i int, diags *sklog.Diagnostics) {
+			parseFile(level[i], j, diags)
+		})
 
-	// Start the recursive import collection.
-	return parseImports(root, j.Main.Content, j)
-}
+		var next []*srcFile
+		for _, f := range level {
+			next = append(next, parseImports(g, f, j)...)
+		}
+		level = next
+	}
 
-func parseImports(root string, fileContent string, j *job) *job {
-	// Read first few lines, look for `import` keyword.
-	// If we find a keyword aside from `import`, eject.
-	buf := bytes.NewBufferString(fileContent)
-
-	for {
-		l, err := buf.ReadBytes('\n')
-		if err != nil {
-			// On an EOF, we still have data from the read. Read the last line before
-			// returning.
-			if errors.Is(err, io.EOF) {
-				parseImportGuard(root, l, j)
-				return j
-			}
+	// Order the modules.
+	j.Imports = g.sort(j)
 
-			sklog.CFatalF(
-				"Failed to read next line of main import with error: {err}.",
-				err.Error(),
-			)
-		}
+	return j
+}
 
-		// Strip whitespace.
-		l = bytes.TrimSpace(l)
+// Lexes and parses source file `f`.
+func parseFile(f *srcFile, j *job, diags *sklog.Diagnostics) {
+	var tc *token.Collection
+	j.Perf.measure(PhaseLex, f.Path, func() {
+		tc = lex.Lex(f.Path, f.Content, diags)
+	})
+	j.Perf.measure(PhaseParse, f.Path, func() {
+		f.Tree = parse.Parse(tc)
+	})
+	j.Perf.count(f.Path, tc.Len(), f.Tree)
+}
+
+// Collects the imports of parsed source file `from`, producing the modules it
+// imports which are read for the first time.
+func parseImports(g *graph, from *srcFile, j *job) []*srcFile {
+	// A file which failed to parse has been reported.
+	if from.Tree == nil {
+		return nil
+	}
 
-		// Ignore comment and blank lines.
-		if bytes.HasPrefix(l, []byte(token.Comment.String())) || len(l) == 0 {
+	var read []*srcFile
+	for _, imp := range typeset.Imports(from.Tree) {
+		var (
+			importPath string
+			ok         bool
+		)
+		j.Perf.measure(PhaseImports, from.Path, func() {
+			importPath, ok = getImportPath(imp, from, j)
+		})
+		if !ok {
 			continue
 		}
 
-		// If it's not an import line, don't bother reading the rest of the File.
-		if !bytes.HasPrefix(l, []byte(token.Import.String())) {
-			return j
+		if imported := parseImport(g, importPath, imp, from, j); imported != nil {
+			read = append(read, imported)
 		}
-
-		// Parse the import line.
-		parseImportGuard(root, l, j)
 	}
-}
-
-func parseImportGuard(root string, l []byte, j *job) *job {
-	// Strip whitespace.
-	l = bytes.TrimSpace(l)
 
-	// Ignore comment and blank lines.
-	if bytes.HasPrefix(l, []byte(token.Comment.String())) || len(l) == 0 {
-		return j
-	}
+	return read
+}
 
-	// If it's not an import line, don't bother reading the rest of the File.
-	if !bytes.HasPrefix(l, []byte(token.Import.String())) {
-		return j
+// Records import `imp` of `from`, reading the imported module if it hasn't
+// been read yet.
+func parseImport(g *graph, importPath string, imp *typeset.Import, from *srcFile, j *job) *srcFile {
+	// Declaration files only contribute extern declarations, they produce no
+	// code.
+	if filepath.Ext(importPath) == decl.Ext {
+		if !imp.Whole() {
+			importError(
+				j,
+				imp.Token(),
+				"Declaration file '{path}' can't be imported with an alias or selectively.",
+				"path", imp.Path,
+			)
+		}
+		j.Decls.Merge(decl.Load(j.FS, importPath, j.Diags))
+		return nil
 	}
 
-	return parseImport(root, getImportPath(root, l), j)
-}
+	// Record the import boundary.
+	imp.Module = importPath
+	from.Imports = append(from.Imports, imp)
 
-func parseImport(root, importPath string, j *job) *job {
-	// Skip duplicate imports.
-	for _, i := range j.Imports {
-		if i.Path == importPath {
-			return j
-		}
+	// Each module is read once, however many files import it.
+	if g.files[importPath] != nil {
+		return nil
 	}
 
 	// Read the imported module.
-	c, err := os.ReadFile(importPath)
+	var (
+		c   []byte
+		err error
+	)
+	j.Perf.measure(PhaseImports, importPath, func() {
+		c, err = j.FS.ReadFile(importPath)
+	})
 	if err != nil {
-		sklog.CFatalF(
-			"Failed to read imported module: {path} with error: {err}.",
+		importError(
+			j,
+			imp.Token(),
+			"Failed to read module {path} imported by {from} with error: {err}.",
 			"path", importPath,
+			"from", from.Path,
 			"err", err.Error(),
 		)
+		return nil
 	}
 
-	// Recursively Evaluate for Imports.
-	parseImports(root, string(c), j)
+	imported := &srcFile{Path: importPath, Content: string(c), Import: true}
+	g.files[importPath] = imported
 
-	// Append the import.
-	j.Imports = append(
-		j.Imports,
-		&srcFile{Path: importPath, Content: string(c), Import: true})
+	return imported
+}
 
-	return j
+/*------------------------------------------------------------------------------
+ * Graph
+ *----------------------------------------------------------------------------*/
+
+// The module dependency graph of a job: the source files by path, each with
+// its import edges (`srcFile.Imports`).
+type graph struct {
+	root  string
+	files map[string]*srcFile
+}
+
+// Module states of the topological sort.
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+// Orders the imported modules topologically: depth-first from the entrypoint,
+// following imports in source order, each module after the modules it imports.
+// The order depends only on the source, modules are initialized in it.
+//
+// Each import cycle found is reported.
+func (g *graph) sort(j *job) []*srcFile {
+	var (
+		order []*srcFile
+		state = make(map[string]int, len(g.files))
+		// The import edges leading from the entrypoint to the current module.
+		path []*typeset.Import
+	)
+
+	var visit func(f *srcFile)
+	visit = func(f *srcFile) {
+		state[f.Path] = visiting
+		for _, imp := range f.Imports {
+			path = append(path, imp)
+			switch state[imp.Module] {
+			case unvisited:
+				if next := g.files[imp.Module]; next != nil {
+					visit(next)
+				}
+			case visiting:
+				g.cycle(path, j)
+			}
+			path = path[:len(path)-1]
+		}
+		state[f.Path] = visited
+
+		if f != j.Main {
+			order = append(order, f)
+		}
+	}
+	visit(j.Main)
+
+	return order
 }
 
-var pSQImport = regexp.MustCompile("(?:'(.+?)')")
-var pDQImport = regexp.MustCompile("(?:\"(.+?)\")")
+// Reports the import cycle closed by the last edge of `path`, at the import
+// line of the first edge of the cycle, with those of the others related.
+func (g *graph) cycle(path []*typeset.Import, j *job) {
+	// The cycle starts at the module the last edge leads back to.
+	last := path[len(path)-1]
+	start := len(path) - 1
+	for start > 0 && path[start-1].Module != last.Module {
+		start--
+	}
+	edges := path[start:]
 
-func getImportPath(root string, l []byte) string {
-	// Parse import module name inside quotes.
-	var imp []byte
-	if pSQImport.Match(l) {
-		imp = pSQImport.FindSubmatch(l)[1]
+	// a.sk -> b.sk -> a.sk
+	chain := []string{g.rel(last.Module)}
+	for _, imp := range edges {
+		chain = append(chain, g.rel(imp.Module))
 	}
 
-	if pDQImport.Match(l) {
-		imp = pDQImport.FindSubmatch(l)[1]
+	first := edges[0].Token()
+	ev := sklog.NewCompilerEvent(sklog.MsgTypeCompilerError, sklog.LevelFatal).
+		To(j.Diags).
+		WithCode(sklog.CodeImportCycle).
+		WithSourceHint(first.SrcLine(), first.File(), first.LineStart(), first.ColumnStart(), first.ColumnEnd())
+	for _, imp := range edges[1:] {
+		tk := imp.Token()
+		ev.WithRelated(
+			fstr.Pairs(
+				"{from} imports {to}",
+				"from", g.rel(tk.File()),
+				"to", g.rel(imp.Module),
+			),
+			tk.SrcLine(), tk.File(), tk.LineStart(), tk.ColumnStart(), tk.ColumnEnd(),
+		)
 	}
 
+	ev.AddF(
+		"Import cycle: {chain}.",
+		"chain", strings.Join(chain, " -> "),
+	).
+		Send()
+}
+
+// Produces the path of a module relative to the root directory.
+func (g *graph) rel(path string) string {
+	if rel, err := filepath.Rel(g.root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+
+	return path
+}
+
+// Reports an import which can't be satisfied, at the import `tk`.
+func importError(j *job, tk token.Token, msg string, pairs ...string) {
+	ev := sklog.NewCompilerEvent(sklog.MsgTypeCompilerError, sklog.LevelFatal).
+		To(j.Diags).
+		WithCode(sklog.CodeReadFailed)
+	if tk != nil {
+		ev.WithSourceHint(tk.SrcLine(), tk.File(), tk.LineStart(), tk.ColumnStart(), tk.ColumnEnd())
+	}
+
+	ev.AddF(msg, pairs...).
+		Send()
+}
+
+// Resolves the path of import `imp`, trying each search root of the job in
+// order.
+func getImportPath(imp *typeset.Import, from *srcFile, j *job) (string, bool) {
+	for _, root := range j.Roots {
+		if path, ok := findImport(root, imp.Path, j); ok {
+			return path, true
+		}
+	}
+
+	importError(
+		j,
+		imp.Token(),
+		"Failed to identify a valid import for: '{path}' in {from}, searched: {roots}.",
+		"path", imp.Path,
+		"from", from.Path,
+		"roots", strings.Join(j.Roots, ", "),
+	)
+	return "", false
+}
+
+// Looks for the module at import path `path` in search root `root`.
+func findImport(root, path string, j *job) (string, bool) {
 	// Split the path and rejoin (cross-OS File pathing support /\).
-	paths := strings.Split(string(imp), "/")
+	paths := strings.Split(path, "/")
 
 	// Prepend the root path.
 	paths = append([]string{root}, paths...)
@@ -130,30 +282,32 @@ func getImportPath(root string, l []byte) string {
 	// Rejoin on OS sep, this will serve as the checked directory path.
 	dpath := filepath.Join(paths...)
 
+	// Declaration files are imported by their full file name.
+	if filepath.Ext(dpath) == decl.Ext {
+		fstat, err := j.FS.Stat(dpath)
+		return dpath, err == nil && !fstat.IsDir()
+	}
+
 	// Append the `.sk` extension, this will serve as the checked File path.
 	fpath := dpath + ".sk"
 
 	// Identify if this path points to a directory or a File.
 	// If it's a File, simply process the File.
-	fstat, err := os.Stat(fpath)
+	fstat, err := j.FS.Stat(fpath)
 	if err == nil && !fstat.IsDir() {
-		return fpath
+		return fpath, true
 	}
 
 	// If it's a directory, look for a `Mod.sk` File in that directory.
-	dirstat, err := os.Stat(dpath)
+	dirstat, err := j.FS.Stat(dpath)
 	if err == nil && dirstat.IsDir() {
 		// Look for the `Mod.sk` File.
 		path := filepath.Join(dpath, "Mod.sk")
-		if fstat, err = os.Stat(path); err == nil && !fstat.IsDir() {
+		if fstat, err = j.FS.Stat(path); err == nil && !fstat.IsDir() {
 			// The `Mod.sk` File exists.
-			return path
+			return path, true
 		}
 	}
 
-	e := fstr.Pairs(
-		"Failed to identify a valid import for: '{path}'.",
-		"path", string(imp),
-	)
-	panic(e)
+	return "", false
 }
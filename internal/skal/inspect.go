@@ -0,0 +1,112 @@
+package skal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/inspect"
+	"github.com/illbjorn/skal/internal/skal/lex"
+	"github.com/illbjorn/skal/internal/skal/parse"
+	"github.com/illbjorn/skal/internal/skal/resolve"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/vfs"
+)
+
+// Inspect runs the pipeline over the Skal source file at `inputPath` up to
+// stage `stage` (see `inspect.Stages`), producing the result of the stage:
+//
+//   - tokens  : The tokens lexed from the file.
+//   - ast     : The parse tree of the file.
+//   - typeset : The typeset of the file.
+//   - symbols : The names of the file, each with the symbol it resolved to.
+//     Imports are resolved with it.
+//
+// If any errors are reported, an error listing every diagnostic is returned.
+// Names which fail to resolve don't stop the symbols stage: its result is
+// returned with the error, the names left unresolved.
+func Inspect(inputPath, stage string, opts Options) (tree *inspect.Tree, err error) {
+	if !slices.Contains(inspect.Stages, stage) {
+		return nil, fmt.Errorf(
+			"unknown stage '%s', expected one of: %s",
+			stage, strings.Join(inspect.Stages, ", "))
+	}
+
+	diags := opts.diagnostics()
+	resolved := false
+	defer func() {
+		if diags.Catch(recover()) || err == nil {
+			err = diags.Err()
+		}
+		if err != nil && !resolved {
+			tree = nil
+		}
+	}()
+
+	// Lex (-> Parse)
+	if stage == "tokens" || stage == "ast" {
+		src, err := readSource(inputPath, opts)
+		if err != nil {
+			return nil, err
+		}
+		tc := lex.Lex(inputPath, src, diags)
+		if stage == "tokens" {
+			return inspect.Tokens(inputPath, tc), nil
+		}
+		return inspect.AST(inputPath, parse.Parse(tc)), nil
+	}
+
+	// Lex -> Parse -> Typeset (-> Resolve)
+	j := newJob(inputPath, opts, diags)
+	if diags.Errors() > 0 {
+		return nil, nil
+	}
+	files := j.Files()
+	mods := make([]*resolve.Module, len(files))
+	for i, f := range files {
+		mods[i] = newModule(f, typesetFile(f, j.Decls, diags))
+	}
+	main := mods[len(mods)-1]
+	if stage == "typeset" {
+		return inspect.Typeset(inputPath, main.Set), nil
+	}
+
+	if diags.Errors() > 0 {
+		return nil, nil
+	}
+	resolve.Resolve(j.Decls, diags, mods...)
+	resolved = true
+
+	return inspect.Symbols(inputPath, main.Set), nil
+}
+
+// DumpAST writes the parse tree of the Skal source file at `inputPath` to
+// `path` as JSON (see `parse.Node.Serialize`).
+func DumpAST(inputPath, path string, opts Options) (err error) {
+	src, err := readSource(inputPath, opts)
+	if err != nil {
+		return err
+	}
+
+	diags := sklog.NewDiagnostics()
+	defer func() {
+		if diags.Catch(recover()) {
+			err = diags.Err()
+		}
+	}()
+	root := parse.Parse(lex.Lex(inputPath, src, diags))
+	if err := diags.Err(); err != nil {
+		return err
+	}
+
+	return root.Serialize(path)
+}
+
+func readSource(inputPath string, opts Options) (string, error) {
+	b, err := vfs.New(opts.FS).ReadFile(inputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input file '%s': %w", inputPath, err)
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,248 @@
+package inspect
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/decl"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/parse"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+/*------------------------------------------------------------------------------
+ * Tokens
+ *----------------------------------------------------------------------------*/
+
+// Tokens produces the tokens lexed from source file `path`, in source order.
+func Tokens(path string, tc *token.Collection) *Tree {
+	root := &Tree{Kind: "tokens", File: path}
+	for i := range tc.Len() {
+		tk := tc.At(i)
+		root.Children = append(root.Children, (&Tree{Kind: tk.Type().String(), Value: tk.Value()}).at(tk))
+	}
+
+	return root
+}
+
+/*------------------------------------------------------------------------------
+ * AST
+ *----------------------------------------------------------------------------*/
+
+// AST produces the parse tree of source file `path`.
+func AST(path string, root *parse.Node) *Tree {
+	t := ast(root)
+	t.Kind, t.File = "ast", path
+
+	return t
+}
+
+func ast(n *parse.Node) *Tree {
+	// Nodes grouping their children have neither a type nor a token.
+	kind := "node"
+	if n.Type != 0 || n.Token != nil {
+		kind = n.Type.String()
+	}
+
+	t := (&Tree{Kind: kind, Value: n.Value}).at(n.Token)
+	for _, child := range n.Children {
+		t.Children = append(t.Children, ast(child))
+	}
+
+	return t
+}
+
+/*------------------------------------------------------------------------------
+ * Typeset
+ *----------------------------------------------------------------------------*/
+
+var (
+	skalType   = reflect.TypeFor[typeset.SkalType]()
+	tokenType  = reflect.TypeFor[token.Type]()
+	declSymbol = reflect.TypeFor[*decl.Symbol]()
+)
+
+// Typeset produces the typeset of source file `path`. Each typeset value is a
+// node of its Go type, valued by its ref. Scalar fields are attributes, and
+// typeset values held by fields are children.
+func Typeset(path string, set typeset.TypeSet) *Tree {
+	root := &Tree{Kind: "typeset", File: path}
+	for _, m := range set.Members {
+		if t := typesetValue("", reflect.ValueOf(m.Value)); t != nil {
+			root.Children = append(root.Children, t)
+		}
+	}
+
+	return root
+}
+
+func typesetValue(field string, v reflect.Value) *Tree {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+	} else if v.CanAddr() {
+		v = v.Addr()
+	}
+
+	t := &Tree{Field: field, Kind: reflect.Indirect(v).Type().Name()}
+	if st, ok := v.Interface().(typeset.SkalType); ok {
+		t.Value = st.Ref()
+		t.at(st.Token())
+		if st.Pub() {
+			t.attr("pub", "true")
+		}
+		if st.Type() != token.Undefined {
+			t.attr("type", st.Type().String())
+		}
+		if sym := st.Symbol(); sym != nil {
+			t.attr("symbol", sym.Kind.String())
+		}
+	}
+
+	s := reflect.Indirect(v)
+	if s.Kind() != reflect.Struct {
+		return t
+	}
+	for i := range s.NumField() {
+		f, fv := s.Type().Field(i), s.Field(i)
+		if f.Anonymous || !f.IsExported() {
+			continue
+		}
+		typesetField(t, f.Name, fv)
+	}
+
+	return t
+}
+
+// Records field `name` of value `v` on its node `t`.
+func typesetField(t *Tree, name string, v reflect.Value) {
+	key := snake(name)
+
+	switch {
+	// Host declaration
+	case v.Type() == declSymbol:
+		if !v.IsNil() {
+			t.attr(key, v.Interface().(*decl.Symbol).Name)
+		}
+
+	case v.Type() == tokenType:
+		if tt := v.Interface().(token.Type); tt != token.Undefined {
+			t.attr(key, tt.String())
+		}
+
+	case v.Kind() == reflect.String:
+		if v.String() != "" {
+			t.attr(key, v.String())
+		}
+
+	case v.Kind() == reflect.Bool:
+		if v.Bool() {
+			t.attr(key, "true")
+		}
+
+	// Typeset values
+	case v.Kind() == reflect.Pointer:
+		if child := typesetValue(name, v); child != nil {
+			t.Children = append(t.Children, child)
+		}
+
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		if v.Len() > 0 {
+			t.attr(key, strings.Join(v.Interface().([]string), "."))
+		}
+
+	case v.Kind() == reflect.Slice:
+		for i := range v.Len() {
+			if child := typesetValue(name, v.Index(i)); child != nil {
+				t.Children = append(t.Children, child)
+			}
+		}
+	}
+}
+
+// Produces the snake case of field name `name` (e.g. `ReturnType` ->
+// `return_type`).
+func snake(name string) string {
+	b := new(strings.Builder)
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 && !(name[i-1] >= 'A' && name[i-1] <= 'Z') {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+/*------------------------------------------------------------------------------
+ * Symbols
+ *----------------------------------------------------------------------------*/
+
+// Symbols produces each name of the resolved typeset of source file `path`,
+// with the symbol it resolved to, in source order.
+func Symbols(path string, set typeset.TypeSet) *Tree {
+	seen := make(map[typeset.SkalType]bool)
+	var refs []typeset.SkalType
+	for _, m := range set.Members {
+		walk(reflect.ValueOf(m.Value), func(st typeset.SkalType) {
+			if st.Symbol() != nil && st.Token() != nil && !seen[st] {
+				seen[st] = true
+				refs = append(refs, st)
+			}
+		})
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].Token().Start().Abs < refs[j].Token().Start().Abs
+	})
+
+	root := &Tree{Kind: "symbols", File: path}
+	for _, st := range refs {
+		sym := st.Symbol()
+		t := (&Tree{Kind: sym.Kind.String(), Value: st.Ref()}).at(st.Token())
+		t.attr("name", sym.Name)
+		if sym.Pub {
+			t.attr("pub", "true")
+		}
+		switch {
+		case sym.Token != nil:
+			t.attr("declared", sym.File+":"+pos(sym.Token.Start()))
+		case sym.Extern != nil && sym.Extern.File != "":
+			t.attr("declared", sym.Extern.File)
+		}
+		root.Children = append(root.Children, t)
+	}
+
+	return root
+}
+
+// Calls `fn` with each typeset value reachable from `v`, parents first.
+func walk(v reflect.Value, fn func(typeset.SkalType)) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		// Host declarations link back to their parents.
+		if v.IsNil() || v.Type() == declSymbol {
+			return
+		}
+		if v.Type().Implements(skalType) {
+			fn(v.Interface().(typeset.SkalType))
+		}
+		walk(v.Elem(), fn)
+
+	case reflect.Slice:
+		for i := range v.Len() {
+			walk(v.Index(i), fn)
+		}
+
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if f := v.Type().Field(i); f.IsExported() && !f.Anonymous {
+				walk(v.Field(i), fn)
+			}
+		}
+	}
+}
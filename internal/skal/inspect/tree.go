@@ -0,0 +1,122 @@
+// Package inspect renders the intermediate results of the compiler pipeline
+// (tokens, AST, typeset and resolved symbols) for `skal inspect`.
+//
+// Each stage is rendered as a Tree, printed as JSON or as an indented tree:
+//
+//	typeset
+//	  Fn "add" 1:1-1:3 pub=true
+//	    Args: FnArg "a" 1:8-1:9
+//	    Args: FnArg "b" 1:11-1:12
+//	    Block: Statement "c" 2:3-2:6 stmt_type=let
+package inspect
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+)
+
+// Stages lists the pipeline stages which can be inspected.
+var Stages = []string{"tokens", "ast", "typeset", "symbols"}
+
+// Tree is a node of an inspected stage.
+type Tree struct {
+	// The field of the parent the node is held by, if it's significant.
+	Field string `json:"field,omitempty"`
+	// What the node is: a token type, an AST node type, a typeset type or a
+	// symbol kind.
+	Kind  string `json:"kind"`
+	Value string `json:"value,omitempty"`
+	File  string `json:"file,omitempty"`
+	// Source range of the node's token, if it has one.
+	Start    *token.Position   `json:"start,omitempty"`
+	End      *token.Position   `json:"end,omitempty"`
+	Attrs    map[string]string `json:"attrs,omitempty"`
+	Children []*Tree           `json:"children,omitempty"`
+}
+
+// Positions the node at token `tk`.
+func (t *Tree) at(tk token.Token) *Tree {
+	if tk == nil {
+		return t
+	}
+
+	start, end := tk.Start(), tk.End()
+	t.Start, t.End = &start, &end
+
+	return t
+}
+
+func (t *Tree) attr(k, v string) {
+	if t.Attrs == nil {
+		t.Attrs = make(map[string]string)
+	}
+
+	t.Attrs[k] = v
+}
+
+/*------------------------------------------------------------------------------
+ * Rendering
+ *----------------------------------------------------------------------------*/
+
+// JSON writes the tree to `w` as indented JSON.
+func (t *Tree) JSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	return enc.Encode(t)
+}
+
+// Text writes the tree to `w`, a node per line indented beneath its parent:
+//
+//	[field: ]kind ["value"] [line:col-line:col] [attr=value ...]
+//
+// Attribute values are quoted if they hold spaces.
+func (t *Tree) Text(w io.Writer) error {
+	b := new(strings.Builder)
+	t.text(b, 0)
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func (t *Tree) text(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	if t.Field != "" {
+		b.WriteString(t.Field + ": ")
+	}
+	b.WriteString(t.Kind)
+	if t.Value != "" {
+		b.WriteString(" " + strconv.Quote(t.Value))
+	}
+	if t.Start != nil {
+		b.WriteString(" " + pos(*t.Start) + "-" + pos(*t.End))
+	}
+
+	keys := make([]string, 0, len(t.Attrs))
+	for k := range t.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := t.Attrs[k]
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(" " + k + "=" + v)
+	}
+	b.WriteByte('\n')
+
+	for _, child := range t.Children {
+		child.text(b, depth+1)
+	}
+}
+
+func pos(p token.Position) string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Col)
+}
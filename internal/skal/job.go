@@ -1,8 +1,21 @@
 package skal
 
+import (
+	"github.com/illbjorn/skal/internal/skal/decl"
+	"github.com/illbjorn/skal/internal/skal/parse"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/target"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+	"github.com/illbjorn/skal/internal/skal/vfs"
+)
+
 type srcFile struct {
 	Path    string
 	Content string
+	// The parsed file, once its imports are collected.
+	Tree *parse.Node
+	// The modules directly imported by this file.
+	Imports []*typeset.Import
 	Import  bool
 }
 
@@ -10,21 +23,36 @@ type job struct {
 	Main       *srcFile
 	OutputPath string
 	Imports    []*srcFile
+	Decls      *decl.Set
+	Diags      *sklog.Diagnostics
+	// The file system source files and imports are read from.
+	FS vfs.FS
+	// The module search roots imports are resolved against, in order: the
+	// directory of the entrypoint, then `Options.Paths`.
+	Roots []string
+	// Per-module output of previous compilations, nil if disabled.
+	Cache *cache
+	// The Lua runtime the output targets.
+	Target target.Target
+	// Modules are compiled to separate Lua files, rather than bundled.
+	Split bool
+	// The output is a library, returning the `pub` symbols of the entrypoint
+	// rather than running as an app.
+	Lib bool
+	// Modules are optimized before they're emitted (see `opt.Optimize`).
+	Optimize bool
+	// The output is minified once bundled (see `minify.Minify`).
+	Minify bool
+	// Source comments are kept in the output.
+	Comments bool
+	// Receives the performance measurements of the compilation, nil if
+	// disabled.
+	Perf *Perf
 }
 
-func (j *job) Gen() chan *srcFile {
-	ch := make(chan *srcFile)
-
-	go func() {
-		defer close(ch)
-		// Send the imports first.
-		for _, f := range j.Imports {
-			ch <- f
-		}
-
-		// Finish with the main entrypoint.
-		ch <- j.Main
-	}()
-
-	return ch
+// Files lists the source files of the job in dependency order: imports are
+// sorted topologically (see `graph.sort`), so each module follows the modules
+// it imports, and the main entrypoint is last.
+func (j *job) Files() []*srcFile {
+	return append(append([]*srcFile{}, j.Imports...), j.Main)
 }
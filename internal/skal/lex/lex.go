@@ -1,401 +1,266 @@
 package lex
 
 import (
+	"strings"
+
 	"github.com/illbjorn/skal/internal/skal/lex/token"
 	"github.com/illbjorn/skal/internal/skal/sklog"
 )
 
-func Lex(path, in string) *token.Collection {
-	tc := token.NewCollection(path, in)
-	l := newLexer(path, in, tc)
-	ch := tc.InputStream()
-
-	newToken := func() token.Token {
-		token := token.NewToken(tc)
-
-		token.SetFile(l.file)
-		token.SetSrcStart(l.pos)
-		token.SetColumnStart(l.col)
-		token.SetLineStart(l.line)
-
-		return token
-	}
-
-	sendToken := func(token token.Token) {
-		token.SetSrcEnd(l.pos)
-		token.SetColumnEnd(l.col)
-		token.SetLineEnd(l.line)
-
-		ch <- token
-	}
+// Lex tokenizes a source file. Problems are reported to `diags`, the offending
+// input is skipped.
+func Lex(path, in string, diags *sklog.Diagnostics) *token.Collection {
+	tc := token.NewCollection(path, in, diags)
+	l := newLexer(path, in, tc, diags)
 
 	for {
 		c := l.LA()
 		switch {
 		// StrL
 		case c == '\'' || c == '"':
-			tk := newToken()
-			tk.SetType(token.StrL)
-			tk = eatString(tk, l)
-			sendToken(tk)
+			tk := l.newToken(token.StrL)
+			eatString(&tk, l)
+			l.push(tk)
 
 		// IntL
 		case isNum(c):
-			tk := newToken()
-			tk.SetType(token.IntL)
-			tk = eatNum(tk, l)
-			sendToken(tk)
+			tk := l.newToken(token.IntL)
+			eatNum(l)
+			l.push(tk)
 
 		// Keyword | ID
 		case isAlpha(c):
-			tk := newToken()
-			tk = eatWord(tk, l)
-			tk.SetType(classifyKeyword(tk.Value()))
-
-			// Discard import lines.
-			if tk.Type() == token.Import {
-				eatImport(l)
-				break
-			}
-			sendToken(tk)
+			tk := l.newToken(token.Undefined)
+			eatWord(l)
+			tk._type = classifyKeyword(l.text(tk))
+			l.push(tk)
 
 		// Symbol
 		case isSymbol(c):
-			tk := newToken()
-			tk = eatSymbol(tk, l)
+			tk := l.newToken(token.Undefined)
 
-			// Nil == consumed line comment.
-			if tk == nil {
-				break
+			// False == consumed line comment (or unknown symbol).
+			if eatSymbol(&tk, l) {
+				l.push(tk)
 			}
-			sendToken(tk)
 
-		// Discard whitespace.
-		case c == ' ', c == '\t', c == '\n':
+		// Discard whitespace. A '\r' ends a line with the '\n' following it.
+		case c == ' ', c == '\t', c == '\n', c == '\r':
 			l.Adv()
 
 		// Break when we hit the EOF.
 		case c == rEOF:
-			// Wait for the input stream to empty its queue.
-			close(ch)
-			tc.Wait()
+			l.endTrivia()
 			return tc
 
 		default:
-			sklog.CFatalF(
-				"Fell through lexer switch statement with rune: '{rune}'.",
-				"rune", string(c),
-			)
+			col := l.col
+			l.Adv()
+			l.error(sklog.CodeUnexpectedChar, l.line, col,
+				"Found unexpected character: '{rune}'.", "rune", string(c))
 		}
 	}
 }
 
-func eatWord(tk token.Token, l *lexer) token.Token {
-	for {
-		if l.LA() == rEOF || !isAlphaNum(l.LA()) {
-			return tk
-		}
-
-		tk.AddRune(l.Adv())
+func eatWord(l *lexer) {
+	for isAlphaNum(l.LA()) {
+		l.Adv()
 	}
 }
 
+// The keywords, by their text.
+var keywords = map[string]token.Type{
+	token.New.String():      token.New,
+	token.Pub.String():      token.Pub,
+	token.Let.String():      token.Let,
+	token.In.String():       token.In,
+	token.For.String():      token.For,
+	token.If.String():       token.If,
+	token.Elif.String():     token.Elif,
+	token.Else.String():     token.Else,
+	token.Ret.String():      token.Ret,
+	token.Continue.String(): token.Continue,
+	token.This.String():     token.This,
+	token.Fn.String():       token.Fn,
+	token.Enum.String():     token.Enum,
+	token.Struct.String():   token.Struct,
+	token.True.String():     token.True,
+	token.False.String():    token.False,
+	token.Import.String():   token.Import,
+	token.Defer.String():    token.Defer,
+	token.Extern.String():   token.Extern,
+	token.As.String():       token.As,
+	token.Nil.String():      token.Nil,
+	token.Int.String():      token.Int,
+	token.Bool.String():     token.Bool,
+	token.Str.String():      token.Str,
+}
+
 func classifyKeyword(s string) token.Type {
-	switch s {
-	case token.New.String():
-		return token.New
-	case token.New.String():
-		return token.New
-	case token.Pub.String():
-		return token.Pub
-	case token.Let.String():
-		return token.Let
-	case token.In.String():
-		return token.In
-	case token.For.String():
-		return token.For
-	case token.If.String():
-		return token.If
-	case token.Elif.String():
-		return token.Elif
-	case token.Else.String():
-		return token.Else
-	case token.Ret.String():
-		return token.Ret
-	case token.This.String():
-		return token.This
-	case token.Fn.String():
-		return token.Fn
-	case token.Enum.String():
-		return token.Enum
-	case token.Struct.String():
-		return token.Struct
-	case token.True.String():
-		return token.True
-	case token.False.String():
-		return token.False
-	case token.Import.String():
-		return token.Import
-	case token.Defer.String():
-		return token.Defer
-	case token.Extern.String():
-		return token.Extern
-	case token.As.String():
-		return token.As
-	case token.Nil.String():
-		return token.Nil
-	case token.Int.String():
-		return token.Int
-	case token.Bool.String():
-		return token.Bool
-	case token.Str.String():
-		return token.Str
-	// ID
-	default:
-		return token.ID
+	if t, ok := keywords[s]; ok {
+		return t
 	}
+
+	// ID
+	return token.ID
 }
 
-func eatNum(tk token.Token, l *lexer) token.Token {
+func eatNum(l *lexer) {
 	// Consume the first number in the series.
-	tk.AddRune(l.Adv())
+	l.Adv()
 
 	// Consume numbers until we reach the end.
 	for isNum(l.LA()) || (isNum(l.Cur()) && l.LA() == '.') {
-		tk.AddRune(l.Adv())
+		l.Adv()
 	}
-
-	return tk
 }
 
-func eatString(tk token.Token, l *lexer) token.Token {
+func eatString(tk *lexeme, l *lexer) {
 	// Consume the left quote.
 	term := l.Adv()
 
 	for l.LA() != term {
-		c := l.Adv()
-		tk.AddRune(c)
-
-		if c == rEOF {
-			sklog.CFatalF(
-				"Reached EOL looking for matching '{term}' in string literal.",
+		if l.Adv() == rEOF {
+			l.error(
+				sklog.CodeUnterminatedString,
+				tk.line,
+				tk.col,
+				"Reached EOF looking for matching '{term}' in string literal.",
 				"term", string(term),
 			)
+			return
 		}
 	}
 
 	// Consume the right quote.
 	l.Adv()
-
-	return tk
 }
 
-func eatSymbol(tk token.Token, l *lexer) token.Token {
-	for {
-		// Consume the next rune.
-		c := l.Adv()
-		tk.AddRune(c)
-
-		// Attempt to classify it.
-		switch tk.Value() {
-		// --------------------------------------------------------------------------
-		// Multi-byte symbols.
-		// '->'
-		case token.Arrow.String():
-			tk.SetType(token.Arrow)
-
-		// '...'
-		case token.Spread.String():
-			tk.SetType(token.Spread)
-
-		// '..'
-		case token.Concat.String():
-			// '...'
-			if l.LA() == '.' {
-				break
-			}
-
-			// '..'
-			tk.SetType(token.Concat)
-
-		// '[]'
-		case token.List.String():
-			tk.SetType(token.List)
-
-		// '>='
-		case token.GE.String():
-			tk.SetType(token.GE)
-
-		// '<='
-		case token.LE.String():
-			tk.SetType(token.LE)
-
-		// '!='
-		case token.NE.String():
-			tk.SetType(token.NE)
-
-		// '=='
-		case token.EQEQ.String():
-			tk.SetType(token.EQEQ)
-
-		// '&&'
-		case token.And.String():
-			tk.SetType(token.And)
-
-		// '||'
-		case token.Or.String():
-			tk.SetType(token.Or)
-
-		// '#'
-		case token.Comment.String():
-			eatComment(l)
-			return nil
-
-		// --------------------------------------------------------------------------
-		// Exclusively single-byte symbols.
-		// '(' | ')'
-		case token.ParenOpen.String():
-			tk.SetType(token.ParenOpen)
-
-		case token.ParenClose.String():
-			tk.SetType(token.ParenClose)
-
-		// '{'
-		case token.BraceOpen.String():
-			tk.SetType(token.BraceOpen)
-
-		// '}'
-		case token.BraceClose.String():
-			tk.SetType(token.BraceClose)
-
-		// '+'
-		case token.Plus.String():
-			tk.SetType(token.Plus)
-
-		// '*'
-		case token.Mult.String():
-			tk.SetType(token.Mult)
-
-		// ','
-		case token.Comma.String():
-			tk.SetType(token.Comma)
-
-		// ';'
-		case token.SemiColon.String():
-			tk.SetType(token.SemiColon)
-
-		// ']'
-		// The open bracket is further down since it can indicate an empty list
-		// literal ('[]').
-		case token.BrackClose.String():
-			tk.SetType(token.BrackClose)
-
-		// ':'
-		case token.Colon.String():
-			tk.SetType(token.Colon)
-
-		// --------------------------------------------------------------------------
-		// Multi-byte symbol passthrough.
-		// This just prevents exclusively multi-byte symbols from hitting the default
-		// switch case.
-		// '&&'
-		case "&":
-
-		// '||'
-		case "|":
-
-		// --------------------------------------------------------------------------
-		// Maybe multi-byte symbols.
-		// '-' | '->'
-		case token.Minus.String():
-			// '->'
-			if l.LA() == '>' {
-				break
-			}
-			tk.SetType(token.Minus)
-
-		// '/' | '//'
-		case token.Div.String():
-			tk.SetType(token.Div)
-
-		// '>' | '>='
-		case token.GT.String():
-			// '>='
-			if l.LA() == '=' {
-				break
-			}
-			tk.SetType(token.GT)
-
-		// '<' | '<='
-		case token.LT.String():
-			// '<='
-			if l.LA() == '=' {
-				break
-			}
-			tk.SetType(token.LT)
-
-		// '!' | '!='
-		case token.Not.String():
-			// '!='
-			if l.LA() == '=' {
-				break
-			}
-			// '!'
-			tk.SetType(token.Not)
-
-		// '.' | '..' | '...'
-		case token.Dot.String():
-			// '..' | '...'
-			if l.LA() == '.' {
-				break
-			}
-			// '.'
-			tk.SetType(token.Dot)
-
-		// '==' | '='
-		case token.EQ.String():
-			// '=='
-			if l.LA() == '=' {
-				break
-			}
-
-			// '='
-			tk.SetType(token.EQ)
-
-		// '[' | '[]'
-		case token.BrackOpen.String():
-			// '[]'
-			if l.LA() == ']' {
-				break
-			}
+// Consumes a symbol, indicating whether it's a token (rather than a comment or
+// an unknown symbol).
+func eatSymbol(tk *lexeme, l *lexer) bool {
+	switch l.Adv() {
+	// '#'
+	case '#':
+		l.comment(tk.line, eatComment(l))
+		return false
+
+	// --------------------------------------------------------------------------
+	// Exclusively single-byte symbols.
+	// '(' | ')'
+	case '(':
+		tk._type = token.ParenOpen
+
+	case ')':
+		tk._type = token.ParenClose
+
+	// '{'
+	case '{':
+		tk._type = token.BraceOpen
+
+	// '}'
+	case '}':
+		tk._type = token.BraceClose
+
+	// '+'
+	case '+':
+		tk._type = token.Plus
+
+	// '*'
+	case '*':
+		tk._type = token.Mult
+
+	// ','
+	case ',':
+		tk._type = token.Comma
+
+	// ';'
+	case ';':
+		tk._type = token.SemiColon
+
+	// ']'
+	case ']':
+		tk._type = token.BrackClose
+
+	// ':'
+	case ':':
+		tk._type = token.Colon
+
+	// '~'
+	case '~':
+		tk._type = token.BitXor
+
+	// --------------------------------------------------------------------------
+	// Maybe multi-byte symbols.
+	// '-' | '->'
+	case '-':
+		tk._type = l.either('>', token.Arrow, token.Minus)
+
+	// '/' | '//'
+	case '/':
+		tk._type = l.either('/', token.FloorDiv, token.Div)
+
+	// '&' | '&&'
+	case '&':
+		tk._type = l.either('&', token.And, token.BitAnd)
+
+	// '|' | '||'
+	case '|':
+		tk._type = l.either('|', token.Or, token.BitOr)
+
+	// '!' | '!='
+	case '!':
+		tk._type = l.either('=', token.NE, token.Not)
+
+	// '=' | '=='
+	case '=':
+		tk._type = l.either('=', token.EQEQ, token.EQ)
+
+	// '[' | '[]'
+	case '[':
+		tk._type = l.either(']', token.List, token.BrackOpen)
+
+	// '>' | '>=' | '>>'
+	case '>':
+		tk._type = l.either('=', token.GE, token.GT)
+		if tk._type == token.GT {
+			tk._type = l.either('>', token.Shr, token.GT)
+		}
 
-			// '['
-			tk.SetType(token.BrackOpen)
-		default:
-			sklog.CFatalF(
-				"Found unknown symbol: '{symbol}'.",
-				"symbol", tk.Value(),
-			)
+	// '<' | '<=' | '<<'
+	case '<':
+		tk._type = l.either('=', token.LE, token.LT)
+		if tk._type == token.LT {
+			tk._type = l.either('<', token.Shl, token.LT)
 		}
 
-		if tk.Type() > 0 {
-			return tk
+	// '.' | '..' | '...'
+	case '.':
+		tk._type = l.either('.', token.Concat, token.Dot)
+		if tk._type == token.Concat {
+			tk._type = l.either('.', token.Spread, token.Concat)
 		}
+
+	default:
+		l.error(
+			sklog.CodeUnknownSymbol,
+			tk.line,
+			tk.col,
+			"Found unknown symbol: '{symbol}'.",
+			"symbol", l.text(*tk),
+		)
+		return false
 	}
+
+	return true
 }
 
-func eatImport(l *lexer) {
+// Consumes a line comment, producing its text.
+func eatComment(l *lexer) string {
+	start := l.next
 	for l.LA() != '\n' && l.LA() != rEOF {
 		l.Adv()
 	}
-}
 
-func eatComment(l *lexer) {
-	for {
-		if l.LA() == '\n' {
-			return
-		}
-
-		l.Adv()
-	}
+	return strings.TrimRight(l.src[start:l.next], "\r")
 }
@@ -0,0 +1,293 @@
+package lex
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/parse"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+)
+
+// The type, text and start of a token lexed.
+type lexed struct {
+	t         token.Type
+	value     string
+	line, col int
+}
+
+func lexAll(t testing.TB, src string) ([]lexed, *sklog.Diagnostics) {
+	t.Helper()
+
+	diags := sklog.NewDiagnostics()
+	tc := Lex("main.sk", src, diags)
+
+	out := make([]lexed, tc.Len())
+	for i := range out {
+		tk := tc.At(i)
+		out[i] = lexed{tk.Type(), tk.Value(), tk.LineStart(), tk.ColumnStart()}
+	}
+
+	return out, diags
+}
+
+func TestLex(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want []lexed
+	}{
+		{
+			name: "positions",
+			src:  "let a = 1\nprint(a)",
+			want: []lexed{
+				{token.Let, "let", 1, 1},
+				{token.ID, "a", 1, 5},
+				{token.EQ, "=", 1, 7},
+				{token.IntL, "1", 1, 9},
+				{token.ID, "print", 2, 1},
+				{token.ParenOpen, "(", 2, 6},
+				{token.ID, "a", 2, 7},
+				{token.ParenClose, ")", 2, 8},
+			},
+		},
+		{
+			// Columns count runes, not bytes.
+			name: "multi-byte",
+			src:  "let s = 'é→ü' .. x",
+			want: []lexed{
+				{token.Let, "let", 1, 1},
+				{token.ID, "s", 1, 5},
+				{token.EQ, "=", 1, 7},
+				{token.StrL, "é→ü", 1, 9},
+				{token.Concat, "..", 1, 15},
+				{token.ID, "x", 1, 18},
+			},
+		},
+		{
+			name: "crlf",
+			src:  "let a = 1\r\n\r\nprint(a) # note\r\n",
+			want: []lexed{
+				{token.Let, "let", 1, 1},
+				{token.ID, "a", 1, 5},
+				{token.EQ, "=", 1, 7},
+				{token.IntL, "1", 1, 9},
+				{token.ID, "print", 3, 1},
+				{token.ParenOpen, "(", 3, 6},
+				{token.ID, "a", 3, 7},
+				{token.ParenClose, ")", 3, 8},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, diags := lexAll(t, c.src)
+			if err := diags.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("got %d tokens %v, want %d", len(got), got, len(c.want))
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Errorf("token %d = %+v, want %+v", i, got[i], c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLexComments(t *testing.T) {
+	diags := sklog.NewDiagnostics()
+	tc := Lex("main.sk", "# lead\r\nlet a = 1 # trail\r\n# end\r\n", diags)
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tc.At(0).Trivia()
+	if len(tr.Leading) != 1 || tr.Leading[0].Text != " lead" {
+		t.Errorf("leading = %+v, want ' lead'", tr.Leading)
+	}
+	if tr.Trailing == nil || tr.Trailing.Text != " trail" {
+		t.Errorf("trailing = %+v, want ' trail'", tr.Trailing)
+	}
+	if tr.Next == nil || len(tr.Next.Leading) != 1 || tr.Next.Leading[0].Text != " end" {
+		t.Errorf("end = %+v, want ' end'", tr.Next)
+	}
+}
+
+func TestLexTrivia(t *testing.T) {
+	// Only lines with comments have trivia, other tokens share that of the
+	// nearest line before them.
+	diags := sklog.NewDiagnostics()
+	tc := Lex("main.sk", "let a = 1\n# lead\nlet b = a\nprint(b) # trail\nprint(a)\n", diags)
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []int
+	for i := range tc.Len() {
+		line := 0
+		if tr := tc.At(i).Trivia(); tr != nil {
+			line = tr.Line
+		}
+		lines = append(lines, line)
+	}
+	want := []int{0, 0, 0, 0, 3, 3, 3, 3, 4, 4, 4, 4, 4, 4, 4, 4}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("trivia lines = %v, want %v", lines, want)
+	}
+
+	lead := tc.At(4).Trivia()
+	trail := tc.At(8).Trivia()
+	if lead.Next != trail || trail.Prev != lead || trail.Next != nil {
+		t.Errorf("trivia aren't linked in order")
+	}
+	if len(trail.Leading) != 0 || trail.Trailing == nil || trail.Trailing.Text != " trail" {
+		t.Errorf("line 4 = %+v, want a trailing ' trail'", trail)
+	}
+}
+
+func TestLexEndComments(t *testing.T) {
+	// Comments following the final token, with none before them.
+	diags := sklog.NewDiagnostics()
+	tc := Lex("main.sk", "\nlet a = 1\n# one\n# two\n", diags)
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tc.At(0).Trivia()
+	if tr == nil || tr.Line != 2 || len(tr.Leading) != 0 {
+		t.Fatalf("first line = %+v, want empty trivia of line 2", tr)
+	}
+	for i := range tc.Len() {
+		if tc.At(i).Trivia() != tr {
+			t.Errorf("token %d doesn't share the trivia of the first line", i)
+		}
+	}
+	if tr.Next == nil || len(tr.Next.Leading) != 2 || tr.Next.Leading[1].Text != " two" {
+		t.Errorf("end = %+v, want ' one' and ' two'", tr.Next)
+	}
+}
+
+func TestLexSymbols(t *testing.T) {
+	got, diags := lexAll(t, "-> ... .. . [] [ >= >> > <= << < != ! == = && & || | // / ~ -")
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []token.Type{
+		token.Arrow, token.Spread, token.Concat, token.Dot, token.List, token.BrackOpen,
+		token.GE, token.Shr, token.GT, token.LE, token.Shl, token.LT, token.NE, token.Not,
+		token.EQEQ, token.EQ, token.And, token.BitAnd, token.Or, token.BitOr,
+		token.FloorDiv, token.Div, token.BitXor, token.Minus,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens %v, want %d", len(got), got, len(want))
+	}
+	for i := range got {
+		if got[i].t != want[i] {
+			t.Errorf("token %d (%q) = %s, want %s", i, got[i].value, got[i].t, want[i])
+		}
+	}
+}
+
+func TestLexKeywords(t *testing.T) {
+	for text, want := range keywords {
+		if got := classifyKeyword(text); got != want {
+			t.Errorf("classifyKeyword(%q) = %s, want %s", text, got, want)
+		}
+		if got := classifyKeyword(text + "s"); got != token.ID {
+			t.Errorf("classifyKeyword(%q) = %s, want %s", text+"s", got, token.ID)
+		}
+	}
+}
+
+func TestLexUnterminatedString(t *testing.T) {
+	got, diags := lexAll(t, "let a = 'abc\nprint(a)")
+
+	list := diags.List()
+	if len(list) != 1 || list[0].Code != sklog.CodeUnterminatedString {
+		t.Fatalf("diagnostics = %+v, want one %s", list, sklog.CodeUnterminatedString)
+	}
+	if list[0].Line != 1 || list[0].ColStart != 9 {
+		t.Errorf("reported at %d:%d, want 1:9", list[0].Line, list[0].ColStart)
+	}
+
+	// The string runs to the end of the source.
+	last := got[len(got)-1]
+	if last.t != token.StrL || last.value != "abc\nprint(a)" {
+		t.Errorf("last token = %+v, want the rest of the source", last)
+	}
+}
+
+func TestLexEOF(t *testing.T) {
+	diags := sklog.NewDiagnostics()
+	tc := Lex("main.sk", "let é = 1\n", diags)
+	for range tc.Len() {
+		tc.Adv()
+	}
+
+	eof := tc.Adv()
+	if eof.Type() != token.EOF {
+		t.Fatalf("type = %s, want %s", eof.Type(), token.EOF)
+	}
+	if eof.LineStart() != 1 || eof.ColumnStart() != 10 || eof.ColumnEnd() != 11 {
+		t.Errorf("at %d:%d-%d, want 1:10-11", eof.LineStart(), eof.ColumnStart(), eof.ColumnEnd())
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Benchmarks
+ *----------------------------------------------------------------------------*/
+
+// Produces a source of `n` fns, each with a comment, a loop, strings and
+// arithmetic.
+func source(n int) string {
+	var b strings.Builder
+	for i := range n {
+		id := strconv.Itoa(i)
+		b.WriteString("# Sums the rolls of fn " + id + ".\n")
+		b.WriteString("fn roll" + id + "(n: int, label: str) int {\n")
+		b.WriteString("  let total = 0 # running total\n")
+		b.WriteString("  for i = 1, n {\n")
+		b.WriteString("    total = total + math.random(1, 6) * " + id + "\n")
+		b.WriteString("  }\n")
+		b.WriteString("  if total >= 100 && label != 'é' {\n")
+		b.WriteString("    print(\"roll " + id + ": \" .. label, total)\n")
+		b.WriteString("  }\n")
+		b.WriteString("  return total\n")
+		b.WriteString("}\n\n")
+	}
+
+	return b.String()
+}
+
+func BenchmarkLex(b *testing.B) {
+	src := source(5000)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		Lex("main.sk", src, sklog.NewDiagnostics())
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	src := source(5000)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		diags := sklog.NewDiagnostics()
+		parse.Parse(Lex("main.sk", src, diags))
+		if err := diags.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
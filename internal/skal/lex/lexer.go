@@ -1,15 +1,19 @@
 package lex
 
 import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/illbjorn/fstr"
 	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/sklog"
 )
 
-func newLexer(path, s string, tc *token.Collection) *lexer {
+func newLexer(path, s string, tc *token.Collection, diags *sklog.Diagnostics) *lexer {
 	return &lexer{
+		diags: diags,
 		file:  path,
-		runes: []rune(s),
-		pos:   -1,
-		len:   len([]rune(s)),
+		src:   s,
 		col:   1,
 		line:  1,
 		tc:    tc,
@@ -17,68 +21,178 @@ func newLexer(path, s string, tc *token.Collection) *lexer {
 }
 
 type lexer struct {
-	tc               *token.Collection
-	file             string
-	runes            []rune
-	currentLineStart int
-	pos              int
-	line             int
-	col              int
-	len              int
+	tc    *token.Collection
+	diags *sklog.Diagnostics
+	file  string
+	src   string
+	// The current rune, and the byte offset of the rune following it.
+	cur  rune
+	next int
+	// Byte offset of the start of the current line.
+	lineStart int
+	line      int
+	col       int
+	// The line of the last token sent, and the index of the first token of the
+	// line.
+	tokLine, lineTok int
+	// The comments of the last line with comments a token was sent from.
+	trivia *token.Trivia
+	// Comments not yet attached to a line, leading the next holding a token.
+	leading []token.LineComment
+}
+
+// A token being lexed: its type, and where it starts.
+type lexeme struct {
+	_type     token.Type
+	start     int
+	line, col int
+}
+
+// Starts a token of type `t` at the next rune.
+func (l *lexer) newToken(t token.Type) lexeme {
+	return lexeme{_type: t, start: l.next, line: l.line, col: l.col}
+}
+
+// Sends token `tk`, ending at the current rune, to the collection. Comments
+// leading its line are kept as the trivia of the line.
+func (l *lexer) push(tk lexeme) {
+	if tk.line != l.tokLine {
+		l.tokLine, l.lineTok = tk.line, l.tc.Len()
+		if len(l.leading) > 0 {
+			l.addTrivia(tk.line)
+		}
+	}
+
+	l.tc.Push(tk._type, tk.start, l.next, l.trivia)
+}
+
+// Produces the source text of token `tk` consumed so far.
+func (l *lexer) text(tk lexeme) string {
+	return l.src[tk.start:l.next]
 }
 
-// Adv moves the position index forward by one and returns the now-current rune
-// in the slice.
+// Adv moves the position index forward by one rune and returns the
+// now-current rune.
 func (l *lexer) Adv() rune {
-	// Make sure we're not indexing outside the slice bounds.
-	if l.pos >= l.len-1 {
+	// Make sure we're not reading past the end of the source.
+	if l.next >= len(l.src) {
 		return rEOF
 	}
 
-	// Bump the pointer.
-	l.pos++
+	r, n := rune(l.src[l.next]), 1
+	if r >= utf8.RuneSelf {
+		r, n = utf8.DecodeRuneInString(l.src[l.next:])
+	}
+	l.cur, l.next = r, l.next+n
 
 	// On line feed, increment line count and reset column index.
-	if l.runes[l.pos] == '\n' {
-		// Add the line position.
-		l.tc.MarkLine(l.line, l.currentLineStart, l.pos)
-
-		// Increment the line count.
+	if r == '\n' {
 		l.line++
-
-		// Reset the column position.
 		l.col = 1
-
-		// Mark the new-current-line start.
-		l.currentLineStart = l.pos
+		l.lineStart = l.next
 
 		// Otherwise, just bump the column position.
 	} else {
 		l.col++
 	}
 
-	return l.Cur()
+	return r
 }
 
-// Cur returns the current-index rune in the runes slice.
+// Consumes the next rune if it's `r`, producing `t` if it was, or `otherwise`
+// if not.
+func (l *lexer) either(r rune, t, otherwise token.Type) token.Type {
+	if l.LA() != r {
+		return otherwise
+	}
+	l.Adv()
+
+	return t
+}
+
+// Cur returns the current rune.
 func (l *lexer) Cur() rune {
-	return l.runes[l.pos]
+	return l.cur
 }
 
-// LA returns the current-index+1 rune in the runes slice.
+// LA returns the rune following the current rune.
 func (l *lexer) LA() rune {
-	if l.pos+1 < len(l.runes) {
-		return l.runes[l.pos+1]
+	if l.next >= len(l.src) {
+		return rEOF
 	}
 
-	return rEOF
+	if r := rune(l.src[l.next]); r < utf8.RuneSelf {
+		return r
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.next:])
+
+	return r
+}
+
+// Reports a lex error starting at `line`:`col` and spanning to the current
+// position (if on the same line).
+func (l *lexer) error(code string, line, col int, msg string, pairs ...string) {
+	src, end := l.srcLine(), l.col
+	if line < l.line {
+		src, end = l.tc.SrcLine(line), col+1
+	}
+
+	sklog.NewCompilerEvent(sklog.MsgTypeLexError, sklog.LevelError).
+		To(l.diags).
+		WithCode(code).
+		WithSourceHint(src, l.file, line, col, end).
+		Str(fstr.Pairs(msg, pairs...)).
+		Send()
 }
 
-// LB returns the current position - 1 rune in the runes slice.
-func (l *lexer) LB() rune {
-	if l.pos-1 >= 0 {
-		return l.runes[l.pos-1]
+// Returns the source text of the current line.
+func (l *lexer) srcLine() string {
+	line := l.src[l.lineStart:]
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
 	}
 
-	return rEOF
+	return strings.TrimSuffix(line, "\r")
+}
+
+// Starts the trivia of source line `line`, holding the comments leading it.
+func (l *lexer) addTrivia(line int) {
+	tr := &token.Trivia{Line: line, Leading: l.leading, Prev: l.trivia}
+	if l.trivia != nil {
+		l.trivia.Next = tr
+	}
+	l.trivia = tr
+	l.leading = nil
+}
+
+// Keeps the comments following the final token of the source, as the last
+// trivia of the source. Without trivia to follow, the tokens share that
+// of the first line, so the comments can be reached.
+func (l *lexer) endTrivia() {
+	if l.tokLine == 0 || len(l.leading) == 0 {
+		return
+	}
+
+	if l.trivia == nil {
+		l.trivia = &token.Trivia{Line: l.tc.At(0).LineStart()}
+		l.tc.SetTrivia(0, l.trivia)
+	}
+	l.addTrivia(l.line + 1)
+}
+
+// Keeps comment `text`, found on source line `line`. A comment following a
+// token on its line ends the line, others lead the next line holding a token.
+func (l *lexer) comment(line int, text string) {
+	c := token.LineComment{Text: text, Line: line}
+	if l.tokLine != line {
+		l.leading = append(l.leading, c)
+		return
+	}
+
+	// The tokens of the line sent so far hold the trivia of an earlier line.
+	if l.trivia == nil || l.trivia.Line != line {
+		l.addTrivia(line)
+		l.tc.SetTrivia(l.lineTok, l.trivia)
+	}
+	l.trivia.Trailing = &c
 }
@@ -1,95 +1,125 @@
 package token
 
 import (
+	"sort"
 	"strings"
-	"sync"
+
+	"github.com/illbjorn/skal/internal/skal/sklog"
 )
 
-func NewCollection(file, in string) *Collection {
-	return &Collection{
-		file:   file,
-		src:    in,
-		tokens: make([]Token, 0),
+func NewCollection(file, in string, diags *sklog.Diagnostics) *Collection {
+	tc := &Collection{
+		diags: diags,
+		file:  file,
+		src:   in,
+		lines: []int{0},
+		// Roughly a token per 5 bytes of source.
+		tokens: make([]token, 0, len(in)/5),
 		// We always look one token ahead for all parsing considerations,
 		// so we start at -1 since the first token we'll check is n+1 or index 0.
 		pos: -1,
-		wg:  &sync.WaitGroup{},
 	}
+
+	for i := 0; ; {
+		n := strings.IndexByte(in[i:], '\n')
+		if n < 0 {
+			break
+		}
+		i += n + 1
+		tc.lines = append(tc.lines, i)
+	}
+
+	return tc
 }
 
 type Collection struct {
-	file        string
-	wg          *sync.WaitGroup
-	src         string
-	lineMarkers [][3]int
-	tokens      []Token
-	pos         int
+	diags *sklog.Diagnostics
+	file  string
+	src   string
+	// Byte offset of the start of each line.
+	lines  []int
+	tokens []token
+	// The EOF Token, once produced.
+	eofToken *token
+	pos      int
 }
 
-func (tc *Collection) MarkLine(lineNum, posStart, posEnd int) {
-	tc.lineMarkers = append(tc.lineMarkers, [3]int{lineNum, posStart, posEnd})
+// Push appends a Token of type `t`, spanning source bytes [start, end), with
+// the comments `trivia` of its line (or the nearest line before it with
+// comments).
+//
+// All Tokens must be pushed before any are retrieved.
+func (tc *Collection) Push(t Type, start, end int, trivia *Trivia) {
+	tc.tokens = append(tc.tokens, token{
+		tc:     tc,
+		trivia: trivia,
+		start:  int32(start),
+		end:    int32(end),
+		_type:  t,
+	})
 }
 
-// Retrieves the entire source text line by a provided line number.
-func (tc *Collection) SrcLine(line int) string {
-	if line < 0 || line >= len(tc.lineMarkers) {
-		return ""
-	}
-
-	// Locate the line markers for the current line.
-	var start, end int
-	for _, position := range tc.lineMarkers {
-		if position[0] == line {
-			start, end = position[1], position[2]
-			break
-		}
+// SetTrivia sets the comments of the Tokens from the `from`th on to `trivia`.
+func (tc *Collection) SetTrivia(from int, trivia *Trivia) {
+	for i := from; i < len(tc.tokens); i++ {
+		tc.tokens[i].trivia = trivia
 	}
+}
 
-	// Extract the source text line.
-	src := tc.src[start:end]
+// Diagnostics returns the collector problems with the tokens are reported to.
+func (tc *Collection) Diagnostics() *sklog.Diagnostics {
+	return tc.diags
+}
 
-	// Strip any newlines.
-	src = strings.Trim(src, "\n")
+// Pos returns the index of the most recently consumed Token.
+func (tc *Collection) Pos() int {
+	return tc.pos
+}
 
-	return src
+// Len returns the number of Tokens lexed.
+func (tc *Collection) Len() int {
+	return len(tc.tokens)
 }
 
-// Wait simply blocks on the internal WaitGroup.
-func (tc *Collection) Wait() {
-	tc.wg.Wait()
+// At returns the `i`th Token lexed.
+func (tc *Collection) At(i int) Token {
+	return &tc.tokens[i]
 }
 
-// InputStream returns a Token channel it then spawns a Goroutine to
-// continually consume from. All received Collection are added to the
-// Collection's tokens slice.
-func (tc *Collection) InputStream() chan Token {
-	ch := make(chan Token, 1)
-	tc.wg.Add(1)
-
-	// Start the token collector.
-	go func() {
-		defer tc.wg.Done()
-		for tk := range ch {
-			if tk == nil {
-				continue
-			}
+// Retrieves the entire source text line by a provided line number, less the
+// line ending.
+func (tc *Collection) SrcLine(line int) string {
+	if line < 1 || line > len(tc.lines) {
+		return ""
+	}
 
-			tc.tokens = append(tc.tokens, tk)
-		}
-	}()
+	end := len(tc.src)
+	if line < len(tc.lines) {
+		end = tc.lines[line] - 1
+	}
+
+	return strings.TrimSuffix(tc.src[tc.lines[line-1]:end], "\r")
+}
+
+// Produces the line (from 1) holding byte offset `off` of the source.
+func (tc *Collection) line(off int) int {
+	// The first line starting past `off` follows it.
+	return sort.SearchInts(tc.lines, off+1)
+}
 
-	// Return the input channel.
-	return ch
+// A Token with no position.
+func (tc *Collection) none() Token {
+	return &token{tc: tc, start: -1, end: -1}
 }
 
 // Cur returns the current Token.
 func (tc *Collection) Cur() Token {
 	// Watch out for the end of the slice.
 	if tc.pos >= len(tc.tokens) || tc.pos < 0 {
-		return &token{_type: EOF}
+		return tc.eof()
 	}
 
-	return tc.tokens[tc.pos]
+	return &tc.tokens[tc.pos]
 }
 
 // Adv advances the position index ahead 1 and returns a pointer to the new
@@ -100,11 +130,11 @@ func (tc *Collection) Adv() Token {
 
 	// Watch out for the end of the slice.
 	if tc.pos >= len(tc.tokens) {
-		return &token{_type: EOF}
+		return tc.eof()
 	}
 
 	// Return the new "current" token.
-	return tc.tokens[tc.pos]
+	return &tc.tokens[tc.pos]
 }
 
 // AdvIf only consumes the next character if it matches a provided `tts` value.
@@ -118,19 +148,36 @@ func (tc *Collection) AdvIf(tts ...Type) (Token, bool) {
 			return tk, true
 		}
 	}
-	return NewToken(tc), false
+	return tc.none(), false
 }
 
 // AdvT advances forward expecting a single provided Token type. If the Token
-// encountered is not as specified, a panic occurs.
+// encountered is not as specified, a parse error bails out.
 func (tc *Collection) AdvT(tt Type) Token {
 	// Get the next token.
 	tk := tc.Adv()
 
 	// Confirm we have a match.
 	if tk.Type() != tt {
+		// A block left open at EOF.
+		if tt == BraceClose && tk.Type() == EOF {
+			assertError(
+				sklog.CodeMissingBrace,
+				tk,
+				sprintf("Expected %s, found %s. A block is missing its closing brace.", tt, tk.Type()),
+				sklog.Fix{
+					Description: "Insert '}' at the end of the file.",
+					File:        tk.File(),
+					Line:        tk.LineStart(),
+					ColStart:    tk.ColumnStart(),
+					ColEnd:      tk.ColumnStart(),
+					Text:        "\n}",
+				},
+			)
+		}
+
 		assertError(
-			tk.SrcLine(),
+			sklog.CodeUnexpectedToken,
 			tk,
 			sprintf("Expected %s, found %s.", tt, tk.Type()),
 		)
@@ -167,23 +214,36 @@ func (tc *Collection) AdvOneOfT(tts ...Type) Token {
 	expected := join(expecteds, ", ")
 
 	assertError(
-		tk.SrcLine(),
+		sklog.CodeUnexpectedToken,
 		tk,
 		sprintf("Expected %s, found %s", expected, tk.Type()),
 	)
 
-	return NewToken(tc)
+	return tc.none()
+}
+
+// Produces the EOF Token, positioned just past the final Token.
+func (tc *Collection) eof() Token {
+	if tc.eofToken == nil {
+		end := 0
+		if len(tc.tokens) > 0 {
+			end = int(tc.tokens[len(tc.tokens)-1].end)
+		}
+		tc.eofToken = &token{tc: tc, start: int32(end), end: int32(end), _type: EOF}
+	}
+
+	return tc.eofToken
 }
 
 // LA returns the lookahead (pos+1) Token.
 func (tc *Collection) LA() Token {
 	// Confirm we're not attempting to index outside the slice.
 	if tc.pos+1 >= len(tc.tokens) {
-		return &token{_type: EOF}
+		return tc.eof()
 	}
 
 	// Return a pointer to the pos+1 token.
-	return tc.tokens[tc.pos+1]
+	return &tc.tokens[tc.pos+1]
 }
 
 // NTT returns a boolean Value indicating if the next Token has the provided
@@ -0,0 +1,126 @@
+package token
+
+import (
+	"testing"
+)
+
+func TestPosition(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		off  int
+		want Position
+	}{
+		{name: "start", src: "let a", off: 0, want: Position{Abs: 0, Line: 1, Col: 1}},
+		{name: "ascii", src: "let a", off: 4, want: Position{Abs: 4, Line: 1, Col: 5}},
+		// 'é' is 2 bytes, 'ü' 2 and '→' 3: columns count runes.
+		{name: "multi-byte", src: "let é = 'ü'", off: 7, want: Position{Abs: 7, Line: 1, Col: 7}},
+		{name: "multi-byte end", src: "let é = 'ü'", off: 13, want: Position{Abs: 13, Line: 1, Col: 12}},
+		{name: "multi-byte line", src: "a\n→ b", off: 6, want: Position{Abs: 6, Line: 2, Col: 3}},
+		{name: "line start", src: "a\nb", off: 2, want: Position{Abs: 2, Line: 2, Col: 1}},
+		{name: "newline", src: "a\nb", off: 1, want: Position{Abs: 1, Line: 1, Col: 2}},
+		{name: "crlf", src: "a\r\nbc\r\nd", off: 4, want: Position{Abs: 4, Line: 2, Col: 2}},
+		{name: "crlf line start", src: "a\r\nbc\r\nd", off: 7, want: Position{Abs: 7, Line: 3, Col: 1}},
+		{name: "none", src: "a", off: -1, want: Position{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tc := NewCollection("main.sk", c.src, nil)
+			if got := tc.position(c.off); got != c.want {
+				t.Errorf("position(%d) = %+v, want %+v", c.off, got, c.want)
+			}
+		})
+	}
+}
+
+func TestLine(t *testing.T) {
+	tc := NewCollection("main.sk", "ab\r\n\r\ncd\n", nil)
+	for off, want := range []int{1, 1, 1, 1, 2, 2, 3, 3, 3, 4} {
+		if got := tc.line(off); got != want {
+			t.Errorf("line(%d) = %d, want %d", off, got, want)
+		}
+	}
+}
+
+func TestSrcLine(t *testing.T) {
+	tc := NewCollection("main.sk", "let é = 1\r\n\r\nprint(é)", nil)
+	for line, want := range map[int]string{
+		0: "",
+		1: "let é = 1",
+		2: "",
+		3: "print(é)",
+		4: "",
+	} {
+		if got := tc.SrcLine(line); got != want {
+			t.Errorf("SrcLine(%d) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestEOF(t *testing.T) {
+	cases := []struct {
+		name       string
+		src        string
+		tokens     [][2]int
+		line       int
+		start, end int
+	}{
+		{name: "empty", src: "", line: 1, start: 1, end: 2},
+		{name: "final token", src: "let a", tokens: [][2]int{{0, 3}, {4, 5}}, line: 1, start: 6, end: 7},
+		{name: "multi-byte", src: "'→'", tokens: [][2]int{{0, 5}}, line: 1, start: 4, end: 5},
+		// The EOF follows the final token, rather than trailing whitespace.
+		{name: "trailing newline", src: "a\nb\n", tokens: [][2]int{{0, 1}, {2, 3}}, line: 2, start: 2, end: 3},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tc := NewCollection("main.sk", c.src, nil)
+			for _, span := range c.tokens {
+				tc.Push(ID, span[0], span[1], nil)
+			}
+
+			eof := tc.eof()
+			if eof.Type() != EOF {
+				t.Fatalf("type = %s, want %s", eof.Type(), EOF)
+			}
+			if eof.LineStart() != c.line || eof.LineEnd() != c.line {
+				t.Errorf("lines = %d-%d, want %d", eof.LineStart(), eof.LineEnd(), c.line)
+			}
+			if eof.ColumnStart() != c.start || eof.ColumnEnd() != c.end {
+				t.Errorf("columns = %d-%d, want %d-%d", eof.ColumnStart(), eof.ColumnEnd(), c.start, c.end)
+			}
+		})
+	}
+}
+
+func TestValue(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		t    Type
+		want string
+	}{
+		{name: "id", src: "abc", t: ID, want: "abc"},
+		{name: "string", src: "'abc'", t: StrL, want: "abc"},
+		{name: "double quoted", src: `"abc"`, t: StrL, want: "abc"},
+		{name: "empty string", src: "''", t: StrL, want: ""},
+		{name: "other quote", src: `'a"'`, t: StrL, want: `a"`},
+		{name: "multi-byte", src: "'ü→'", t: StrL, want: "ü→"},
+		// An unterminated string runs to the end of the source.
+		{name: "unterminated", src: "'abc", t: StrL, want: "abc"},
+		{name: "unterminated lines", src: "'ab\ncd", t: StrL, want: "ab\ncd"},
+		{name: "unterminated other quote", src: `'ab"`, t: StrL, want: `ab"`},
+		{name: "unterminated quote", src: "'", t: StrL, want: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tc := NewCollection("main.sk", c.src, nil)
+			tc.Push(c.t, 0, len(c.src), nil)
+			if got := tc.At(0).Value(); got != c.want {
+				t.Errorf("Value() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
@@ -4,14 +4,18 @@ import (
 	"github.com/illbjorn/skal/internal/skal/sklog"
 )
 
-func assertError(src string, tk Token, msg string) {
+func assertError(code string, tk Token, msg string, fixes ...sklog.Fix) {
 	file := tk.File()
 	line := tk.LineStart()
 	col1 := tk.ColumnStart()
 	col2 := tk.ColumnEnd()
-	sklog.NewCompilerEvent(sklog.MsgTypeParseError, sklog.LevelFatal).
+	ev := sklog.NewCompilerEvent(sklog.MsgTypeParseError, sklog.LevelFatal).
+		WithCode(code).
 		WithCallStack(3).
-		WithSourceHint(src, file, line, col1, col2).
-		Str(msg).
-		Send()
+		WithSourceHint(tk.SrcLine(), file, line, col1, col2).
+		Str(msg)
+	for _, fix := range fixes {
+		ev.WithFix(fix)
+	}
+	ev.Bail()
 }
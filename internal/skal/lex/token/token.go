@@ -1,8 +1,8 @@
 package token
 
 import (
-	"bytes"
 	"strconv"
+	"unicode/utf8"
 
 	"github.com/illbjorn/fstr"
 )
@@ -10,94 +10,99 @@ import (
 var itoa = strconv.Itoa
 
 type Token interface {
-	SetType(t Type)
 	Type() Type
 	LineStart() int
-	SetLineStart(i int)
 	LineEnd() int
-	SetLineEnd(i int)
 	ColumnStart() int
-	SetColumnStart(i int)
 	ColumnEnd() int
-	SetColumnEnd(i int)
-	SetSrcStart(i int)
-	SetSrcEnd(i int)
-	AddRune(r rune)
+	Start() Position
+	End() Position
 	File() string
-	SetFile(file string)
 	Value() string
 	String() string
 	Src() string
 	SrcLine() string
-}
-
-func NewToken(tc *Collection) Token {
-	token := &token{value: bytes.NewBuffer(nil)}
-	token.start = new(Position)
-	token.end = new(Position)
-
-	token.src = func() string {
-		if token.end.Abs < token.start.Abs {
-			return ""
-		}
-		return tc.src[token.start.Abs:token.end.Abs]
-	}
-
-	token.srcLine = func() string {
-		return tc.SrcLine(token.start.Line)
-	}
-
-	return token
+	Trivia() *Trivia
 }
 
 var _ Token = (*token)(nil)
 
+// A token is a span of its Collection's source. It holds only byte offsets,
+// its positions and text are produced from the source when asked for.
+//
+// Tokens are stored by value in their Collection, and handed out as pointers
+// into it.
 type token struct {
-	value   *bytes.Buffer
-	start   *Position
-	end     *Position
-	src     func() string
-	srcLine func() string
-	file    string
-	_type   Type
+	tc     *Collection
+	trivia *Trivia
+	// Byte offsets of the token's source text, [start, end). Both are -1 for a
+	// token with no position.
+	start, end int32
+	_type      Type
 }
 
 // Type
-func (tk *token) SetType(t Type) { tk._type = t }
-func (tk *token) Type() Type     { return tk._type }
+func (tk *token) Type() Type { return tk._type }
 
 // Line Position
-func (tk *token) LineStart() int     { return tk.start.Line }
-func (tk *token) SetLineStart(i int) { tk.start.Line = i }
-func (tk *token) LineEnd() int       { return tk.end.Line }
-func (tk *token) SetLineEnd(i int)   { tk.end.Line = i }
+func (tk *token) LineStart() int { return tk.Start().Line }
+func (tk *token) LineEnd() int   { return tk.End().Line }
 
 // Column Position
-func (tk *token) ColumnStart() int     { return tk.start.Col }
-func (tk *token) SetColumnStart(i int) { tk.start.Col = i }
-func (tk *token) ColumnEnd() int       { return tk.end.Col }
-func (tk *token) SetColumnEnd(i int)   { tk.end.Col = i }
-
-// Absolute Source Position
-func (tk *token) SetSrcStart(i int) { tk.start.Abs = i }
-func (tk *token) SetSrcEnd(i int)   { tk.end.Abs = i }
+func (tk *token) ColumnStart() int { return tk.Start().Col }
+func (tk *token) ColumnEnd() int   { return tk.End().Col }
+
+// Positions
+func (tk *token) Start() Position { return tk.tc.position(int(tk.start)) }
+
+// The end of a token is the position following its final rune. Zero-width
+// tokens (EOF) span a column.
+func (tk *token) End() Position {
+	end := tk.tc.position(int(tk.end))
+	if tk.start == tk.end && tk.start >= 0 {
+		end.Col++
+	}
 
-// Buffer Writer
-func (tk *token) AddRune(r rune) { tk.value.WriteRune(r) }
+	return end
+}
 
-// Source File Get/Set
-func (tk *token) File() string        { return tk.file }
-func (tk *token) SetFile(file string) { tk.file = file }
+// Source File Get
+func (tk *token) File() string { return tk.tc.file }
 
 // Source Text Getters
-func (tk *token) Src() string     { return tk.src() }
-func (tk *token) SrcLine() string { return tk.srcLine() }
+func (tk *token) Src() string {
+	if tk.start < 0 {
+		return ""
+	}
+
+	return tk.tc.src[tk.start:tk.end]
+}
 
-// Buffer Stringer
-func (tk *token) Value() string { return tk.value.String() }
+func (tk *token) SrcLine() string { return tk.tc.SrcLine(tk.LineStart()) }
+
+// Comments
+func (tk *token) Trivia() *Trivia { return tk.trivia }
+
+// Value produces the text of the token: its source, less the quotes of string
+// literals.
+func (tk *token) Value() string {
+	src := tk.Src()
+	if tk._type != StrL || len(src) == 0 {
+		return src
+	}
+
+	// An unterminated string runs to the end of the source.
+	src = src[1:]
+	if len(src) > 0 && src[len(src)-1] == tk.tc.src[tk.start] {
+		src = src[:len(src)-1]
+	}
+
+	return src
+}
 
 // fmt.Stringer
 func (tk *token) String() string {
+	start, end := tk.Start(), tk.End()
 	return fstr.Pairs(
 		`File    : {File}
 Type    : {type}
@@ -105,15 +110,32 @@ Start X : {sx}
 Start Y : {sy}
 End X   : {ex}
 End Y   : {ey}`,
-		"File", tk.file,
+		"File", tk.tc.file,
 		"type", tk._type.String(),
-		"sx", itoa(tk.start.Col),
-		"sy", itoa(tk.start.Line),
-		"ex", itoa(tk.end.Col),
-		"ey", itoa(tk.end.Line),
+		"sx", itoa(start.Col),
+		"sy", itoa(start.Line),
+		"ex", itoa(end.Col),
+		"ey", itoa(end.Line),
 	)
 }
 
+// Position locates a point of the source: its line and column (from 1, in
+// runes), and its offset in bytes from the start of the file.
 type Position struct {
-	Abs, Col, Line int
+	Abs  int `json:"offset"`
+	Col  int `json:"column"`
+	Line int `json:"line"`
+}
+
+// Produces the position of byte offset `off` of the source, the zero Position
+// for a negative offset.
+func (tc *Collection) position(off int) Position {
+	if off < 0 {
+		return Position{}
+	}
+
+	line := tc.line(off)
+	col := utf8.RuneCountInString(tc.src[tc.lines[line-1]:off]) + 1
+
+	return Position{Abs: off, Col: col, Line: line}
 }
@@ -2,6 +2,11 @@ package token
 
 type Type uint8
 
+// MarshalText encodes the Type by its name, e.g. in AST dumps.
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
 func (t Type) String() string {
 	switch t {
 	// Keywords
@@ -29,6 +34,8 @@ func (t Type) String() string {
 		return "enum"
 	case Let:
 		return "let"
+	case Continue:
+		return "continue"
 
 	// Primitive Types
 	case Int:
@@ -99,6 +106,20 @@ func (t Type) String() string {
 		return "*"
 	case Div:
 		return "/"
+	case FloorDiv:
+		return "//"
+
+	// Bitwise Operators
+	case BitAnd:
+		return "&"
+	case BitOr:
+		return "|"
+	case BitXor:
+		return "~"
+	case Shl:
+		return "<<"
+	case Shr:
+		return ">>"
 
 	// Spread Operator
 	case Spread:
@@ -169,6 +190,8 @@ func (t Type) String() string {
 		return "concat operator"
 	case MathOperator:
 		return "math operator"
+	case BitwiseOperator:
+		return "bitwise operator"
 
 	// Conditionals
 	case Elifs:
@@ -253,18 +276,19 @@ const (
 	//////////////////////////////////////////////////////////////////////////////
 	//
 	// Keywords
-	This   Type = iota // Struct instance self-reference.
-	Pub                // Public modifier.
-	New                // Struct constructor.
-	Ret                // Fn return.
-	For                // For loop.
-	In                 // For 'in'.
-	Import             // Module import statement.
-	Defer              // Defer statement.
-	Fn                 // Function definition.
-	Struct             // Struct definition.
-	Enum               // Enum definition.
-	Let                // Let binding.
+	This     Type = iota // Struct instance self-reference.
+	Pub                  // Public modifier.
+	New                  // Struct constructor.
+	Ret                  // Fn return.
+	For                  // For loop.
+	In                   // For 'in'.
+	Import               // Module import statement.
+	Defer                // Defer statement.
+	Fn                   // Function definition.
+	Struct               // Struct definition.
+	Enum                 // Enum definition.
+	Let                  // Let binding.
+	Continue             // Skip to the next loop iteration.
 
 	// Primitive Types
 	Int
@@ -308,6 +332,14 @@ const (
 	Minus
 	Mult
 	Div
+	FloorDiv
+
+	// Bitwise Operators
+	BitAnd
+	BitOr
+	BitXor
+	Shl
+	Shr
 
 	// Spread Operator
 	Spread
@@ -356,6 +388,7 @@ const (
 	LogicOperator
 	ConcatOperator
 	MathOperator
+	BitwiseOperator
 
 	// Conditionals
 	Elifs // Elifs are categorized under an umbrella for AST construction.
@@ -403,3 +436,18 @@ const (
 	ForIterable // ex: The `Value` in: for k, v in Value {
 	ForIterator // ex: The `i` in: for i = 1, 10 {
 )
+
+// Keywords lists the spelling of every reserved word, for suggestions.
+func Keywords() []string {
+	types := []Type{
+		This, Pub, New, Ret, For, In, Import, Defer, Fn, Struct, Enum, Let, Continue,
+		Int, Bool, Str, Extern, As, If, Elif, Else, True, False, Nil,
+	}
+
+	out := make([]string, len(types))
+	for i, t := range types {
+		out[i] = t.String()
+	}
+
+	return out
+}
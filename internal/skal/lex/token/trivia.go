@@ -0,0 +1,24 @@
+package token
+
+// LineComment is a `#` comment of the source.
+type LineComment struct {
+	// The text following the `#`.
+	Text string
+	Line int
+}
+
+// Trivia holds the comments of a line of source holding a token. Only lines
+// with comments have trivia, a token holds that of its line, or else of the
+// nearest line before it with trivia.
+type Trivia struct {
+	Line int
+	// The comments on the lines preceding the line, since the last line holding
+	// a token.
+	Leading []LineComment
+	// The comment ending the line, if any.
+	Trailing *LineComment
+	// The trivia before and after that of the line. The comments following the
+	// final token of the source have trivia of their own, following that of
+	// its line.
+	Prev, Next *Trivia
+}
@@ -1,14 +1,19 @@
 package lua
 
-var StdlibFns = []string{
-	"print",
-	"insert",
-	"random",
-	"strmatch",
-	"tonumber",
-	"tostring",
-	"date",
-}
+import (
+	_ "embed"
+
+	"github.com/illbjorn/skal/internal/skal/decl"
+)
+
+//go:embed stdlib.skd
+var stdlibDecl string
+
+// Stdlib holds the bundled declarations of the host Lua standard library.
+var Stdlib = decl.Parse("stdlib.skd", stdlibDecl, nil)
+
+// StdlibFns lists the global fns declared by the bundled standard library.
+var StdlibFns = Stdlib.Fns()
 
 // Translates a provided Skal term to a Lua equivalent, if one exists.
 func Translate(v string) string {
@@ -0,0 +1,190 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/vfs"
+)
+
+// LockName is the file name of lockfiles, alongside the manifest.
+const LockName = "skal.lock"
+
+// Lock pins the content of each vendored dependency of a project.
+//
+//	# Generated by `skal mod`, do not edit.
+//
+//	[deps.ui]
+//	source = "../shared/ui"
+//	hash = "sha256:9f86d0..."
+type Lock struct {
+	// Path of the lockfile.
+	Path string
+	// The pinned dependencies, by name.
+	Deps map[string]Locked
+}
+
+// Locked is a pinned dependency.
+type Locked struct {
+	// The source the dependency was vendored from, as declared by the
+	// manifest.
+	Source string
+	// Hash of the vendored tree.
+	Hash string
+}
+
+// LockPath is the path of the project's lockfile.
+func (m *Manifest) LockPath() string {
+	return filepath.Join(m.Dir(), LockName)
+}
+
+// LoadLock reads the lockfile of manifest `m`, a missing lockfile pins
+// nothing. Problems are reported to `diags`, returning nil if it's invalid.
+func LoadLock(fsys vfs.FS, m *Manifest, diags *sklog.Diagnostics) *Lock {
+	l := &Lock{Path: m.LockPath(), Deps: make(map[string]Locked)}
+	b, err := fsys.ReadFile(l.Path)
+	if err != nil {
+		return l
+	}
+
+	d := &decoder{path: l.Path, lines: strings.Split(string(b), "\n"), diags: diags}
+	root, keys, perr := parseTOML(string(b))
+	if perr != nil {
+		d.report(sklog.LevelFatal, perr.pos, perr.msg)
+		return nil
+	}
+	d.keys = keys
+
+	deps, ok := root["deps"].(table)
+	if !ok && root["deps"] != nil {
+		d.errorf("deps", "Expected 'deps' to be a table.")
+	}
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		k := "deps." + name
+		dep, ok := deps[name].(table)
+		if !ok {
+			d.errorf(k, "Expected '{key}' to be a table.", "key", k)
+			continue
+		}
+		l.Deps[name] = Locked{
+			Source: d.str(k+".source", dep["source"]),
+			Hash:   d.str(k+".hash", dep["hash"]),
+		}
+	}
+
+	if d.failed {
+		return nil
+	}
+
+	return l
+}
+
+// Bytes renders the lockfile, its dependencies sorted by name.
+func (l *Lock) Bytes() []byte {
+	names := make([]string, 0, len(l.Deps))
+	for name := range l.Deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := new(strings.Builder)
+	out.WriteString("# Generated by `skal mod`, do not edit.\n")
+	for _, name := range names {
+		dep := l.Deps[name]
+		fmt.Fprintf(out, "\n[deps.%s]\nsource = %s\nhash = %s\n", name, quote(dep.Source), quote(dep.Hash))
+	}
+
+	return []byte(out.String())
+}
+
+/*------------------------------------------------------------------------------
+ * Editing
+ *----------------------------------------------------------------------------*/
+
+// SetDep declares dependency `name` with source `source` in the manifest
+// source text `src`, returning the edited text. An existing declaration is
+// replaced, the rest of the text (comments included) is kept as is.
+func SetDep(src, name, source string) (string, error) {
+	_, keys, perr := parseTOML(src)
+	if perr != nil {
+		return "", fmt.Errorf("%d:%d: %s", perr.line, perr.col, perr.msg)
+	}
+
+	decl := name + " = " + quote(source)
+	lines := strings.Split(src, "\n")
+
+	// Replace the existing declaration.
+	if at, ok := keys["deps."+name]; ok {
+		lines[at.line-1] = decl
+		return strings.Join(lines, "\n"), nil
+	}
+
+	// Add to the end of the `[deps]` table.
+	if at, ok := keys["deps"]; ok {
+		last := at.line
+		for k, p := range keys {
+			if strings.HasPrefix(k, "deps.") && p.line > last {
+				last = p.line
+			}
+		}
+		lines = append(lines[:last], append([]string{decl}, lines[last:]...)...)
+		return strings.Join(lines, "\n"), nil
+	}
+
+	// Add a `[deps]` table.
+	if src != "" && !strings.HasSuffix(src, "\n") {
+		src += "\n"
+	}
+
+	return src + "\n[deps]\n" + decl + "\n", nil
+}
+
+// Quotes `s` as a TOML basic string.
+func quote(s string) string {
+	out := new(strings.Builder)
+	out.WriteByte('"')
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(c)
+		case c == '\n':
+			out.WriteString(`\n`)
+		case c == '\t':
+			out.WriteString(`\t`)
+		case c == '\r':
+			out.WriteString(`\r`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(out, `\u%04X`, c)
+		default:
+			out.WriteRune(c)
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
+
+// ValidName indicates whether `name` is a valid dependency name: a bare TOML
+// key, usable as an import path.
+func ValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !isBare(c) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,314 @@
+// Package manifest loads `skal.toml` project manifests.
+//
+//	# skal.toml
+//	name = "game"
+//	entrypoints = ["main.sk", "tools/editor.sk"]
+//	output = "build"
+//	target = "luajit"
+//	paths = ["lib", "../shared"]
+//
+//	[deps]
+//	ui = "../shared/ui"
+//	json = "archives/json-1.2.tar.gz"
+//
+// Paths are relative to the directory of the manifest.
+package manifest
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/illbjorn/fstr"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/target"
+	"github.com/illbjorn/skal/internal/skal/vfs"
+)
+
+// Name is the file name of project manifests.
+const Name = "skal.toml"
+
+// Deps is the directory, alongside the manifest, vendored dependencies are
+// imported from.
+const Deps = "deps"
+
+// Manifest describes a Skal project.
+type Manifest struct {
+	// Path of the manifest file.
+	Path string
+	// Name of the project.
+	Name string
+	// Source files compiled by a bare `skal c`.
+	Entrypoints []string
+	// Directory the output of the entrypoints is written to.
+	Output string
+	// Name of the Lua runtime the output targets (see `target.Names`).
+	Target string
+	// Module search roots, consulted in order after the directory of the
+	// entrypoint.
+	Paths []string
+	// The source (a directory or archive) of each dependency, by name. Sources
+	// are vendored into `Deps` by `skal mod`.
+	Deps map[string]string
+}
+
+// Dir is the directory of the manifest, its paths are relative to.
+func (m *Manifest) Dir() string {
+	return filepath.Dir(m.Path)
+}
+
+// Roots lists the module search roots of the project, in order: its `paths`,
+// then the vendored dependencies directory.
+func (m *Manifest) Roots() []string {
+	roots := make([]string, 0, len(m.Paths)+1)
+	for _, path := range m.Paths {
+		roots = append(roots, m.path(path))
+	}
+
+	return append(roots, m.DepsDir())
+}
+
+// DepsDir is the directory dependencies are vendored into.
+func (m *Manifest) DepsDir() string {
+	return m.path(Deps)
+}
+
+// SourcePath resolves the source of a dependency.
+func (m *Manifest) SourcePath(source string) string {
+	return m.path(source)
+}
+
+// EntryPaths lists the entrypoints of the project.
+func (m *Manifest) EntryPaths() []string {
+	paths := make([]string, len(m.Entrypoints))
+	for i, entry := range m.Entrypoints {
+		paths[i] = m.path(entry)
+	}
+
+	return paths
+}
+
+// OutputPath is the path the output of entrypoint `entry` is written to: its
+// path relative to the manifest, with a `.lua` extension, in the output
+// directory.
+func (m *Manifest) OutputPath(entry string) string {
+	rel, err := filepath.Rel(m.Dir(), entry)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(entry)
+	}
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + ".lua"
+
+	return filepath.Join(m.path(m.Output), rel)
+}
+
+// Resolves `path`, relative to the directory of the manifest.
+func (m *Manifest) path(path string) string {
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(m.Dir(), path)
+}
+
+/*------------------------------------------------------------------------------
+ * Loading
+ *----------------------------------------------------------------------------*/
+
+// Find looks for a manifest in `dir` and each of its parents, loading the
+// nearest. Returns nil if there is none. Problems are reported to `diags`.
+func Find(fsys vfs.FS, dir string, diags *sklog.Diagnostics) *Manifest {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	for {
+		path := filepath.Join(dir, Name)
+		if stat, err := fsys.Stat(path); err == nil && !stat.IsDir() {
+			return Load(fsys, path, diags)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads and parses the manifest at `path`. Problems are reported to
+// `diags`, returning nil if it can't be loaded.
+func Load(fsys vfs.FS, path string, diags *sklog.Diagnostics) *Manifest {
+	b, err := fsys.ReadFile(path)
+	if err != nil {
+		sklog.NewCompilerEvent(sklog.MsgTypeManifestError, sklog.LevelFatal).
+			To(diags).
+			WithCode(sklog.CodeBadManifest).
+			AddF(
+				"Failed to read manifest: '{path}', with error: {err}.",
+				"path", path,
+				"err", err.Error(),
+			).
+			Send()
+		return nil
+	}
+
+	return Parse(path, string(b), diags)
+}
+
+// Parse parses the source text of a manifest. Problems are reported to
+// `diags`, returning nil if it's invalid.
+func Parse(path, src string, diags *sklog.Diagnostics) *Manifest {
+	d := &decoder{path: path, lines: strings.Split(src, "\n"), diags: diags}
+
+	root, keys, perr := parseTOML(src)
+	if perr != nil {
+		d.report(sklog.LevelFatal, perr.pos, perr.msg)
+		return nil
+	}
+	d.keys = keys
+
+	// Decode the keys in source order, so problems are reported in order.
+	names := make([]string, 0, len(root))
+	for k := range root {
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool { return keys[names[i]].line < keys[names[j]].line })
+
+	m := &Manifest{Path: path, Output: "."}
+	for _, k := range names {
+		switch v := root[k]; k {
+		// 'name'
+		case "name":
+			m.Name = d.str(k, v)
+
+		// 'entrypoints'
+		case "entrypoints":
+			m.Entrypoints = d.strs(k, v)
+
+		// 'output'
+		case "output":
+			m.Output = d.str(k, v)
+
+		// 'target'
+		case "target":
+			m.Target = d.str(k, v)
+			if _, ok := target.Lookup(m.Target); !ok && m.Target != "" {
+				d.errorf(k,
+					"Unknown target '{target}', expected one of: {names}.",
+					"target", m.Target,
+					"names", strings.Join(target.Names(), ", "),
+				)
+			}
+
+		// 'paths'
+		case "paths":
+			m.Paths = d.strs(k, v)
+
+		// '[deps]'
+		case "deps":
+			m.Deps = d.deps(k, v)
+
+		default:
+			d.report(sklog.LevelWarn, keys[k], fstr.Pairs("Unknown manifest key '{key}'.", "key", k))
+		}
+	}
+
+	if d.failed {
+		return nil
+	}
+
+	return m
+}
+
+// Decodes the values of a parsed manifest, reporting problems at the position
+// of their key.
+type decoder struct {
+	path   string
+	lines  []string
+	keys   map[string]pos
+	diags  *sklog.Diagnostics
+	failed bool
+}
+
+func (d *decoder) str(k string, v any) string {
+	s, ok := v.(string)
+	if !ok {
+		d.errorf(k, "Expected '{key}' to be a string.", "key", k)
+	}
+
+	return s
+}
+
+func (d *decoder) strs(k string, v any) []string {
+	arr, ok := v.([]any)
+	if !ok {
+		d.errorf(k, "Expected '{key}' to be an array of strings.", "key", k)
+		return nil
+	}
+
+	out := make([]string, 0, len(arr))
+	for _, e := range arr {
+		s, ok := e.(string)
+		if !ok {
+			d.errorf(k, "Expected '{key}' to be an array of strings.", "key", k)
+			return nil
+		}
+		out = append(out, s)
+	}
+
+	return out
+}
+
+func (d *decoder) deps(k string, v any) map[string]string {
+	t, ok := v.(table)
+	if !ok {
+		d.errorf(k, "Expected '{key}' to be a table.", "key", k)
+		return nil
+	}
+
+	names := make([]string, 0, len(t))
+	for name := range t {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make(map[string]string, len(t))
+	for _, name := range names {
+		source := t[name]
+		if !ValidName(name) {
+			d.errorf(k+"."+name, "Invalid dependency name '{name}', expected letters, digits, '_' or '-'.", "name", name)
+			continue
+		}
+		out[name] = d.str(k+"."+name, source)
+	}
+
+	return out
+}
+
+func (d *decoder) errorf(k, msg string, pairs ...string) {
+	d.report(sklog.LevelError, d.keys[k], fstr.Pairs(msg, pairs...))
+}
+
+func (d *decoder) report(level string, at pos, msg string) {
+	if level != sklog.LevelWarn {
+		d.failed = true
+	}
+
+	mtype := sklog.MsgTypeManifestError
+	if level == sklog.LevelWarn {
+		mtype = sklog.MsgTypeManifestWarning
+	}
+
+	ev := sklog.NewCompilerEvent(mtype, level).
+		To(d.diags).
+		WithCode(sklog.CodeBadManifest)
+	if at.line > 0 && at.line <= len(d.lines) {
+		src := strings.TrimRight(d.lines[at.line-1], "\r")
+		ev.WithSourceHint(src, d.path, at.line, at.col, at.end)
+	}
+
+	ev.Str(msg).
+		Send()
+}
@@ -0,0 +1,170 @@
+package manifest
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/illbjorn/skal/internal/skal/sklog"
+)
+
+func TestParse(t *testing.T) {
+	diags := sklog.NewDiagnostics()
+	m := Parse("/proj/skal.toml", `# skal.toml
+name = "game"
+entrypoints = ["main.sk", 'tools/editor.sk'] # both
+output = "build"
+target = "luajit"
+paths = [
+  "lib",
+  "../shared",
+]
+
+[deps]
+ui = "../shared/ui"
+json = 'archives/json-1.2.tar.gz'
+`, diags)
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Manifest{
+		Path:        "/proj/skal.toml",
+		Name:        "game",
+		Entrypoints: []string{"main.sk", "tools/editor.sk"},
+		Output:      "build",
+		Target:      "luajit",
+		Paths:       []string{"lib", "../shared"},
+		Deps:        map[string]string{"ui": "../shared/ui", "json": "archives/json-1.2.tar.gz"},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got %+v, want %+v", m, want)
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	diags := sklog.NewDiagnostics()
+	m := Parse("/proj/skal.toml", "", diags)
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Output != "." || m.Target != "" || m.Deps != nil {
+		t.Errorf("got %+v, want the output in the manifest's directory", m)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	type reported struct {
+		level     string
+		line, col int
+		msg       string
+	}
+
+	cases := []struct {
+		name string
+		src  string
+		want []reported
+	}{
+		{
+			name: "syntax",
+			src:  "name = 'game'\npaths = [\n  'lib'\n  'src'\n]",
+			want: []reported{{sklog.LevelFatal, 4, 3, "Expected ',' or ']' in array."}},
+		},
+		{
+			// Each problem is reported, in source order.
+			name: "types",
+			src:  "name = 1\nentrypoints = 'main.sk'\npaths = ['lib', 2]\ndeps = 'x'",
+			want: []reported{
+				{sklog.LevelError, 1, 1, "Expected 'name' to be a string."},
+				{sklog.LevelError, 2, 1, "Expected 'entrypoints' to be an array of strings."},
+				{sklog.LevelError, 3, 1, "Expected 'paths' to be an array of strings."},
+				{sklog.LevelError, 4, 1, "Expected 'deps' to be a table."},
+			},
+		},
+		{
+			name: "target",
+			src:  "\ntarget = 'lua9'",
+			want: []reported{{sklog.LevelError, 2, 1, "Unknown target 'lua9', expected one of: lua5.1, lua5.2, lua5.3, lua5.4, luajit, luau."}},
+		},
+		{
+			name: "deps",
+			src:  "[deps]\nui = '../ui'\n'bad name' = 'x'\njson = 1",
+			want: []reported{
+				{sklog.LevelError, 3, 1, "Invalid dependency name 'bad name', expected letters, digits, '_' or '-'."},
+				{sklog.LevelError, 4, 1, "Expected 'deps.json' to be a string."},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			diags := sklog.NewDiagnostics()
+			if m := Parse("skal.toml", c.src, diags); m != nil {
+				t.Errorf("got %+v, want nil", m)
+			}
+
+			var got []reported
+			for _, d := range diags.List() {
+				if d.Code != sklog.CodeBadManifest || d.File != "skal.toml" {
+					t.Errorf("reported %s in %q, want %s in skal.toml", d.Code, d.File, sklog.CodeBadManifest)
+				}
+				got = append(got, reported{d.Level, d.Line, d.ColStart, d.Message})
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %+v\nwant %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseUnknownKey(t *testing.T) {
+	diags := sklog.NewDiagnostics()
+	m := Parse("skal.toml", "name = 'game'\nversion = '1.0'", diags)
+	if m == nil {
+		t.Fatal("an unknown key failed the manifest")
+	}
+
+	list := diags.List()
+	if len(list) != 1 || list[0].Level != sklog.LevelWarn || list[0].Line != 2 ||
+		list[0].Message != "Unknown manifest key 'version'." {
+		t.Errorf("diagnostics = %+v, want a warning of 'version' at line 2", list)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	dir := filepath.FromSlash("/proj")
+	abs, err := filepath.Abs(filepath.FromSlash("/shared/lib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manifest{
+		Path:        filepath.Join(dir, Name),
+		Entrypoints: []string{"main.sk", "tools/editor.sk"},
+		Output:      "build",
+		Paths:       []string{"lib", abs},
+	}
+	join := func(elem ...string) string {
+		return filepath.Join(append([]string{dir}, elem...)...)
+	}
+
+	// Search roots: the manifest's paths, then the vendored dependencies.
+	if got, want := m.Roots(), []string{join("lib"), abs, join(Deps)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Roots() = %v, want %v", got, want)
+	}
+
+	if got, want := m.EntryPaths(), []string{join("main.sk"), join("tools", "editor.sk")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EntryPaths() = %v, want %v", got, want)
+	}
+
+	for entry, want := range map[string]string{
+		join("main.sk"):            join("build", "main.lua"),
+		join("tools", "editor.sk"): join("build", "tools", "editor.lua"),
+		// Entrypoints outside of the project are written by name.
+		filepath.FromSlash("/other/app.sk"): join("build", "app.lua"),
+	} {
+		if got := m.OutputPath(entry); got != want {
+			t.Errorf("OutputPath(%q) = %q, want %q", entry, got, want)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package manifest
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/illbjorn/fstr"
+)
+
+// A minimal TOML parser, covering what project files use:
+//
+//   - Comments (`# ...`).
+//   - Tables (`[deps]`, `[deps.ui]`) with bare or quoted keys.
+//   - Pairs of strings ("basic" or 'literal'), integers, booleans and arrays of
+//     them. Arrays may span lines and have a trailing comma.
+//
+// Anything else TOML allows (floats, dates, inline tables, arrays of tables,
+// multi-line strings, dotted keys) is reported as unsupported.
+
+// A parsed TOML table. Values are a string, int64, bool, []any or table.
+type table map[string]any
+
+// The position of a key or value in the source.
+type pos struct {
+	line, col, end int
+}
+
+type parseError struct {
+	pos
+	msg string
+}
+
+type tomlParser struct {
+	src       string
+	off       int
+	line, col int
+	// The position of each key, by its full dotted path.
+	keys map[string]pos
+	// The full dotted paths of the tables defined by a header.
+	defined map[string]bool
+}
+
+// Parses TOML source `src`, returning the root table and the position of each
+// key (by its full dotted path, e.g. "deps.ui").
+func parseTOML(src string) (root table, keys map[string]pos, err *parseError) {
+	p := &tomlParser{
+		src:     src,
+		line:    1,
+		col:     1,
+		keys:    make(map[string]pos),
+		defined: make(map[string]bool),
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(*parseError)
+			if !ok {
+				panic(r)
+			}
+			root, keys, err = nil, nil, perr
+		}
+	}()
+
+	root = table{}
+	cur, prefix := root, ""
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, p.keys, nil
+		}
+
+		// '[' Key ']'
+		if p.peek() == '[' {
+			cur, prefix = p.header(root)
+		} else {
+			p.pair(cur, prefix)
+		}
+
+		p.endLine()
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Statements
+ *----------------------------------------------------------------------------*/
+
+// Parses a table header, returning the table and its dotted path.
+func (p *tomlParser) header(root table) (table, string) {
+	start := p.pos()
+	p.next() // '['
+	if p.peek() == '[' {
+		p.fail("Arrays of tables ('[[...]]') are unsupported.")
+	}
+
+	// Key ('.' Key)*
+	var path []string
+	for {
+		p.skipSpace()
+		path = append(path, p.key())
+		p.skipSpace()
+		if p.peek() != '.' {
+			break
+		}
+		p.next()
+	}
+
+	// ']'
+	p.expect(']')
+
+	// Create any missing tables along the path.
+	cur := root
+	for i, k := range path {
+		full := strings.Join(path[:i+1], ".")
+		switch v := cur[k].(type) {
+		case nil:
+			t := table{}
+			cur[k] = t
+			cur = t
+			p.keys[full] = pos{start.line, start.col + 1, p.col - 1}
+		case table:
+			cur = v
+		default:
+			p.failAt(start, fstr.Pairs("Key '{key}' is not a table.", "key", full))
+		}
+	}
+
+	full := strings.Join(path, ".")
+	if p.defined[full] {
+		p.failAt(start, fstr.Pairs("Table [{key}] is defined more than once.", "key", full))
+	}
+	p.defined[full] = true
+
+	return cur, full + "."
+}
+
+// Parses a `key = value` pair into table `t`, at dotted path `prefix`.
+func (p *tomlParser) pair(t table, prefix string) {
+	start := p.pos()
+	k := p.key()
+	kpos := pos{start.line, start.col, p.col}
+	p.skipSpace()
+	if p.peek() == '.' {
+		p.fail("Dotted keys are unsupported, use a table header.")
+	}
+
+	// '='
+	p.expect('=')
+	p.skipSpace()
+
+	if _, ok := t[k]; ok {
+		p.failAt(start, fstr.Pairs("Key '{key}' is defined more than once.", "key", prefix+k))
+	}
+	t[k] = p.value()
+	p.keys[prefix+k] = kpos
+}
+
+// Expects the end of a line, allowing trailing whitespace and a comment.
+func (p *tomlParser) endLine() {
+	p.skipSpace()
+	p.skipComment()
+	if p.eof() {
+		return
+	}
+	if !p.newline() {
+		p.fail(fstr.Pairs("Expected the end of the line, found '{c}'.", "c", string(p.peek())))
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Keys and Values
+ *----------------------------------------------------------------------------*/
+
+func (p *tomlParser) key() string {
+	switch c := p.peek(); {
+	case c == '"':
+		return p.basicString()
+	case c == '\'':
+		return p.literalString()
+	case isBare(c):
+		start := p.off
+		for !p.eof() && isBare(p.peek()) {
+			p.next()
+		}
+		return p.src[start:p.off]
+	}
+
+	p.fail("Expected a key.")
+	return ""
+}
+
+func (p *tomlParser) value() any {
+	switch c := p.peek(); {
+	// String
+	case c == '"':
+		return p.basicString()
+	case c == '\'':
+		return p.literalString()
+
+	// Array
+	case c == '[':
+		return p.array()
+
+	// Inline Table
+	case c == '{':
+		p.fail("Inline tables are unsupported, use a table header.")
+
+	// Bool
+	case strings.HasPrefix(p.src[p.off:], "true"):
+		p.advance(4)
+		return true
+	case strings.HasPrefix(p.src[p.off:], "false"):
+		p.advance(5)
+		return false
+
+	// Integer
+	case c == '+' || c == '-' || (c >= '0' && c <= '9'):
+		return p.integer()
+	}
+
+	p.fail("Expected a value: a string, integer, boolean or array.")
+	return nil
+}
+
+func (p *tomlParser) basicString() string {
+	if strings.HasPrefix(p.src[p.off:], `"""`) {
+		p.fail("Multi-line strings are unsupported.")
+	}
+	p.next() // '"'
+
+	out := new(strings.Builder)
+	for {
+		if p.eof() || p.peek() == '\n' {
+			p.fail("Unterminated string.")
+		}
+
+		c := p.next()
+		switch c {
+		case '"':
+			return out.String()
+		case '\\':
+			out.WriteRune(p.escape())
+		default:
+			out.WriteRune(c)
+		}
+	}
+}
+
+func (p *tomlParser) escape() rune {
+	if p.eof() {
+		p.fail("Unterminated string.")
+	}
+
+	switch c := p.next(); c {
+	case '"', '\\':
+		return c
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.off+n > len(p.src) {
+			p.fail("Invalid unicode escape.")
+		}
+		code, err := strconv.ParseUint(p.src[p.off:p.off+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			p.fail("Invalid unicode escape.")
+		}
+		p.advance(n)
+		return rune(code)
+	default:
+		p.fail(fstr.Pairs("Invalid escape sequence '{seq}'.", "seq", "\\"+string(c)))
+		return 0
+	}
+}
+
+func (p *tomlParser) literalString() string {
+	if strings.HasPrefix(p.src[p.off:], "'''") {
+		p.fail("Multi-line strings are unsupported.")
+	}
+	p.next() // '\''
+
+	start := p.off
+	for {
+		if p.eof() || p.peek() == '\n' {
+			p.fail("Unterminated string.")
+		}
+		if p.next() == '\'' {
+			return p.src[start : p.off-1]
+		}
+	}
+}
+
+func (p *tomlParser) integer() int64 {
+	at, start := p.pos(), p.off
+	for !p.eof() && strings.ContainsRune("+-_0123456789", p.peek()) {
+		p.next()
+	}
+	if !p.eof() && strings.ContainsRune(".eE:T", p.peek()) {
+		p.fail("Floats and dates are unsupported.")
+	}
+
+	n, err := strconv.ParseInt(strings.ReplaceAll(p.src[start:p.off], "_", ""), 10, 64)
+	if err != nil {
+		p.failAt(pos{at.line, at.col, p.col}, fstr.Pairs("Invalid integer '{n}'.", "n", p.src[start:p.off]))
+	}
+
+	return n
+}
+
+func (p *tomlParser) array() []any {
+	p.next() // '['
+
+	out := []any{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.next()
+			return out
+		}
+
+		out = append(out, p.value())
+
+		// ',' or ']'
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case ']':
+		default:
+			p.fail("Expected ',' or ']' in array.")
+		}
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Scanning
+ *----------------------------------------------------------------------------*/
+
+func (p *tomlParser) eof() bool {
+	return p.off >= len(p.src)
+}
+
+func (p *tomlParser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+
+	c, _ := utf8.DecodeRuneInString(p.src[p.off:])
+	return c
+}
+
+func (p *tomlParser) next() rune {
+	c, n := utf8.DecodeRuneInString(p.src[p.off:])
+	p.off += n
+	if c == '\n' {
+		p.line++
+		p.col = 1
+	} else {
+		p.col++
+	}
+
+	return c
+}
+
+func (p *tomlParser) advance(n int) {
+	for i := 0; i < n && !p.eof(); i++ {
+		p.next()
+	}
+}
+
+func (p *tomlParser) expect(c rune) {
+	if p.peek() != c {
+		p.fail(fstr.Pairs("Expected '{c}'.", "c", string(c)))
+	}
+	p.next()
+}
+
+// Consumes a line break, returning false if there isn't one.
+func (p *tomlParser) newline() bool {
+	switch {
+	case p.peek() == '\n':
+		p.next()
+		return true
+	case strings.HasPrefix(p.src[p.off:], "\r\n"):
+		p.advance(2)
+		return true
+	}
+
+	return false
+}
+
+func (p *tomlParser) skipSpace() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.next()
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if p.peek() != '#' {
+		return
+	}
+	for !p.eof() && p.peek() != '\n' {
+		p.next()
+	}
+}
+
+// Skips whitespace, comments and line breaks.
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if !p.newline() {
+			return
+		}
+	}
+}
+
+func (p *tomlParser) pos() pos {
+	return pos{p.line, p.col, p.col + 1}
+}
+
+func (p *tomlParser) fail(msg string) {
+	p.failAt(p.pos(), msg)
+}
+
+func (p *tomlParser) failAt(at pos, msg string) {
+	panic(&parseError{pos: at, msg: msg})
+}
+
+// Characters of bare keys.
+func isBare(c rune) bool {
+	return c == '_' || c == '-' ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
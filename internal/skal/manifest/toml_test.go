@@ -0,0 +1,159 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want table
+	}{
+		{
+			name: "strings",
+			src: `basic = "a \"b\" \\ \t\u00e9\U0001F600"
+literal = 'C:\dir\'
+empty = ""
+"quoted key" = 'x'
+'literal key' = "y"`,
+			want: table{
+				"basic":       "a \"b\" \\ \té😀",
+				"literal":     `C:\dir\`,
+				"empty":       "",
+				"quoted key":  "x",
+				"literal key": "y",
+			},
+		},
+		{
+			name: "integers and booleans",
+			src: `a = 42
+b = -7
+c = +1_000
+yes = true
+no = false`,
+			want: table{"a": int64(42), "b": int64(-7), "c": int64(1000), "yes": true, "no": false},
+		},
+		{
+			name: "arrays",
+			src: `empty = []
+one = ["a"]
+mixed = [1, 'b', true]
+nested = [[1, 2], []]
+lines = [
+  "main.sk", # first
+  # between
+  "tools/editor.sk",
+]`,
+			want: table{
+				"empty":  []any{},
+				"one":    []any{"a"},
+				"mixed":  []any{int64(1), "b", true},
+				"nested": []any{[]any{int64(1), int64(2)}, []any{}},
+				"lines":  []any{"main.sk", "tools/editor.sk"},
+			},
+		},
+		{
+			name: "tables",
+			src: `name = "game"
+
+[deps]
+ui = "../shared/ui"
+"json-lib" = "archives/json.tar.gz"
+
+[deps.extra]
+x = 1
+
+[other]`,
+			want: table{
+				"name": "game",
+				"deps": table{
+					"ui":       "../shared/ui",
+					"json-lib": "archives/json.tar.gz",
+					"extra":    table{"x": int64(1)},
+				},
+				"other": table{},
+			},
+		},
+		{
+			name: "comments and blank lines",
+			src:  "# leading\r\n\r\n  name = 'a' # trailing\r\n\t# indented\r\n[deps] # header\r\n# last",
+			want: table{"name": "a", "deps": table{}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, _, err := parseTOML(c.src)
+			if err != nil {
+				t.Fatalf("%d:%d: %s", err.line, err.col, err.msg)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseTOMLKeys(t *testing.T) {
+	_, keys, err := parseTOML(`name = "game"
+
+[deps.ui]
+  path = 'x'`)
+	if err != nil {
+		t.Fatalf("%d:%d: %s", err.line, err.col, err.msg)
+	}
+
+	want := map[string]pos{
+		"name":         {1, 1, 5},
+		"deps":         {3, 2, 9},
+		"deps.ui":      {3, 2, 9},
+		"deps.ui.path": {4, 3, 7},
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		src       string
+		line, col int
+		msg       string
+	}{
+		{name: "unterminated string", src: "a = 1\nb = \"abc\nc = 2", line: 2, col: 9, msg: "Unterminated string."},
+		{name: "unterminated literal", src: "b = 'abc", line: 1, col: 9, msg: "Unterminated string."},
+		{name: "bad escape", src: `a = "\q"`, line: 1, col: 8, msg: `Invalid escape sequence '\q'.`},
+		{name: "bad unicode escape", src: `a = "\u00zz"`, line: 1, col: 8, msg: "Invalid unicode escape."},
+		{name: "multi-line string", src: `a = """x"""`, line: 1, col: 5, msg: "Multi-line strings are unsupported."},
+		{name: "missing equals", src: "\nname 'x'", line: 2, col: 6, msg: "Expected '='."},
+		{name: "missing value", src: "a =\n", line: 1, col: 4, msg: "Expected a value: a string, integer, boolean or array."},
+		{name: "missing key", src: "= 1", line: 1, col: 1, msg: "Expected a key."},
+		{name: "trailing text", src: "a = 1 2", line: 1, col: 7, msg: "Expected the end of the line, found '2'."},
+		{name: "duplicate key", src: "a = 1\n\na = 2", line: 3, col: 1, msg: "Key 'a' is defined more than once."},
+		{name: "duplicate table key", src: "[deps]\nui = 'a'\nui = 'b'", line: 3, col: 1, msg: "Key 'deps.ui' is defined more than once."},
+		{name: "duplicate table", src: "[deps]\n[other]\n[deps]", line: 3, col: 1, msg: "Table [deps] is defined more than once."},
+		{name: "key not a table", src: "deps = 1\n[deps.ui]", line: 2, col: 1, msg: "Key 'deps' is not a table."},
+		{name: "unclosed header", src: "[deps\n", line: 1, col: 6, msg: "Expected ']'."},
+		{name: "array missing comma", src: "a = [\n  1\n  2\n]", line: 3, col: 3, msg: "Expected ',' or ']' in array."},
+		{name: "float", src: "a = 1.5", line: 1, col: 6, msg: "Floats and dates are unsupported."},
+		{name: "bad integer", src: "a = 1-2", line: 1, col: 5, msg: "Invalid integer '1-2'."},
+		{name: "inline table", src: "a = { b = 1 }", line: 1, col: 5, msg: "Inline tables are unsupported, use a table header."},
+		{name: "array of tables", src: "[[deps]]", line: 1, col: 2, msg: "Arrays of tables ('[[...]]') are unsupported."},
+		{name: "dotted key", src: "deps.ui = 'x'", line: 1, col: 5, msg: "Dotted keys are unsupported, use a table header."},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, _, err := parseTOML(c.src)
+			if err == nil {
+				t.Fatal("parsed, want an error")
+			}
+			if err.line != c.line || err.col != c.col || err.msg != c.msg {
+				t.Errorf("got %d:%d: %s\nwant %d:%d: %s", err.line, err.col, err.msg, c.line, c.col, c.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package minify
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/sklog"
+)
+
+type kind uint8
+
+const (
+	kindName kind = iota
+	kindKeyword
+	kindNumber
+	kindString
+	kindOp
+)
+
+// A token of Lua source.
+type tok struct {
+	kind kind
+	text string
+	// Line of the source the token starts on.
+	line int
+	// The local a name declares or references, nil for globals and fields.
+	local *local
+	// The token starts a statement which must be separated from the one before
+	// it by a `;` (e.g. `(f or g)()`).
+	semi bool
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "if": true,
+	"in": true, "local": true, "nil": true, "not": true, "or": true,
+	"repeat": true, "return": true, "then": true, "true": true, "until": true,
+	"while": true,
+}
+
+// Operators, longest first.
+var ops = []string{
+	"...", "..", "==", "~=", "<=", ">=", "<<", ">>", "//", "::",
+	"+", "-", "*", "/", "%", "^", "#", "&", "~", "|", "<", ">", "=",
+	"(", ")", "{", "}", "[", "]", ";", ":", ",", ".",
+}
+
+// Splits Lua source `src` into tokens, dropping whitespace and comments.
+// `goto` and `continue` are lexed as names, they're only keywords to some
+// runtimes.
+func lex(src string) []*tok {
+	var (
+		toks []*tok
+		line = 1
+	)
+	for i := 0; i < len(src); {
+		c := src[i]
+		start, startLine := i, line
+
+		switch {
+		// Whitespace
+		case c == '\n':
+			line++
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+
+		// Comment
+		case strings.HasPrefix(src[i:], "--"):
+			i += 2
+			if n := longBracket(src[i:]); n >= 0 {
+				end := longEnd(src, i, n)
+				line += strings.Count(src[i:end], "\n")
+				i = end
+				continue
+			}
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+
+		// Long string
+		case c == '[' && longBracket(src[i:]) >= 0:
+			i = longEnd(src, i, longBracket(src[i:]))
+			line += strings.Count(src[start:i], "\n")
+			toks = append(toks, &tok{kind: kindString, text: src[start:i], line: startLine})
+			continue
+
+		// String
+		case c == '\'' || c == '"':
+			i++
+			for i < len(src) && src[i] != c {
+				if src[i] == '\\' {
+					i++
+				}
+				if i < len(src) && src[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			if i >= len(src) {
+				unexpected("unterminated string", startLine)
+			}
+			i++
+			toks = append(toks, &tok{kind: kindString, text: src[start:i], line: startLine})
+			continue
+
+		// Number
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			i = number(src, i)
+			toks = append(toks, &tok{kind: kindNumber, text: src[start:i], line: startLine})
+			continue
+
+		// Name | Keyword
+		case isWord(c):
+			for i < len(src) && isWord(src[i]) {
+				i++
+			}
+			k := kindName
+			if keywords[src[start:i]] {
+				k = kindKeyword
+			}
+			toks = append(toks, &tok{kind: k, text: src[start:i], line: startLine})
+			continue
+		}
+
+		// Operator
+		op := ""
+		for _, o := range ops {
+			if strings.HasPrefix(src[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			unexpected("character '"+string(c)+"'", line)
+		}
+		i += len(op)
+		toks = append(toks, &tok{kind: kindOp, text: op, line: startLine})
+	}
+
+	return toks
+}
+
+// Produces the level of the long bracket `src` opens (the number of `=`
+// between its brackets), or -1 if it doesn't open one.
+func longBracket(src string) int {
+	if len(src) == 0 || src[0] != '[' {
+		return -1
+	}
+
+	n := 1
+	for n < len(src) && src[n] == '=' {
+		n++
+	}
+	if n < len(src) && src[n] == '[' {
+		return n - 1
+	}
+
+	return -1
+}
+
+// Produces the offset following the long bracket of level `n` opened at
+// `src[i]`.
+func longEnd(src string, i, n int) int {
+	closing := "]" + strings.Repeat("=", n) + "]"
+	end := strings.Index(src[i:], closing)
+	if end < 0 {
+		unexpected("unterminated long bracket", strings.Count(src[:i], "\n")+1)
+	}
+
+	return i + end + len(closing)
+}
+
+// Produces the offset following the number at `src[i]`.
+func number(src string, i int) int {
+	expo := "Ee"
+	if strings.HasPrefix(src[i:], "0x") || strings.HasPrefix(src[i:], "0X") {
+		expo = "Pp"
+		i += 2
+	}
+
+	for i < len(src) {
+		switch c := src[i]; {
+		case strings.IndexByte(expo, c) >= 0:
+			i++
+			if i < len(src) && (src[i] == '+' || src[i] == '-') {
+				i++
+			}
+		case isWord(c) || c == '.':
+			i++
+		default:
+			return i
+		}
+	}
+
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWord(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Abandons minifying output the minifier doesn't understand.
+func unexpected(what string, line int) {
+	sklog.BailF(
+		"Found unexpected {what} minifying line {line} of the output.",
+		"what", what,
+		"line", strconv.Itoa(line),
+	)
+}
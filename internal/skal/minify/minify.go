@@ -0,0 +1,184 @@
+// Package minify rewrites compiled Lua to the fewest bytes it can, for
+// `--minify` builds.
+package minify
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/srcmap"
+)
+
+// Minify produces the minified form of compiled Lua `src`:
+//
+//   - Locals and fn args are renamed to the shortest names free in their
+//     scope. Globals (e.g. `pub` symbols) and fields keep their names.
+//   - Comments, indentation and blank lines are dropped.
+//   - Lines are joined, but each statement `m` maps keeps a line of its own,
+//     as does the boilerplate following a mapped statement, so stack traces
+//     can still be mapped back. The lines of `m` are updated to those of the
+//     output, and the locals renamed are recorded to its names.
+//
+// A problem minifying is reported to `diags`, producing nil.
+func Minify(src []byte, m *srcmap.Map, diags *sklog.Diagnostics) (out []byte) {
+	defer func() {
+		if diags.Catch(recover()) {
+			out = nil
+		}
+	}()
+
+	toks := lex(string(src))
+	r := resolve(toks)
+	rename(r)
+
+	// Lines starting a mapped statement, and the lines mapped.
+	mapped := make(map[int]bool, len(m.Mappings))
+	covered := make(map[int]bool)
+	for _, mapping := range m.Mappings {
+		mapped[mapping.Line] = true
+		for line := mapping.Line; line <= mapping.EndLine; line++ {
+			covered[line] = true
+		}
+	}
+
+	// The output line each source line is written to.
+	lines := make([]int, bytes.Count(src, []byte("\n"))+2)
+
+	f := new(bytes.Buffer)
+	line, last := 1, 0
+	var prev *tok
+	for _, t := range toks {
+		// Lines no token starts on (blank, or within a comment or string) are
+		// part of the line before.
+		for ; last < t.line-1; last++ {
+			lines[last+1] = line
+		}
+
+		switch {
+		case t.line != last && prev != nil && (mapped[t.line] || covered[prev.line] && !covered[t.line]):
+			f.WriteByte('\n')
+			line++
+		case prev != nil && space(prev, t, f.Bytes()):
+			f.WriteByte(' ')
+		}
+		if t.semi && prev != nil {
+			f.WriteByte(';')
+		}
+		if t.line != last {
+			lines[t.line] = line
+			last = t.line
+		}
+
+		text := t.text
+		if t.local != nil {
+			text = t.local.out()
+		}
+		f.WriteString(text)
+		line += strings.Count(text, "\n")
+		prev = t
+	}
+	for ; last+1 < len(lines); last++ {
+		lines[last+1] = line
+	}
+
+	// A local already standing in for a Skal name keeps that name.
+	var names []srcmap.Name
+	for _, l := range r.locals {
+		if l.keep || l.short == l.name {
+			continue
+		}
+
+		names = append(names, srcmap.Name{
+			Line:    lines[l.decl.line],
+			EndLine: lines[l.last.line],
+			Name:    l.short,
+			SrcName: m.Original(l.decl.line, l.name),
+		})
+	}
+	for i := range m.Mappings {
+		m.Mappings[i].Line = lines[m.Mappings[i].Line]
+		m.Mappings[i].EndLine = lines[m.Mappings[i].EndLine]
+	}
+	for i := range m.Names {
+		m.Names[i].Line = lines[m.Names[i].Line]
+		m.Names[i].EndLine = lines[m.Names[i].EndLine]
+	}
+	m.Names = append(m.Names, names...)
+
+	return f.Bytes()
+}
+
+// Indicates whether token `t` must be separated from the output `out`
+// preceding it, ending with token `prev`, to be lexed apart.
+func space(prev, t *tok, out []byte) bool {
+	a, b := out[len(out)-1], t.text[0]
+	if t.local != nil {
+		b = t.local.out()[0]
+	}
+
+	switch {
+	// `local x`, `1 and`
+	case isWord(a) && isWord(b):
+		return true
+
+	// `1 ..`
+	case prev.kind == kindNumber && b == '.':
+		return true
+
+	// `- -x`, `[ [[s]] ]`, `.. ...`
+	case prev.kind == kindOp && t.kind != kindName:
+		switch string([]byte{a, b}) {
+		case "--", "..", "[[", "[=", "==", "~=", "<=", ">=", "<<", ">>", "//", "::":
+			return true
+		}
+	}
+
+	return false
+}
+
+/*------------------------------------------------------------------------------
+ * Renaming
+ *----------------------------------------------------------------------------*/
+
+// Names each local the shortest name which isn't a keyword, a global the chunk
+// references, nor the name of another local visible where it's declared.
+// Every reference within the local's scope then resolves to it: a local
+// declared within its scope is declared where it's visible, so is named
+// otherwise.
+func rename(r *resolver) {
+	taken := make(map[string]bool)
+	for _, l := range r.locals {
+		if l.keep {
+			continue
+		}
+
+		for v := l.prev; v != nil; v = v.prev {
+			taken[v.out()] = true
+		}
+		for i := 0; ; i++ {
+			name := short(i)
+			if !taken[name] && !keywords[name] && !r.globals[name] && !keep(name) {
+				l.short = name
+				break
+			}
+		}
+		clear(taken)
+	}
+}
+
+const (
+	first = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	rest  = first + "0123456789_"
+)
+
+// Produces the `i`th name, shortest first: `a` ... `Z`, `aa`, `ba` ...
+func short(i int) string {
+	name := []byte{first[i%len(first)]}
+	for i /= len(first); i > 0; i /= len(rest) {
+		i--
+		name = append(name, rest[i%len(rest)])
+	}
+
+	return string(name)
+}
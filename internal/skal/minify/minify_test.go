@@ -0,0 +1,165 @@
+package minify
+
+import (
+	"testing"
+
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/srcmap"
+)
+
+func minify(t *testing.T, src string, m *srcmap.Map) string {
+	t.Helper()
+
+	diags := sklog.NewDiagnostics()
+	out := Minify([]byte(src), m, diags)
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return string(out)
+}
+
+func TestMinify(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "locals",
+			in: `local count = 1
+local function twice(n)
+  return n * 2
+end
+print(twice(count))`,
+			want: `local a=1 local function b(c)return c*2 end print(b(a))`,
+		},
+		{
+			// The inner `x` is renamed apart from the outer, which it shadows.
+			name: "shadowing",
+			in: `local x = 1
+do
+  local x = x + 1
+  print(x)
+end
+print(x)`,
+			want: `local a=1 do local b=a+1 print(b)end print(a)`,
+		},
+		{
+			// The upvalue keeps its name within the closure. A later local may
+			// reuse the name of the closure's arg, out of scope.
+			name: "upvalues",
+			in: `local total = 0
+local function add(n)
+  total = total + n
+end
+local step = 2
+add(step)`,
+			want: `local a=0 local function b(c)a=a+c end local c=2 b(c)`,
+		},
+		{
+			name: "globals",
+			in: `local a = b
+function f(x)
+  return x + a
+end`,
+			want: `local a=b function f(c)return c+a end`,
+		},
+		{
+			name: "kept names",
+			in: `local __ENV__ = {}
+local function __LOAD__(_ENV)
+  local t = {}
+  function t:get(key)
+    return self[key]
+  end
+end
+__LOAD__(__ENV__)`,
+			want: `local __ENV__={}local function __LOAD__(_ENV)local a={}function a:get(b)return self[b]end end __LOAD__(__ENV__)`,
+		},
+		{
+			name: "long strings",
+			in: `local s = [[
+  keep  -- this
+]]
+local q = [==[a]]b]==]
+print(s, q)`,
+			want: `local a=[[
+  keep  -- this
+]]local b=[==[a]]b]==]print(a,b)`,
+		},
+		{
+			name: "comments",
+			in: `--[[ a
+long comment ]]
+local a = 1 -- trailing
+--[==[ another
+]==]
+print(a - -a)`,
+			want: `local a=1 print(a- -a)`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := minify(t, c.in, srcmap.New()); got != c.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestMinifySourceMap(t *testing.T) {
+	src := `-- Boilerplate.
+local function __LOAD__()
+  local count = 1
+  print(count)
+
+  local function twice(n)
+    return n * 2
+  end
+end
+__LOAD__()`
+	m := srcmap.New()
+	m.Add(2, 6, []srcmap.Mapping{
+		{Line: 1, Source: "main.sk", SrcLine: 1, SrcCol: 1},
+		{Line: 2, Source: "main.sk", SrcLine: 2, SrcCol: 1},
+		{Line: 4, Source: "main.sk", SrcLine: 4, SrcCol: 1},
+	})
+
+	got := minify(t, src, m)
+	want := `local function __LOAD__()
+local a=1
+print(a)
+local function b(c)return c*2 end
+end __LOAD__()`
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Each statement keeps a line of its own.
+	wantLines := [][2]int{{2, 2}, {3, 3}, {4, 4}}
+	for i, mapping := range m.Mappings {
+		if got := [2]int{mapping.Line, mapping.EndLine}; got != wantLines[i] {
+			t.Errorf("mapping %d covers lines %v, want %v", i, got, wantLines[i])
+		}
+	}
+
+	// Renamed locals map back to their names within their scope.
+	for _, c := range []struct {
+		line       int
+		name, want string
+	}{
+		{2, "a", "count"},
+		{4, "a", "count"},
+		{4, "b", "twice"},
+		{4, "c", "n"},
+		// Out of scope.
+		{3, "c", "c"},
+		{6, "a", "a"},
+	} {
+		if got := m.Original(c.line, c.name); got != c.want {
+			t.Errorf("Original(%d, %q) = %q, want %q", c.line, c.name, got, c.want)
+		}
+	}
+}
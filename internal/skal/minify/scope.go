@@ -0,0 +1,588 @@
+package minify
+
+import (
+	"strings"
+)
+
+/*------------------------------------------------------------------------------
+ * Locals
+ *----------------------------------------------------------------------------*/
+
+// A local variable (or fn arg) declared by the source.
+type local struct {
+	name string
+	// The innermost local visible where this one is declared, the locals
+	// visible to it are those of the chain.
+	prev *local
+	// The name the local is renamed to.
+	short string
+	// The token declaring the local, and the last of its scope.
+	decl, last *tok
+	// The local keeps its name: the implicit `self` of methods, `_ENV` and the
+	// compiler's boilerplate (e.g. `__LOAD__`, which runtime errors are
+	// reported against).
+	keep bool
+}
+
+// The name of a local in the output.
+func (l *local) out() string {
+	if l.keep {
+		return l.name
+	}
+
+	return l.short
+}
+
+func keep(name string) bool {
+	return name == "self" || name == "_ENV" ||
+		(len(name) > 4 && strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__"))
+}
+
+/*------------------------------------------------------------------------------
+ * Resolution
+ *----------------------------------------------------------------------------*/
+
+// Resolves the names of a chunk to the locals they declare or reference, a
+// recursive descent over the statements of Lua 5.1-5.4 (and the `continue` of
+// Luau).
+type resolver struct {
+	toks []*tok
+	i    int
+	// The innermost local visible.
+	vis *local
+	// Every local, in order of declaration.
+	locals []*local
+	// Names referenced which resolve to no local.
+	globals map[string]bool
+}
+
+func resolve(toks []*tok) *resolver {
+	r := &resolver{toks: toks, globals: make(map[string]bool)}
+	r.scoped(r.block)
+	if r.i < len(r.toks) {
+		r.fail()
+	}
+
+	return r
+}
+
+func (r *resolver) peek() *tok {
+	if r.i < len(r.toks) {
+		return r.toks[r.i]
+	}
+
+	return &tok{kind: kindOp}
+}
+
+func (r *resolver) next() *tok {
+	t := r.peek()
+	r.i++
+
+	return t
+}
+
+// Indicates whether the next token is `text`, consuming it if so.
+func (r *resolver) accept(text string) bool {
+	if t := r.peek(); t.kind != kindName && t.kind != kindString && t.text == text {
+		r.i++
+		return true
+	}
+
+	return false
+}
+
+func (r *resolver) expect(text string) {
+	if !r.accept(text) {
+		r.fail()
+	}
+}
+
+func (r *resolver) name() *tok {
+	t := r.next()
+	if t.kind != kindName {
+		r.i--
+		r.fail()
+	}
+
+	return t
+}
+
+func (r *resolver) fail() {
+	t := r.peek()
+	if t.text == "" {
+		unexpected("end of output", r.toks[len(r.toks)-1].line)
+	}
+	unexpected("'"+t.text+"'", t.line)
+}
+
+// Declares the local named by `t`.
+func (r *resolver) declare(t *tok) {
+	l := &local{name: t.text, prev: r.vis, keep: keep(t.text), decl: t}
+	r.locals = append(r.locals, l)
+	r.vis = l
+	t.local = l
+}
+
+// Resolves the reference of `t` to the innermost local of its name.
+func (r *resolver) reference(t *tok) {
+	for l := r.vis; l != nil; l = l.prev {
+		if l.name == t.text {
+			t.local = l
+			return
+		}
+	}
+	r.globals[t.text] = true
+}
+
+// Resolves a block, the locals it declares go out of scope at its end.
+func (r *resolver) scoped(fn func()) {
+	vis := r.vis
+	fn()
+	for l := r.vis; l != vis; l = l.prev {
+		l.last = r.toks[min(r.i, len(r.toks))-1]
+	}
+	r.vis = vis
+}
+
+/*------------------------------------------------------------------------------
+ * Statements
+ *----------------------------------------------------------------------------*/
+
+func (r *resolver) block() {
+	for {
+		t := r.peek()
+		if t.text == "" && t.kind == kindOp {
+			return
+		}
+
+		switch t.text {
+		// Block end
+		case "end", "else", "elseif", "until":
+			if t.kind == kindKeyword {
+				return
+			}
+
+		// 'return'
+		case "return":
+			r.next()
+			if !r.blockEnd() && r.peek().text != ";" {
+				r.exprs()
+			}
+			r.accept(";")
+			return
+		}
+
+		r.statement()
+	}
+}
+
+// Indicates whether the next token ends a block.
+func (r *resolver) blockEnd() bool {
+	t := r.peek()
+	switch {
+	case t.kind == kindOp && t.text == "":
+		return true
+	case t.kind == kindKeyword:
+		return t.text == "end" || t.text == "else" || t.text == "elseif" || t.text == "until"
+	}
+
+	return false
+}
+
+func (r *resolver) statement() {
+	t := r.peek()
+	if t.kind == kindName {
+		r.nameStatement(t)
+		return
+	}
+
+	switch t.text {
+	// ';'
+	case ";":
+		r.next()
+
+	// '::' label '::'
+	case "::":
+		r.next()
+		r.name()
+		r.expect("::")
+
+	// 'break'
+	case "break":
+		r.next()
+
+	// 'do'
+	case "do":
+		r.next()
+		r.scoped(r.block)
+		r.expect("end")
+
+	// 'while'
+	case "while":
+		r.next()
+		r.expr()
+		r.expect("do")
+		r.scoped(r.block)
+		r.expect("end")
+
+	// 'repeat'
+	// The condition sees the locals of the body.
+	case "repeat":
+		r.next()
+		r.scoped(func() {
+			r.block()
+			r.expect("until")
+			r.expr()
+		})
+
+	// 'if'
+	case "if":
+		r.next()
+		r.expr()
+		r.expect("then")
+		r.scoped(r.block)
+		for r.accept("elseif") {
+			r.expr()
+			r.expect("then")
+			r.scoped(r.block)
+		}
+		if r.accept("else") {
+			r.scoped(r.block)
+		}
+		r.expect("end")
+
+	// 'for'
+	case "for":
+		r.next()
+		r.forLoop()
+
+	// 'function'
+	case "function":
+		r.next()
+		root := r.name()
+		r.reference(root)
+		method := false
+		for r.peek().text == "." || r.peek().text == ":" {
+			method = r.next().text == ":"
+			r.name()
+			if method {
+				break
+			}
+		}
+		r.body(method)
+
+	// 'local'
+	case "local":
+		r.next()
+		r.localStatement()
+
+	default:
+		r.exprStatement()
+	}
+}
+
+// Resolves a statement led by a name: `goto`, `continue`, or an expression.
+func (r *resolver) nameStatement(t *tok) {
+	after := &tok{kind: kindOp}
+	if r.i+1 < len(r.toks) {
+		after = r.toks[r.i+1]
+	}
+
+	switch {
+	// 'goto' label
+	case t.text == "goto" && after.kind == kindName:
+		r.i += 2
+
+	// 'continue'
+	case t.text == "continue" && !continues(after):
+		r.next()
+
+	default:
+		r.exprStatement()
+	}
+}
+
+// Indicates whether a name followed by `t` is an expression rather than a
+// statement keyword.
+func continues(t *tok) bool {
+	if t.kind == kindString {
+		return true
+	}
+
+	switch t.text {
+	case "=", ",", "(", ".", ":", "[", "{":
+		return t.kind == kindOp
+	}
+
+	return false
+}
+
+func (r *resolver) forLoop() {
+	first := r.name()
+
+	// 'for' i '=' start ',' stop [',' step]
+	if r.accept("=") {
+		r.exprs()
+		r.expect("do")
+		r.scoped(func() {
+			r.declare(first)
+			r.block()
+		})
+		r.expect("end")
+		return
+	}
+
+	// 'for' k, v 'in' exprs
+	names := []*tok{first}
+	for r.accept(",") {
+		names = append(names, r.name())
+	}
+	r.expect("in")
+	r.exprs()
+	r.expect("do")
+	r.scoped(func() {
+		for _, t := range names {
+			r.declare(t)
+		}
+		r.block()
+	})
+	r.expect("end")
+}
+
+// Resolves a `local` statement. The locals are in scope from the statement
+// following, their values see the locals they shadow.
+func (r *resolver) localStatement() {
+	// 'local' 'function'
+	// The fn sees itself.
+	if r.accept("function") {
+		r.declare(r.name())
+		r.body(false)
+		return
+	}
+
+	names := []*tok{r.name()}
+	r.attrib()
+	for r.accept(",") {
+		names = append(names, r.name())
+		r.attrib()
+	}
+	if r.accept("=") {
+		r.exprs()
+	}
+
+	for _, t := range names {
+		r.declare(t)
+	}
+}
+
+// Skips the attribute of a Lua 5.4 local (e.g. `<const>`).
+func (r *resolver) attrib() {
+	if r.accept("<") {
+		r.name()
+		r.expect(">")
+	}
+}
+
+func (r *resolver) exprStatement() {
+	t := r.peek()
+	if t.text == "(" {
+		t.semi = true
+	}
+
+	r.suffixed()
+	if r.peek().text != "=" && r.peek().text != "," {
+		return
+	}
+
+	// Assignment
+	for r.accept(",") {
+		r.suffixed()
+	}
+	r.expect("=")
+	r.exprs()
+}
+
+/*------------------------------------------------------------------------------
+ * Expressions
+ *----------------------------------------------------------------------------*/
+
+var (
+	unary  = map[string]bool{"not": true, "-": true, "#": true, "~": true}
+	binary = map[string]bool{
+		"or": true, "and": true, "<": true, ">": true, "<=": true, ">=": true,
+		"~=": true, "==": true, "|": true, "~": true, "&": true, "<<": true,
+		">>": true, "..": true, "+": true, "-": true, "*": true, "/": true,
+		"//": true, "%": true, "^": true,
+	}
+)
+
+func (r *resolver) exprs() {
+	r.expr()
+	for r.accept(",") {
+		r.expr()
+	}
+}
+
+// Resolves an expression. Operator precedence doesn't bear on names, operands
+// are resolved in order.
+func (r *resolver) expr() {
+	for {
+		for t := r.peek(); t.kind != kindName && t.kind != kindString && unary[t.text]; t = r.peek() {
+			r.next()
+		}
+		r.simple()
+
+		t := r.peek()
+		if t.kind == kindName || t.kind == kindString || !binary[t.text] {
+			return
+		}
+		r.next()
+	}
+}
+
+func (r *resolver) simple() {
+	t := r.peek()
+	switch {
+	case t.kind == kindNumber, t.kind == kindString:
+		r.next()
+
+	case t.kind == kindName:
+		r.suffixed()
+
+	default:
+		switch t.text {
+		// 'nil' | 'true' | 'false' | '...'
+		case "nil", "true", "false", "...":
+			r.next()
+
+		// Anonymous fn
+		case "function":
+			r.next()
+			r.body(false)
+
+		// Table
+		case "{":
+			r.table()
+
+		default:
+			r.suffixed()
+		}
+	}
+}
+
+// Resolves a reference, index, field or call chain.
+func (r *resolver) suffixed() {
+	// Primary
+	switch t := r.peek(); {
+	case t.kind == kindName:
+		r.reference(r.next())
+	case t.text == "(":
+		r.next()
+		r.expr()
+		r.expect(")")
+	default:
+		r.fail()
+	}
+
+	// Suffixes
+	for {
+		t := r.peek()
+		switch {
+		// String call
+		case t.kind == kindString:
+			r.next()
+
+		case t.kind != kindOp:
+			return
+
+		// '.' field
+		case t.text == ".":
+			r.next()
+			r.name()
+
+		// '[' index ']'
+		case t.text == "[":
+			r.next()
+			r.expr()
+			r.expect("]")
+
+		// ':' method args
+		case t.text == ":":
+			r.next()
+			r.name()
+			r.args()
+
+		// Call
+		case t.text == "(" || t.text == "{":
+			r.args()
+
+		default:
+			return
+		}
+	}
+}
+
+func (r *resolver) args() {
+	switch t := r.peek(); {
+	case t.kind == kindString:
+		r.next()
+	case t.text == "{":
+		r.table()
+	default:
+		r.expect("(")
+		if !r.accept(")") {
+			r.exprs()
+			r.expect(")")
+		}
+	}
+}
+
+func (r *resolver) table() {
+	r.expect("{")
+	for !r.accept("}") {
+		switch {
+		// '[' key ']' '=' value
+		case r.accept("["):
+			r.expr()
+			r.expect("]")
+			r.expect("=")
+			r.expr()
+
+		// key '=' value
+		case r.peek().kind == kindName && r.i+1 < len(r.toks) && r.toks[r.i+1].text == "=" && r.toks[r.i+1].kind == kindOp:
+			r.i += 2
+			r.expr()
+
+		default:
+			r.expr()
+		}
+
+		if !r.accept(",") && !r.accept(";") {
+			r.expect("}")
+			return
+		}
+	}
+}
+
+// Resolves the args and block of an fn. Methods have an implicit `self` arg.
+func (r *resolver) body(method bool) {
+	r.scoped(func() {
+		if method {
+			r.declare(&tok{kind: kindName, text: "self"})
+		}
+
+		r.expect("(")
+		for !r.accept(")") {
+			if !r.accept("...") {
+				r.declare(r.name())
+			}
+			if !r.accept(",") {
+				r.expect(")")
+				break
+			}
+		}
+
+		r.block()
+		r.expect("end")
+	})
+}
@@ -0,0 +1,258 @@
+package mod
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Archive extensions a dependency can be vendored from.
+var archiveExts = []string{".tar.gz", ".tgz", ".tar"}
+
+// Indicates whether `source` is an archive (rather than a directory).
+func isArchive(source string) bool {
+	return archiveExt(source) != ""
+}
+
+func archiveExt(source string) string {
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(strings.ToLower(source), ext) {
+			return ext
+		}
+	}
+
+	return ""
+}
+
+// Produces the default name of the dependency at `source`: its base name, less
+// any archive extension.
+func defaultName(source string) string {
+	base := filepath.Base(source)
+	return base[:len(base)-len(archiveExt(base))]
+}
+
+// Copies the tree of dependency source `source` (a directory or archive) into
+// directory `dst`, which must not exist.
+func fetch(source, dst string) error {
+	if isArchive(source) {
+		return extract(source, dst)
+	}
+
+	stat, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("'%s' is neither a directory nor a .tar, .tar.gz or .tgz archive", source)
+	}
+
+	return copyTree(source, dst)
+}
+
+// Indicates whether the file or directory `name` is left out of dependency
+// trees: hidden files such as `.git` or `.skal/cache`.
+func skipped(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+/*------------------------------------------------------------------------------
+ * Directories
+ *----------------------------------------------------------------------------*/
+
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." && skipped(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		case d.Type().IsRegular():
+			return copyFile(p, filepath.Join(dst, rel))
+		}
+
+		// Symlinks and other special files are left out.
+		return nil
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFile(dst, in)
+}
+
+func writeFile(dst string, r io.Reader) error {
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+/*------------------------------------------------------------------------------
+ * Archives
+ *----------------------------------------------------------------------------*/
+
+// Extracts archive `source` into `dst`. An archive whose entries are all
+// beneath one directory (e.g. `json-1.2/`) is extracted from that directory.
+func extract(source, dst string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if archiveExt(source) != ".tar" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %w", source, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	// Extract into a staging directory, its single top level directory (if
+	// any) is then moved into place.
+	tmp := dst + ".extract"
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %w", source, err)
+		}
+
+		// Reject entries escaping the destination.
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if name == "." {
+			continue
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("archive '%s' has an entry outside of it: '%s'", source, hdr.Name)
+		}
+		if hidden(name) {
+			continue
+		}
+
+		target := filepath.Join(tmp, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = writeFile(target, tr)
+			}
+		}
+		// Links and other special files are left out.
+		if err != nil {
+			return err
+		}
+	}
+
+	root := tmp
+	if entries, err := os.ReadDir(tmp); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		root = filepath.Join(tmp, entries[0].Name())
+	}
+
+	return os.Rename(root, dst)
+}
+
+// Indicates whether any element of slash path `name` is skipped.
+func hidden(name string) bool {
+	for _, elem := range strings.Split(name, "/") {
+		if skipped(elem) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*------------------------------------------------------------------------------
+ * Hashing
+ *----------------------------------------------------------------------------*/
+
+// Hash produces the content hash of the vendored tree at `dir`: a SHA-256 of
+// the path, size and content of each file, in path order. Hidden files are
+// left out, as they are when vendoring.
+func Hash(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." && skipped(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		// Path
+		h.Write([]byte(filepath.ToSlash(rel) + "\n"))
+		// Size
+		h.Write([]byte(strconv.Itoa(len(b)) + "\n"))
+		// Content
+		h.Write(b)
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
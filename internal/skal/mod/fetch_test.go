@@ -0,0 +1,278 @@
+package mod
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A single entry of a test archive.
+type entry struct {
+	name string
+	// Defaults to a regular file.
+	typeflag byte
+	body     string
+	link     string
+}
+
+// Writes the archive of `entries` to `dir/name`, gzipped unless `name` ends in
+// `.tar`.
+func writeArchive(t *testing.T, dir, name string, entries []entry) string {
+	t.Helper()
+
+	p := filepath.Join(dir, name)
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if archiveExt(name) != ".tar" {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.link, Mode: 0644}
+		switch e.typeflag {
+		case 0:
+			hdr.Typeflag, hdr.Size = tar.TypeReg, int64(len(e.body))
+		case tar.TypeDir:
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+// Lists the files beneath `dir` as slash paths mapped to their content.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			files[filepath.ToSlash(rel)] = "<" + d.Type().String() + ">"
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		files[filepath.ToSlash(rel)] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+var jsonEntries = []entry{
+	{name: "json-1.2/", typeflag: tar.TypeDir},
+	{name: "json-1.2/init.sk", body: "pub fn parse(s) {}\n"},
+	{name: "json-1.2/lib/encode.sk", body: "pub fn encode(v) {}\n"},
+	{name: "json-1.2/.git/config", body: "[core]\n"},
+	{name: "json-1.2/link.sk", typeflag: tar.TypeSymlink, link: "init.sk"},
+	{name: "json-1.2/hard.sk", typeflag: tar.TypeLink, link: "json-1.2/init.sk"},
+}
+
+func TestExtract(t *testing.T) {
+	// Hidden files and links are left out, the single top level directory is
+	// extracted from.
+	want := map[string]string{
+		"init.sk":       "pub fn parse(s) {}\n",
+		"lib/encode.sk": "pub fn encode(v) {}\n",
+	}
+
+	for _, ext := range archiveExts {
+		t.Run(ext, func(t *testing.T) {
+			dir := t.TempDir()
+			source := writeArchive(t, dir, "json-1.2"+ext, jsonEntries)
+			dst := filepath.Join(dir, "deps", defaultName(source))
+
+			if err := fetch(source, dst); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTree(t, dst); !reflect.DeepEqual(got, want) {
+				t.Errorf("extracted %v, want %v", got, want)
+			}
+			if _, err := os.Stat(dst + ".extract"); !os.IsNotExist(err) {
+				t.Errorf("staging directory left behind: %v", err)
+			}
+		})
+	}
+}
+
+// Archives without a single top level directory are extracted as is.
+func TestExtractFlat(t *testing.T) {
+	dir := t.TempDir()
+	source := writeArchive(t, dir, "flat.tar", []entry{
+		{name: "./init.sk", body: "a"},
+		{name: "util.sk", body: "b"},
+	})
+	dst := filepath.Join(dir, "flat")
+
+	if err := fetch(source, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readTree(t, dst), map[string]string{"init.sk": "a", "util.sk": "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extracted %v, want %v", got, want)
+	}
+}
+
+func TestExtractOutside(t *testing.T) {
+	for _, name := range []string{
+		"../evil.sk",
+		"json/../../evil.sk",
+		"/tmp/evil.sk",
+		"..",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			source := writeArchive(t, dir, "json.tgz", []entry{
+				{name: "json/init.sk", body: "a"},
+				{name: name, body: "evil"},
+			})
+			dst := filepath.Join(dir, "deps", "json")
+
+			err := fetch(source, dst)
+			if err == nil || !strings.Contains(err.Error(), "has an entry outside of it") {
+				t.Fatalf("fetch() = %v, want an entry outside of the archive", err)
+			}
+			for _, p := range []string{dst, dst + ".extract", filepath.Join(dir, "evil.sk"), filepath.Join(dir, "deps", "evil.sk")} {
+				if _, err := os.Stat(p); !os.IsNotExist(err) {
+					t.Errorf("%s exists after a failed extract", p)
+				}
+			}
+		})
+	}
+}
+
+// The extension selects the compression: a `.tgz` must be gzipped, a `.tar`
+// must not be.
+func TestExtractCompression(t *testing.T) {
+	dir := t.TempDir()
+	plain := writeArchive(t, dir, "json.tar", jsonEntries)
+	if err := os.Rename(plain, filepath.Join(dir, "json.tgz")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch(filepath.Join(dir, "json.tgz"), filepath.Join(dir, "a")); err == nil {
+		t.Error("extracted an uncompressed .tgz")
+	}
+
+	gzipped := writeArchive(t, dir, "json.tar.gz", jsonEntries)
+	if err := os.Rename(gzipped, filepath.Join(dir, "json.tar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch(filepath.Join(dir, "json.tar"), filepath.Join(dir, "b")); err == nil {
+		t.Error("extracted a gzipped .tar")
+	}
+}
+
+func TestFetchDir(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "json")
+	for name, body := range map[string]string{
+		"init.sk":       "pub fn parse(s) {}\n",
+		"lib/encode.sk": "pub fn encode(v) {}\n",
+		".git/config":   "[core]\n",
+	} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("init.sk", filepath.Join(src, "link.sk")); err != nil {
+		t.Log("symlinks unsupported:", err)
+	}
+
+	dst := filepath.Join(dir, "deps", "json")
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"init.sk":       "pub fn parse(s) {}\n",
+		"lib/encode.sk": "pub fn encode(v) {}\n",
+	}
+	if got := readTree(t, dst); !reflect.DeepEqual(got, want) {
+		t.Errorf("copied %v, want %v", got, want)
+	}
+}
+
+// A tree hashes the same however it was vendored: whatever the order of the
+// archive's entries, and from an archive or a directory.
+func TestHash(t *testing.T) {
+	dir := t.TempDir()
+	hash := func(name string, entries []entry) string {
+		t.Helper()
+		dst := filepath.Join(dir, name)
+		if err := fetch(writeArchive(t, dir, name+".tgz", entries), dst); err != nil {
+			t.Fatal(err)
+		}
+		h, err := Hash(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	want := hash("ordered", jsonEntries)
+	if !strings.HasPrefix(want, "sha256:") {
+		t.Errorf("Hash() = %q, want a sha256", want)
+	}
+
+	reversed := make([]entry, 0, len(jsonEntries))
+	for i := len(jsonEntries) - 1; i >= 0; i-- {
+		reversed = append(reversed, jsonEntries[i])
+	}
+	if got := hash("reversed", reversed); got != want {
+		t.Errorf("Hash() of the reversed archive = %q, want %q", got, want)
+	}
+
+	// Hidden files don't take part.
+	if got := hash("hidden", append(jsonEntries[:len(jsonEntries):len(jsonEntries)],
+		entry{name: "json-1.2/.skal/cache", body: "x"})); got != want {
+		t.Errorf("Hash() with a hidden file = %q, want %q", got, want)
+	}
+
+	// Content, and which file holds it, do.
+	for name, entries := range map[string][]entry{
+		"content": {{name: "json-1.2/init.sk", body: "pub fn parse(s) { }\n"}, jsonEntries[2]},
+		"moved":   {{name: "json-1.2/init.sk", body: jsonEntries[1].body}, {name: "json-1.2/encode.sk", body: jsonEntries[2].body}},
+	} {
+		if got := hash(name, entries); got == want {
+			t.Errorf("Hash() of the %s archive = the original's", name)
+		}
+	}
+}
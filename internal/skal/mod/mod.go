@@ -0,0 +1,260 @@
+// Package mod manages the dependencies of a project. The sources declared by
+// the `[deps]` table of the manifest (local directories or archives) are copied
+// into its `deps/` directory, and the content hash of each copy is pinned by
+// the lockfile. Nothing is fetched from the network.
+package mod
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/illbjorn/fstr"
+	"github.com/illbjorn/skal/internal/skal/manifest"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/vfs"
+)
+
+// Add declares the dependency at `source` (a directory or .tar, .tar.gz or .tgz
+// archive, relative to the working directory) as `name`, vendors it and pins
+// it. A dependency already named `name` is replaced. If `name` is empty, the
+// base name of `source` is used.
+//
+// Problems with the lockfile are reported to `diags`, progress is written to
+// `out`.
+func Add(m *manifest.Manifest, source, name string, diags *sklog.Diagnostics, out io.Writer) error {
+	if name == "" {
+		name = defaultName(source)
+	}
+	if !manifest.ValidName(name) {
+		return fmt.Errorf("invalid dependency name '%s', expected letters, digits, '_' or '-' (name it with 'skal mod add <source> <name>')", name)
+	}
+
+	// Sources are declared relative to the manifest.
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(m.Dir(), abs); err == nil {
+		source = filepath.ToSlash(rel)
+	}
+
+	l, err := loadLock(m, diags)
+	if err != nil {
+		return err
+	}
+
+	// Vendor
+	hash, err := vendor(m, name, source, "")
+	if err != nil {
+		return err
+	}
+
+	// Declare
+	b, err := os.ReadFile(m.Path)
+	if err != nil {
+		return err
+	}
+	src, err := manifest.SetDep(string(b), name, source)
+	if err != nil {
+		return fmt.Errorf("failed to edit '%s': %w", m.Path, err)
+	}
+	if err := os.WriteFile(m.Path, []byte(src), 0644); err != nil {
+		return err
+	}
+
+	// Pin
+	l.Deps[name] = manifest.Locked{Source: source, Hash: hash}
+	if err := writeLock(l); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, fstr.Pairs("Added {name} from {source} ({hash}).", "name", name, "source", source, "hash", hash))
+	return nil
+}
+
+// Tidy reconciles the lockfile and the vendored dependencies with the
+// manifest. Dependencies it no longer declares are removed. Those it declares
+// which aren't pinned, or were pinned from another source, are vendored and
+// pinned. Pinned dependencies missing from `deps/` are vendored again.
+func Tidy(m *manifest.Manifest, diags *sklog.Diagnostics, out io.Writer) error {
+	l, err := loadLock(m, diags)
+	if err != nil {
+		return err
+	}
+
+	// Removed
+	for _, name := range sorted(l.Deps) {
+		if _, ok := m.Deps[name]; ok {
+			continue
+		}
+
+		delete(l.Deps, name)
+		if err := os.RemoveAll(filepath.Join(m.DepsDir(), name)); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, fstr.Pairs("Removed {name}.", "name", name))
+	}
+
+	// Added or changed
+	for _, name := range sorted(m.Deps) {
+		source := m.Deps[name]
+		pin, ok := l.Deps[name]
+		if ok && pin.Source == source {
+			if _, err := os.Stat(filepath.Join(m.DepsDir(), name)); err == nil {
+				continue
+			}
+			if _, err := vendor(m, name, source, pin.Hash); err != nil {
+				return err
+			}
+			fmt.Fprintln(out, fstr.Pairs("Vendored {name} from {source}.", "name", name, "source", source))
+			continue
+		}
+
+		hash, err := vendor(m, name, source, "")
+		if err != nil {
+			return err
+		}
+		l.Deps[name] = manifest.Locked{Source: source, Hash: hash}
+		fmt.Fprintln(out, fstr.Pairs("Pinned {name} from {source} ({hash}).", "name", name, "source", source, "hash", hash))
+	}
+
+	return writeLock(l)
+}
+
+// Vendor copies every dependency declared by the manifest from its source into
+// `deps/`, replacing the existing copies. Each copy must match its pin.
+func Vendor(m *manifest.Manifest, diags *sklog.Diagnostics, out io.Writer) error {
+	l, err := loadLock(m, diags)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range sorted(m.Deps) {
+		source := m.Deps[name]
+		pin, ok := l.Deps[name]
+		if !ok || pin.Source != source {
+			return fmt.Errorf("dependency '%s' isn't pinned by %s, run 'skal mod tidy'", name, manifest.LockName)
+		}
+
+		if _, err := vendor(m, name, source, pin.Hash); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, fstr.Pairs("Vendored {name} from {source}.", "name", name, "source", source))
+	}
+
+	return nil
+}
+
+// Verify checks that each dependency declared by the manifest is vendored and
+// matches its pin. Problems are reported to `diags`.
+func Verify(m *manifest.Manifest, diags *sklog.Diagnostics) {
+	if len(m.Deps) == 0 {
+		return
+	}
+
+	l := manifest.LoadLock(vfs.New(nil), m, diags)
+	if l == nil {
+		return
+	}
+
+	for _, name := range sorted(m.Deps) {
+		pin, ok := l.Deps[name]
+		if !ok || pin.Source != m.Deps[name] {
+			verifyError(diags,
+				"Dependency '{name}' isn't pinned by {lock}, run 'skal mod tidy'.",
+				"name", name,
+				"lock", l.Path,
+			)
+			continue
+		}
+
+		hash, err := Hash(filepath.Join(m.DepsDir(), name))
+		switch {
+		case err != nil:
+			verifyError(diags,
+				"Dependency '{name}' isn't vendored, run 'skal mod vendor'.",
+				"name", name,
+			)
+		case hash != pin.Hash:
+			verifyError(diags,
+				"Vendored dependency '{name}' doesn't match its pin in {lock}, it was modified. Run 'skal mod vendor' to restore it.",
+				"name", name,
+				"lock", l.Path,
+			)
+		}
+	}
+}
+
+func verifyError(diags *sklog.Diagnostics, msg string, pairs ...string) {
+	sklog.NewCompilerEvent(sklog.MsgTypeManifestError, sklog.LevelError).
+		To(diags).
+		WithCode(sklog.CodeDepMismatch).
+		AddF(msg, pairs...).
+		Send()
+}
+
+// Vendors dependency `name` from `source` into `deps/`, returning the hash of
+// the copy. If `pin` is set, the copy must match it. The existing copy is only
+// replaced once the new one is complete.
+func vendor(m *manifest.Manifest, name, source, pin string) (string, error) {
+	if err := os.MkdirAll(m.DepsDir(), 0755); err != nil {
+		return "", err
+	}
+
+	// Stage the copy.
+	tmp := filepath.Join(m.DepsDir(), "."+name+".tmp")
+	if err := os.RemoveAll(tmp); err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := fetch(m.SourcePath(source), tmp); err != nil {
+		return "", fmt.Errorf("failed to vendor '%s' from '%s': %w", name, source, err)
+	}
+
+	hash, err := Hash(tmp)
+	if err != nil {
+		return "", err
+	}
+	if pin != "" && hash != pin {
+		return "", fmt.Errorf(
+			"the source of '%s' ('%s') doesn't match its pin in %s, it changed since it was pinned. Run 'skal mod add %s %s' to pin it again",
+			name, source, manifest.LockName, source, name)
+	}
+
+	// Replace the existing copy.
+	dst := filepath.Join(m.DepsDir(), name)
+	if err := os.RemoveAll(dst); err != nil {
+		return "", err
+	}
+
+	return hash, os.Rename(tmp, dst)
+}
+
+// Loads the lockfile, problems with it are reported to `diags`.
+func loadLock(m *manifest.Manifest, diags *sklog.Diagnostics) (*manifest.Lock, error) {
+	l := manifest.LoadLock(vfs.New(nil), m, diags)
+	if err := diags.Err(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func writeLock(l *manifest.Lock) error {
+	return os.WriteFile(l.Path, l.Bytes(), 0644)
+}
+
+// Lists the keys of `m` in order.
+func sorted[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,101 @@
+package opt
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+/*------------------------------------------------------------------------------
+ * Dead Fns
+ *----------------------------------------------------------------------------*/
+
+// Removes the non-`pub` named fns the module never references (other than from
+// their own body), until none remain. Other modules can't reference them.
+func removeDead(set typeset.TypeSet) typeset.TypeSet {
+	for {
+		uses := names(func(w *walker) { w.module(set) })
+
+		dead := make(map[*typeset.Fn]bool)
+		w := &walker{
+			decl: func(fn *typeset.Fn) {
+				if fn.Pub() || fn.RefsLen() != 1 || fn.Method || fn.Constructor {
+					return
+				}
+				own := names(func(w *walker) { w.fn(fn) })
+				if uses[fn.ID()] == own[fn.ID()] {
+					dead[fn] = true
+				}
+			},
+		}
+		w.module(set)
+		if len(dead) == 0 {
+			return set
+		}
+
+		// Top-level
+		members := set.Members[:0:0]
+		for _, member := range set.Members {
+			if fn, ok := member.Value.(*typeset.Fn); !ok || !dead[fn] {
+				members = append(members, member)
+			}
+		}
+		set.Members = members
+
+		// Blocks
+		w = &walker{
+			block: func(block []*typeset.Statement) []*typeset.Statement {
+				out := block[:0:0]
+				for _, stmt := range block {
+					if stmt.StmtType != token.Fn || !dead[stmt.Fn] {
+						out = append(out, stmt)
+					}
+				}
+				return out
+			},
+		}
+		w.module(set)
+	}
+}
+
+// Matches the names used to index a reference (e.g. the `i` of `a[i]`).
+var ident = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// Counts the references to each name in the nodes `visit` walks. Names are
+// counted regardless of the symbol they resolve to, so a shadowed name keeps
+// the declarations it shadows.
+func names(visit func(w *walker)) map[string]int {
+	uses := make(map[string]int)
+	note := func(refs []string) {
+		for i, ref := range refs {
+			switch {
+			// Index
+			case strings.HasPrefix(ref, "["):
+				for _, name := range ident.FindAllString(ref, -1) {
+					uses[name]++
+				}
+
+			// Root
+			case i == 0:
+				uses[ref]++
+			}
+		}
+	}
+
+	visit(&walker{
+		ref: func(t typeset.SkalType) {
+			// `for i = 1, n`
+			if v, ok := t.(*typeset.ForV); ok && v.IterableType == token.ID {
+				uses[v.Value]++
+			}
+			note(t.Refs())
+		},
+		bound: func(b *typeset.Base) {
+			note(b.Refs())
+		},
+	})
+
+	return uses
+}
@@ -0,0 +1,451 @@
+package opt
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+/*------------------------------------------------------------------------------
+ * Constant Folding
+ *----------------------------------------------------------------------------*/
+
+// Folds a run of values, evaluating the operators whose operands are literals.
+//
+//	60 * 60 * 24    ->  86400
+//	'v' .. 1 .. 2   ->  'v12'
+//	!(3 > 2)        ->  false
+func fold(run []*typeset.Value) []*typeset.Value {
+	x := typeset.ParseExpr(run)
+
+	// Leave runs the parser doesn't consume whole as they are.
+	if len(x.Values()) != len(run) {
+		return run
+	}
+
+	run = foldExpr(x).Values()
+
+	// A run holding only a group of operators (e.g. an inlined fn body) needs
+	// no parens.
+	if len(run) == 1 && run[0].ValueType == token.ValueGroup && len(run[0].Group) > 1 {
+		return run[0].Group
+	}
+
+	return run
+}
+
+func foldExpr(x *typeset.Expr) *typeset.Expr {
+	switch {
+	case x == nil:
+		return nil
+
+	// Operand
+	case x.Op == nil:
+		// A group holding only a literal is the literal.
+		if v := x.Value; v.ValueType == token.ValueGroup && len(v.Group) == 1 {
+			if _, ok := constOf(v.Group[0]); ok {
+				return &typeset.Expr{Value: v.Group[0]}
+			}
+		}
+		return x
+
+	// '!'
+	case x.LHS == nil:
+		rhs := foldExpr(x.RHS)
+		if c, ok := constOf(rhs.Value); ok && rhs.Op == nil {
+			return &typeset.Expr{Value: boolL(x.Op.SkalType, !c.truthy())}
+		}
+		return &typeset.Expr{Op: x.Op, RHS: rhs}
+	}
+
+	lhs, rhs := foldExpr(x.LHS), foldExpr(x.RHS)
+	l, lok := constOf(lhs.Value)
+	lok = lok && lhs.Op == nil
+
+	// '&&' | '||' with a literal left operand produce one of their operands.
+	switch {
+	case lok && x.Op.Op == token.And.String():
+		if l.truthy() {
+			return rhs
+		}
+		return lhs
+
+	case lok && x.Op.Op == token.Or.String():
+		if l.truthy() {
+			return lhs
+		}
+		return rhs
+	}
+
+	r, rok := constOf(rhs.Value)
+	if lok && rok && rhs.Op == nil {
+		if v := eval(x.Op, l, r); v != nil {
+			return &typeset.Expr{Value: v}
+		}
+	}
+
+	return &typeset.Expr{Op: x.Op, LHS: lhs, RHS: rhs}
+}
+
+// The largest integer every target represents exactly (numbers are doubles on
+// Lua 5.1, 5.2 and LuaJIT).
+const maxExactInt = 1 << 53
+
+// Integers print alike on every target below this (Lua 5.1 formats numbers
+// with `%.14g`).
+const maxPrintInt = 1e14
+
+// A literal operand.
+type constant struct {
+	kind token.Type
+	n    int64
+	s    string
+	b    bool
+}
+
+// Produces the constant of literal `v`. Integers beyond the exact range of
+// doubles and other number literals (e.g. `1.5`) aren't folded.
+func constOf(v *typeset.Value) (constant, bool) {
+	if v == nil {
+		return constant{}, false
+	}
+
+	switch v.ValueType {
+	// IntL
+	case token.IntL:
+		n, err := strconv.ParseInt(v.IntL, 10, 64)
+		if err != nil || n > maxExactInt || n < -maxExactInt {
+			return constant{}, false
+		}
+		return constant{kind: token.IntL, n: n}, true
+
+	// StrL
+	case token.StrL:
+		return constant{kind: token.StrL, s: v.StrL}, true
+
+	// BoolL
+	case token.BoolL:
+		return constant{kind: token.BoolL, b: v.BoolL == token.True.String()}, true
+
+	// Nil
+	case token.Nil:
+		return constant{kind: token.Nil}, true
+	}
+
+	return constant{}, false
+}
+
+// Only `false` and `nil` are falsy in Lua.
+func (c constant) truthy() bool {
+	switch c.kind {
+	case token.Nil:
+		return false
+	case token.BoolL:
+		return c.b
+	default:
+		return true
+	}
+}
+
+// Evaluates binary operator `op` over two literal operands, producing the
+// literal result. Returns nil where the result isn't the same on every target.
+func eval(op *typeset.Value, l, r constant) *typeset.Value {
+	switch op.Op {
+	// '+' | '-' | '*' | '//'
+	case token.Plus.String(), token.Minus.String(), token.Mult.String(), token.FloorDiv.String():
+		if l.kind != token.IntL || r.kind != token.IntL {
+			return nil
+		}
+		n, ok := arith(op.Op, l.n, r.n)
+		if !ok {
+			return nil
+		}
+		return intL(op.SkalType, n)
+
+	// '..'
+	case token.Concat.String():
+		ls, lok := l.str()
+		rs, rok := r.str()
+		if !lok || !rok {
+			return nil
+		}
+		return strL(op.SkalType, ls+rs)
+
+	// '==' | '!='
+	case token.EQEQ.String(), token.NE.String():
+		eq, ok := equal(l, r)
+		if !ok {
+			return nil
+		}
+		return boolL(op.SkalType, eq == (op.Op == token.EQEQ.String()))
+
+	// '<' | '>' | '<=' | '>='
+	// Strings compare by locale, only integers are folded.
+	case token.LT.String(), token.GT.String(), token.LE.String(), token.GE.String():
+		if l.kind != token.IntL || r.kind != token.IntL {
+			return nil
+		}
+		switch op.Op {
+		case token.LT.String():
+			return boolL(op.SkalType, l.n < r.n)
+		case token.GT.String():
+			return boolL(op.SkalType, l.n > r.n)
+		case token.LE.String():
+			return boolL(op.SkalType, l.n <= r.n)
+		default:
+			return boolL(op.SkalType, l.n >= r.n)
+		}
+	}
+
+	// '/' produces floats (which print differently across targets), bitwise
+	// operators are lowered per target.
+	return nil
+}
+
+func arith(op string, a, b int64) (int64, bool) {
+	var n int64
+	switch op {
+	case token.Plus.String():
+		n = a + b
+	case token.Minus.String():
+		n = a - b
+	case token.Mult.String():
+		if a != 0 && (b > maxExactInt/abs(a) || b < -maxExactInt/abs(a)) {
+			return 0, false
+		}
+		n = a * b
+	default:
+		if b == 0 {
+			return 0, false
+		}
+		// Lua floors the quotient, Go truncates it.
+		n = a / b
+		if (a%b != 0) && ((a < 0) != (b < 0)) {
+			n--
+		}
+	}
+
+	return n, n <= maxExactInt && n >= -maxExactInt
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// Produces the text of a concatenated operand.
+func (c constant) str() (string, bool) {
+	switch {
+	case c.kind == token.StrL:
+		return c.s, true
+	case c.kind == token.IntL && c.n < maxPrintInt && c.n > -maxPrintInt:
+		return strconv.FormatInt(c.n, 10), true
+	}
+
+	return "", false
+}
+
+// Compares two literals. Strings holding escape sequences are left unfolded,
+// two spellings may produce the same string.
+func equal(l, r constant) (bool, bool) {
+	if l.kind != r.kind {
+		return false, true
+	}
+
+	switch l.kind {
+	case token.IntL:
+		return l.n == r.n, true
+	case token.StrL:
+		if strings.Contains(l.s, `\`) || strings.Contains(r.s, `\`) {
+			return false, false
+		}
+		return l.s == r.s, true
+	case token.BoolL:
+		return l.b == r.b, true
+	default:
+		return true, true
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Literals
+ *----------------------------------------------------------------------------*/
+
+// Literals produced by folding are positioned at the operator they replace.
+
+func intL(at typeset.SkalType, n int64) *typeset.Value {
+	return &typeset.Value{SkalType: at, ValueType: token.IntL, IntL: strconv.FormatInt(n, 10)}
+}
+
+func strL(at typeset.SkalType, s string) *typeset.Value {
+	return &typeset.Value{SkalType: at, ValueType: token.StrL, StrL: s}
+}
+
+func boolL(at typeset.SkalType, b bool) *typeset.Value {
+	return &typeset.Value{SkalType: at, ValueType: token.BoolL, BoolL: strconv.FormatBool(b)}
+}
+
+/*------------------------------------------------------------------------------
+ * Branches
+ *----------------------------------------------------------------------------*/
+
+// A single branch of a conditional.
+type branch struct {
+	at    typeset.SkalType
+	conds []*typeset.Value
+	block []*typeset.Statement
+}
+
+// Indicates whether a run of conditions has folded to a literal, and whether
+// it's truthy.
+func constCond(conds []*typeset.Value) (truthy, ok bool) {
+	if len(conds) != 1 {
+		return false, false
+	}
+
+	c, ok := constOf(conds[0])
+	if !ok {
+		// Other literals are tables and fns.
+		switch conds[0].ValueType {
+		case token.ListL, token.List, token.Fn:
+			return true, true
+		}
+		return false, false
+	}
+
+	return c.truthy(), true
+}
+
+// Drops the branches of conditional `nif` whose condition is a falsy literal,
+// and those following a branch whose condition is a truthy literal. Returns
+// the statements to replace the conditional with: nothing if no branch
+// remains, the conditional otherwise.
+func prune(stmt *typeset.Statement) []*typeset.Statement {
+	nif := stmt.If
+
+	branches := []branch{{at: nif, conds: nif.Conditions, block: nif.Block}}
+	for _, elif := range nif.Elifs {
+		branches = append(branches, branch{at: elif, conds: elif.Conditions, block: elif.Block})
+	}
+
+	var (
+		kept    []branch
+		els     *branch
+		changed bool
+	)
+	for i := 0; i < len(branches) && els == nil; i++ {
+		truthy, ok := constCond(branches[i].conds)
+		switch {
+		case !ok:
+			kept = append(kept, branches[i])
+
+		case !truthy:
+			changed = true
+
+		// The first truthy branch always runs, it's the `else` of those before
+		// it.
+		default:
+			changed = true
+			els = &branches[i]
+		}
+	}
+	if els == nil && nif.Else != nil {
+		els = &branch{at: nif.Else, block: nif.Else.Block}
+	}
+	if !changed {
+		return []*typeset.Statement{stmt}
+	}
+
+	switch {
+	// Nothing runs.
+	case len(kept) == 0 && els == nil:
+		return nil
+
+	// A single block always runs.
+	case len(kept) == 0:
+		if spliceable(els.block) {
+			return els.block
+		}
+		nif.Conditions = []*typeset.Value{boolL(els.at, true)}
+		nif.Block, nif.Elifs, nif.Else = els.block, nil, nil
+		return []*typeset.Statement{stmt}
+	}
+
+	nif.Conditions, nif.Block = kept[0].conds, kept[0].block
+	nif.Elifs = nil
+	for _, b := range kept[1:] {
+		nif.Elifs = append(nif.Elifs, &typeset.Elif{SkalType: b.at, Conditions: b.conds, Block: b.block})
+	}
+	nif.Else = nil
+	if els != nil {
+		nif.Else = &typeset.Else{SkalType: els.at, Block: els.block}
+	}
+
+	return []*typeset.Statement{stmt}
+}
+
+// Indicates whether a block can replace the conditional holding it, unwrapped:
+// it declares no locals or defers, and doesn't end its block early (Lua
+// permits nothing to follow a `return`, nor a `break` on Lua 5.1).
+func spliceable(block []*typeset.Statement) bool {
+	for _, stmt := range block {
+		switch stmt.StmtType {
+		case token.Bind, token.Fn, token.Defer, token.Ret, token.Continue:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Prunes the conditionals of a block.
+func pruneBlock(block []*typeset.Statement) []*typeset.Statement {
+	out := block[:0:0]
+	for _, stmt := range block {
+		if stmt.StmtType != token.If {
+			out = append(out, stmt)
+			continue
+		}
+		out = append(out, prune(stmt)...)
+	}
+
+	return out
+}
+
+// Prunes the conditionals of a module's top-level.
+func pruneMembers(set typeset.TypeSet) typeset.TypeSet {
+	members := set.Members[:0:0]
+	for _, member := range set.Members {
+		nif, ok := member.Value.(*typeset.If)
+		if !ok {
+			members = append(members, member)
+			continue
+		}
+
+		for _, stmt := range prune(&typeset.Statement{SkalType: nif, StmtType: token.If, If: nif}) {
+			members = append(members, typeset.Type{Value: stmtValue(stmt), ID: member.ID, Type: stmt.StmtType})
+		}
+	}
+	set.Members = members
+
+	return set
+}
+
+// Produces the top-level member of statement `stmt`.
+func stmtValue(stmt *typeset.Statement) any {
+	switch stmt.StmtType {
+	case token.Call:
+		return stmt.Call
+	case token.Rebind:
+		return stmt.Bind
+	case token.For:
+		return stmt.For
+	default:
+		return stmt.If
+	}
+}
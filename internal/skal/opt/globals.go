@@ -0,0 +1,189 @@
+package opt
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/decl"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+/*------------------------------------------------------------------------------
+ * Global Lookups
+ *----------------------------------------------------------------------------*/
+
+// Caches the host fns each named fn (and the module's top-level code) calls
+// more than once, or within a loop, in locals declared at the top of the fn (or
+// module). A call through a local skips looking up the host table in the
+// environment, then the fn in the table.
+//
+//	for i = 1, n {
+//	  total = total + math.random(6)
+//	}
+//
+// is emitted as:
+//
+//	local _math_random = math.random
+//	for i = 1, n do
+//	  total = total + _math_random(6)
+//	end
+func cacheGlobals(set typeset.TypeSet) typeset.TypeSet {
+	taken := names(func(w *walker) { w.module(set) })
+
+	w := &walker{
+		decl: func(fn *typeset.Fn) {
+			fn.Block = append(cacheCalls(func(w *walker) { w.fn(fn) }, taken), fn.Block...)
+		},
+	}
+	w.module(set)
+
+	// Top-level
+	// Named fns and struct methods cache their own calls.
+	var chunk typeset.TypeSet
+	for _, member := range set.Members {
+		switch member.Value.(type) {
+		case *typeset.Fn, *typeset.Struct:
+		default:
+			chunk.Members = append(chunk.Members, member)
+		}
+	}
+	locals := cacheCalls(func(w *walker) { w.module(chunk) }, taken)
+	members := make([]typeset.Type, 0, len(locals)+len(set.Members))
+	for _, local := range locals {
+		members = append(members, typeset.Type{Value: local.Bind, ID: local.Bind.Binds[0].ID(), Type: token.Bind})
+	}
+	set.Members = append(members, set.Members...)
+
+	return set
+}
+
+// Cached produces the host fns cached in locals of optimized module `set`, by
+// the name of the local (e.g. `_math_floor` -> `math.floor`). Runtime errors
+// name a fn by the local it was called through.
+func Cached(set typeset.TypeSet) map[string]string {
+	out := make(map[string]string)
+	leading := func(v any) bool {
+		bind, ok := v.(*typeset.Bind)
+		if !ok || bind.Rebind || len(bind.Binds) != 1 || len(bind.Values) != 1 ||
+			bind.Values[0].ValueType != token.Ref {
+			return false
+		}
+
+		name, path := bind.Binds[0].Ref(), bind.Values[0].Ref()
+		suffix, ok := strings.CutPrefix(name, "_"+strings.ReplaceAll(path, ".", "_"))
+		if ok && strings.Trim(suffix, "0123456789") == "" {
+			out[name] = path
+		}
+		return true
+	}
+
+	// The locals lead the module, and each named fn.
+	for _, member := range set.Members {
+		if !leading(member.Value) {
+			break
+		}
+	}
+	w := &walker{
+		decl: func(fn *typeset.Fn) {
+			for _, stmt := range fn.Block {
+				if stmt.StmtType != token.Bind || !leading(stmt.Bind) {
+					break
+				}
+			}
+		},
+	}
+	w.module(set)
+
+	return out
+}
+
+// Rewrites the host fn calls visited by `visit` which are made more than once,
+// or within a loop, to call through locals. The declarations of the locals are
+// returned, to be placed ahead of the calls.
+func cacheCalls(visit func(w *walker), taken map[string]int) []*typeset.Statement {
+	calls := make(map[string][]*typeset.Call)
+	hot := make(map[string]bool)
+
+	// Fns declared within the body cache their own calls.
+	w := &walker{shallow: true}
+	w.call = func(call *typeset.Call) {
+		if !hostFn(call) {
+			return
+		}
+		path := call.Ref()
+		calls[path] = append(calls[path], call)
+		if w.loops > 0 {
+			hot[path] = true
+		}
+	}
+	visit(w)
+
+	paths := make([]string, 0, len(calls))
+	for path := range calls {
+		if len(calls[path]) > 1 || hot[path] {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	locals := make([]*typeset.Statement, 0, len(paths))
+	for _, path := range paths {
+		name := local(path, taken)
+		locals = append(locals, declare(name, calls[path][0].Refs()))
+
+		for _, call := range calls[path] {
+			ref := new(typeset.Base)
+			ref.AddRef(name)
+			ref.SetToken(call.Token())
+			call.SkalType = ref
+		}
+	}
+
+	return locals
+}
+
+// Indicates whether `call` calls a fn of a declared host table (e.g.
+// `math.random`).
+func hostFn(call *typeset.Call) bool {
+	sym := call.Symbol()
+	refs := call.Refs()
+	if !sym.HostTable() || len(refs) != 2 || strings.HasPrefix(refs[1], "[") {
+		return false
+	}
+
+	m := sym.Extern.Member(refs[1])
+	return m != nil && m.Kind == decl.KindFn
+}
+
+// Produces an unused local name for host fn `path`.
+func local(path string, taken map[string]int) string {
+	base := "_" + strings.ReplaceAll(path, ".", "_")
+	name := base
+	for i := 2; taken[name] > 0; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	taken[name]++
+
+	return name
+}
+
+// Produces the declaration of local `name`, bound to reference `refs`.
+func declare(name string, refs []string) *typeset.Statement {
+	bound := new(typeset.Base)
+	bound.AddRef(name)
+
+	host := new(typeset.Base)
+	for _, ref := range refs {
+		host.AddRef(ref)
+	}
+
+	bind := &typeset.Bind{
+		SkalType: new(typeset.Base),
+		Binds:    []*typeset.Base{bound},
+		Values:   []*typeset.Value{{SkalType: host, ValueType: token.Ref}},
+	}
+
+	return &typeset.Statement{SkalType: bind, StmtType: token.Bind, Bind: bind}
+}
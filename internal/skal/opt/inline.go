@@ -0,0 +1,289 @@
+package opt
+
+import (
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+/*------------------------------------------------------------------------------
+ * Enums
+ *----------------------------------------------------------------------------*/
+
+// Replaces enum member references with the member's value.
+//
+//	UnitType.FRIEND  ->  'friend'
+func inlineEnums(run []*typeset.Value) []*typeset.Value {
+	for i, v := range run {
+		if lit := enumValue(v); lit != nil {
+			run[i] = lit
+		}
+	}
+
+	return run
+}
+
+// Produces the literal value of enum member reference `v`, or nil if `v`
+// isn't one.
+func enumValue(v *typeset.Value) *typeset.Value {
+	sym := v.Symbol()
+	if v.ValueType != token.Ref || v.RefsLen() != 2 || sym == nil || sym.Kind != typeset.SymEnum {
+		return nil
+	}
+	enum, ok := sym.Decl.(*typeset.Enum)
+	if !ok {
+		return nil
+	}
+
+	for _, m := range enum.Members {
+		if m.ID() != v.ID() || m.Value == "" {
+			continue
+		}
+
+		switch m.ValueType {
+		case token.StrL.String():
+			return strL(v.SkalType, m.Value)
+		case token.IntL.String():
+			return &typeset.Value{SkalType: v.SkalType, ValueType: token.IntL, IntL: m.Value}
+		case token.BoolL.String():
+			return &typeset.Value{SkalType: v.SkalType, ValueType: token.BoolL, BoolL: m.Value}
+		}
+	}
+
+	return nil
+}
+
+/*------------------------------------------------------------------------------
+ * Fns
+ *----------------------------------------------------------------------------*/
+
+// The most values the body of an inlined fn may hold.
+const maxInline = 16
+
+// Collects the fns of a module calls to which may be inlined: non-`pub` named
+// fns whose body is a single `return` of an expression over their args and
+// literals, which are never reassigned.
+//
+//	fn area(w, h) {
+//	  return w * h
+//	}
+func inlinable(set typeset.TypeSet) map[*typeset.Fn]bool {
+	fns := make(map[*typeset.Fn]bool)
+	rebound := make(map[*typeset.Symbol]bool)
+
+	w := &walker{
+		decl: func(fn *typeset.Fn) {
+			if fn.Pub() || fn.RefsLen() != 1 || fn.Method || fn.Constructor || len(fn.Block) != 1 {
+				return
+			}
+			ret := fn.Block[0]
+			if ret.StmtType != token.Ret || len(ret.Values) == 0 || size(ret.Values) > maxInline {
+				return
+			}
+			for _, arg := range fn.Args {
+				if arg.Vararg {
+					return
+				}
+			}
+			if !pure(ret.Values, func(v *typeset.Value) bool { return argOf(fn, v) >= 0 }) {
+				return
+			}
+			fns[fn] = true
+		},
+	}
+	w.module(set)
+
+	// Rebinds
+	w = &walker{
+		bound: func(b *typeset.Base) {
+			if b.Symbol() != nil {
+				rebound[b.Symbol()] = true
+			}
+		},
+	}
+	w.module(set)
+	for fn := range fns {
+		if rebound[fn.Symbol()] {
+			delete(fns, fn)
+		}
+	}
+
+	return fns
+}
+
+// Replaces calls to inlinable fns `fns` with the fn's body, its args
+// substituted.
+//
+//	area(w, 2)  ->  (w * 2)
+func inlineCalls(fns map[*typeset.Fn]bool) func(run []*typeset.Value) []*typeset.Value {
+	return func(run []*typeset.Value) []*typeset.Value {
+		for i, v := range run {
+			if v.ValueType != token.Call {
+				continue
+			}
+			if body := inline(fns, v); body != nil {
+				run[i] = body
+			}
+		}
+
+		return run
+	}
+}
+
+// Produces the inlined body of call value `v`, or nil if it can't be inlined.
+func inline(fns map[*typeset.Fn]bool, v *typeset.Value) *typeset.Value {
+	call := v.Call
+	sym := call.Symbol()
+	if sym == nil || sym.Kind != typeset.SymFn || call.RefsLen() != 1 {
+		return nil
+	}
+	fn, ok := sym.Decl.(*typeset.Fn)
+	if !ok || !fns[fn] || len(call.Args) != len(fn.Args) {
+		return nil
+	}
+	body := fn.Block[0].Values
+
+	// Args are evaluated once, in order, at the call site. Substituted into
+	// the body, they may be evaluated in another order, or any number of times,
+	// so only references and literals are substituted.
+	uses := make([]int, len(fn.Args))
+	count(body, fn, uses)
+	for i, arg := range call.Args {
+		if arg.Spread || len(arg.Values) == 0 || !pure(arg.Values, plainRef) {
+			return nil
+		}
+		if uses[i] > 1 && len(arg.Values) > 1 {
+			return nil
+		}
+	}
+
+	out := substitute(body, fn, call)
+	if len(out) == 1 {
+		return out[0]
+	}
+
+	return &typeset.Value{SkalType: v.SkalType, ValueType: token.ValueGroup, Group: out}
+}
+
+// Copies the body of `fn`, replacing its args with those of `call`.
+func substitute(body []*typeset.Value, fn *typeset.Fn, call *typeset.Call) []*typeset.Value {
+	out := make([]*typeset.Value, 0, len(body))
+	for _, v := range body {
+		if i := argOf(fn, v); i >= 0 {
+			arg := call.Args[i]
+			if len(arg.Values) == 1 {
+				out = append(out, clone(arg.Values[0]))
+				continue
+			}
+			out = append(out, &typeset.Value{SkalType: arg.SkalType, ValueType: token.ValueGroup, Group: cloneRun(arg.Values)})
+			continue
+		}
+
+		c := clone(v)
+		if c.ValueType == token.ValueGroup {
+			c.Group = substitute(v.Group, fn, call)
+		}
+		out = append(out, c)
+	}
+
+	return out
+}
+
+// Counts the references to each arg of `fn` in a run of values.
+func count(run []*typeset.Value, fn *typeset.Fn, uses []int) {
+	for _, v := range run {
+		if i := argOf(fn, v); i >= 0 {
+			uses[i]++
+		}
+		count(v.Group, fn, uses)
+	}
+}
+
+// Produces the index of the arg of `fn` value `v` references, or -1.
+func argOf(fn *typeset.Fn, v *typeset.Value) int {
+	if v.ValueType != token.Ref || v.RefsLen() != 1 || v.Symbol() == nil {
+		return -1
+	}
+	for i, arg := range fn.Args {
+		if v.Symbol().Decl == arg {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Indicates whether a run of values is free of side effects: literals, and
+// operators over literals and the references `ref` accepts.
+func pure(run []*typeset.Value, ref func(v *typeset.Value) bool) bool {
+	for _, v := range run {
+		switch v.ValueType {
+		// Literals
+		case token.IntL, token.StrL, token.BoolL, token.Nil:
+
+		// Operators
+		case token.Not, token.MathOperator, token.BitwiseOperator, token.ComparisonOperator,
+			token.ConcatOperator, token.LogicOperator:
+
+		// Reference
+		case token.Ref:
+			if !ref(v) {
+				return false
+			}
+
+		// Value group
+		case token.ValueGroup:
+			if !pure(v.Group, ref) {
+				return false
+			}
+
+		// Calls, fns and tables.
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Indicates whether reference value `v` involves no call (e.g. `a[f()]`).
+func plainRef(v *typeset.Value) bool {
+	for _, ref := range v.Refs() {
+		if strings.Contains(ref, "(") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Counts the values of a run, those of groups included.
+func size(run []*typeset.Value) int {
+	n := len(run)
+	for _, v := range run {
+		n += size(v.Group)
+	}
+
+	return n
+}
+
+func clone(v *typeset.Value) *typeset.Value {
+	c := *v
+	c.Group = cloneRun(v.Group)
+
+	return &c
+}
+
+func cloneRun(run []*typeset.Value) []*typeset.Value {
+	if run == nil {
+		return nil
+	}
+
+	out := make([]*typeset.Value, len(run))
+	for i, v := range run {
+		out[i] = clone(v)
+	}
+
+	return out
+}
@@ -0,0 +1,56 @@
+// Package opt rewrites resolved modules into equivalent ones which run faster,
+// for `-O` builds.
+package opt
+
+import (
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+// Optimize rewrites a resolved module, returning the optimized module. Passes
+// run in order, each working from the output of those before it:
+//
+//   - Enum member references are replaced with the member's value, and calls
+//     to small fns (a single `return` over their args) with the fn's body.
+//   - Operators over literals are folded into literals, and branches of
+//     conditionals whose condition folds to a literal are dropped.
+//   - Non-`pub` fns the module no longer references are removed.
+//   - Host fns a fn (or the module's top-level code) calls repeatedly, or
+//     within a loop, are cached in locals.
+//
+// Enum members are treated as constants, they must not be reassigned.
+func Optimize(set typeset.TypeSet) typeset.TypeSet {
+	for _, pass := range passes {
+		set = pass(set)
+	}
+
+	return set
+}
+
+// The passes of `Optimize()`, in order.
+var passes = []func(set typeset.TypeSet) typeset.TypeSet{
+	inlineSet,
+	foldSet,
+	removeDead,
+	cacheGlobals,
+}
+
+// Inlines enum members and small fn calls.
+func inlineSet(set typeset.TypeSet) typeset.TypeSet {
+	fns := inlinable(set)
+	w := &walker{
+		values: func(run []*typeset.Value) []*typeset.Value {
+			return inlineCalls(fns)(inlineEnums(run))
+		},
+	}
+	w.module(set)
+
+	return set
+}
+
+// Folds constant operators and prunes the branches they decide.
+func foldSet(set typeset.TypeSet) typeset.TypeSet {
+	w := &walker{values: fold, block: pruneBlock}
+	w.module(set)
+
+	return pruneMembers(set)
+}
@@ -0,0 +1,307 @@
+package opt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/illbjorn/skal/internal/skal/emit"
+	"github.com/illbjorn/skal/internal/skal/lex"
+	"github.com/illbjorn/skal/internal/skal/lua"
+	"github.com/illbjorn/skal/internal/skal/parse"
+	"github.com/illbjorn/skal/internal/skal/resolve"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+	"github.com/illbjorn/skal/pkg/formatter"
+)
+
+type passCase struct {
+	name string
+	in   string
+	// The expected Lua, or "" where the pass must leave the module as is.
+	want string
+}
+
+// Compiles `src`, applying `pass` to the resolved module, returning the
+// emitted Lua.
+func compile(t *testing.T, src string, pass func(set typeset.TypeSet) typeset.TypeSet) string {
+	t.Helper()
+
+	diags := sklog.NewDiagnostics()
+	func() {
+		defer func() { diags.Catch(recover()) }()
+		tree := parse.Parse(lex.Lex("main.sk", src, diags))
+		set := typeset.Typeset(tree, lua.Stdlib, diags)
+		mod := &resolve.Module{Path: "main.sk", Set: set}
+		resolve.Resolve(lua.Stdlib, diags, mod)
+		if diags.Errors() > 0 {
+			return
+		}
+		src = string(emitSet(pass(mod.Set), diags))
+	}()
+	if err := diags.Err(); err != nil {
+		t.Fatalf("compile: %s", err)
+	}
+
+	return dedent(src)
+}
+
+// Trims the module body out of the `__LOAD__()` wrapper emitted around it.
+func dedent(lua string) string {
+	lines := strings.Split(lua, "\n")
+	var body []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "  ") {
+			body = append(body, line[2:])
+		}
+	}
+
+	return strings.Join(body, "\n")
+}
+
+func emitSet(set typeset.TypeSet, diags *sklog.Diagnostics) []byte {
+	out, _ := emit.Emit(set, formatter.NewFormatter(), emit.Options{
+		Path:        "main.sk",
+		Diagnostics: diags,
+	})
+	return out
+}
+
+func identity(set typeset.TypeSet) typeset.TypeSet { return set }
+
+func runPass(t *testing.T, pass func(set typeset.TypeSet) typeset.TypeSet, cases []passCase) {
+	t.Helper()
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			want := c.want
+			if want == "" {
+				want = compile(t, c.in, identity)
+			}
+			got := compile(t, c.in, pass)
+			if got != strings.TrimSpace(want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestInline(t *testing.T) {
+	runPass(t, inlineSet, []passCase{
+		{
+			name: "enum member",
+			in: `enum Dir {
+  UP = 1
+  DOWN = 2
+}
+pub fn f() { return Dir.DOWN }`,
+			want: `local Dir = {
+  UP = 1,
+  DOWN = 2
+}
+function f()
+  return 2
+end`,
+		},
+		{
+			name: "small fn",
+			in: `fn twice(n) { return n * 2 }
+pub fn f(x) { return twice(x + 1) }`,
+			want: `local function twice(n)
+  return n * 2
+end
+function f(x)
+  return ((x + 1) * 2)
+end`,
+		},
+		{
+			name: "multiple statements",
+			in: `fn twice(n) {
+  print(n)
+  return n * 2
+}
+pub fn f(x) { return twice(x) }`,
+		},
+		{
+			name: "varargs",
+			in: `fn first(...xs) { return xs }
+pub fn f() { return first(1, 2) }`,
+		},
+		{
+			name: "impure arg used twice",
+			in: `fn sq(n) { return n * n }
+pub fn f() { return sq(math.random(6)) }`,
+		},
+	})
+}
+
+func TestFold(t *testing.T) {
+	runPass(t, foldSet, []passCase{
+		{
+			name: "arithmetic",
+			in:   `pub fn f() { return 2 * 3 + 4 }`,
+			want: `function f()
+  return 10
+end`,
+		},
+		{
+			name: "string concat",
+			in:   `pub fn f() { return "a" .. "b" }`,
+			want: `function f()
+  return 'ab'
+end`,
+		},
+		{
+			name: "false branch",
+			in: `pub fn f() {
+  if false {
+    print("never")
+  } else {
+    print("always")
+  }
+}`,
+			want: `function f()
+  print('always')
+end`,
+		},
+		{
+			name: "non-literal operand",
+			in:   `pub fn f(x) { return x * 2 + 4 }`,
+		},
+		{
+			name: "non-literal condition",
+			in: `pub fn f(x) {
+  if x {
+    print("x")
+  }
+}`,
+		},
+	})
+}
+
+func TestRemoveDead(t *testing.T) {
+	runPass(t, removeDead, []passCase{
+		{
+			name: "unused private fn",
+			in: `fn unused() { print("unused") }
+pub fn f() { print("f") }`,
+			want: `function f()
+  print('f')
+end`,
+		},
+		{
+			name: "used and pub fns",
+			in: `fn used() { print("used") }
+pub fn f() { used() }
+pub fn g() { print("g") }`,
+		},
+	})
+}
+
+func TestCacheGlobals(t *testing.T) {
+	runPass(t, cacheGlobals, []passCase{
+		{
+			name: "loop in fn",
+			in: `pub fn f(n) {
+  for i = 1, n {
+    print(math.floor(i))
+  }
+}`,
+			want: `function f(n)
+  local _math_floor = math.floor
+  for i = 1, n do
+    print(_math_floor(i))
+  end
+end`,
+		},
+		{
+			name: "repeated call",
+			in: `pub fn f(x) {
+  print(math.floor(x))
+  print(math.floor(x))
+}`,
+			want: `function f(x)
+  local _math_floor = math.floor
+  print(_math_floor(x))
+  print(_math_floor(x))
+end`,
+		},
+		{
+			name: "top-level loop",
+			in: `for i = 1, 10 {
+  print(math.floor(i))
+}`,
+			want: `local _math_floor = math.floor
+for i = 1, 10 do
+  print(_math_floor(i))
+end`,
+		},
+		{
+			name: "single call",
+			in:   `pub fn f(x) { return math.floor(x) }`,
+		},
+		{
+			name: "user table",
+			in: `pub fn f(t, n) {
+  for i = 1, n {
+    t.g(i)
+  }
+}`,
+		},
+	})
+}
+
+func TestOptimize(t *testing.T) {
+	runPass(t, Optimize, []passCase{
+		{
+			name: "all passes",
+			in: `enum Size {
+  SMALL = 2
+}
+fn double(n) { return n * 2 }
+fn unused() { print("unused") }
+pub fn f(n) {
+  let m = double(Size.SMALL)
+  for i = 1, m {
+    print(math.floor(i * n))
+  }
+}`,
+			want: `local Size = {
+  SMALL = 2
+}
+function f(n)
+  local _math_floor = math.floor
+  local m = 4
+  for i = 1, m do
+    print(_math_floor(i * n))
+  end
+end`,
+		},
+	})
+}
+
+func TestCached(t *testing.T) {
+	var got map[string]string
+	compile(t, `let _math_floor = 1
+for i = 1, 10 {
+  print(math.floor(i))
+}
+pub fn f(n) {
+  for i = 1, n {
+    print(math.random(i), string.upper("a"))
+  }
+}`, func(set typeset.TypeSet) typeset.TypeSet {
+		set = cacheGlobals(set)
+		got = Cached(set)
+		return set
+	})
+
+	want := map[string]string{
+		"_math_floor2":  "math.floor",
+		"_math_random":  "math.random",
+		"_string_upper": "string.upper",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Cached() = %v, want %v", got, want)
+	}
+}
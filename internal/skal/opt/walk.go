@@ -0,0 +1,217 @@
+package opt
+
+import (
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+// Visits the tree of a module. Each hook is optional, runs of values and blocks
+// of statements are visited after their contents, so the contents have already
+// been rewritten.
+type walker struct {
+	// Rewrites a run of values.
+	values func(run []*typeset.Value) []*typeset.Value
+	// Rewrites a block of statements.
+	block func(block []*typeset.Statement) []*typeset.Statement
+	// Visits each named fn declaration, before its body.
+	decl func(fn *typeset.Fn)
+	// Visits each call.
+	call func(call *typeset.Call)
+	// Visits each reference: values, callees and iterables.
+	ref func(t typeset.SkalType)
+	// Visits each name bound or assigned.
+	bound func(b *typeset.Base)
+	// Named fns declared within blocks aren't descended into.
+	shallow bool
+	// Number of loops enclosing the node being visited.
+	loops int
+}
+
+func (w *walker) module(set typeset.TypeSet) {
+	for _, member := range set.Members {
+		switch v := member.Value.(type) {
+		// Bind | Rebind
+		case *typeset.Bind:
+			w.bind(v)
+
+		// 'fn'
+		case *typeset.Fn:
+			w.fnDecl(v)
+
+		// Call
+		case *typeset.Call:
+			w.callee(v)
+
+		// 'if'
+		case *typeset.If:
+			w.conditional(v)
+
+		// 'for'
+		case *typeset.For:
+			w.forLoop(v)
+
+		// 'struct'
+		case *typeset.Struct:
+			for _, method := range v.Methods {
+				w.fnDecl(method)
+			}
+		}
+	}
+}
+
+func (w *walker) statements(block []*typeset.Statement) []*typeset.Statement {
+	for _, stmt := range block {
+		w.statement(stmt)
+	}
+
+	if w.block != nil {
+		block = w.block(block)
+	}
+
+	return block
+}
+
+func (w *walker) statement(stmt *typeset.Statement) {
+	switch stmt.StmtType {
+	// 'return'
+	case token.Ret:
+		stmt.Values = w.run(stmt.Values)
+
+	// Call
+	case token.Call:
+		w.callee(stmt.Call)
+
+	// Bind | Rebind
+	case token.Bind, token.Rebind:
+		w.bind(stmt.Bind)
+
+	// 'fn'
+	case token.Fn:
+		if !w.shallow {
+			w.fnDecl(stmt.Fn)
+		}
+
+	// 'for'
+	case token.For:
+		w.forLoop(stmt.For)
+
+	// 'if'
+	case token.If:
+		w.conditional(stmt.If)
+
+	// 'defer'
+	case token.Defer:
+		for d := range stmt.Defers() {
+			w.statement(d)
+		}
+	}
+}
+
+func (w *walker) fnDecl(fn *typeset.Fn) {
+	if w.decl != nil {
+		w.decl(fn)
+	}
+
+	// Fns assigned to a field (`fn a.b()`) reference the root.
+	if fn.RefsLen() > 1 && w.ref != nil {
+		w.ref(fn)
+	}
+
+	w.fn(fn)
+}
+
+func (w *walker) fn(fn *typeset.Fn) {
+	// A loop enclosing the declaration doesn't run the body.
+	loops := w.loops
+	w.loops = 0
+	defer func() { w.loops = loops }()
+
+	fn.Values = w.run(fn.Values)
+	fn.Block = w.statements(fn.Block)
+}
+
+func (w *walker) bind(bind *typeset.Bind) {
+	bind.Values = w.run(bind.Values)
+
+	if w.bound != nil {
+		for _, b := range bind.Binds {
+			w.bound(b)
+		}
+	}
+}
+
+func (w *walker) conditional(nif *typeset.If) {
+	// If
+	nif.Conditions = w.run(nif.Conditions)
+	nif.Block = w.statements(nif.Block)
+
+	// Elifs
+	for _, elif := range nif.Elifs {
+		elif.Conditions = w.run(elif.Conditions)
+		elif.Block = w.statements(elif.Block)
+	}
+
+	// Else
+	if nif.Else != nil {
+		nif.Else.Block = w.statements(nif.Else.Block)
+	}
+}
+
+func (w *walker) forLoop(nfor *typeset.For) {
+	if w.ref != nil {
+		for _, iterable := range nfor.Iterables {
+			w.ref(iterable)
+		}
+	}
+
+	w.loops++
+	nfor.Block = w.statements(nfor.Block)
+	w.loops--
+}
+
+func (w *walker) callee(call *typeset.Call) {
+	if w.call != nil {
+		w.call(call)
+	}
+	if w.ref != nil {
+		w.ref(call)
+	}
+
+	for _, arg := range call.Args {
+		arg.Values = w.run(arg.Values)
+	}
+}
+
+func (w *walker) run(run []*typeset.Value) []*typeset.Value {
+	for _, v := range run {
+		w.value(v)
+	}
+
+	if len(run) > 0 && w.values != nil {
+		run = w.values(run)
+	}
+
+	return run
+}
+
+func (w *walker) value(v *typeset.Value) {
+	switch v.ValueType {
+	// Reference
+	case token.Ref:
+		if w.ref != nil {
+			w.ref(v)
+		}
+
+	// Call
+	case token.Call:
+		w.callee(v.Call)
+
+	// Anonymous fn
+	case token.Fn:
+		w.fn(v.Fn)
+
+	// Value group
+	case token.ValueGroup:
+		v.Group = w.run(v.Group)
+	}
+}
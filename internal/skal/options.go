@@ -0,0 +1,97 @@
+package skal
+
+import (
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/illbjorn/skal/internal/skal/decl"
+	"github.com/illbjorn/skal/internal/skal/exec"
+	"github.com/illbjorn/skal/internal/skal/lua"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/vfs"
+)
+
+// Options holds the user-configurable settings of a compilation.
+type Options struct {
+	// Module search roots, consulted in order after the directory of the
+	// entrypoint when resolving imports.
+	Paths []string
+	// Paths of declaration files (or directories containing them) to load in
+	// addition to the bundled standard library declarations.
+	Decls []string
+	// Collects every diagnostic reported by the compilation, including warnings.
+	// If nil, a collector is created per compilation.
+	Diagnostics *sklog.Diagnostics
+	// If set, the entrypoint, its imports and Decls are read from FS rather than
+	// the host disk.
+	FS fs.FS
+	// Receives the output of executed scripts. If nil, os.Stdout is used.
+	Stdout io.Writer
+	// The host libraries executed scripts may use, beyond those which can't
+	// reach outside of the script (see exec.Libs).
+	Libs exec.Libs
+	// If set, the output of each module is cached in CacheDir, and reused by
+	// later compilations while the module and the modules it imports are
+	// unchanged. The cache may be shared by any number of projects, it's kept
+	// within bounds as entries are written: entries unused for 30 days are
+	// evicted, as are the least recently used while it exceeds 256 MiB.
+	CacheDir string
+	// If set, receives the cache statistics of the compilation.
+	CacheStats *CacheStats
+	// Name of the Lua runtime the output targets (see `target.Names`). Defaults
+	// to Lua 5.1. Executed scripts always target Lua 5.1.
+	Target string
+	// If set, each module is compiled to its own Lua file (see BuildModules),
+	// rather than bundled into one.
+	Split bool
+	// If set, the output is a Lua module returning a table of the entrypoint's
+	// `pub` symbols, with no effect on the global environment, rather than an
+	// app.
+	Lib bool
+	// If set, modules are optimized before they're emitted: constants are
+	// folded, dead code removed, small fns and enum members inlined, and
+	// repeated host fn lookups cached in locals. Executed scripts are
+	// optimized too.
+	Optimize bool
+	// If set, the output is minified: locals are renamed to short names, and
+	// comments and whitespace dropped. Statements keep the lines they're
+	// mapped by, so source maps still apply.
+	Minify bool
+	// If set, the comments of the source are kept in the output, those leading
+	// `pub` fns and structs as LuaLS doc comments. Minified output drops them.
+	Comments bool
+	// If set, `Compile` writes the parse tree of the entrypoint alongside the
+	// output as JSON, to `<output>.ast.json`.
+	DumpAST bool
+	// If set, receives the performance measurements of the compilation (see
+	// Perf).
+	Perf *Perf
+}
+
+// Assembles the declarations available to a compilation: the bundled standard
+// library followed by any user-provided declaration files.
+func (opts Options) decls(diags *sklog.Diagnostics) *decl.Set {
+	set := decl.NewSet().Merge(lua.Stdlib)
+	for _, path := range opts.Decls {
+		set.Merge(decl.Load(vfs.New(opts.FS), path, diags))
+	}
+
+	return set
+}
+
+func (opts Options) diagnostics() *sklog.Diagnostics {
+	if opts.Diagnostics != nil {
+		return opts.Diagnostics
+	}
+
+	return sklog.NewDiagnostics()
+}
+
+func (opts Options) stdout() io.Writer {
+	if opts.Stdout != nil {
+		return opts.Stdout
+	}
+
+	return os.Stdout
+}
@@ -5,22 +5,88 @@ import (
 	"github.com/illbjorn/skal/internal/skal/sklog"
 )
 
-func parseError(msg string, tk token.Token, fatal bool) {
-	level := sklog.LevelError
-	if fatal {
-		level = sklog.LevelFatal
-	}
+// Reports a parse error, bailing out to the nearest recovery point.
+func parseError(code, msg string, tk token.Token) {
+	parseEvent(code, msg, tk).
+		WithCallStack(3).
+		Bail()
+}
 
+// Prepares a parse error, for callers attaching suggestions or fixes before
+// bailing.
+func parseEvent(code, msg string, tk token.Token) *sklog.CompilerEvent {
 	src := tk.SrcLine()
 	file := tk.File()
 	line := tk.LineStart()
 	col1 := tk.ColumnStart()
 	col2 := tk.ColumnEnd()
 
-	sklog.
-		NewCompilerEvent(sklog.MsgTypeParseError, level).
-		WithCallStack(3).
+	return sklog.
+		NewCompilerEvent(sklog.MsgTypeParseError, sklog.LevelFatal).
+		WithCode(code).
 		WithSourceHint(src, file, line, col1, col2).
-		Str(msg).
-		Send()
+		Str(msg)
+}
+
+/*------------------------------------------------------------------------------
+ * Recovery
+ *----------------------------------------------------------------------------*/
+
+// Runs parse fn `fn`, recovering from a parse error by reporting it and
+// skipping ahead to the next statement. On recovery nil is returned.
+//
+// Top-level recovery also skips a closing brace, since no enclosing block will
+// consume it.
+func recoverable(tc *token.Collection, top bool, fn func() *Node) (n *Node) {
+	start := tc.Pos()
+
+	defer func() {
+		if tc.Diagnostics().Catch(recover()) {
+			synchronize(tc, start, top)
+			n = nil
+		}
+	}()
+
+	return fn()
+}
+
+// Skips tokens up to the next statement keyword or the close of the enclosing
+// block. At least one token is always skipped, ensuring the parser progresses.
+func synchronize(tc *token.Collection, start int, top bool) {
+	if tc.Pos() == start {
+		tc.Adv()
+	}
+
+	var depth int
+	for {
+		switch tc.LA().Type() {
+		// EOF
+		case token.EOF:
+			return
+
+		// '{'
+		case token.BraceOpen:
+			depth++
+
+		// '}'
+		case token.BraceClose:
+			if depth > 0 {
+				depth--
+				break
+			}
+			if top {
+				tc.Adv()
+			}
+			return
+
+		// Statement keywords
+		case token.Pub, token.Let, token.Fn, token.If, token.For, token.Ret,
+			token.Continue, token.Defer, token.Struct, token.Enum, token.Extern, token.Import:
+			if depth == 0 {
+				return
+			}
+		}
+
+		tc.Adv()
+	}
 }
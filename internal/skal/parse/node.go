@@ -2,12 +2,12 @@ package parse
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
 
 	"github.com/illbjorn/fstr"
 	"github.com/illbjorn/skal/internal/skal/lex/token"
-	"github.com/illbjorn/skal/internal/skal/sklog"
 )
 
 func NewNode(tc *token.Collection, tk token.Token) *Node {
@@ -118,23 +118,33 @@ func (node *Node) SetTokenOnly(tk token.Token) *Node {
  * Serialization Support
  *----------------------------------------------------------------------------*/
 
-// Serializes Token `a` to JSON and writes the result to provided `path`.
-func (node *Node) Serialize(path string) {
+// MarshalJSON encodes the Node with the source positions of its Token.
+func (node *Node) MarshalJSON() ([]byte, error) {
+	type plain Node
+	out := struct {
+		*plain
+		Start *token.Position `json:"start,omitempty"`
+		End   *token.Position `json:"end,omitempty"`
+	}{plain: (*plain)(node)}
+
+	if node.Token != nil {
+		start, end := node.Token.Start(), node.Token.End()
+		out.Start, out.End = &start, &end
+	}
+
+	return json.Marshal(out)
+}
+
+// Serializes Node `node` to JSON and writes the result to provided `path`.
+func (node *Node) Serialize(path string) (err error) {
 	f, err := os.OpenFile(path, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
-		sklog.CFatalF(
-			"Failed to open output AST JSON file with error: {err}.",
-			"err", err.Error(),
-		)
+		return fmt.Errorf("failed to open AST file '%s': %w", path, err)
 	}
 
 	defer func() {
-		err := f.Close()
-		if err != nil {
-			sklog.CFatalF(
-				"Failed to close AST JSON file: {err}.",
-				"err", err.Error(),
-			)
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close AST file '%s': %w", path, cerr)
 		}
 	}()
 
@@ -142,9 +152,8 @@ func (node *Node) Serialize(path string) {
 	enc.SetIndent("", "  ")
 	enc.SetEscapeHTML(false)
 	if err = enc.Encode(node); err != nil {
-		sklog.CFatalF(
-			"Failed to encode the AST with error: {err}.",
-			"err", err.Error(),
-		)
+		return fmt.Errorf("failed to encode the AST: %w", err)
 	}
+
+	return nil
 }
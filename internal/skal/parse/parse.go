@@ -1,19 +1,113 @@
 package parse
 
 import (
+	"github.com/illbjorn/fstr"
 	"github.com/illbjorn/skal/internal/skal/lex/token"
 	"github.com/illbjorn/skal/internal/skal/sklog"
 )
 
-func Parse(tc *token.Collection, root, n *Node) *Node {
-	if root == nil {
-		root = new(Node)
+// Parse builds the syntax tree of a token collection.
+//
+// Parse errors are reported to the diagnostics of the collection. The parser
+// recovers from an error by skipping ahead to the next statement, allowing
+// every error in a file to be reported.
+func Parse(tc *token.Collection) *Node {
+	root := new(Node)
+
+	// Imports lead the file.
+	for tc.NTT(token.Import) {
+		line := tc.LA().LineStart()
+		n := recoverable(tc, true, func() *Node {
+			return new(Node).AddChild(parseImport(tc))
+		})
+		if n != nil {
+			root.AddChild(n)
+			continue
+		}
+
+		// Skip the remainder of a malformed import.
+		for !tc.NTT(token.EOF, token.Import) && tc.LA().LineStart() == line {
+			tc.Adv()
+		}
 	}
 
-	if n == nil {
-		n = new(Node)
+	for !tc.NTT(token.EOF) {
+		n := recoverable(tc, true, func() *Node {
+			return parseMember(tc, new(Node))
+		})
+		if n != nil {
+			root.AddChild(n)
+		}
+	}
+
+	return root
+}
+
+// The word introducing the path of a selective import. It's only meaningful
+// within an import, so isn't a keyword.
+const from = "from"
+
+// import 'ui/button'
+// import 'ui/button' as btn
+// import { Button, Theme } from 'ui'
+func parseImport(tc *token.Collection) *Node {
+	nimport := new(Node).SetType(token.Import).SetTokenOnly(tc.AdvT(token.Import))
+
+	// Selective
+	// '{'
+	if _, ok := tc.AdvIf(token.BraceOpen); ok {
+		for !tc.NTT(token.BraceClose) {
+			// ID
+			nimport.AddChild(
+				new(Node).SetToken(tc.AdvT(token.ID)),
+			)
+
+			// ','
+			if _, ok := tc.AdvIf(token.Comma); !ok {
+				break
+			}
+		}
+
+		// '}'
+		tc.AdvT(token.BraceClose)
+
+		// 'from'
+		if tk := tc.AdvT(token.ID); tk.Value() != from {
+			parseError(
+				sklog.CodeUnexpectedToken,
+				fstr.Pairs(
+					"Expected '{from}' following the names of a selective import, found '{found}'.",
+					"from", from,
+					"found", tk.Value(),
+				),
+				tk,
+			)
+		}
+
+		// Path
+		return nimport.AddChild(
+			new(Node).SetToken(tc.AdvT(token.StrL)),
+		)
 	}
 
+	// Path
+	nimport.AddChild(
+		new(Node).SetToken(tc.AdvT(token.StrL)),
+	)
+
+	// 'as'
+	if _, ok := tc.AdvIf(token.As); ok {
+		// Alias
+		nimport.AddChild(
+			new(Node).SetType(token.As).SetToken(tc.AdvT(token.ID)),
+		)
+	}
+
+	return nimport
+}
+
+// Parses a single top-level member into node `n`.
+func parseMember(tc *token.Collection, n *Node) *Node {
 	tk := tc.LA()
 
 	switch tk.Type() {
@@ -22,7 +116,7 @@ func Parse(tc *token.Collection, root, n *Node) *Node {
 		n.AddChild(
 			new(Node).SetToken(tc.Adv()),
 		)
-		return Parse(tc, root, n)
+		return parseMember(tc, n)
 
 	// 'enum'
 	case token.Enum:
@@ -63,14 +157,15 @@ func Parse(tc *token.Collection, root, n *Node) *Node {
 	// 'import'
 	case token.Import:
 		parseError(
+			sklog.CodeMisplacedImport,
 			"Import statements must appear in the File before any other code.",
 			tk,
-			true,
 		)
 
 	// ID
 	// Rebind | Call
 	case token.ID:
+		id := tk
 		tk := tc.LookPastRef()
 		switch tk.Type() {
 		// '('
@@ -87,8 +182,20 @@ func Parse(tc *token.Collection, root, n *Node) *Node {
 				parseRebind(tc),
 			)
 
+		// A misspelled keyword is lexed as an ID.
 		default:
-			sklog.UnexpectedType("LookPast token", tk.Type().String())
+			parseEvent(
+				sklog.CodeExpectedCall,
+				fstr.Pairs(
+					"Expected a call or assignment, found {found} following '{name}'.",
+					"found", tk.Type().String(),
+					"name", id.Value(),
+				),
+				id,
+			).
+				WithCallStack(3).
+				WithSuggestions(sklog.Suggest(id.Value(), token.Keywords())...).
+				Bail()
 		}
 
 	// 'extern'
@@ -99,21 +206,28 @@ func Parse(tc *token.Collection, root, n *Node) *Node {
 
 	// EOF
 	case token.EOF:
-		return root
+		return nil
 
 	// 'defer'
 	case token.Defer:
 		parseError(
+			sklog.CodeTopLevelDefer,
 			"Top level deferrals are not allowed.",
 			tk,
-			true)
+		)
 
 	default:
-		sklog.UnexpectedType("parse token", tc.LA().Type().String())
+		parseError(
+			sklog.CodeExpectedStatement,
+			fstr.Pairs(
+				"Expected a declaration or statement, found {found}.",
+				"found", tk.Type().String(),
+			),
+			tk,
+		)
 	}
 
-	root.AddChild(n)
-	return Parse(tc, root, new(Node))
+	return n
 }
 
 func parseDefer(tc *token.Collection) *Node {
@@ -554,10 +668,37 @@ func parseElse(tc *token.Collection) *Node {
 }
 
 func parseConditions(tc *token.Collection) *Node {
-	return new(Node).
+	conds := new(Node).
 		SetType(token.Conditions).
 		SetTokenOnly(tc.LA()).
 		AddChildren(parseValue(tc))
+
+	// '='
+	// An assignment where a comparison was intended.
+	// if a = b {
+	if tk := tc.LA(); tk.Type() == token.EQ {
+		parseEvent(
+			sklog.CodeAssignInCondition,
+			fstr.Pairs(
+				"Found '{eq}' in a condition, values are compared with '{eqeq}'.",
+				"eq", token.EQ.String(),
+				"eqeq", token.EQEQ.String(),
+			),
+			tk,
+		).
+			WithCallStack(3).
+			WithFix(sklog.Fix{
+				Description: "Replace '" + token.EQ.String() + "' with '" + token.EQEQ.String() + "'.",
+				File:        tk.File(),
+				Line:        tk.LineStart(),
+				ColStart:    tk.ColumnStart(),
+				ColEnd:      tk.ColumnEnd(),
+				Text:        token.EQEQ.String(),
+			}).
+			Bail()
+	}
+
+	return conds
 }
 
 func parseBind(tc *token.Collection) *Node {
@@ -657,16 +798,16 @@ func parseBlock(tc *token.Collection) *Node {
 	block := new(Node).SetType(token.Block).SetTokenOnly(tc.LA())
 
 	for {
-		if tc.NTT(token.BraceOpen, token.BraceClose) {
+		if tc.NTT(token.BraceOpen, token.BraceClose, token.EOF) {
 			return block
 		}
 
-		stmt := parseStatement(tc, nil)
-		if stmt == nil {
-			return block
+		stmt := recoverable(tc, false, func() *Node {
+			return parseStatement(tc, nil)
+		})
+		if stmt != nil {
+			block.AddChild(stmt)
 		}
-
-		block.AddChild(stmt)
 	}
 }
 
@@ -708,7 +849,14 @@ func parseForIterables(tc *token.Collection) []*Node {
 			)
 
 		default:
-			sklog.UnexpectedType("for iterables token", tk.Type().String())
+			parseError(
+				sklog.CodeExpectedIterable,
+				fstr.Pairs(
+					"Expected a for iterable, found {found}.",
+					"found", tk.Type().String(),
+				),
+				tk,
+			)
 		}
 
 		iterables = append(iterables, iterable)
@@ -749,10 +897,20 @@ func parseStatement(tc *token.Collection, stmt *Node) *Node {
 			return stmt
 		}
 
-		// Reference
-		stmt.AddChild(
-			parseRef(tc),
-		)
+		// A bare reference is not a statement, most likely a misspelled keyword
+		// lexed as an ID.
+		parseEvent(
+			sklog.CodeExpectedCall,
+			fstr.Pairs(
+				"Expected a call or assignment, found {found} following '{name}'.",
+				"found", tc.LookPastRef().Type().String(),
+				"name", tk.Value(),
+			),
+			tk,
+		).
+			WithCallStack(3).
+			WithSuggestions(sklog.Suggest(tk.Value(), token.Keywords())...).
+			Bail()
 
 	// 'if'
 	case token.If:
@@ -790,8 +948,21 @@ func parseStatement(tc *token.Collection, stmt *Node) *Node {
 			parseReturnStatement(tc),
 		)
 
+	// 'continue'
+	case token.Continue:
+		stmt.AddChild(
+			new(Node).SetToken(tc.AdvT(token.Continue)),
+		)
+
 	default:
-		sklog.UnexpectedType("parse statement", tk.Type().String())
+		parseError(
+			sklog.CodeExpectedStatement,
+			fstr.Pairs(
+				"Expected a statement, found {found}.",
+				"found", tk.Type().String(),
+			),
+			tk,
+		)
 	}
 
 	return stmt
@@ -910,7 +1081,9 @@ var ttsAnyOperator = []token.Type{
 	// String Concat
 	token.Concat,
 	// Math
-	token.Mult, token.Div, token.Plus, token.Minus,
+	token.Mult, token.Div, token.FloorDiv, token.Plus, token.Minus,
+	// Bitwise
+	token.BitAnd, token.BitOr, token.BitXor, token.Shl, token.Shr,
 	// Comparison
 	token.GE, token.LE, token.NE, token.EQEQ, token.GT, token.LT,
 	// Logic
@@ -996,9 +1169,14 @@ func parseValue(tc *token.Collection) []*Node {
 					)
 
 				default:
-					sklog.UnexpectedType(
-						"parse list literal Value",
-						tc.LA().Type().String())
+					parseError(
+						sklog.CodeInvalidListValue,
+						fstr.Pairs(
+							"Expected a string or int list literal value, found {found}.",
+							"found", tc.LA().Type().String(),
+						),
+						tc.LA(),
+					)
 				}
 
 				// ','
@@ -1020,7 +1198,8 @@ func parseValue(tc *token.Collection) []*Node {
 
 		// Operators
 		// >= | <= | != | == | > | <
-		// .. | +  | -  | *  | /
+		// .. | +  | -  | *  | /  | //
+		// &  | |  | ~  | << | >>
 		// || | && | !
 		if tk, ok := tc.AdvIf(ttsAnyOperator...); !ok {
 			return values
@@ -1070,15 +1249,19 @@ func opType(op token.Type) token.Type {
 		return token.ConcatOperator
 
 	// Arithmetic Operators
-	case token.Plus, token.Minus, token.Mult, token.Div:
+	case token.Plus, token.Minus, token.Mult, token.Div, token.FloorDiv:
 		return token.MathOperator
 
+	// Bitwise Operators
+	case token.BitAnd, token.BitOr, token.BitXor, token.Shl, token.Shr:
+		return token.BitwiseOperator
+
 	// Logic Operators
 	case token.And, token.Or:
 		return token.LogicOperator
 
 	default:
-		sklog.CFatalF(
+		sklog.BailF(
 			"Failed to classify binary operator: {op}.",
 			"op", op.String(),
 		)
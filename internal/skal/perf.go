@@ -0,0 +1,196 @@
+package skal
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/illbjorn/skal/internal/skal/parse"
+)
+
+// Phase is a stage of the compiler pipeline, as measured by Perf.
+type Phase uint8
+
+const (
+	PhaseImports  Phase = iota // Locating and reading source files.
+	PhaseLex                   // Lexing source files.
+	PhaseParse                 // Parsing source files.
+	PhaseTypeset               // Typesetting parsed source files.
+	PhaseResolve               // Resolving names across all modules.
+	PhaseFlow                  // Checking the control flow of each module.
+	PhaseOptimize              // Optimizing modules (`-O`).
+	PhaseEmit                  // Emitting the Lua of each module.
+	PhaseBundle                // Bundling (or splitting) and minifying output.
+	phases
+)
+
+// Phases lists every Phase, in pipeline order.
+func Phases() []Phase {
+	out := make([]Phase, phases)
+	for i := range out {
+		out[i] = Phase(i)
+	}
+
+	return out
+}
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseImports:
+		return "imports"
+	case PhaseLex:
+		return "lex"
+	case PhaseParse:
+		return "parse"
+	case PhaseTypeset:
+		return "typeset"
+	case PhaseResolve:
+		return "resolve"
+	case PhaseFlow:
+		return "flow"
+	case PhaseOptimize:
+		return "optimize"
+	case PhaseEmit:
+		return "emit"
+	case PhaseBundle:
+		return "bundle"
+	default:
+		return ""
+	}
+}
+
+// MarshalText encodes the Phase by its name, keying Perf JSON.
+func (p Phase) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// Measure is the cost of running a phase.
+type Measure struct {
+	Time time.Duration `json:"time_ns"`
+	// Heap allocations made, and the bytes allocated.
+	Allocs uint64 `json:"allocs"`
+	Bytes  uint64 `json:"bytes"`
+}
+
+func (m Measure) add(o Measure) Measure {
+	return Measure{Time: m.Time + o.Time, Allocs: m.Allocs + o.Allocs, Bytes: m.Bytes + o.Bytes}
+}
+
+// FilePerf holds the measurements of a single source file.
+type FilePerf struct {
+	Path string `json:"path"`
+	// The tokens lexed from the file, and the nodes of its parse tree.
+	Tokens int `json:"tokens"`
+	Nodes  int `json:"nodes"`
+	// The file's output was reused from the cache, only its summary was
+	// compiled.
+	Cached bool              `json:"cached,omitempty"`
+	Phases map[Phase]Measure `json:"phases"`
+}
+
+// Perf holds the performance measurements of a compilation, per source file
+// and in aggregate.
+//
+// Measuring runs the phases of each module one module at a time, rather than
+// concurrently, so the allocations of each can be told apart.
+type Perf struct {
+	// Source files, in the order they were read: the entrypoint, then its
+	// imports.
+	Files []*FilePerf `json:"files"`
+	// Each phase across the compilation: the sum over source files, along with
+	// the phases which run once (resolve and bundle).
+	Total map[Phase]Measure `json:"total"`
+
+	mu sync.Mutex
+}
+
+// Produces the measurements of source file `path`.
+func (p *Perf) file(path string) *FilePerf {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, f := range p.Files {
+		if f.Path == path {
+			return f
+		}
+	}
+	f := &FilePerf{Path: path, Phases: make(map[Phase]Measure)}
+	p.Files = append(p.Files, f)
+
+	return f
+}
+
+// Measures phase `phase` running `fn`, attributing it to source file `path`
+// (if set). Nothing is measured if `p` is nil.
+func (p *Perf) measure(phase Phase, path string, fn func()) {
+	if p == nil {
+		fn()
+		return
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	fn()
+	dur := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	m := Measure{
+		Time:   dur,
+		Allocs: after.Mallocs - before.Mallocs,
+		Bytes:  after.TotalAlloc - before.TotalAlloc,
+	}
+	var f *FilePerf
+	if path != "" {
+		f = p.file(path)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if f != nil {
+		f.Phases[phase] = f.Phases[phase].add(m)
+	}
+	if p.Total == nil {
+		p.Total = make(map[Phase]Measure)
+	}
+	p.Total[phase] = p.Total[phase].add(m)
+}
+
+// Records the token and node counts of source file `path`, parsed to `tree`.
+func (p *Perf) count(path string, tokens int, tree *parse.Node) {
+	if p == nil {
+		return
+	}
+
+	f := p.file(path)
+	f.Tokens, f.Nodes = tokens, nodes(tree)
+}
+
+// Records that the output of source file `path` was reused from the cache.
+func (p *Perf) cached(path string) {
+	if p != nil {
+		p.file(path).Cached = true
+	}
+}
+
+func nodes(n *parse.Node) int {
+	if n == nil {
+		return 0
+	}
+
+	count := 1
+	for _, child := range n.Children {
+		count += nodes(child)
+	}
+
+	return count
+}
+
+// The number of workers modules are compiled across.
+func (p *Perf) workers() int {
+	if p != nil {
+		return 1
+	}
+
+	return runtime.GOMAXPROCS(0)
+}
@@ -0,0 +1,229 @@
+package resolve
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/illbjorn/fstr"
+	"github.com/illbjorn/skal/internal/skal/decl"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+// The parameters accepted by a resolved callee.
+type signature struct {
+	// The callee's declared signature, included in diagnostics for host fns.
+	hint string
+	// The number of required and optional non-vararg parameters.
+	params   int
+	optional int
+	vararg   bool
+}
+
+// Checks the argument count of a call against its callee's parameters, where
+// the callee could be resolved.
+func (r *resolver) arity(call *typeset.Call) {
+	sig, ok := callee(call)
+	if !ok {
+		return
+	}
+
+	// Count the args.
+	var got int
+	var open bool
+	for i, arg := range call.Args {
+		if len(arg.Values) == 0 {
+			continue
+		}
+		got++
+
+		// A spread arg passes an unknown number of values.
+		if arg.Spread {
+			return
+		}
+
+		// A trailing call passes all of its return values.
+		if i == len(call.Args)-1 && len(arg.Values) == 1 &&
+			arg.Values[0].ValueType == token.Call {
+			open = true
+		}
+	}
+
+	switch {
+	// Too few
+	case got < sig.params && !open:
+		r.arityError(call, sig, got)
+
+	// Too many
+	case got > sig.params+sig.optional && !sig.vararg:
+		r.arityError(call, sig, got)
+	}
+}
+
+func (r *resolver) arityError(call *typeset.Call, sig signature, got int) {
+	r.errors++
+
+	expected := strconv.Itoa(sig.params)
+	switch {
+	case sig.vararg:
+		expected = "at least " + expected
+	case sig.optional > 0:
+		expected += " to " + strconv.Itoa(sig.params+sig.optional)
+	}
+
+	noun := "arguments"
+	if expected == "1" || expected == "at least 1" {
+		noun = "argument"
+	}
+
+	msg := fstr.Pairs(
+		"Call to '{ref}' expected {expected} {noun}, got {got}.",
+		"ref", display(call.Refs()),
+		"expected", expected,
+		"noun", noun,
+		"got", strconv.Itoa(got),
+	)
+	if sig.hint != "" {
+		msg += " Declared as: " + sig.hint + "."
+	}
+
+	r.event(sklog.CodeArity, sklog.MsgTypeValidationError, sklog.LevelError, call.Token(), msg).
+		Send()
+}
+
+// Identifies the parameters of the callee of a call.
+func callee(call *typeset.Call) (signature, bool) {
+	sym := call.Symbol()
+	if sym == nil {
+		return signature{}, false
+	}
+
+	refs := call.Refs()
+
+	// Module member
+	// btn.make()
+	if sym.Module() {
+		if len(refs) < 2 {
+			return signature{}, false
+		}
+		if sym = sym.Decl.(*typeset.Import).Exports[refs[1]]; sym == nil {
+			return signature{}, false
+		}
+		refs = refs[1:]
+	}
+
+	// Direct
+	// fn()
+	// Struct()
+	// extern_alias()
+	if len(refs) == 1 {
+		switch sym.Kind {
+		// Fn
+		case typeset.SymFn:
+			return fnSignature(sym.Decl.(*typeset.Fn))
+
+		// Struct Constructor
+		case typeset.SymStruct:
+			return constructorSignature(sym.Decl.(*typeset.Struct))
+
+		// Host Fn
+		case typeset.SymHost, typeset.SymExtern:
+			return hostSignature(sym.Extern)
+		}
+
+		return signature{}, false
+	}
+
+	switch sym.Kind {
+	// Method
+	// this.method()
+	// Struct.method()
+	case typeset.SymThis, typeset.SymStruct:
+		if len(refs) != 2 {
+			break
+		}
+		for _, method := range sym.Decl.(*typeset.Struct).Methods {
+			if method.ID() == refs[1] {
+				return fnSignature(method)
+			}
+		}
+
+	// Host Table Fn
+	// string.format()
+	case typeset.SymHost, typeset.SymExtern:
+		ext := sym.Extern
+		for _, ref := range refs[1:] {
+			if ext == nil || ext.Kind != decl.KindTable {
+				return signature{}, false
+			}
+			ext = ext.Member(ref)
+		}
+		return hostSignature(ext)
+	}
+
+	return signature{}, false
+}
+
+func fnSignature(fn *typeset.Fn) (signature, bool) {
+	var sig signature
+	for _, arg := range fn.Args {
+		if arg.Vararg {
+			sig.vararg = true
+			continue
+		}
+		sig.params++
+	}
+
+	return sig, true
+}
+
+func constructorSignature(nstruct *typeset.Struct) (signature, bool) {
+	// Custom constructor
+	if nstruct.NoConstructor {
+		for _, method := range nstruct.Methods {
+			if method.Constructor {
+				return fnSignature(method)
+			}
+		}
+
+		return signature{}, false
+	}
+
+	// Default constructor
+	// All fields are positional args.
+	return signature{params: len(nstruct.Fields)}, true
+}
+
+func hostSignature(ext *decl.Symbol) (signature, bool) {
+	if ext == nil || ext.Kind != decl.KindFn {
+		return signature{}, false
+	}
+
+	sig := signature{hint: ext.Signature()}
+	for _, param := range ext.Params {
+		switch {
+		case param.Vararg:
+			sig.vararg = true
+		case param.Optional:
+			sig.optional++
+		default:
+			sig.params++
+		}
+	}
+
+	return sig, true
+}
+
+// Produces the Skal source representation of a reference.
+func display(refs []string) string {
+	out := new(strings.Builder)
+	for i, ref := range refs {
+		if i > 0 && !strings.HasPrefix(ref, "[") {
+			out.WriteString(".")
+		}
+		out.WriteString(ref)
+	}
+
+	return out.String()
+}
@@ -0,0 +1,92 @@
+package resolve
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/illbjorn/fstr"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+/*------------------------------------------------------------------------------
+ * Imports
+ *----------------------------------------------------------------------------*/
+
+// Resolves an import of the module being resolved.
+//
+//	import 'ui/button' as btn           -> Declares the module symbol `btn`.
+//	import { Button, Theme } from 'ui'  -> Makes `Button` and `Theme` visible.
+func (r *resolver) nimport(sc *scope, imp *typeset.Import) {
+	switch {
+	// 'as'
+	case imp.Alias != "":
+		imp.Exports = r.exports(imp.Module)
+		imp.SetSymbol(r.declare(sc, &typeset.Symbol{
+			Decl:  imp,
+			Token: imp.Token(),
+			Name:  imp.Alias,
+			File:  r.file,
+			Kind:  typeset.SymModule,
+		}))
+
+	// Selective
+	case imp.Names != nil:
+		exports := r.exports(imp.Module)
+		for _, name := range imp.Names {
+			sym := exports[name.ID()]
+			if sym == nil {
+				r.notExported(imp, name.ID(), name.Token(), exports)
+				continue
+			}
+
+			name.SetSymbol(sym)
+			r.selected[sym.Name] = sym.File
+		}
+	}
+}
+
+// Checks `name` is a global of the module imported by aliased import `imp`.
+func (r *resolver) member(imp *typeset.Import, name string, tk token.Token) {
+	if _, ok := imp.Exports[name]; ok {
+		return
+	}
+
+	r.notExported(imp, name, tk, imp.Exports)
+}
+
+func (r *resolver) notExported(imp *typeset.Import, name string, tk token.Token, exports map[string]*typeset.Symbol) {
+	names := make([]string, 0, len(exports))
+	for name := range exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r.errors++
+	r.event(
+		sklog.CodeNotExported,
+		sklog.MsgTypeResolveError,
+		sklog.LevelError,
+		tk,
+		fstr.Pairs(
+			"'{name}' is not a pub symbol of {file}.",
+			"name", name,
+			"file", filepath.Base(imp.Module),
+		),
+	).
+		WithSuggestions(sklog.Suggest(name, names)...).
+		Send()
+}
+
+// Collects the global symbols declared by the module at `path`, by name.
+func (r *resolver) exports(path string) map[string]*typeset.Symbol {
+	out := make(map[string]*typeset.Symbol)
+	for name, sym := range r.global.syms {
+		if sym.File == path {
+			out[name] = sym
+		}
+	}
+
+	return out
+}
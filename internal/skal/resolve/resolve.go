@@ -0,0 +1,318 @@
+package resolve
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"github.com/illbjorn/fstr"
+	"github.com/illbjorn/skal/internal/skal/decl"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+// Module is a single typeset source file participating in name resolution.
+type Module struct {
+	Path string
+	// The modules directly imported by this module.
+	Imports []*typeset.Import
+	Set     typeset.TypeSet
+}
+
+// Resolve builds the lexical scopes of the provided modules and binds every
+// reference to the symbol it refers to.
+//
+// Modules share a global scope holding their `pub` symbols, extern aliases and
+// top-level assignments, which is in turn enclosed by the host globals of the
+// provided declarations. A module may only reference the global symbols of
+// modules it (transitively) imports whole, and never the non-`pub` symbols of
+// another module. Aliased imports bind a module symbol indexing the globals of
+// the module, selective imports make only the names selected visible.
+//
+// Undefined references, redeclarations and calls passing the wrong number of
+// arguments to a resolved callee are reported as errors, shadowing is reported
+// as a warning. Problems are reported to `diags`, the number of errors reported
+// is returned.
+func Resolve(decls *decl.Set, diags *sklog.Diagnostics, mods ...*Module) int {
+	r := &resolver{
+		diags:    diags,
+		universe: newScope(nil, scopeUniverse),
+		modules:  make(map[string]*Module),
+		private:  make(map[string][]*typeset.Symbol),
+	}
+
+	// Host globals.
+	for _, ext := range decls.Symbols {
+		r.universe.syms[ext.Name] = &typeset.Symbol{
+			Extern: ext,
+			Token:  ext.Token,
+			Name:   ext.Name,
+			File:   ext.File,
+			Kind:   typeset.SymHost,
+			Pub:    true,
+		}
+	}
+
+	r.global = newScope(r.universe, scopeGlobal)
+
+	// Declare all `pub` symbols and extern aliases ahead of time, these are
+	// visible to every importing module regardless of declaration order.
+	for _, mod := range mods {
+		r.file = mod.Path
+		r.modules[mod.Path] = mod
+		r.declareGlobals(mod)
+
+		// Note the non-`pub` symbols, to report references from other modules.
+		for _, sym := range r.locals(mod) {
+			r.private[sym.Name] = append(r.private[sym.Name], sym)
+		}
+	}
+
+	// Top-level assignments of undeclared names produce globals. These are
+	// identified in a second pass, since they may assign a `pub` symbol of a
+	// module appearing later.
+	for _, mod := range mods {
+		r.file = mod.Path
+		r.declareImplicitGlobals(mod)
+	}
+
+	// Resolve!
+	for _, mod := range mods {
+		r.file = mod.Path
+		r.visible = r.imported(mod, make(map[string]bool))
+		r.selected = make(map[string]string)
+		r.module(mod)
+	}
+
+	return r.errors
+}
+
+type resolver struct {
+	diags    *sklog.Diagnostics
+	universe *scope
+	global   *scope
+	// All modules, by path.
+	modules map[string]*Module
+	// Non-`pub` top-level symbols of all modules, by name.
+	private map[string][]*typeset.Symbol
+	// Paths of the modules visible to the module being resolved.
+	visible map[string]bool
+	// Names selectively imported by the module being resolved, mapped to the
+	// path of the module declaring them.
+	selected map[string]string
+	file     string
+	errors   int
+}
+
+// Collects the paths of all modules transitively imported whole by `mod`.
+func (r *resolver) imported(mod *Module, seen map[string]bool) map[string]bool {
+	for _, imp := range mod.Imports {
+		path := imp.Module
+		if !imp.Whole() || seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if imp, ok := r.modules[path]; ok {
+			r.imported(imp, seen)
+		}
+	}
+
+	return seen
+}
+
+/*------------------------------------------------------------------------------
+ * Declarations
+ *----------------------------------------------------------------------------*/
+
+// Produces a new symbol declared in the current file.
+func (r *resolver) newSymbol(
+	name string,
+	kind typeset.SymbolKind,
+	n typeset.SkalType,
+	tk token.Token,
+) *typeset.Symbol {
+	return &typeset.Symbol{
+		Decl:  n,
+		Token: tk,
+		Name:  name,
+		File:  r.file,
+		Kind:  kind,
+	}
+}
+
+// Declares a symbol in scope `sc`, reporting redeclarations and shadowing.
+func (r *resolver) declare(sc *scope, sym *typeset.Symbol) *typeset.Symbol {
+	if existing, ok := sc.syms[sym.Name]; ok {
+		r.errorf(
+			sklog.CodeRedeclared,
+			sym.Token,
+			"'{name}' is already declared at {loc}.",
+			"name", sym.Name,
+			"loc", location(existing),
+		)
+		return existing
+	}
+
+	if outer := sc.lookupOuter(sym.Name); outer != nil && outer.Kind != typeset.SymThis {
+		r.warnf(
+			sklog.CodeShadowed,
+			sym.Token,
+			"'{name}' shadows the {kind} declared at {loc}.",
+			"name", sym.Name,
+			"kind", outer.Kind.String(),
+			"loc", location(outer),
+		)
+	}
+
+	sc.syms[sym.Name] = sym
+	return sym
+}
+
+// Declares a symbol in the global scope.
+func (r *resolver) declareGlobal(sym *typeset.Symbol) *typeset.Symbol {
+	sym.Pub = true
+
+	if existing, ok := r.global.syms[sym.Name]; ok {
+		r.errorf(
+			sklog.CodeRedeclaredPub,
+			sym.Token,
+			"'{name}' is already declared pub at {loc}.",
+			"name", sym.Name,
+			"loc", location(existing),
+		)
+		return existing
+	}
+
+	if host, ok := r.universe.syms[sym.Name]; ok {
+		r.warnf(
+			sklog.CodeShadowedHost,
+			sym.Token,
+			"'{name}' shadows the host global declared at {loc}.",
+			"name", sym.Name,
+			"loc", location(host),
+		)
+	}
+
+	r.global.syms[sym.Name] = sym
+	return sym
+}
+
+func (r *resolver) declareGlobals(mod *Module) {
+	for _, member := range mod.Set.Members {
+		switch v := member.Value.(type) {
+		case *typeset.Enum:
+			if v.Pub() {
+				v.SetSymbol(r.declareGlobal(
+					r.newSymbol(v.ID(), typeset.SymEnum, v, v.Token())))
+			}
+
+		case *typeset.Struct:
+			if v.Pub() {
+				v.SetSymbol(r.declareGlobal(
+					r.newSymbol(v.ID(), typeset.SymStruct, v, v.Token())))
+			}
+
+		case *typeset.Fn:
+			if v.Pub() && v.RefsLen() == 1 {
+				v.SetSymbol(r.declareGlobal(
+					r.newSymbol(v.ID(), typeset.SymFn, v, v.Token())))
+			}
+
+		case *typeset.Bind:
+			if !v.Pub() || v.Rebind {
+				continue
+			}
+			for _, b := range v.Binds {
+				if b.RefsLen() == 1 {
+					b.SetSymbol(r.declareGlobal(
+						r.newSymbol(b.ID(), typeset.SymVar, v, b.Token())))
+				}
+			}
+
+		case []*typeset.External:
+			for _, ext := range v {
+				sym := r.newSymbol(ext.Alias, typeset.SymExtern, ext, ext.Token())
+				sym.Extern = ext.Decl
+				ext.SetSymbol(r.declareGlobal(sym))
+			}
+		}
+	}
+}
+
+func (r *resolver) declareImplicitGlobals(mod *Module) {
+	// Non-`pub` top-level names declared so far.
+	locals := make(map[string]bool)
+
+	for _, member := range mod.Set.Members {
+		switch v := member.Value.(type) {
+		case *typeset.Enum:
+			locals[v.ID()] = !v.Pub()
+
+		case *typeset.Struct:
+			locals[v.ID()] = !v.Pub()
+
+		case *typeset.Fn:
+			locals[v.ID()] = !v.Pub() && v.RefsLen() == 1
+
+		case *typeset.Bind:
+			for _, b := range v.Binds {
+				if b.RefsLen() != 1 {
+					continue
+				}
+
+				// Let
+				if !v.Rebind {
+					locals[b.ID()] = !v.Pub()
+					continue
+				}
+
+				// Rebind
+				if !locals[b.ID()] && r.global.lookup(b.ID()) == nil {
+					r.declareGlobal(
+						r.newSymbol(b.ID(), typeset.SymVar, v, b.Token()))
+				}
+			}
+		}
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Reporting
+ *----------------------------------------------------------------------------*/
+
+func (r *resolver) errorf(code string, tk token.Token, msg string, pairs ...string) {
+	r.errors++
+	r.event(code, sklog.MsgTypeResolveError, sklog.LevelError, tk, fstr.Pairs(msg, pairs...)).
+		Send()
+}
+
+func (r *resolver) warnf(code string, tk token.Token, msg string, pairs ...string) {
+	r.event(code, sklog.MsgTypeResolveWarning, sklog.LevelWarn, tk, fstr.Pairs(msg, pairs...)).
+		Send()
+}
+
+// Prepares an event positioned at `tk`, to be sent by the caller.
+func (r *resolver) event(code, mtype, level string, tk token.Token, msg string) *sklog.CompilerEvent {
+	ev := sklog.NewCompilerEvent(mtype, level).To(r.diags).WithCode(code)
+	if tk != nil {
+		ev.WithSourceHint(
+			tk.SrcLine(),
+			tk.File(),
+			tk.LineStart(),
+			tk.ColumnStart(),
+			tk.ColumnEnd(),
+		)
+	}
+
+	return ev.Str(msg)
+}
+
+// Produces a `file:line` representation of where a symbol was declared.
+func location(sym *typeset.Symbol) string {
+	if sym.Line() == 0 {
+		return filepath.Base(sym.File)
+	}
+
+	return filepath.Base(sym.File) + ":" + strconv.Itoa(sym.Line())
+}
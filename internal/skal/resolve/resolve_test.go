@@ -0,0 +1,167 @@
+package resolve_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/illbjorn/skal/internal/skal/lex"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/lua"
+	"github.com/illbjorn/skal/internal/skal/parse"
+	"github.com/illbjorn/skal/internal/skal/resolve"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+// A diagnostic expected of a fixture, at `file:line:col`.
+type diag struct {
+	code string
+	pos  string
+}
+
+// Resolves each fixture, comparing the diagnostics reported to those expected.
+// A fixture is `testdata/<name>.sk`, or the listed modules in dependency order
+// (`import 'x'` imports `testdata/x.sk`).
+func TestFixtures(t *testing.T) {
+	fixtures := []struct {
+		name  string
+		files []string
+		want  []diag
+	}{
+		{name: "scopes"},
+		{
+			name: "undefined",
+			want: []diag{
+				{sklog.CodeUndefinedRef, "undefined.sk:2:7"},
+				{sklog.CodeUndefinedRef, "undefined.sk:5:14"},
+				{sklog.CodeUndefinedRef, "undefined.sk:9:12"},
+				{sklog.CodeUndefinedRef, "undefined.sk:11:7"},
+			},
+		},
+		{
+			// Globals are declared ahead of the module's locals.
+			name: "redeclared",
+			want: []diag{
+				{sklog.CodeRedeclaredPub, "redeclared.sk:13:8"},
+				{sklog.CodeRedeclared, "redeclared.sk:2:5"},
+				{sklog.CodeRedeclared, "redeclared.sk:6:7"},
+				{sklog.CodeRedeclared, "redeclared.sk:10:4"},
+			},
+		},
+		{
+			name: "shadowed",
+			want: []diag{
+				{sklog.CodeShadowedHost, "shadowed.sk:8:8"},
+				{sklog.CodeShadowed, "shadowed.sk:4:7"},
+			},
+		},
+		{
+			name: "order",
+			want: []diag{
+				{sklog.CodeAssignBeforeDecl, "order.sk:2:3"},
+				{sklog.CodeAssignUndeclared, "order.sk:3:3"},
+				{sklog.CodeRefBeforeDecl, "order.sk:5:18"},
+				{sklog.CodeRefBeforeDecl, "order.sk:8:7"},
+			},
+		},
+		{
+			// Calls of fns, constructors, methods and host fns. Spread args and
+			// trailing calls pass any number of values.
+			name: "arity",
+			want: []diag{
+				{sklog.CodeArity, "arity.sk:45:1"},
+				{sklog.CodeArity, "arity.sk:46:1"},
+				{sklog.CodeArity, "arity.sk:47:1"},
+				{sklog.CodeArity, "arity.sk:48:1"},
+				{sklog.CodeArity, "arity.sk:49:1"},
+				{sklog.CodeArity, "arity.sk:50:1"},
+				{sklog.CodeArity, "arity.sk:51:1"},
+				{sklog.CodeArity, "arity.sk:52:1"},
+			},
+		},
+		{
+			// A whole import makes the module's pub symbols visible, and only
+			// those.
+			name:  "whole",
+			files: []string{"lib", "other", "whole"},
+			want: []diag{
+				{sklog.CodeNotPub, "whole.sk:4:7"},
+				{sklog.CodeNotImported, "whole.sk:5:7"},
+			},
+		},
+		{
+			// Aliased and selective imports bind the module's pub symbols by
+			// name, leaving the rest of its globals out of scope.
+			name:  "aliased",
+			files: []string{"lib", "aliased"},
+			want: []diag{
+				{sklog.CodeNotExported, "aliased.sk:2:19"},
+				{sklog.CodeNotExported, "aliased.sk:5:7"},
+				{sklog.CodeNotImported, "aliased.sk:6:7"},
+			},
+		},
+		{
+			name: "this",
+			want: []diag{{sklog.CodeThisOutsideMethod, "this.sk:10:3"}},
+		},
+	}
+
+	for _, fx := range fixtures {
+		t.Run(fx.name, func(t *testing.T) {
+			files := fx.files
+			if files == nil {
+				files = []string{fx.name}
+			}
+
+			var got []diag
+			for _, d := range resolveFixture(t, files...).List() {
+				got = append(got, diag{d.Code, fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.ColStart)})
+			}
+			if !reflect.DeepEqual(got, fx.want) {
+				t.Errorf("got %v\nwant %v", got, fx.want)
+			}
+		})
+	}
+}
+
+// Resolves the named `testdata` modules, returning the diagnostics
+// reported.
+func resolveFixture(t *testing.T, names ...string) *sklog.Diagnostics {
+	t.Helper()
+
+	diags := sklog.NewDiagnostics()
+	mods := make([]*resolve.Module, len(names))
+	for i, name := range names {
+		path := name + ".sk"
+		src, err := os.ReadFile(filepath.Join("testdata", path))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		func() {
+			defer func() { diags.Catch(recover()) }()
+			tree := parse.Parse(lex.Lex(path, string(src), diags))
+			imps := typeset.Imports(tree)
+			set := typeset.Typeset(tree, lua.Stdlib, diags)
+
+			// The imports lead the module, as they're compiled.
+			imports := typeset.NewTypeSet()
+			for _, imp := range imps {
+				imp.Module = imp.Path + ".sk"
+				imports.Add(imp, imp.Alias, token.Import)
+			}
+			set.Members = append(imports.Members, set.Members...)
+			mods[i] = &resolve.Module{Path: path, Imports: imps, Set: set}
+		}()
+	}
+	if err := diags.Err(); err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	resolve.Resolve(lua.Stdlib, diags, mods...)
+
+	return diags
+}
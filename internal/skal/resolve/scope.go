@@ -0,0 +1,83 @@
+package resolve
+
+import (
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+type scopeKind uint8
+
+const (
+	scopeUniverse scopeKind = iota + 1 // Host globals (stdlib, .skd).
+	scopeGlobal                        // `pub` symbols of all modules.
+	scopeModule                        // Non-`pub` top-level symbols of a module.
+	scopeFn                            // Fn args and body.
+	scopeBlock                         // If, elif, else and for bodies.
+	scopeFor                           // For iterators.
+)
+
+func newScope(parent *scope, kind scopeKind) *scope {
+	return &scope{
+		parent: parent,
+		kind:   kind,
+		syms:   make(map[string]*typeset.Symbol),
+		later:  make(map[string]*typeset.Symbol),
+	}
+}
+
+// A single lexical scope.
+type scope struct {
+	parent *scope
+	// Symbols declared so far.
+	syms map[string]*typeset.Symbol
+	// Symbols declared further down in the scope. These are not yet visible, but
+	// allow us to produce a clearer error on use-before-declaration.
+	later map[string]*typeset.Symbol
+	kind  scopeKind
+}
+
+// Looks up a visible symbol by name, walking outward through parent scopes.
+func (s *scope) lookup(name string) *typeset.Symbol {
+	for sc := s; sc != nil; sc = sc.parent {
+		if sym, ok := sc.syms[name]; ok {
+			return sym
+		}
+	}
+
+	return nil
+}
+
+// Looks up a symbol by name which is declared later in this or any enclosing
+// scope.
+func (s *scope) lookupLater(name string) *typeset.Symbol {
+	for sc := s; sc != nil; sc = sc.parent {
+		if sym, ok := sc.later[name]; ok {
+			return sym
+		}
+	}
+
+	return nil
+}
+
+// Looks up a symbol by name in the enclosing user-code scopes only, excluding
+// this scope and host globals. Used to detect shadowing.
+func (s *scope) lookupOuter(name string) *typeset.Symbol {
+	for sc := s.parent; sc != nil && sc.kind != scopeUniverse; sc = sc.parent {
+		if sym, ok := sc.syms[name]; ok {
+			return sym
+		}
+	}
+
+	return nil
+}
+
+// Lists the names of all visible symbols, for suggestions.
+func (s *scope) names() []string {
+	var out []string
+	for sc := s; sc != nil; sc = sc.parent {
+		for name := range sc.syms {
+			out = append(out, name)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,588 @@
+package resolve
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/illbjorn/fstr"
+	"github.com/illbjorn/skal/internal/skal/decl"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+/*------------------------------------------------------------------------------
+ * Module
+ *----------------------------------------------------------------------------*/
+
+func (r *resolver) module(mod *Module) {
+	sc := newScope(r.global, scopeModule)
+
+	// Note all non-`pub` top-level declarations ahead of time.
+	for _, sym := range r.locals(mod) {
+		if _, ok := sc.later[sym.Name]; !ok {
+			sc.later[sym.Name] = sym
+		}
+	}
+
+	// Imports lead the module.
+	for _, imp := range mod.Imports {
+		r.nimport(sc, imp)
+	}
+
+	for _, member := range mod.Set.Members {
+		switch v := member.Value.(type) {
+		case *typeset.Enum:
+			r.enum(sc, v)
+
+		case *typeset.Struct:
+			r.nstruct(sc, v)
+
+		case *typeset.Fn:
+			r.fnDecl(sc, v)
+
+		case *typeset.Bind:
+			r.bind(sc, v)
+
+		case *typeset.Call:
+			r.call(sc, v)
+
+		case *typeset.If:
+			r.conditional(sc, v)
+
+		case *typeset.For:
+			r.forLoop(sc, v)
+
+		// Extern aliases are declared with the globals.
+		case []*typeset.External:
+
+		// Imports are resolved ahead of the module.
+		case *typeset.Import:
+		}
+	}
+}
+
+// Produces symbols for all non-`pub` top-level declarations of a module.
+func (r *resolver) locals(mod *Module) []*typeset.Symbol {
+	var out []*typeset.Symbol
+	note := func(name string, kind typeset.SymbolKind, n typeset.SkalType) {
+		if name == "" {
+			return
+		}
+		out = append(out, r.newSymbol(name, kind, n, n.Token()))
+	}
+
+	for _, member := range mod.Set.Members {
+		switch v := member.Value.(type) {
+		case *typeset.Enum:
+			if !v.Pub() {
+				note(v.ID(), typeset.SymEnum, v)
+			}
+		case *typeset.Struct:
+			if !v.Pub() {
+				note(v.ID(), typeset.SymStruct, v)
+			}
+		case *typeset.Fn:
+			if !v.Pub() && v.RefsLen() == 1 {
+				note(v.ID(), typeset.SymFn, v)
+			}
+		case *typeset.Bind:
+			if v.Pub() || v.Rebind {
+				continue
+			}
+			for _, b := range v.Binds {
+				if b.RefsLen() == 1 {
+					note(b.ID(), typeset.SymVar, b)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Notes a declaration further down in scope `sc`.
+func (r *resolver) later(
+	sc *scope,
+	name string,
+	kind typeset.SymbolKind,
+	n typeset.SkalType,
+	local bool,
+) {
+	if !local || name == "" {
+		return
+	}
+
+	if _, ok := sc.later[name]; ok {
+		return
+	}
+
+	sc.later[name] = r.newSymbol(name, kind, n, n.Token())
+}
+
+func (r *resolver) laterBinds(sc *scope, bind *typeset.Bind, local bool) {
+	if bind.Rebind {
+		return
+	}
+
+	for _, b := range bind.Binds {
+		if b.RefsLen() == 1 {
+			r.later(sc, b.ID(), typeset.SymVar, b, local)
+		}
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Enums and Structs
+ *----------------------------------------------------------------------------*/
+
+func (r *resolver) enum(sc *scope, enum *typeset.Enum) {
+	if enum.Symbol() != nil {
+		return // `pub`
+	}
+
+	enum.SetSymbol(r.declare(
+		sc, r.newSymbol(enum.ID(), typeset.SymEnum, enum, enum.Token())))
+}
+
+func (r *resolver) nstruct(sc *scope, nstruct *typeset.Struct) {
+	if nstruct.Symbol() == nil {
+		nstruct.SetSymbol(r.declare(
+			sc, r.newSymbol(nstruct.ID(), typeset.SymStruct, nstruct, nstruct.Token())))
+	}
+
+	// Methods
+	// The struct instance is available as `this`.
+	this := r.newSymbol(
+		token.This.String(), typeset.SymThis, nstruct, nstruct.Token())
+	for _, method := range nstruct.Methods {
+		r.fn(sc, method, this)
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Fns
+ *----------------------------------------------------------------------------*/
+
+// Resolves a named fn declaration.
+func (r *resolver) fnDecl(sc *scope, fn *typeset.Fn) {
+	switch {
+	// `pub` fns are declared with the globals.
+	case fn.Symbol() != nil:
+
+	// Fns assigned to a field (`fn a.b()`) declare nothing, the root must exist.
+	case fn.RefsLen() > 1:
+		r.ref(sc, fn)
+
+	// Declare the fn before resolving its body, allowing recursion.
+	default:
+		fn.SetSymbol(r.declare(
+			sc, r.newSymbol(fn.ID(), typeset.SymFn, fn, fn.Token())))
+	}
+
+	r.fn(sc, fn, nil)
+}
+
+// Resolves the args and body of a fn. If `this` is provided, the fn is a
+// struct method.
+func (r *resolver) fn(sc *scope, fn *typeset.Fn, this *typeset.Symbol) {
+	fsc := newScope(sc, scopeFn)
+	if this != nil {
+		fsc.syms[this.Name] = this
+	}
+
+	// Args
+	for _, arg := range fn.Args {
+		arg.SetSymbol(r.declare(
+			fsc, r.newSymbol(arg.ID(), typeset.SymArg, arg, arg.Token())))
+	}
+
+	// Anonymous fns have values rather than statements.
+	r.values(fsc, fn.Values)
+
+	// Block
+	// Lua fn bodies share their scope with the fn args.
+	r.statements(fsc, fn.Block)
+}
+
+/*------------------------------------------------------------------------------
+ * Statements
+ *----------------------------------------------------------------------------*/
+
+// Resolves a block of statements in a new scope.
+func (r *resolver) block(sc *scope, block []*typeset.Statement) {
+	r.statements(newScope(sc, scopeBlock), block)
+}
+
+// Resolves a block of statements in scope `sc`.
+func (r *resolver) statements(sc *scope, block []*typeset.Statement) {
+	// Note all declarations ahead of time.
+	for _, stmt := range block {
+		switch stmt.StmtType {
+		case token.Bind:
+			r.laterBinds(sc, stmt.Bind, true)
+		case token.Fn:
+			r.later(sc, stmt.Fn.ID(), typeset.SymFn, stmt.Fn, stmt.Fn.RefsLen() == 1)
+		}
+	}
+
+	for _, stmt := range block {
+		r.statement(sc, stmt)
+	}
+}
+
+func (r *resolver) statement(sc *scope, stmt *typeset.Statement) {
+	switch stmt.StmtType {
+	// 'return'
+	case token.Ret:
+		r.values(sc, stmt.Values)
+
+	// 'for'
+	case token.For:
+		r.forLoop(sc, stmt.For)
+
+	// Call
+	case token.Call:
+		r.call(sc, stmt.Call)
+
+	// 'if'
+	case token.If:
+		r.conditional(sc, stmt.If)
+
+	// Bind | Rebind
+	case token.Bind, token.Rebind:
+		r.bind(sc, stmt.Bind)
+
+	// 'fn'
+	case token.Fn:
+		r.fnDecl(sc, stmt.Fn)
+
+	// 'defer'
+	case token.Defer:
+		for d := range stmt.Defers() {
+			r.statement(sc, d)
+		}
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Binds
+ *----------------------------------------------------------------------------*/
+
+func (r *resolver) bind(sc *scope, bind *typeset.Bind) {
+	// Values are resolved before the bound names are declared, `let x = x`
+	// refers to an outer `x`.
+	r.values(sc, bind.Values)
+
+	for _, b := range bind.Binds {
+		switch {
+		// Already bound (`pub` and implicit globals).
+		case b.Symbol() != nil:
+
+		// Assignment to a field, the root must exist.
+		case b.RefsLen() > 1:
+			r.ref(sc, b)
+
+		// Let
+		case !bind.Rebind:
+			b.SetSymbol(r.declare(
+				sc, r.newSymbol(b.ID(), typeset.SymVar, bind, b.Token())))
+
+		// Rebind
+		default:
+			if sym := sc.lookup(b.ID()); sym != nil {
+				b.SetSymbol(sym)
+				continue
+			}
+
+			if sym := sc.lookupLater(b.ID()); sym != nil {
+				r.errorf(
+					sklog.CodeAssignBeforeDecl,
+					b.Token(),
+					"'{name}' is assigned before its declaration at {loc}.",
+					"name", b.ID(),
+					"loc", location(sym),
+				)
+				continue
+			}
+
+			r.errors++
+			r.event(
+				sklog.CodeAssignUndeclared,
+				sklog.MsgTypeResolveError,
+				sklog.LevelError,
+				b.Token(),
+				fstr.Pairs(
+					"Assignment to undeclared name '{name}', declare it with '{let}' first.",
+					"name", b.ID(),
+					"let", token.Let.String(),
+				),
+			).
+				WithSuggestions(sklog.Suggest(b.ID(), sc.names())...).
+				Send()
+		}
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * Conditionals
+ *----------------------------------------------------------------------------*/
+
+func (r *resolver) conditional(sc *scope, nif *typeset.If) {
+	// If
+	r.values(sc, nif.Conditions)
+	r.block(sc, nif.Block)
+
+	// Elifs
+	for _, elif := range nif.Elifs {
+		r.values(sc, elif.Conditions)
+		r.block(sc, elif.Block)
+	}
+
+	// Else
+	if nif.Else != nil {
+		r.block(sc, nif.Else.Block)
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * For
+ *----------------------------------------------------------------------------*/
+
+func (r *resolver) forLoop(sc *scope, nfor *typeset.For) {
+	// Iterables are resolved in the enclosing scope.
+	for _, iterable := range nfor.Iterables {
+		r.ref(sc, iterable)
+		r.hostMembers(iterable)
+	}
+
+	// Iterators are scoped to the loop.
+	fsc := newScope(sc, scopeFor)
+	for _, iterator := range nfor.Iterators {
+		iterator.SetSymbol(r.declare(
+			fsc, r.newSymbol(iterator.ID(), typeset.SymIterator, iterator, iterator.Token())))
+	}
+
+	r.block(fsc, nfor.Block)
+}
+
+/*------------------------------------------------------------------------------
+ * Values
+ *----------------------------------------------------------------------------*/
+
+func (r *resolver) values(sc *scope, values []*typeset.Value) {
+	for _, v := range values {
+		r.value(sc, v)
+	}
+}
+
+func (r *resolver) value(sc *scope, value *typeset.Value) {
+	switch value.ValueType {
+	// Reference
+	case token.Ref:
+		r.ref(sc, value)
+		r.hostMembers(value)
+
+	// Call
+	case token.Call:
+		r.call(sc, value.Call)
+
+	// Anonymous fn
+	case token.Fn:
+		r.fn(sc, value.Fn, nil)
+
+	// Value group
+	case token.ValueGroup:
+		r.values(sc, value.Group)
+
+	// List literal
+	case token.ListL:
+		r.values(sc, value.List)
+	}
+}
+
+func (r *resolver) call(sc *scope, call *typeset.Call) {
+	r.ref(sc, call)
+	r.hostMembers(call)
+
+	for _, arg := range call.Args {
+		r.values(sc, arg.Values)
+	}
+
+	r.arity(call)
+}
+
+/*------------------------------------------------------------------------------
+ * References
+ *----------------------------------------------------------------------------*/
+
+// Resolves the root name of a reference (and any names used to index it),
+// binding the resolved symbol to `t`.
+func (r *resolver) ref(sc *scope, t typeset.SkalType) {
+	refs := t.Refs()
+	if len(refs) == 0 {
+		return
+	}
+
+	if sym := r.name(sc, refs[0], t.Token()); sym != nil {
+		t.SetSymbol(sym)
+
+		// Module member
+		// btn.Button
+		if sym.Module() && len(refs) > 1 && !strings.HasPrefix(refs[1], "[") {
+			r.member(sym.Decl.(*typeset.Import), refs[1], t.Token())
+		}
+	}
+
+	// Index
+	// a[i]
+	// a[this.Field]
+	for _, ref := range refs[1:] {
+		if !strings.HasPrefix(ref, "[") {
+			continue
+		}
+
+		index := strings.Split(strings.Trim(ref, "[]"), ".")[0]
+		switch {
+		// 'this'
+		case index == "self":
+			r.name(sc, token.This.String(), t.Token())
+
+		// Skip literals.
+		case !isName(index):
+
+		default:
+			r.name(sc, index, t.Token())
+		}
+	}
+}
+
+// Confirms the members read of a declared host table (or an extern alias of
+// one) are declared. Assignments may add members.
+//
+// math.floor
+// m.floor
+func (r *resolver) hostMembers(t typeset.SkalType) {
+	sym := t.Symbol()
+	if !sym.HostTable() {
+		return
+	}
+
+	refs := t.Refs()
+	ext := sym.Extern
+	for i, ref := range refs[1:] {
+		if strings.HasPrefix(ref, "[") || ext.Kind != decl.KindTable {
+			return
+		}
+
+		member := ext.Member(ref)
+		if member != nil {
+			ext = member
+			continue
+		}
+
+		names := make([]string, 0, len(ext.Members))
+		for _, m := range ext.Members {
+			names = append(names, m.Name)
+		}
+
+		r.errors++
+		r.event(
+			sklog.CodeUndeclaredExtern,
+			sklog.MsgTypeResolveError,
+			sklog.LevelError,
+			t.Token(),
+			fstr.Pairs(
+				"'{ref}' is not declared, {table} has no such member.",
+				"ref", display(refs[:i+2]),
+				"table", ext.Signature(),
+			),
+		).
+			WithSuggestions(sklog.Suggest(ref, names)...).
+			Send()
+		return
+	}
+}
+
+// Resolves a single name, reporting it if it can't be resolved.
+func (r *resolver) name(sc *scope, name string, tk token.Token) *typeset.Symbol {
+	if sym := sc.lookup(name); sym != nil {
+		r.boundary(sym, tk)
+		return sym
+	}
+
+	// Non-`pub` symbol of another module.
+	for _, sym := range r.private[name] {
+		if sym.File == r.file {
+			continue
+		}
+
+		r.errorf(
+			sklog.CodeNotPub,
+			tk,
+			"'{name}' is not pub, it is private to {file} (declared at {loc}).",
+			"name", name,
+			"file", filepath.Base(sym.File),
+			"loc", location(sym),
+		)
+		return nil
+	}
+
+	switch {
+	// 'this'
+	case name == token.This.String():
+		r.errorf(sklog.CodeThisOutsideMethod, tk, "'{this}' is only available inside struct methods.",
+			"this", token.This.String())
+
+	// Referenced before declaration.
+	case sc.lookupLater(name) != nil:
+		r.errorf(
+			sklog.CodeRefBeforeDecl,
+			tk,
+			"'{name}' is referenced before its declaration at {loc}.",
+			"name", name,
+			"loc", location(sc.lookupLater(name)),
+		)
+
+	default:
+		r.errors++
+		r.event(
+			sklog.CodeUndefinedRef,
+			sklog.MsgTypeResolveError,
+			sklog.LevelError,
+			tk,
+			fstr.Pairs("Undefined reference: '{name}'.", "name", name),
+		).
+			WithSuggestions(sklog.Suggest(name, append(sc.names(), token.Keywords()...))...).
+			Send()
+	}
+
+	return nil
+}
+
+// Confirms a global symbol declared by another module is visible across the
+// import boundaries of the module being resolved.
+func (r *resolver) boundary(sym *typeset.Symbol, tk token.Token) {
+	if !sym.Pub || sym.Kind == typeset.SymHost || sym.File == r.file || r.visible[sym.File] ||
+		r.selected[sym.Name] == sym.File {
+		return
+	}
+
+	r.errorf(
+		sklog.CodeNotImported,
+		tk,
+		"'{name}' is declared in {file}, which is not imported by {this}.",
+		"name", sym.Name,
+		"file", filepath.Base(sym.File),
+		"this", filepath.Base(r.file),
+	)
+}
+
+// Indicates whether `s` is an identifier, as opposed to a literal.
+func isName(s string) bool {
+	if s == "" || s == token.True.String() || s == token.False.String() {
+		return false
+	}
+
+	c := s[0]
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
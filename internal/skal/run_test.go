@@ -0,0 +1,87 @@
+package skal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/illbjorn/skal/internal/skal/exec"
+)
+
+func TestRunError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dice.sk": {Data: []byte(`pub fn roll(x) {
+  for i = 1, 3 {
+    print(math.floor(x))
+  }
+}
+`)},
+		"main.sk": {Data: []byte(`import 'dice'
+
+fn play(label) {
+  roll(label)
+}
+
+play("abc")
+`)},
+	}
+
+	cases := []struct {
+		name string
+		opts Options
+		// The host fn raising, as Lua names it. Those cached in locals by `-O`
+		// are named by their path.
+		fn string
+	}{
+		{name: "plain", fn: "floor"},
+		{name: "optimized", opts: Options{Optimize: true}, fn: "math.floor"},
+		{name: "minified", opts: Options{Minify: true}, fn: "floor"},
+		{name: "optimized and minified", opts: Options{Optimize: true, Minify: true}, fn: "math.floor"},
+		{name: "cached", opts: Options{Optimize: true, CacheDir: t.TempDir()}, fn: "math.floor"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.opts.FS = fsys
+			c.opts.Stdout = io.Discard
+			err := Run(context.Background(), "main.sk", c.opts)
+			if c.opts.CacheDir != "" {
+				// Reusing the modules cached.
+				c.opts.CacheStats = new(CacheStats)
+				err = Run(context.Background(), "main.sk", c.opts)
+				if c.opts.CacheStats.Hits != 2 {
+					t.Fatalf("cache hits = %d, want 2", c.opts.CacheStats.Hits)
+				}
+			}
+
+			rerr := new(exec.Error)
+			if !errors.As(err, &rerr) {
+				t.Fatalf("Run() = %v, want an *exec.Error", err)
+			}
+			d := rerr.Diagnostic
+			if d.File != "dice.sk" || d.Line != 3 || d.ColStart != 5 {
+				t.Errorf("at %s:%d:%d, want dice.sk:3:5", d.File, d.Line, d.ColStart)
+			}
+			if want := "    print(math.floor(x))"; d.Src != want {
+				t.Errorf("source = %q, want %q", d.Src, want)
+			}
+			if want := "bad argument #1 to " + c.fn + " (number expected, got string)"; d.Message != want {
+				t.Errorf("message = %q, want %q", d.Message, want)
+			}
+
+			// Frames name fns as they're named in Skal.
+			want := []string{
+				"[G]: in function '" + c.fn + "'",
+				"dice.sk:3:5: in function 'roll'",
+				"main.sk:4:3: in function 'play'",
+				"main.sk:7:1: in main chunk",
+			}
+			if !reflect.DeepEqual(d.Stack, want) {
+				t.Errorf("stack = %q, want %q", d.Stack, want)
+			}
+		})
+	}
+}
@@ -5,10 +5,8 @@ import (
 	"runtime"
 	"slices"
 	"strconv"
-	"strings"
 
 	"github.com/illbjorn/fstr"
-	"github.com/illbjorn/skal/pkg/pprint"
 )
 
 // Produces a formatted string representation of the `i`th caller down the
@@ -60,16 +58,3 @@ func callStack(depth int) []string {
 
 	return out
 }
-
-// Wraps `callStack()` call output into a *pprint.Msg for use in log messages.
-func callStackF(msg *pprint.Msg, depth int) *pprint.Msg {
-	frames := callStack(depth)
-	for i, v := range frames {
-		// Add the 'AT' block in white.
-		msg.Add("\n" + strings.Repeat(" ", i+1))
-		msg.Yellow("AT: ")
-		// Add the call stack value in yellow.
-		msg.White(v)
-	}
-	return msg
-}
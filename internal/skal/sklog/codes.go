@@ -0,0 +1,578 @@
+package sklog
+
+import (
+	"sort"
+	"strings"
+)
+
+// Diagnostic Codes
+//
+// Codes are stable: once published a code keeps its meaning, retired codes are
+// never reused. The leading digits group codes by compiler phase.
+const (
+	// 00xx: Lex
+	CodeUnexpectedChar     = "SK0001"
+	CodeUnterminatedString = "SK0002"
+	CodeUnknownSymbol      = "SK0003"
+
+	// 01xx: Parse
+	CodeUnexpectedToken   = "SK0101"
+	CodeMissingBrace      = "SK0102"
+	CodeAssignInCondition = "SK0103"
+	CodeMisplacedImport   = "SK0104"
+	CodeTopLevelDefer     = "SK0105"
+	CodeExpectedStatement = "SK0106"
+	CodeExpectedCall      = "SK0107"
+	CodeExpectedIterable  = "SK0108"
+	CodeInvalidListValue  = "SK0109"
+
+	// 02xx: Typeset
+	CodeUndeclaredExtern = "SK0201"
+
+	// 03xx: Resolve
+	CodeUndefinedRef      = "SK0301"
+	CodeRedeclared        = "SK0302"
+	CodeShadowed          = "SK0303"
+	CodeRedeclaredPub     = "SK0304"
+	CodeShadowedHost      = "SK0305"
+	CodeAssignBeforeDecl  = "SK0306"
+	CodeAssignUndeclared  = "SK0307"
+	CodeNotPub            = "SK0308"
+	CodeThisOutsideMethod = "SK0309"
+	CodeRefBeforeDecl     = "SK0310"
+	CodeNotImported       = "SK0311"
+	CodeArity             = "SK0312"
+	CodeNotExported       = "SK0313"
+
+	// 04xx: Flow
+	CodeUnreachableAfterRet = "SK0401"
+	CodeUnreachable         = "SK0402"
+	CodeMissingReturnValue  = "SK0403"
+	CodeMissingReturn       = "SK0404"
+	CodeUnassigned          = "SK0405"
+	CodeMaybeUnassigned     = "SK0406"
+	CodeStrayContinue       = "SK0407"
+
+	// 05xx: Input
+	CodeReadFailed  = "SK0501"
+	CodeDeclFailed  = "SK0502"
+	CodeImportCycle = "SK0503"
+	CodeBadManifest = "SK0504"
+	CodeDepMismatch = "SK0505"
+
+	// 06xx: Emit
+	CodeIntPrecision = "SK0601"
+	CodeNoBitwise    = "SK0602"
+	CodeOutsideRoot  = "SK0603"
+
+	// 09xx: Internal
+	CodeInternal = "SK0901"
+)
+
+type codeInfo struct {
+	title   string
+	explain string
+}
+
+// Title returns the one-line description of a diagnostic code.
+func Title(code string) (string, bool) {
+	info, ok := codes[code]
+	return info.title, ok
+}
+
+// Explain returns the long-form help of a diagnostic code.
+func Explain(code string) (string, bool) {
+	info, ok := codes[strings.ToUpper(code)]
+	if !ok {
+		return "", false
+	}
+
+	return strings.TrimSpace(info.explain), true
+}
+
+// Codes returns all diagnostic codes, in order.
+func Codes() []string {
+	out := make([]string, 0, len(codes))
+	for code := range codes {
+		out = append(out, code)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+var codes = map[string]codeInfo{
+	/*--------------------------------------------------------------------------
+	 * Lex
+	 *------------------------------------------------------------------------*/
+
+	CodeUnexpectedChar: {
+		title: "Unexpected character",
+		explain: `
+The source contains a character which can't begin any token, for example a
+stray '@' or '$'. The character is skipped and lexing continues.
+
+Remove the character, or if it belongs in text, move it inside a string
+literal:
+
+    let s = '$5'
+`,
+	},
+
+	CodeUnterminatedString: {
+		title: "Unterminated string literal",
+		explain: `
+A string literal was opened but the end of the file was reached before its
+closing quote. Strings must be closed with the same quote that opened them:
+
+    let a = 'single'
+    let b = "double"
+`,
+	},
+
+	CodeUnknownSymbol: {
+		title: "Unknown symbol",
+		explain: `
+A run of punctuation doesn't form any operator Skal understands. Note that
+logical operators are written '&&', '||' and '!', the single '&', '|' and '~'
+are bitwise operators.
+`,
+	},
+
+	/*--------------------------------------------------------------------------
+	 * Parse
+	 *------------------------------------------------------------------------*/
+
+	CodeUnexpectedToken: {
+		title: "Unexpected token",
+		explain: `
+The parser expected a particular token (e.g. '(' after a fn name, or '{' to
+open a block) but found another. The diagnostic names both; the usual cause
+is a typo or a missing delimiter just before the reported position.
+`,
+	},
+
+	CodeMissingBrace: {
+		title: "Missing closing brace",
+		explain: `
+The end of the file was reached while a block was still open. Every '{' must
+be closed with a matching '}':
+
+    fn greet() {
+      print('hi')
+    }
+
+The suggested fix inserts the missing '}' at the end of the file, check it
+closes the block you intended.
+`,
+	},
+
+	CodeAssignInCondition: {
+		title: "Assignment in condition",
+		explain: `
+A condition of an 'if' or 'elif' uses '=', which assigns, where '==', which
+compares, was almost certainly intended:
+
+    if count = 0 { ... }   # Error
+    if count == 0 { ... }  # OK
+
+Assignments are statements in Skal and can't appear in conditions. The
+suggested fix replaces '=' with '=='.
+`,
+	},
+
+	CodeMisplacedImport: {
+		title: "Import after code",
+		explain: `
+Imports must be the first statements of a file, ahead of any declarations or
+code. Move the import to the top of the file.
+`,
+	},
+
+	CodeTopLevelDefer: {
+		title: "Top-level defer",
+		explain: `
+'defer' schedules a statement to run when the enclosing fn returns, so it
+can only appear inside a fn body. At the top level of a module there is no
+fn to return from.
+`,
+	},
+
+	CodeExpectedStatement: {
+		title: "Expected a statement",
+		explain: `
+A token which can't begin a declaration or statement was found where one was
+expected. Statements begin with a keyword ('let', 'fn', 'if', 'for',
+'return', 'defer', 'struct', 'enum', 'extern', 'pub') or with a name being
+called or assigned.
+
+A misspelled keyword is parsed as a name, check the diagnostic for a
+suggestion.
+`,
+	},
+
+	CodeExpectedCall: {
+		title: "Expected a call or assignment",
+		explain: `
+A statement beginning with a name must either call it or assign to it:
+
+    print('hi')
+    count = count + 1
+
+A value on its own is not a statement. If the name is a misspelled keyword
+(e.g. 'retrun'), the diagnostic suggests the keyword.
+`,
+	},
+
+	CodeExpectedIterable: {
+		title: "Expected a for iterable",
+		explain: `
+A 'for' loop iterates either a numeric range or the results of a call:
+
+    for i = 1, 10 { ... }
+    for k, v in pairs(t) { ... }
+`,
+	},
+
+	CodeInvalidListValue: {
+		title: "Invalid list literal value",
+		explain: `
+List literals may only contain string or int literal values.
+`,
+	},
+
+	/*--------------------------------------------------------------------------
+	 * Typeset
+	 *------------------------------------------------------------------------*/
+
+	CodeUndeclaredExtern: {
+		title: "Undeclared extern member",
+		explain: `
+An 'extern', or a reference read through a declared host table (such as
+'math.foo'), names a member the table's declaration doesn't have. Check the
+spelling against the .skd declaration, or add the member to it.
+
+Where a similarly spelled member is declared, it is suggested.
+`,
+	},
+
+	/*--------------------------------------------------------------------------
+	 * Resolve
+	 *------------------------------------------------------------------------*/
+
+	CodeUndefinedRef: {
+		title: "Undefined reference",
+		explain: `
+A name is used which isn't declared in any enclosing scope, by an imported
+module, or as a host global. Declare it with 'let', 'fn', 'struct' or
+'enum', import the module declaring it, or for host globals, declare it in a
+.skd file (see '--decl').
+
+Where a similarly spelled name is in scope, it is suggested.
+`,
+	},
+
+	CodeRedeclared: {
+		title: "Duplicate declaration",
+		explain: `
+A name is declared twice in the same scope. Rename one of the declarations,
+or assign to the existing one without 'let':
+
+    let x = 1
+    x = 2
+`,
+	},
+
+	CodeShadowed: {
+		title: "Shadowed declaration",
+		explain: `
+A declaration hides a declaration of the same name in an enclosing scope.
+This is allowed, but within the inner scope the outer declaration can't be
+reached, which is a common source of bugs. Consider renaming one of them.
+`,
+	},
+
+	CodeRedeclaredPub: {
+		title: "Duplicate pub declaration",
+		explain: `
+'pub' declarations of all modules share a single namespace, and two modules
+declare the same pub name. Rename one of them.
+`,
+	},
+
+	CodeShadowedHost: {
+		title: "Shadowed host global",
+		explain: `
+A declaration hides a host global (a Lua standard library or .skd declared
+name) of the same name, making the host global unreachable.
+`,
+	},
+
+	CodeAssignBeforeDecl: {
+		title: "Assignment before declaration",
+		explain: `
+A name is assigned before the 'let' declaring it. Move the declaration ahead
+of the assignment.
+`,
+	},
+
+	CodeAssignUndeclared: {
+		title: "Assignment to undeclared name",
+		explain: `
+A name is assigned which was never declared. Declare it with 'let' first:
+
+    let count = 0
+`,
+	},
+
+	CodeNotPub: {
+		title: "Reference to a private symbol",
+		explain: `
+A top-level name declared by another module is referenced, but it isn't
+'pub'. Only pub declarations are visible outside the module declaring them:
+
+    pub fn helper() { ... }
+`,
+	},
+
+	CodeThisOutsideMethod: {
+		title: "'this' outside a method",
+		explain: `
+'this' refers to the struct instance a method is called on, and is only
+available inside struct methods.
+`,
+	},
+
+	CodeRefBeforeDecl: {
+		title: "Reference before declaration",
+		explain: `
+A name is used before the statement declaring it. Move the declaration ahead
+of its first use.
+`,
+	},
+
+	CodeNotImported: {
+		title: "Reference to an unimported module",
+		explain: `
+A pub name declared by another module is referenced, but that module isn't
+imported (directly or transitively) by the referencing module. Import it:
+
+    import 'ui/button'
+`,
+	},
+
+	CodeArity: {
+		title: "Wrong number of arguments",
+		explain: `
+A call passes more or fewer arguments than its callee declares. The
+diagnostic includes the callee's declared signature where it is known.
+
+Spread arguments and trailing calls pass an unknown number of values and are
+not checked.
+`,
+	},
+
+	CodeNotExported: {
+		title: "Name not exported by the imported module",
+		explain: `
+A selective import names, or an aliased module is indexed by, a name the
+imported module doesn't declare as a global ('pub' symbols, extern aliases and
+top-level assignments):
+
+    import { Buton } from 'ui/button'   # 'Button' was meant
+    import 'ui/button' as btn
+    btn.Buton()
+
+Check the spelling, or declare the name 'pub' in the imported module.
+`,
+	},
+
+	/*--------------------------------------------------------------------------
+	 * Flow
+	 *------------------------------------------------------------------------*/
+
+	CodeUnreachableAfterRet: {
+		title: "Statement after return or continue",
+		explain: `
+A statement directly follows a 'return' or 'continue' in the same block. Lua
+requires 'return' (and 'break', which 'continue' may be lowered to) to be the
+final statement of a block, so this is an error rather than a warning. Remove
+the statement, or move it ahead of the return.
+`,
+	},
+
+	CodeUnreachable: {
+		title: "Unreachable code",
+		explain: `
+A statement can never run, because every path leading to it returns first.
+`,
+	},
+
+	CodeMissingReturnValue: {
+		title: "Missing return value",
+		explain: `
+A fn declared with a return type contains a bare 'return', which returns no
+value. Return a value of the declared type.
+`,
+	},
+
+	CodeMissingReturn: {
+		title: "Missing return",
+		explain: `
+A fn declared with a return type may reach the end of its body without
+returning, for example when an 'if' without an 'else' returns:
+
+    fn sign(n): int {
+      if n < 0 {
+        return -1
+      }
+      // Missing: return 1
+    }
+`,
+	},
+
+	CodeUnassigned: {
+		title: "Read of an unassigned name",
+		explain: `
+A name declared without a value ('let x;') is read before any assignment to
+it, its value is always nil at that point.
+`,
+	},
+
+	CodeMaybeUnassigned: {
+		title: "Read of a possibly unassigned name",
+		explain: `
+A name declared without a value ('let x;') is read where some, but not all,
+paths leading to the read assign it.
+`,
+	},
+
+	CodeStrayContinue: {
+		title: "'continue' outside a loop",
+		explain: `
+'continue' skips to the next iteration of the innermost enclosing 'for' loop,
+so it can only appear within a loop body. A fn declared inside a loop body is
+not within the loop.
+`,
+	},
+
+	/*--------------------------------------------------------------------------
+	 * Input
+	 *------------------------------------------------------------------------*/
+
+	CodeReadFailed: {
+		title: "Failed to read source",
+		explain: `
+An input file or imported module couldn't be read. Check the path exists and
+is readable.
+
+Imports are resolved against each module search root in order: the directory
+of the entrypoint, the 'paths' of the project manifest (skal.toml), its 'deps/'
+directory, then each directory of the SKAL_PATH environment variable.
+`,
+	},
+
+	CodeDeclFailed: {
+		title: "Failed to load declarations",
+		explain: `
+A declaration file or directory passed with '--decl' couldn't be read.
+`,
+	},
+
+	CodeImportCycle: {
+		title: "Import cycle",
+		explain: `
+A module imports itself, directly or through the modules it imports:
+
+    a.sk -> b.sk -> a.sk
+
+Modules are initialized in dependency order, each after the modules it
+imports, so a cycle has no valid order. Move the symbols the modules share
+into a module of their own, imported by each of them.
+`,
+	},
+
+	CodeBadManifest: {
+		title: "Invalid project manifest",
+		explain: `
+The project manifest (skal.toml) couldn't be read, isn't valid TOML or has a
+key of the wrong type. Manifests use a subset of TOML: strings, integers,
+booleans, arrays and tables.
+
+    name = "game"
+    entrypoints = ["main.sk"]
+    output = "build"
+    target = "luajit"
+    paths = ["lib", "../shared"]
+`,
+	},
+
+	CodeDepMismatch: {
+		title: "Dependency doesn't match the lockfile",
+		explain: `
+Each dependency declared by the '[deps]' table of the manifest is vendored
+into the 'deps/' directory, and the content hash of the copy is pinned by the
+lockfile (skal.lock). A compile checks every dependency is pinned, vendored and
+unmodified:
+
+    skal mod tidy     # Pin new dependencies, remove dropped ones.
+    skal mod vendor   # Restore 'deps/' from the pinned sources.
+    skal mod add <source> [name]
+                      # Pin a dependency again after its source changed.
+`,
+	},
+
+	/*--------------------------------------------------------------------------
+	 * Emit
+	 *------------------------------------------------------------------------*/
+
+	CodeIntPrecision: {
+		title: "Integer literal exceeds target precision",
+		explain: `
+The target (see '--target') has no integer type, all numbers are doubles,
+which represent integers exactly only up to 2^53 (9007199254740992). A larger
+integer literal is rounded to the nearest double.
+
+Target Lua 5.3 or 5.4, which have 64-bit integers, or keep the value as a
+string.
+`,
+	},
+
+	CodeNoBitwise: {
+		title: "Bitwise operators unavailable on target",
+		explain: `
+The target (see '--target') has neither bitwise operators nor a bitwise
+library, so '&', '|', '~', '<<' and '>>' can't be compiled for it. Lua 5.1
+lacks both, LuaJIT provides the 'bit' library and Lua 5.2 and Luau provide
+'bit32'.
+`,
+	},
+
+	CodeOutsideRoot: {
+		title: "Module outside the module search roots",
+		explain: `
+With '--split', each module is compiled to a Lua file at the same path relative
+to the output as the module is to its search root (the directory of the
+entrypoint, a manifest 'paths' entry, 'deps/' or a SKAL_PATH entry), and is
+loaded with 'require' by that path. A module imported from outside every search
+root (e.g. 'import '../shared'') has no such path.
+
+Add the module's directory to the manifest 'paths', or compile without
+'--split' to bundle it.
+`,
+	},
+
+	/*--------------------------------------------------------------------------
+	 * Internal
+	 *------------------------------------------------------------------------*/
+
+	CodeInternal: {
+		title: "Internal compiler error",
+		explain: `
+The compiler reached a state it doesn't expect, this is a bug in Skal.
+Please report it with the source which triggered it:
+
+    https://github.com/Illbjorn/Skal/issues
+`,
+	},
+}
@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"github.com/illbjorn/fstr"
-	"github.com/illbjorn/skal/pkg/pprint"
 )
 
 const (
@@ -22,30 +21,39 @@ const (
 const (
 	// CompilerEvent Message Types
 	MsgTypeTodo            = "Todo"
+	MsgTypeLexError        = "Lex Error"
 	MsgTypeParseError      = "Parse Error"
 	MsgTypeValidationError = "Validation Error"
 	MsgTypeEmitError       = "Emit Error"
+	MsgTypeEmitWarning     = "Emit Warning"
 	MsgTypeTypesetError    = "Typeset Error"
+	MsgTypeResolveError    = "Resolve Error"
+	MsgTypeResolveWarning  = "Resolve Warning"
+	MsgTypeFlowError       = "Flow Error"
+	MsgTypeFlowWarning     = "Flow Warning"
+	MsgTypeManifestError   = "Manifest Error"
+	MsgTypeManifestWarning = "Manifest Warning"
 	MsgTypeCompilerError   = "Compiler Error"
+	MsgTypeRuntimeError    = "Runtime Error"
 )
 
 func NewCompilerEvent(mtype, level string) *CompilerEvent {
 	return &CompilerEvent{
-		mtype: mtype,
-		level: level,
+		d: Diagnostic{
+			Type:  mtype,
+			Level: level,
+		},
 	}
 }
 
 type CompilerEvent struct {
-	stack *pprint.Msg
-	msg   *pprint.Msg
-	hint  *pprint.Msg
-	mtype string
-	level string
+	diags *Diagnostics
+	msg   *strings.Builder
+	d     Diagnostic
 }
 
 func (m *CompilerEvent) WithCallStack(depth int) *CompilerEvent {
-	m.stack = callStackF(pprint.New(), depth)
+	m.d.Stack = callStack(depth)
 	return m
 }
 
@@ -57,39 +65,58 @@ type SrcHintable interface {
 }
 
 func (m *CompilerEvent) WithSourceHint(src, file string, line, col1, col2 int) *CompilerEvent {
-	// Create the message.
-	hint := pprint.New().
-		Newline().
-		Yellow("  File   : " + file).Newline().
-		Yellow("  Src    : " + cstr(line)).Newline().
-		Yellow("  Source : " + src)
-
-	// Create the underscore of the exact problem area.
-	sx, ex := col1, col2
-	if ex-sx-1 >= 0 {
-		ptrLead := strings.Repeat(" ", sx-1)
-		ptrDashes := strings.Repeat("-", ex-sx-1)
-		var ptr string
-		if ex-sx-1 == 0 {
-			ptr = "           " + ptrLead + "^"
-		} else {
-			ptr = "           " + ptrLead + "^" + ptrDashes + "^"
-		}
-		hint.Newline().Yellow(ptr)
-	}
+	m.d.Src = src
+	m.d.File = file
+	m.d.Line = line
+	m.d.ColStart = col1
+	m.d.ColEnd = col2
+	return m
+}
+
+// WithRelated attaches a further source position involved in the event.
+func (m *CompilerEvent) WithRelated(msg, src, file string, line, col1, col2 int) *CompilerEvent {
+	m.d.Related = append(m.d.Related, Related{
+		Message:  msg,
+		File:     file,
+		Src:      src,
+		Line:     line,
+		ColStart: col1,
+		ColEnd:   col2,
+	})
+	return m
+}
 
-	// Store the hint.
-	m.hint = hint
+// WithCode identifies the event with a stable diagnostic code (see codes.go).
+func (m *CompilerEvent) WithCode(code string) *CompilerEvent {
+	m.d.Code = code
+	return m
+}
+
+// WithSuggestions attaches "did you mean" alternatives to the event.
+func (m *CompilerEvent) WithSuggestions(names ...string) *CompilerEvent {
+	m.d.Suggestions = append(m.d.Suggestions, names...)
+	return m
+}
 
+// WithFix attaches a machine-applicable edit resolving the event.
+func (m *CompilerEvent) WithFix(fix Fix) *CompilerEvent {
+	m.d.Fixes = append(m.d.Fixes, fix)
+	return m
+}
+
+// To directs the event to a diagnostics collector. Rather than printing (and
+// exiting, for fatal events), `Send()` records the event.
+func (m *CompilerEvent) To(diags *Diagnostics) *CompilerEvent {
+	m.diags = diags
 	return m
 }
 
 func (m *CompilerEvent) Str(msg string) *CompilerEvent {
 	if m.msg == nil {
-		m.msg = pprint.New()
+		m.msg = new(strings.Builder)
 	}
 
-	m.msg.Add(msg)
+	m.msg.WriteString(msg)
 	return m
 }
 
@@ -98,52 +125,35 @@ func (m *CompilerEvent) AddF(msg string, pairs ...string) *CompilerEvent {
 	return m
 }
 
-func (m *CompilerEvent) Send() {
-	// Init the output formatter.
-	msg := pprint.New()
-
-	// Prepare the level.
-	lvl := m.level + " "
-	switch m.level {
-	case LevelInfo:
-		msg.Green(lvl)
-	case LevelDebug:
-		msg.Gray(lvl)
-	case LevelWarn:
-		msg.Yellow(lvl)
-	case LevelFatal, LevelError:
-		msg.Red(lvl)
-	default:
-		msg.Green(lvl)
-	}
-
-	// Prepare the type.
-	msg.Yellow("[" + m.mtype + "]: ")
-
-	// Prepare the actual message.
+// Diagnostic produces the structured form of the event.
+func (m *CompilerEvent) Diagnostic() Diagnostic {
+	d := m.d
 	if m.msg != nil {
-		msg.White(m.msg.String())
+		d.Message = m.msg.String()
 	}
 
-	// Prepare the source hint.
-	if m.hint != nil {
-		msg.Newline()
-		msg.Add(m.hint.String())
-	}
+	return d
+}
 
-	// Prepare the call stack.
-	if m.stack != nil {
-		msg.Newline()
-		msg.Add(m.stack.String())
+func (m *CompilerEvent) Send() {
+	if m.diags != nil {
+		m.diags.Add(m.Diagnostic())
+		return
 	}
 
-	fmt.Println(msg.String())
+	fmt.Println(m.Diagnostic().String())
 
-	if m.level == LevelFatal {
+	if m.d.Level == LevelFatal {
 		os.Exit(1)
 	}
 }
 
+// Bail aborts the current unit of work with the event. The event is recorded
+// by the nearest enclosing `Diagnostics.Catch()`.
+func (m *CompilerEvent) Bail() {
+	panic(bailout{m})
+}
+
 // Simple helper to stringify a 32-bit integer.
 func cstr(i int) string {
 	return strconv.Itoa(i)
@@ -0,0 +1,325 @@
+package sklog
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/illbjorn/fstr"
+	"github.com/illbjorn/skal/pkg/pprint"
+)
+
+/*------------------------------------------------------------------------------
+ * Diagnostic
+ *----------------------------------------------------------------------------*/
+
+// Diagnostic is a single problem reported while compiling.
+type Diagnostic struct {
+	Level string
+	Type  string
+	// Stable identifier of the diagnostic, if it has one.
+	Code    string
+	Message string
+	// Source position, if known.
+	File     string
+	Src      string
+	Line     int
+	ColStart int
+	ColEnd   int
+	// Call stack of the compiler for internal errors, or of the script for
+	// runtime errors.
+	Stack []string
+	// Similarly named alternatives ("did you mean").
+	Suggestions []string
+	// Machine-applicable edits resolving the diagnostic.
+	Fixes []Fix
+	// Further source positions involved in the diagnostic.
+	Related []Related
+}
+
+// Related is a source position involved in a diagnostic, other than the one it
+// is reported at.
+type Related struct {
+	Message  string
+	File     string
+	Src      string
+	Line     int
+	ColStart int
+	ColEnd   int
+}
+
+// Fix is a machine-applicable edit to the source: the columns [ColStart,
+// ColEnd) of a line are replaced with Text. An insertion has ColStart equal to
+// ColEnd.
+type Fix struct {
+	Description string
+	File        string
+	Line        int
+	ColStart    int
+	ColEnd      int
+	Text        string
+}
+
+// IsError indicates whether the diagnostic fails the compile.
+func (d Diagnostic) IsError() bool {
+	return d.Level == LevelError || d.Level == LevelFatal
+}
+
+// String renders the diagnostic for terminal output.
+func (d Diagnostic) String() string {
+	// Init the output formatter.
+	msg := pprint.New()
+
+	// Prepare the level.
+	lvl := d.Level + " "
+	switch d.Level {
+	case LevelInfo:
+		msg.Green(lvl)
+	case LevelDebug:
+		msg.Gray(lvl)
+	case LevelWarn:
+		msg.Yellow(lvl)
+	case LevelFatal, LevelError:
+		msg.Red(lvl)
+	default:
+		msg.Green(lvl)
+	}
+
+	// Prepare the code and type.
+	if d.Code != "" {
+		msg.Yellow(d.Code + " ")
+	}
+	msg.Yellow("[" + d.Type + "]: ")
+
+	// Prepare the actual message.
+	msg.White(d.Message)
+
+	// Prepare the source hint.
+	if d.File != "" {
+		msg.Newline().
+			Newline().
+			Yellow("  File   : " + d.File).Newline().
+			Yellow("  Src    : " + cstr(d.Line)).Newline().
+			Yellow("  Source : " + d.Src)
+
+		// Create the underscore of the exact problem area.
+		if ptr := underscore(d.ColStart, d.ColEnd); ptr != "" {
+			msg.Newline().Yellow(ptr)
+		}
+	}
+
+	// Prepare the related source hints.
+	for _, rel := range d.Related {
+		msg.Newline().
+			Newline().
+			Cyan("  Note   : " + rel.Message).Newline().
+			Yellow("  File   : " + rel.File).Newline().
+			Yellow("  Src    : " + cstr(rel.Line)).Newline().
+			Yellow("  Source : " + rel.Src)
+		if ptr := underscore(rel.ColStart, rel.ColEnd); ptr != "" {
+			msg.Newline().Yellow(ptr)
+		}
+	}
+
+	// Prepare the suggestions and fixes.
+	if len(d.Suggestions) > 0 || len(d.Fixes) > 0 {
+		msg.Newline()
+	}
+	if len(d.Suggestions) > 0 {
+		msg.Newline().Cyan("  Help   : " + d.didYouMean())
+	}
+	for _, fix := range d.Fixes {
+		msg.Newline().Cyan("  Fix    : " + fix.Description)
+	}
+
+	// Prepare the call stack.
+	if len(d.Stack) > 0 {
+		msg.Newline()
+		for i, v := range d.Stack {
+			msg.Add("\n" + strings.Repeat(" ", i+1))
+			msg.Yellow("AT: ")
+			msg.White(v)
+		}
+	}
+
+	return msg.String()
+}
+
+// Produces the underscore of the columns [sx, ex) of a source hint, aligned
+// below the "  Source : " prefix.
+func underscore(sx, ex int) string {
+	if ex-sx-1 < 0 {
+		return ""
+	}
+
+	ptrLead := strings.Repeat(" ", sx-1)
+	if ex-sx-1 == 0 {
+		return "           " + ptrLead + "^"
+	}
+
+	return "           " + ptrLead + "^" + strings.Repeat("-", ex-sx-1) + "^"
+}
+
+// Plain renders the diagnostic as a single uncolored line.
+//
+//	main.sk:3:5: ERR SK0101 [Parse Error]: Expected =, found if.
+func (d Diagnostic) Plain() string {
+	var pos, code, help string
+	if d.File != "" {
+		pos = d.File + ":" + strconv.Itoa(d.Line) + ":" + strconv.Itoa(d.ColStart) + ": "
+	}
+	if d.Code != "" {
+		code = d.Code + " "
+	}
+	if len(d.Suggestions) > 0 {
+		help = " " + d.didYouMean()
+	}
+
+	return fstr.Pairs(
+		"{pos}{level} {code}[{type}]: {msg}{help}",
+		"pos", pos,
+		"level", d.Level,
+		"code", code,
+		"type", d.Type,
+		"msg", d.Message,
+		"help", help,
+	)
+}
+
+// Produces the "did you mean" hint of the suggestions.
+func (d Diagnostic) didYouMean() string {
+	quoted := make([]string, len(d.Suggestions))
+	for i, s := range d.Suggestions {
+		quoted[i] = "'" + s + "'"
+	}
+
+	return "Did you mean " + strings.Join(quoted, " or ") + "?"
+}
+
+/*------------------------------------------------------------------------------
+ * Diagnostics
+ *----------------------------------------------------------------------------*/
+
+func NewDiagnostics() *Diagnostics {
+	return new(Diagnostics)
+}
+
+// Diagnostics collects the problems reported while compiling, allowing a
+// compile to continue past (and report) more than one error.
+//
+// A nil *Diagnostics is valid: events sent to it are printed immediately and
+// fatal events exit the process.
+type Diagnostics struct {
+	mu   sync.Mutex
+	list []Diagnostic
+}
+
+// Add records a diagnostic.
+func (d *Diagnostics) Add(diag Diagnostic) {
+	if d == nil {
+		fmt.Println(diag.String())
+		if diag.Level == LevelFatal {
+			os.Exit(1)
+		}
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.list = append(d.list, diag)
+}
+
+// Merge records the diagnostics of each of `others`, in order.
+func (d *Diagnostics) Merge(others ...*Diagnostics) {
+	for _, o := range others {
+		for _, diag := range o.List() {
+			d.Add(diag)
+		}
+	}
+}
+
+// List returns all recorded diagnostics, in the order they were reported.
+func (d *Diagnostics) List() []Diagnostic {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Diagnostic(nil), d.list...)
+}
+
+// Errors returns the number of recorded diagnostics which fail the compile.
+func (d *Diagnostics) Errors() int {
+	var n int
+	for _, diag := range d.List() {
+		if diag.IsError() {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Err produces an error listing every recorded diagnostic if any of them fail
+// the compile, otherwise nil.
+func (d *Diagnostics) Err() error {
+	n := d.Errors()
+	if n == 0 {
+		return nil
+	}
+
+	return &Error{Diagnostics: d.List(), errors: n}
+}
+
+// Catch records the event of a `CompilerEvent.Bail()`, returning true if `r`
+// was a bailout. It must be passed the result of `recover()`:
+//
+//	defer func() { diags.Catch(recover()) }()
+//
+// Panics other than bailouts are re-raised.
+func (d *Diagnostics) Catch(r any) bool {
+	if r == nil {
+		return false
+	}
+
+	b, ok := r.(bailout)
+	if !ok {
+		panic(r)
+	}
+
+	d.Add(b.ev.Diagnostic())
+	return true
+}
+
+// The panic value of a `CompilerEvent.Bail()`.
+type bailout struct {
+	ev *CompilerEvent
+}
+
+/*------------------------------------------------------------------------------
+ * Error
+ *----------------------------------------------------------------------------*/
+
+// Error is returned by a failed compile, it lists every diagnostic reported.
+type Error struct {
+	Diagnostics []Diagnostic
+	errors      int
+}
+
+func (e *Error) Error() string {
+	out := new(strings.Builder)
+	out.WriteString(fstr.Pairs(
+		"compilation failed with {n} error(s):",
+		"n", strconv.Itoa(e.errors),
+	))
+
+	for _, diag := range e.Diagnostics {
+		out.WriteString("\n  ")
+		out.WriteString(diag.Plain())
+	}
+
+	return out.String()
+}
@@ -1,20 +1,13 @@
 package sklog
 
-import "github.com/illbjorn/fstr"
-
-// Logs a generic fatal compiler error.
-func CFatal(msg string) {
+// Aborts the current unit of work with a generic fatal compiler error, to be
+// recorded by the nearest `Diagnostics.Catch()`.
+func BailF(msg string, pairs ...string) {
 	NewCompilerEvent(MsgTypeCompilerError, LevelFatal).
+		WithCode(CodeInternal).
 		WithCallStack(3).
-		Str(msg).
-		Send()
-}
-
-// Adds a `fstr.PairsStrip()` message format before sending to `CFatal()`.
-func CFatalF(msg string, pairs ...string) {
-	CFatal(
-		fstr.Pairs(msg, pairs...),
-	)
+		AddF(msg, pairs...).
+		Bail()
 }
 
 // Produces a warning indicating a particular code branch or feature is not yet
@@ -29,7 +22,7 @@ func Todo(loc string) {
 // Used for producing a generic terminating error where we expected a
 // particular: token, node or emit statement type.
 func UnexpectedType(loc, found string) {
-	CFatalF(
+	BailF(
 		"Found unexpected {loc} type: {found}.",
 		"loc", loc,
 		"found", found,
@@ -0,0 +1,345 @@
+package sklog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// Format is an output format for diagnostics.
+type Format string
+
+const (
+	// ANSI-colored text, for terminals.
+	FormatText Format = "text"
+	// One JSON object per line.
+	FormatJSON Format = "json"
+	// A SARIF 2.1.0 log, for code-scanning dashboards.
+	FormatSARIF Format = "sarif"
+)
+
+// ParseFormat validates a diagnostics format name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatJSON, FormatSARIF:
+		return f, nil
+	}
+
+	return "", fmt.Errorf(
+		"unknown diagnostics format '%s', expected one of: %s, %s, %s",
+		s, FormatText, FormatJSON, FormatSARIF,
+	)
+}
+
+// Severity maps the level of the diagnostic to one of "error", "warning" or
+// "note".
+func (d Diagnostic) Severity() string {
+	switch d.Level {
+	case LevelError, LevelFatal:
+		return "error"
+	case LevelWarn:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// Write writes diagnostics to `w` in format `f`.
+func Write(w io.Writer, f Format, diags []Diagnostic) error {
+	switch f {
+	// JSON Lines
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, d := range diags {
+			if err := enc.Encode(newJSONDiagnostic(d)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	// SARIF
+	case FormatSARIF:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(newSARIFLog(diags))
+
+	// Text
+	default:
+		for _, d := range diags {
+			if _, err := fmt.Fprintln(w, d.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+/*------------------------------------------------------------------------------
+ * JSON
+ *----------------------------------------------------------------------------*/
+
+type jsonDiagnostic struct {
+	Severity  string `json:"severity"`
+	Type      string `json:"type"`
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	EndColumn int    `json:"end_column,omitempty"`
+
+	Suggestions []string      `json:"suggestions,omitempty"`
+	Fixes       []jsonFix     `json:"fixes,omitempty"`
+	Related     []jsonRelated `json:"related,omitempty"`
+}
+
+type jsonRelated struct {
+	Message   string `json:"message"`
+	File      string `json:"file"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndColumn int    `json:"end_column,omitempty"`
+}
+
+type jsonFix struct {
+	Description string `json:"description"`
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	EndColumn   int    `json:"end_column"`
+	Text        string `json:"text"`
+}
+
+func newJSONDiagnostic(d Diagnostic) jsonDiagnostic {
+	out := jsonDiagnostic{
+		Severity: d.Severity(),
+		Type:     d.Type,
+		Code:     d.Code,
+		Message:  d.Message,
+		File:     d.File,
+
+		Suggestions: d.Suggestions,
+	}
+
+	if d.Line > 0 {
+		out.Line, out.Column = d.Line, d.ColStart
+		out.EndLine, out.EndColumn = d.Line, d.ColEnd
+	}
+
+	for _, fix := range d.Fixes {
+		out.Fixes = append(out.Fixes, jsonFix{
+			Description: fix.Description,
+			File:        fix.File,
+			Line:        fix.Line,
+			Column:      fix.ColStart,
+			EndColumn:   fix.ColEnd,
+			Text:        fix.Text,
+		})
+	}
+
+	for _, rel := range d.Related {
+		out.Related = append(out.Related, jsonRelated{
+			Message:   rel.Message,
+			File:      rel.File,
+			Line:      rel.Line,
+			Column:    rel.ColStart,
+			EndColumn: rel.ColEnd,
+		})
+	}
+
+	return out
+}
+
+/*------------------------------------------------------------------------------
+ * SARIF
+ *----------------------------------------------------------------------------*/
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription sarifMessage  `json:"shortDescription"`
+	Help             *sarifMessage `json:"help,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+	Fixes     []sarifFix      `json:"fixes,omitempty"`
+	// Further source positions involved in the result.
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+func newSARIFLog(diags []Diagnostic) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "skal",
+				InformationURI: "https://skal.dev",
+				Rules:          []sarifRule{},
+			},
+		},
+		Results: []sarifResult{},
+	}
+
+	rules := make(map[string]bool)
+	for _, d := range diags {
+		// Rules
+		// Diagnostics are identified by their code, falling back to their type.
+		id := d.Code
+		if id == "" {
+			id = d.Type
+		}
+		if !rules[id] {
+			rules[id] = true
+			rule := sarifRule{
+				ID:               id,
+				ShortDescription: sarifMessage{Text: d.Type},
+			}
+			if title, ok := Title(d.Code); ok {
+				explain, _ := Explain(d.Code)
+				rule.ShortDescription.Text = title
+				rule.Help = &sarifMessage{Text: explain}
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		// Results
+		result := sarifResult{
+			RuleID:  id,
+			Level:   d.Severity(),
+			Message: sarifMessage{Text: d.Message},
+		}
+		if len(d.Suggestions) > 0 {
+			result.Message.Text += " " + d.didYouMean()
+		}
+
+		if d.File != "" {
+			loc := sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(d.File)},
+				},
+			}
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{
+					StartLine:   d.Line,
+					StartColumn: d.ColStart,
+					EndLine:     d.Line,
+					EndColumn:   d.ColEnd,
+				}
+			}
+			result.Locations = append(result.Locations, loc)
+		}
+
+		for _, rel := range d.Related {
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(rel.File)},
+					Region: &sarifRegion{
+						StartLine:   rel.Line,
+						StartColumn: rel.ColStart,
+						EndLine:     rel.Line,
+						EndColumn:   rel.ColEnd,
+					},
+				},
+				Message: &sarifMessage{Text: rel.Message},
+			})
+		}
+
+		for _, fix := range d.Fixes {
+			result.Fixes = append(result.Fixes, sarifFix{
+				Description: sarifMessage{Text: fix.Description},
+				ArtifactChanges: []sarifArtifactChange{{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(fix.File)},
+					Replacements: []sarifReplacement{{
+						DeletedRegion: sarifRegion{
+							StartLine:   fix.Line,
+							StartColumn: fix.ColStart,
+							EndLine:     fix.Line,
+							EndColumn:   fix.ColEnd,
+						},
+						InsertedContent: sarifMessage{Text: fix.Text},
+					}},
+				}},
+			})
+		}
+
+		run.Results = append(run.Results, result)
+	}
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}
+}
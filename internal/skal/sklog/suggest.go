@@ -0,0 +1,86 @@
+package sklog
+
+import "sort"
+
+// Suggest returns the candidates closest to `name` by edit distance, for "did
+// you mean" hints. At most 3 candidates are returned, nearest first.
+//
+// The allowed distance scales with the length of `name`, so short names only
+// match near-identical candidates.
+func Suggest(name string, candidates []string) []string {
+	limit := len(name) / 3
+	if limit < 1 {
+		limit = 1
+	}
+
+	type match struct {
+		name string
+		dist int
+	}
+
+	var matches []match
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if c == name || seen[c] {
+			continue
+		}
+		seen[c] = true
+
+		if d := distance(name, c); d <= limit {
+			matches = append(matches, match{c, d})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	var out []string
+	for i := 0; i < len(matches) && i < 3; i++ {
+		out = append(out, matches[i].name)
+	}
+
+	return out
+}
+
+// Computes the Damerau-Levenshtein (optimal string alignment) distance of `a`
+// and `b`, counting a transposition of adjacent characters as one edit.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Three rows of the edit matrix are enough to detect transpositions.
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(
+				prev[j]+1,      // Deletion
+				cur[j-1]+1,     // Insertion
+				prev[j-1]+cost, // Substitution
+			)
+
+			// Transposition
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(rb)]
+}
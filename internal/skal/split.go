@@ -0,0 +1,209 @@
+package skal
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/minify"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/srcmap"
+)
+
+// Module is the Lua output of a single module of a split compile.
+type Module struct {
+	// The name the module is required by (e.g. `ui.button`).
+	Name string
+	// Path of the Lua file, relative to the directory of the entrypoint's output.
+	Path      string
+	Lua       []byte
+	SourceMap *srcmap.Map
+}
+
+// BuildModules compiles the Skal source file at `inputPath` (and its imports)
+// to a Lua module per source file, the entrypoint last. Each module returns a
+// table of its globals (`pub` symbols, extern aliases and implicit globals), and
+// requires the modules it imports rather than bundling them. With opts.Lib, the
+// entrypoint returns only its `pub` symbols. If any errors are
+// reported, an error listing every diagnostic is returned.
+//
+// A module is required by its path relative to the entrypoint: `ui/button.sk`
+// is compiled to `ui/button.lua` and required as `ui.button`.
+func BuildModules(ctx context.Context, inputPath string, opts Options) ([]*Module, error) {
+	opts.Split = true
+	j, units, err := buildUnits(ctx, inputPath, opts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Module
+	j.Perf.measure(PhaseBundle, "", func() {
+		out = split(j, units)
+	})
+	if err := j.Diags.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Produces the module of each unit.
+func split(j *job, units []unit) []*Module {
+	// Index units by path, to look up imports.
+	byPath := make(map[string]int, len(units))
+	names := make([]string, len(units))
+	for i, u := range units {
+		byPath[u.File.Path] = i
+		names[i] = moduleName(j, u.File.Path)
+	}
+
+	out := make([]*Module, len(units))
+	for i, u := range units {
+		f := new(bytes.Buffer)
+		if j.Minify {
+			f.WriteString(tmplModuleHeaderMin)
+		} else {
+			f.WriteString(tmplModuleHeader)
+		}
+
+		// Bind the globals of every module visible to this one. Modules may
+		// reference the globals of modules they transitively import whole, these
+		// are required in dependency order.
+		visible := make(map[string]bool)
+		visibleImports(units, byPath, u.File, visible)
+		if len(visible) > 0 || len(u.File.Imports) > 0 {
+			f.WriteString("\n-- Import the globals of the modules imported.")
+		}
+		for k, imp := range units {
+			if visible[imp.File.Path] {
+				f.WriteString("\nfor k, v in pairs(require('" + names[k] + "')) do __ENV__[k] = v end")
+			}
+		}
+
+		// Aliased modules are bound to their table of globals, selected names
+		// are bound alone.
+		//
+		// import 'ui/button' as btn          -> local btn = require('ui.button')
+		// import { Button } from 'ui/button' -> __ENV__.Button = require('ui.button').Button
+		for _, imp := range u.File.Imports {
+			k, ok := byPath[imp.Module]
+			if !ok {
+				continue
+			}
+
+			switch {
+			case imp.Alias != "":
+				f.WriteString("\nlocal " + imp.Alias + " = require('" + names[k] + "')")
+			case imp.Names != nil:
+				for _, name := range imp.Names {
+					f.WriteString("\n__ENV__." + name.ID() + " = require('" + names[k] + "')." + name.ID())
+				}
+			}
+		}
+
+		// The module itself.
+		header, footer := tmplModuleOpen, tmplModuleClose
+		if !j.Target.Setfenv {
+			header, footer = tmplModuleOpenEnv, tmplModuleCloseEnv
+		}
+		f.WriteString(header)
+
+		m := srcmap.New()
+		offset := bytes.Count(f.Bytes(), []byte("\n"))
+		m.Add(offset, bytes.Count(u.Lua, []byte("\n"))+1, u.Mappings)
+		m.AddNames(offset, u.Names)
+		f.Write(u.Lua)
+		f.WriteString(footer)
+
+		// Export its globals. A library entrypoint exports only its `pub`
+		// symbols.
+		if j.Lib && i == len(units)-1 {
+			f.WriteString(exports(u.Pubs))
+		} else {
+			f.WriteString(exports(u.Globals))
+		}
+
+		lua := f.Bytes()
+		if j.Minify {
+			lua = minify.Minify(lua, m, j.Diags)
+		}
+
+		path := strings.ReplaceAll(names[i], ".", "/") + ".lua"
+		m.File = filepath.Base(path)
+		out[i] = &Module{Name: names[i], Path: path, Lua: lua, SourceMap: m}
+	}
+
+	return out
+}
+
+// Collects the paths of all modules transitively imported whole by `from`.
+func visibleImports(units []unit, byPath map[string]int, from *srcFile, seen map[string]bool) {
+	for _, imp := range from.Imports {
+		path := imp.Module
+		i, ok := byPath[path]
+		if !ok || !imp.Whole() || seen[path] {
+			continue
+		}
+		seen[path] = true
+		visibleImports(units, byPath, units[i].File, seen)
+	}
+}
+
+// Produces the name the module at `path` is required by, its path relative to
+// the first search root containing it.
+func moduleName(j *job, path string) string {
+	for _, root := range j.Roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		rel = strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+		return strings.ReplaceAll(rel, "/", ".")
+	}
+
+	sklog.NewCompilerEvent(sklog.MsgTypeCompilerError, sklog.LevelError).
+		To(j.Diags).
+		WithCode(sklog.CodeOutsideRoot).
+		AddF(
+			"Module {path} is outside the module search roots ({roots}), it can't be required.",
+			"path", path,
+			"roots", strings.Join(j.Roots, ", "),
+		).
+		Send()
+	return ""
+}
+
+const (
+	// The boilerplate header of a split module. Each module has an environment
+	// of its own, holding its globals and those of the modules it imports.
+	tmplModuleHeader = `-- Create the module environment.
+local __ENV__ = { __index = _G }
+-- Set the metatable.
+setmetatable(__ENV__, __ENV__)`
+
+	// The header of a minified module, creating its environment in a single
+	// statement.
+	tmplModuleHeaderMin = `local __ENV__ = setmetatable({}, { __index = _G })`
+
+	tmplModuleOpen = `
+-- Open the module function.
+local function __LOAD__()`
+
+	tmplModuleOpenEnv = `
+-- Open the module function.
+local function __LOAD__(_ENV)`
+
+	tmplModuleClose = `
+end
+-- Set the module function's environment to the module environment table.
+setfenv(__LOAD__, __ENV__)
+-- Load the module.
+__LOAD__()`
+
+	tmplModuleCloseEnv = `
+end
+-- Load the module, within the module environment table.
+__LOAD__(__ENV__)`
+)
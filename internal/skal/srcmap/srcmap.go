@@ -0,0 +1,140 @@
+// Package srcmap maps lines of compiled Lua back to the Skal source they were
+// compiled from.
+//
+// Maps are written alongside the compiled Lua as `<file>.lua.map`, a JSON
+// document:
+//
+//	{
+//	  "version": 1,
+//	  "file": "main.lua",
+//	  "mappings": [
+//	    {"line": 12, "endLine": 14, "source": "main.sk", "sourceLine": 3, "sourceColumn": 1}
+//	  ],
+//	  "names": [
+//	    {"line": 12, "endLine": 20, "name": "a", "sourceName": "roll"}
+//	  ]
+//	}
+//
+// Each mapping covers the Lua lines [line, endLine] emitted for the Skal
+// statement at source:sourceLine:sourceColumn. Lines of compiler boilerplate
+// are not mapped.
+//
+// Each name records a local renamed by `--minify` (or a host fn cached in a
+// local by `-O`), `name` within the Lua lines [line, endLine] (its scope) is
+// `sourceName` in the Skal source.
+package srcmap
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// The version of the source map format.
+const Version = 1
+
+// Map is the source map of a compiled Lua file.
+type Map struct {
+	Version int `json:"version"`
+	// Name of the mapped Lua file, if it was written to one.
+	File string `json:"file,omitempty"`
+	// Mappings, ordered by line.
+	Mappings []Mapping `json:"mappings"`
+	// Renamed locals, ordered by line.
+	Names []Name `json:"names,omitempty"`
+}
+
+// Mapping maps a range of Lua lines to the Skal statement they were emitted
+// from. Lines and columns are 1-based.
+type Mapping struct {
+	Line    int    `json:"line"`
+	EndLine int    `json:"endLine"`
+	Source  string `json:"source"`
+	SrcLine int    `json:"sourceLine"`
+	SrcCol  int    `json:"sourceColumn"`
+}
+
+// Name maps a local renamed in the Lua to its name in the Skal source, within
+// the range of Lua lines it's visible to.
+type Name struct {
+	Line    int    `json:"line"`
+	EndLine int    `json:"endLine"`
+	Name    string `json:"name"`
+	SrcName string `json:"sourceName"`
+}
+
+func New() *Map {
+	return &Map{Version: Version, Mappings: make([]Mapping, 0)}
+}
+
+// Add appends the mappings of a chunk of `lines` Lua lines, the first of which
+// is line `offset`+1 of the mapped file. The lines of `mappings` are relative to
+// the chunk, each mapping extends to the next (or the end of the chunk).
+func (m *Map) Add(offset, lines int, mappings []Mapping) {
+	for i, mapping := range mappings {
+		end := lines
+		if i < len(mappings)-1 {
+			end = mappings[i+1].Line - 1
+		}
+
+		mapping.Line += offset
+		mapping.EndLine = end + offset
+		m.Mappings = append(m.Mappings, mapping)
+	}
+}
+
+// AddNames appends the renamed locals of a chunk, the first line of which is
+// line `offset`+1 of the mapped file. The lines of `names` are relative to the
+// chunk.
+func (m *Map) AddNames(offset int, names []Name) {
+	for _, name := range names {
+		name.Line += offset
+		name.EndLine += offset
+		m.Names = append(m.Names, name)
+	}
+}
+
+// Lookup finds the mapping of Lua line `line`.
+func (m *Map) Lookup(line int) (Mapping, bool) {
+	if m == nil {
+		return Mapping{}, false
+	}
+
+	// The first mapping following the line.
+	i := sort.Search(len(m.Mappings), func(i int) bool {
+		return m.Mappings[i].Line > line
+	})
+	if i == 0 || m.Mappings[i-1].EndLine < line {
+		return Mapping{}, false
+	}
+
+	return m.Mappings[i-1], true
+}
+
+// Original finds the Skal name of the local `name` referenced on Lua line
+// `line`, or `name` if it wasn't renamed.
+func (m *Map) Original(line int, name string) string {
+	if m == nil {
+		return name
+	}
+
+	// Locals declared later are visible in the scope of those before.
+	for i := len(m.Names) - 1; i >= 0; i-- {
+		n := m.Names[i]
+		if n.Name == name && n.Line <= line && line <= n.EndLine {
+			return n.SrcName
+		}
+	}
+
+	return name
+}
+
+// JSON produces the `.lua.map` form of the map.
+func (m *Map) JSON() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// String renders the Skal position of a mapping (e.g. `main.sk:3:1`).
+func (mapping Mapping) String() string {
+	return mapping.Source + ":" + strconv.Itoa(mapping.SrcLine) + ":" + strconv.Itoa(mapping.SrcCol)
+}
@@ -0,0 +1,125 @@
+package srcmap
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	m := New()
+	m.Add(2, 5, []Mapping{
+		{Line: 1, Source: "a.sk", SrcLine: 1, SrcCol: 1},
+		{Line: 4, Source: "a.sk", SrcLine: 3, SrcCol: 1},
+	})
+	m.Add(9, 1, []Mapping{{Line: 1, Source: "main.sk", SrcLine: 1, SrcCol: 1}})
+
+	// Each mapping extends to the next, the last to the end of its chunk.
+	want := [][2]int{{3, 5}, {6, 7}, {10, 10}}
+	if len(m.Mappings) != len(want) {
+		t.Fatalf("got %d mappings, want %d", len(m.Mappings), len(want))
+	}
+	for i, mapping := range m.Mappings {
+		if got := [2]int{mapping.Line, mapping.EndLine}; got != want[i] {
+			t.Errorf("mapping %d covers lines %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	m := New()
+	m.Add(2, 5, []Mapping{
+		{Line: 1, Source: "a.sk", SrcLine: 1, SrcCol: 1},
+		{Line: 4, Source: "a.sk", SrcLine: 3, SrcCol: 5},
+	})
+	m.Add(9, 1, []Mapping{{Line: 1, Source: "main.sk", SrcLine: 2, SrcCol: 1}})
+
+	for line, want := range map[int]string{
+		1:  "",
+		3:  "a.sk:1:1",
+		5:  "a.sk:1:1",
+		6:  "a.sk:3:5",
+		7:  "a.sk:3:5",
+		8:  "", // Boilerplate between chunks.
+		10: "main.sk:2:1",
+		11: "",
+	} {
+		got := ""
+		if mapping, ok := m.Lookup(line); ok {
+			got = mapping.String()
+		}
+		if got != want {
+			t.Errorf("Lookup(%d) = %q, want %q", line, got, want)
+		}
+	}
+
+	// A script run without a map maps nothing.
+	var none *Map
+	if _, ok := none.Lookup(1); ok {
+		t.Error("nil map found a mapping")
+	}
+}
+
+func TestOriginal(t *testing.T) {
+	m := New()
+	m.AddNames(1, []Name{{Line: 1, EndLine: 9, Name: "_math_floor", SrcName: "math.floor"}})
+	m.Names = append(m.Names,
+		Name{Line: 2, EndLine: 8, Name: "a", SrcName: "roll"},
+		// Declared later, shadowing `a` within its scope.
+		Name{Line: 4, EndLine: 5, Name: "a", SrcName: "n"},
+	)
+
+	for _, c := range []struct {
+		line       int
+		name, want string
+	}{
+		{5, "_math_floor", "math.floor"},
+		{2, "a", "roll"},
+		{4, "a", "n"},
+		{6, "a", "roll"},
+		// Out of scope.
+		{1, "a", "a"},
+		{9, "a", "a"},
+		{11, "_math_floor", "_math_floor"},
+	} {
+		if got := m.Original(c.line, c.name); got != c.want {
+			t.Errorf("Original(%d, %q) = %q, want %q", c.line, c.name, got, c.want)
+		}
+	}
+
+	var none *Map
+	if got := none.Original(1, "a"); got != "a" {
+		t.Errorf("nil map Original = %q, want %q", got, "a")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	m := New()
+	m.File = "main.lua"
+	m.Add(0, 2, []Mapping{{Line: 1, Source: "main.sk", SrcLine: 3, SrcCol: 1}})
+	m.AddNames(0, []Name{{Line: 1, EndLine: 2, Name: "a", SrcName: "roll"}})
+
+	out, err := m.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"version": float64(Version),
+		"file":    "main.lua",
+		"mappings": []any{map[string]any{
+			"line": float64(1), "endLine": float64(2),
+			"source": "main.sk", "sourceLine": float64(3), "sourceColumn": float64(1),
+		}},
+		"names": []any{map[string]any{
+			"line": float64(1), "endLine": float64(2), "name": "a", "sourceName": "roll",
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%v", out, want)
+	}
+}
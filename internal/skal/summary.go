@@ -0,0 +1,202 @@
+package skal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/typeset"
+)
+
+// A summary of the top-level declarations of a module, as Skal source.
+//
+// A summary typesets and resolves like the module it was produced from, but
+// has empty bodies. It stands in for cached modules: other modules resolve
+// against it, without the cached module being compiled.
+//
+//	pub fn greet(name) {
+//	}
+//	pub struct Unit {
+//	  Name
+//	  heal(points) {
+//	  }
+//	}
+type summary struct {
+	// The summary source. Declarations keep their original line numbers, so
+	// diagnostics pointing at them remain accurate.
+	Src string
+	// Hash of the declarations visible to other modules.
+	Surface string
+	// Names of the declarations visible to other modules.
+	Globals []string
+	// Names of the `pub` declarations.
+	Pubs []string
+}
+
+// Summarizes the top-level declarations of a typeset module.
+func summarize(set typeset.TypeSet) summary {
+	s := &summarizer{
+		src:     new(strings.Builder),
+		surface: sha256.New(),
+		line:    1,
+	}
+
+	for _, member := range set.Members {
+		switch v := member.Value.(type) {
+		// 'enum'
+		case *typeset.Enum:
+			s.at(v.Token())
+			out := s.pub(v.Pub()) + token.Enum.String() + " " + v.ID() + " {\n"
+			// Member values are kept, optimized modules inline them (see
+			// `opt.Optimize`).
+			for _, m := range v.Members {
+				out += "  " + m.ID() + " = " + memberValue(m) + "\n"
+			}
+			s.decl(v.Pub(), v.Pub(), v.ID(), out+"}\n")
+
+		// 'struct'
+		case *typeset.Struct:
+			s.at(v.Token())
+			out := s.pub(v.Pub()) + token.Struct.String() + " " + v.ID() + " {\n"
+			for _, field := range v.Fields {
+				out += "  " + field.ID() + "\n"
+			}
+			for _, method := range v.Methods {
+				name := method.ID()
+				if method.Constructor {
+					name = token.New.String()
+				}
+				out += "  " + name + "(" + args(method) + ") {\n  }\n"
+			}
+			s.decl(v.Pub(), v.Pub(), v.ID(), out+"}\n")
+
+		// 'fn'
+		case *typeset.Fn:
+			if v.RefsLen() != 1 {
+				continue
+			}
+			s.at(v.Token())
+			s.decl(v.Pub(), v.Pub(), v.ID(),
+				s.pub(v.Pub())+token.Fn.String()+" "+v.ID()+"("+args(v)+") {\n}\n")
+
+		// Bind | Rebind
+		case *typeset.Bind:
+			var names []string
+			for _, b := range v.Binds {
+				if b.RefsLen() == 1 {
+					names = append(names, b.ID())
+				}
+			}
+			if len(names) == 0 {
+				continue
+			}
+			s.at(v.Token())
+
+			// Top-level assignments of undeclared names produce globals, so rebinds
+			// are always part of the surface.
+			if v.Rebind {
+				s.decl(false, true, strings.Join(names, ","),
+					strings.Join(names, ", ")+" = "+token.Nil.String()+"\n")
+				continue
+			}
+			s.decl(v.Pub(), v.Pub(), strings.Join(names, ","),
+				s.pub(v.Pub())+token.Let.String()+" "+strings.Join(names, ", ")+";\n")
+
+		// 'extern'
+		case []*typeset.External:
+			if len(v) == 0 {
+				continue
+			}
+			s.at(v[0].Token())
+			out := token.Extern.String() + " {\n"
+			var aliases []string
+			for _, ext := range v {
+				out += "  " + strings.Join(ext.Refs(), ".") + " " + token.As.String() + " " + ext.Alias + "\n"
+				aliases = append(aliases, ext.Alias)
+			}
+			s.decl(false, true, strings.Join(aliases, ","), out+"}\n")
+		}
+	}
+
+	return summary{
+		Src:     s.src.String(),
+		Surface: hex.EncodeToString(s.surface.Sum(nil)),
+		Globals: s.globals,
+		Pubs:    s.pubs,
+	}
+}
+
+type summarizer struct {
+	src     *strings.Builder
+	surface hash.Hash
+	globals []string
+	pubs    []string
+	// The line of the summary being written.
+	line int
+}
+
+// Pads the summary to the line of token `tk`.
+func (s *summarizer) at(tk token.Token) {
+	if tk == nil {
+		return
+	}
+
+	for ; s.line < tk.LineStart(); s.line++ {
+		s.src.WriteByte('\n')
+	}
+}
+
+// Writes a declaration of `names` (comma separated). Globals are visible to
+// other modules, all `pub` declarations are globals.
+func (s *summarizer) decl(pub, global bool, names, src string) {
+	s.src.WriteString(src)
+	s.line += strings.Count(src, "\n")
+
+	if pub {
+		s.pubs = append(s.pubs, strings.Split(names, ",")...)
+	}
+	if global {
+		_, _ = s.surface.Write([]byte(src))
+		s.globals = append(s.globals, strings.Split(names, ",")...)
+	}
+}
+
+func (s *summarizer) pub(pub bool) string {
+	if pub {
+		return token.Pub.String() + " "
+	}
+
+	return ""
+}
+
+// Produces the arg list of a fn, without type hints.
+func args(fn *typeset.Fn) string {
+	out := make([]string, len(fn.Args))
+	for i, arg := range fn.Args {
+		out[i] = arg.ID()
+		if arg.Vararg {
+			out[i] = token.Spread.String() + out[i]
+		}
+	}
+
+	return strings.Join(out, ", ")
+}
+
+// Produces the source of the value of enum member `m`.
+func memberValue(m *typeset.EnumMember) string {
+	switch m.ValueType {
+	case token.StrL.String():
+		if strings.Contains(m.Value, "'") {
+			return `"` + m.Value + `"`
+		}
+		return "'" + m.Value + "'"
+
+	case token.IntL.String(), token.BoolL.String():
+		return m.Value
+
+	default:
+		return "0"
+	}
+}
@@ -0,0 +1,113 @@
+// Package target describes the Lua runtimes compiled output can target. The
+// emitter consults the target's profile wherever the runtimes differ.
+package target
+
+import (
+	"sort"
+	"strings"
+)
+
+// Continue describes how `continue` statements are lowered.
+type Continue uint8
+
+const (
+	// `repeat <body> until true`, with `continue` lowered to `break`.
+	ContinueRepeat Continue = iota
+	// `goto` a label at the end of the loop body.
+	ContinueGoto
+	// A native `continue` statement.
+	ContinueNative
+)
+
+// Target is the profile of a Lua runtime.
+type Target struct {
+	Name string
+	// The environment of compiled scripts is installed with `setfenv`, rather
+	// than through an `_ENV` upvalue (5.2+).
+	Setfenv bool
+	// The `//` operator is native, otherwise it's lowered to `math.floor(a / b)`.
+	FloorDiv bool
+	// Bitwise operators are native, otherwise they're lowered to the fns of
+	// BitLib.
+	Bitwise bool
+	// The library providing bitwise fns, if operators aren't native. If empty,
+	// bitwise operators are unavailable.
+	BitLib   string
+	Continue Continue
+	// The fn unpacking a table into values.
+	Unpack string
+	// Numbers have a distinct 64-bit integer subtype, otherwise all numbers are
+	// doubles and integers are exact only up to 2^53.
+	Integers bool
+}
+
+// The default target, the runtime of `skal exec`.
+var Lua51 = Target{
+	Name:     "lua5.1",
+	Setfenv:  true,
+	Continue: ContinueRepeat,
+	Unpack:   "unpack",
+}
+
+var targets = map[string]Target{
+	"lua5.1": Lua51,
+	"lua5.2": {
+		Name:     "lua5.2",
+		BitLib:   "bit32",
+		Continue: ContinueGoto,
+		Unpack:   "table.unpack",
+	},
+	"lua5.3": {
+		Name:     "lua5.3",
+		FloorDiv: true,
+		Bitwise:  true,
+		Continue: ContinueGoto,
+		Unpack:   "table.unpack",
+		Integers: true,
+	},
+	"lua5.4": {
+		Name:     "lua5.4",
+		FloorDiv: true,
+		Bitwise:  true,
+		Continue: ContinueGoto,
+		Unpack:   "table.unpack",
+		Integers: true,
+	},
+	"luajit": {
+		Name:     "luajit",
+		Setfenv:  true,
+		BitLib:   "bit",
+		Continue: ContinueGoto,
+		Unpack:   "unpack",
+	},
+	"luau": {
+		Name:     "luau",
+		Setfenv:  true,
+		FloorDiv: true,
+		BitLib:   "bit32",
+		Continue: ContinueNative,
+		Unpack:   "table.unpack",
+	},
+}
+
+// Lookup finds the target named `name` (e.g. `lua5.4`, or just `5.4`).
+func Lookup(name string) (Target, bool) {
+	name = strings.ToLower(name)
+	if t, ok := targets[name]; ok {
+		return t, true
+	}
+
+	t, ok := targets["lua"+name]
+	return t, ok
+}
+
+// Names lists the names of all targets.
+func Names() []string {
+	out := make([]string, 0, len(targets))
+	for name := range targets {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+
+	return out
+}
@@ -0,0 +1,53 @@
+-- Create the library environment.
+local __ENV__ = setmetatable({}, { __index = _G })
+-- Open the library function.
+local function __LOAD__()
+  do -- FILE: util
+    function clamp(n, lo, hi)
+      return math.max(lo, math.min(n, hi))
+    end
+  end
+  version = 2
+  Level = {
+    LOW = 1,
+    HIGH = 2
+  }
+  Meter = {}
+  setmetatable(Meter, Meter)
+  Meter.__index = Meter
+  function Meter:__call(value)
+    return setmetatable({
+      value = value
+    }, self)
+  end
+  function Meter:set(v)
+    self.value = clamp(v, 0, Level.HIGH)
+  end
+  local count = 0
+  local function scale(n)
+    count = count + 1
+    return n * 2
+  end
+  local Private = {}
+  setmetatable(Private, Private)
+  Private.__index = Private
+  function Private:__call(x)
+    return setmetatable({
+      x = x
+    }, self)
+  end
+  function make()
+    return Meter(scale(0))
+  end
+end
+-- Set the library function's environment to the library environment table.
+setfenv(__LOAD__, __ENV__)
+-- Load the library.
+__LOAD__()
+-- Export the module's symbols.
+return {
+  version = __ENV__.version,
+  Level = __ENV__.Level,
+  Meter = __ENV__.Meter,
+  make = __ENV__.make,
+}
@@ -27,6 +27,8 @@ type SkalType interface {
 	Defers() chan *Statement
 	SetToken(tk token.Token)
 	Token() token.Token
+	SetSymbol(sym *Symbol)
+	Symbol() *Symbol
 }
 
 var _ SkalType = (*Base)(nil)
@@ -42,6 +44,7 @@ func NewBase(n *parse.Node, p SkalType) *Base {
 type Base struct {
 	parent SkalType
 	token  token.Token
+	symbol *Symbol
 	refs   []string
 	defers []*Statement
 	_type  token.Type
@@ -88,7 +91,7 @@ func (s *Base) Ref() string {
 			continue
 		}
 
-		out.WriteString(lua.Translate(p))
+		out.WriteString(s.segment(i))
 		if i < s.RefsLen()-1 && !strings.HasPrefix(
 			next,
 			"[") && next != token.Comma.String() {
@@ -99,6 +102,16 @@ func (s *Base) Ref() string {
 	return out.String()
 }
 
+// Produces the Lua representation of the `i`th ref segment. Only root names
+// are translated, fields (e.g. the `random` of `math.random`) are left as-is.
+func (s *Base) segment(i int) string {
+	if i == 0 || s.refs[i-1] == token.Comma.String() {
+		return lua.Translate(s.refs[i])
+	}
+
+	return s.refs[i]
+}
+
 func (s *Base) Refs() []string {
 	return s.refs
 }
@@ -131,7 +144,7 @@ func (s *Base) MethodRef() string {
 			continue
 		}
 
-		out.WriteString(lua.Translate(p))
+		out.WriteString(s.segment(i))
 		if i < s.RefsLen()-2 && !strings.HasPrefix(
 			next,
 			"[") && next != token.Comma.String() {
@@ -179,6 +192,12 @@ func (s *Base) Token() token.Token {
 	return s.token
 }
 
+// SetSymbol binds the symbol a reference resolved to.
+func (s *Base) SetSymbol(sym *Symbol) { s.symbol = sym }
+
+// Symbol returns the symbol bound by the resolver, if any.
+func (s *Base) Symbol() *Symbol { return s.symbol }
+
 /*------------------------------------------------------------------------------
  * Ref Build
  *----------------------------------------------------------------------------*/
@@ -208,11 +227,17 @@ func buildRef[T SkalType](n node, t T) T {
 func buildRefIndex(n node) []string {
 	var out []string
 	for _, child := range n.Children {
-		if child.Value == token.This.String() {
-			// 'this'
+		switch {
+		// 'this'
+		case child.Value == token.This.String():
 			out = append(out, "self")
-		} else {
-			// Anything else.
+
+		// StrL
+		case child.Type == token.StrL:
+			out = append(out, "'"+child.Value+"'")
+
+		// Anything else.
+		default:
 			out = append(out, child.Value)
 		}
 	}
@@ -26,7 +26,7 @@ func buildBind(n node, p SkalType, rebind bool) Bind {
 		switch child.Type {
 		// Reference
 		case token.Ref:
-			bind.Binds = append(bind.Binds, buildRef(child, &Base{}))
+			bind.Binds = append(bind.Binds, buildRef(child, NewBase(child, &bind)))
 
 		// Values
 		case token.Value:
@@ -34,6 +34,7 @@ func buildEnum(n node) Enum {
 		// ID
 		case token.ID:
 			e.AddRef(child.Value)
+			e.SetToken(child.Token)
 
 		// Members
 		case token.EnumMember:
@@ -0,0 +1,22 @@
+package typeset
+
+import (
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+)
+
+// Reports a typeset error, bailing out of the member being typeset.
+func typesetError(code, msg string, tk token.Token) {
+	ev := sklog.NewCompilerEvent(sklog.MsgTypeTypesetError, sklog.LevelFatal).
+		WithCode(code)
+	if tk != nil {
+		ev.WithSourceHint(
+			tk.SrcLine(),
+			tk.File(),
+			tk.LineStart(),
+			tk.ColumnStart(),
+			tk.ColumnEnd(),
+		)
+	}
+	ev.Str(msg).Bail()
+}
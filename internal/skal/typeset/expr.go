@@ -0,0 +1,118 @@
+package typeset
+
+import "github.com/illbjorn/skal/internal/skal/lex/token"
+
+/*------------------------------------------------------------------------------
+ * Expressions
+ *----------------------------------------------------------------------------*/
+
+// Expr is a run of values parsed into an expression tree, by Lua's precedence
+// rules. Values are held as flat runs of operands and operators, the tree is
+// built by passes which need the operands of each operator.
+//
+//	a + b & c  ->  (a + b) & c
+type Expr struct {
+	// An operand, if Op is nil.
+	Value *Value
+	Op    *Value
+	// LHS is nil for unary operators.
+	LHS, RHS *Expr
+}
+
+// ParseExpr parses a run of values into an expression tree.
+func ParseExpr(values []*Value) *Expr {
+	p := &exprParser{values: values}
+	return p.expr(0)
+}
+
+// Values flattens the expression back into a run of values.
+func (x *Expr) Values() []*Value {
+	switch {
+	case x == nil:
+		return nil
+
+	// Operand
+	case x.Op == nil:
+		return []*Value{x.Value}
+
+	// Unary operator
+	case x.LHS == nil:
+		return append([]*Value{x.Op}, x.RHS.Values()...)
+	}
+
+	return append(append(x.LHS.Values(), x.Op), x.RHS.Values()...)
+}
+
+type exprParser struct {
+	values []*Value
+	i      int
+}
+
+func (p *exprParser) expr(min int) *Expr {
+	lhs := p.unary()
+
+	for p.i < len(p.values) {
+		op := p.values[p.i]
+		prec := Precedence(op.Op)
+		if op.Op == "" || prec < min {
+			break
+		}
+		p.i++
+
+		// Concatenation is right associative.
+		next := prec + 1
+		if op.Op == token.Concat.String() {
+			next = prec
+		}
+
+		lhs = &Expr{Op: op, LHS: lhs, RHS: p.expr(next)}
+	}
+
+	return lhs
+}
+
+func (p *exprParser) unary() *Expr {
+	if p.i >= len(p.values) {
+		return nil
+	}
+
+	v := p.values[p.i]
+	p.i++
+
+	// '!'
+	if v.ValueType == token.Not {
+		return &Expr{Op: v, RHS: p.unary()}
+	}
+
+	return &Expr{Value: v}
+}
+
+// Precedence produces the precedence of binary operator `op`, per the Lua 5.3
+// reference manual. Higher binds tighter, 0 if `op` isn't a binary operator.
+func Precedence(op string) int {
+	switch op {
+	case token.Or.String():
+		return 1
+	case token.And.String():
+		return 2
+	case token.LT.String(), token.GT.String(), token.LE.String(), token.GE.String(),
+		token.NE.String(), token.EQEQ.String():
+		return 3
+	case token.BitOr.String():
+		return 4
+	case token.BitXor.String():
+		return 5
+	case token.BitAnd.String():
+		return 6
+	case token.Shl.String(), token.Shr.String():
+		return 7
+	case token.Concat.String():
+		return 8
+	case token.Plus.String(), token.Minus.String():
+		return 9
+	case token.Mult.String(), token.Div.String(), token.FloorDiv.String():
+		return 10
+	default:
+		return 0
+	}
+}
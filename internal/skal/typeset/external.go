@@ -1,6 +1,9 @@
 package typeset
 
 import (
+	"strings"
+
+	"github.com/illbjorn/skal/internal/skal/decl"
 	"github.com/illbjorn/skal/internal/skal/lex/token"
 	"github.com/illbjorn/skal/internal/skal/parse"
 	"github.com/illbjorn/skal/internal/skal/sklog"
@@ -12,18 +15,31 @@ func NewExternal(n *parse.Node, p SkalType) External {
 
 type External struct {
 	SkalType
+	// The declaration of the foreign reference, if one was found in the loaded
+	// declaration files. Untyped externs have a nil Decl.
+	Decl   *decl.Symbol
 	Alias  string
 	ExtRef []string
 }
 
-func buildExtern(n node) []*External {
+// Signature produces the declared signature of the foreign reference, if the
+// extern is typed.
+func (ext *External) Signature() string {
+	if ext.Decl == nil {
+		return ""
+	}
+
+	return ext.Decl.Signature()
+}
+
+func buildExtern(n node, decls *decl.Set) []*External {
 	extern := make([]*External, 0)
 
 	for _, child := range n.Children {
 		switch child.Type {
 		// External
 		case token.External:
-			external := buildExternal(child, nil)
+			external := buildExternal(child, nil, decls)
 			extern = append(extern, &external)
 
 		default:
@@ -34,7 +50,7 @@ func buildExtern(n node) []*External {
 	return extern
 }
 
-func buildExternal(n node, p SkalType) External {
+func buildExternal(n node, p SkalType, decls *decl.Set) External {
 	ext := NewExternal(n, p)
 
 	for _, child := range n.Children {
@@ -52,5 +68,22 @@ func buildExternal(n node, p SkalType) External {
 		}
 	}
 
+	// Attach the declaration, if the foreign reference is declared.
+	sym, ok := decls.Lookup(ext.Refs()...)
+	switch {
+	// Fully declared.
+	case ok:
+		ext.Decl = sym
+
+	// A declared table is missing the referenced member.
+	case sym != nil && sym.Kind == decl.KindTable:
+		typesetError(
+			sklog.CodeUndeclaredExtern,
+			"Extern '"+strings.Join(ext.Refs(), ".")+"' is not declared, "+
+				sym.Signature()+" has no such member.",
+			ext.Token(),
+		)
+	}
+
 	return ext
 }
@@ -15,6 +15,7 @@ type Fn struct {
 	Args        []*FnArg
 	Block       []*Statement
 	Values      []*Value
+	ReturnType  string
 	Method      bool
 	Constructor bool
 }
@@ -25,7 +26,8 @@ func NewFnArg(n *parse.Node, p SkalType) FnArg {
 
 type FnArg struct {
 	SkalType
-	Vararg bool
+	TypeHint string
+	Vararg   bool
 }
 
 func buildFn(n node, p SkalType) Fn {
@@ -36,6 +38,7 @@ func buildFn(n node, p SkalType) Fn {
 		// ID
 		case token.ID:
 			fn.AddRef(child.Value)
+			fn.SetToken(child.Token)
 
 		// Args
 		case token.FnArg:
@@ -63,9 +66,9 @@ func buildFn(n node, p SkalType) Fn {
 				p.(*Struct).NoConstructor = true
 			}
 
-		// Type hint.
-		// case token.TypeHint:
-		// fn.SetType(child.Value)
+		// Return type hint.
+		case token.TypeHint:
+			fn.ReturnType = child.Value
 
 		default:
 			sklog.UnexpectedType("typeset fn node", child.Type.String())
@@ -89,8 +92,8 @@ func buildFnArg(n node, p SkalType) FnArg {
 			arg.Vararg = true
 
 		// Type Hint
-		// case token.TypeHint:
-		// 	arg.SetType(child.Value)
+		case token.TypeHint:
+			arg.TypeHint = child.Value
 
 		default:
 			sklog.UnexpectedType("typeset fn arg node", child.Type.String())
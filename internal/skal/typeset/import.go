@@ -0,0 +1,91 @@
+package typeset
+
+import (
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+	"github.com/illbjorn/skal/internal/skal/parse"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+)
+
+func NewImport(n *parse.Node, p SkalType) Import {
+	return Import{SkalType: NewBase(n, p)}
+}
+
+// Import is the import of another module.
+//
+//	import 'ui/button'
+//	import 'ui/button' as btn
+//	import { Button, Theme } from 'ui'
+type Import struct {
+	SkalType
+	// The imported path, as written.
+	Path string
+	// Path of the imported module's source file, once located.
+	Module string
+	// The name the module is bound to, if aliased.
+	Alias string
+	// The names imported, if selective.
+	Names []*ImportName
+	// The globals of the imported module, by name. Set by the resolver for
+	// aliased imports.
+	Exports map[string]*Symbol
+}
+
+// Indicates whether the import makes all globals of the module visible, as
+// opposed to an aliased or selective import.
+func (imp *Import) Whole() bool {
+	return imp.Alias == "" && imp.Names == nil
+}
+
+func NewImportName(n *parse.Node, p SkalType) ImportName {
+	return ImportName{SkalType: NewBase(n, p)}
+}
+
+// ImportName is a single name of a selective import.
+type ImportName struct {
+	SkalType
+}
+
+// Imports builds the imports leading a parsed module.
+//
+// Imports are collected before the module is typeset (they determine the
+// modules and declarations it's typeset with), so Typeset skips them.
+func Imports(tree node) []*Import {
+	var out []*Import
+	for _, member := range tree.Children {
+		for _, child := range member.Children {
+			if child.Type == token.Import {
+				imp := buildImport(child)
+				out = append(out, &imp)
+			}
+		}
+	}
+
+	return out
+}
+
+func buildImport(n node) Import {
+	imp := NewImport(n, nil)
+
+	for _, child := range n.Children {
+		switch child.Type {
+		// Path
+		case token.StrL:
+			imp.Path = child.Value
+
+		// Name
+		case token.ID:
+			name := NewImportName(child, &imp)
+			name.AddRef(child.Value)
+			imp.Names = append(imp.Names, &name)
+
+		// 'as'
+		case token.As:
+			imp.Alias = child.Value
+
+		default:
+			sklog.UnexpectedType("import node", child.Type.String())
+		}
+	}
+
+	return imp
+}
@@ -1,8 +1,6 @@
 package typeset
 
 import (
-	"fmt"
-
 	"github.com/illbjorn/skal/internal/skal/lex/token"
 	"github.com/illbjorn/skal/internal/skal/parse"
 	"github.com/illbjorn/skal/internal/skal/sklog"
@@ -32,6 +30,11 @@ func buildStatement(n node, p SkalType) Statement {
 	stmt := NewStatement(n, p)
 
 	for _, child := range n.Children {
+		// Statement nodes carry no token of their own, borrow the first one.
+		if stmt.Token() == nil {
+			stmt.SetToken(child.Token)
+		}
+
 		switch child.Type {
 		// 'return'
 		case token.Ret:
@@ -41,6 +44,10 @@ func buildStatement(n node, p SkalType) Statement {
 				stmt.Values = append(stmt.Values, &value)
 			}
 
+		// 'continue'
+		case token.Continue:
+			stmt.StmtType = token.Continue
+
 		// 'for'
 		case token.For:
 			stmt.StmtType = token.For
@@ -84,20 +91,13 @@ func buildStatement(n node, p SkalType) Statement {
 			stmt.StmtType = token.Defer
 			stmt.AddDefer(&def)
 
-		case token.Ref:
-			fmt.Println(child.Children)
-
 		default:
 			sklog.UnexpectedType("typeset statement node", child.Type.String())
 		}
 	}
 
-	if n.Token != nil {
-		fmt.Println(n.Token.File())
-	}
-
 	if stmt.StmtType == 0 {
-		sklog.CFatal("Statement fell through with no type.")
+		sklog.BailF("Statement fell through with no type.")
 	}
 
 	return stmt
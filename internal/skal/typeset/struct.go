@@ -33,6 +33,7 @@ func buildStruct(n node) Struct {
 		// ID
 		case token.ID:
 			s.AddRef(child.Value)
+			s.SetToken(child.Token)
 
 		// Field
 		case token.StructField:
@@ -42,6 +43,7 @@ func buildStruct(n node) Struct {
 		// Method
 		case token.StructMethod:
 			fn := buildFn(child, &s)
+			fn.Method = true
 			s.Methods = append(s.Methods, &fn)
 
 		default:
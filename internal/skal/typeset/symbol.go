@@ -0,0 +1,85 @@
+package typeset
+
+import (
+	"github.com/illbjorn/skal/internal/skal/decl"
+	"github.com/illbjorn/skal/internal/skal/lex/token"
+)
+
+// SymbolKind describes what a resolved name refers to.
+type SymbolKind uint8
+
+const (
+	SymVar      SymbolKind = iota + 1 // Let binding or top-level assignment.
+	SymFn                             // Fn declaration.
+	SymStruct                         // Struct declaration.
+	SymEnum                           // Enum declaration.
+	SymArg                            // Fn or method argument.
+	SymIterator                       // For loop iterator.
+	SymExtern                         // Extern alias.
+	SymHost                           // Declared host Lua global (stdlib, .skd).
+	SymThis                           // Struct method instance (`this`).
+	SymModule                         // Aliased module import.
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymVar:
+		return "variable"
+	case SymFn:
+		return "fn"
+	case SymStruct:
+		return "struct"
+	case SymEnum:
+		return "enum"
+	case SymArg:
+		return "argument"
+	case SymIterator:
+		return "iterator"
+	case SymExtern:
+		return "extern"
+	case SymHost:
+		return "host global"
+	case SymThis:
+		return "this"
+	case SymModule:
+		return "module"
+	default:
+		return ""
+	}
+}
+
+// Symbol is a single named declaration, as bound to references by the
+// resolver.
+type Symbol struct {
+	// The declaring typeset node, nil for host globals.
+	Decl SkalType
+	// The host declaration for host globals and typed extern aliases.
+	Extern *decl.Symbol
+	Token  token.Token
+	Name   string
+	File   string
+	Kind   SymbolKind
+	Pub    bool
+}
+
+// HostTable indicates whether the symbol refers to a declared host Lua table
+// (e.g. `string`, or an extern alias of one). Fields of host tables are plain
+// fns rather than methods.
+func (s *Symbol) HostTable() bool {
+	return s != nil && s.Extern != nil && s.Extern.Kind == decl.KindTable
+}
+
+// Module indicates whether the symbol refers to an aliased module import.
+// Fields of modules are plain fns rather than methods.
+func (s *Symbol) Module() bool {
+	return s != nil && s.Kind == SymModule
+}
+
+// Line produces the source line the symbol was declared on, or 0 if unknown.
+func (s *Symbol) Line() int {
+	if s.Token == nil {
+		return 0
+	}
+
+	return s.Token.LineStart()
+}
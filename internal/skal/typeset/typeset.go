@@ -1,6 +1,7 @@
 package typeset
 
 import (
+	"github.com/illbjorn/skal/internal/skal/decl"
 	"github.com/illbjorn/skal/internal/skal/lex/token"
 	"github.com/illbjorn/skal/internal/skal/parse"
 	"github.com/illbjorn/skal/internal/skal/sklog"
@@ -10,19 +11,26 @@ import (
 // detangle package coupling.
 type node *parse.Node
 
-func Typeset(tree node) TypeSet {
+// Typeset builds the typed tree of a parsed module. Extern references are
+// checked against and annotated with the provided declarations.
+//
+// Problems are reported to `diags`, a member which fails to typeset is omitted.
+func Typeset(tree node, decls *decl.Set, diags *sklog.Diagnostics) TypeSet {
 	ctc := NewTypeSet()
 
 	for _, child := range tree.Children {
-		ctc.Add(
-			typeset(child),
-		)
+		func() {
+			defer func() { diags.Catch(recover()) }()
+			ctc.Add(
+				typeset(child, decls),
+			)
+		}()
 	}
 
 	return ctc
 }
 
-func typeset(n node) (any, string, token.Type) {
+func typeset(n node, decls *decl.Set) (any, string, token.Type) {
 	var pub bool
 	for _, child := range n.Children {
 		switch child.Type {
@@ -85,9 +93,14 @@ func typeset(n node) (any, string, token.Type) {
 
 		// 'extern'
 		case token.Extern:
-			extern := buildExtern(child)
+			extern := buildExtern(child, decls)
 			return extern, "", token.Extern
 
+		// 'import'
+		// Collected ahead of time, see `Imports`.
+		case token.Import:
+			return nil, "", 0
+
 		default:
 			sklog.UnexpectedType("typeset node", child.Type.String())
 		}
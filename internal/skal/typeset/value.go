@@ -90,7 +90,8 @@ func buildValue(n node, p SkalType) Value {
 		v.Not = true
 
 	// All Operators
-	case token.ConcatOperator, token.MathOperator, token.ComparisonOperator, token.LogicOperator:
+	case token.ConcatOperator, token.MathOperator, token.BitwiseOperator,
+		token.ComparisonOperator, token.LogicOperator:
 		v.Op = n.Value
 
 	default:
@@ -0,0 +1,63 @@
+// Package vfs abstracts the file system source files are read from: the host
+// disk, or a caller-provided `fs.FS`.
+package vfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// FS reads source files. The zero value reads from the host disk.
+type FS struct {
+	fsys fs.FS
+}
+
+// New wraps `fsys`, a nil `fsys` reads from the host disk.
+func New(fsys fs.FS) FS {
+	return FS{fsys: fsys}
+}
+
+// Disk indicates whether the FS reads from the host disk.
+func (v FS) Disk() bool {
+	return v.fsys == nil
+}
+
+func (v FS) ReadFile(name string) ([]byte, error) {
+	if v.fsys == nil {
+		return os.ReadFile(name)
+	}
+
+	return fs.ReadFile(v.fsys, v.name(name))
+}
+
+func (v FS) Stat(name string) (fs.FileInfo, error) {
+	if v.fsys == nil {
+		return os.Stat(name)
+	}
+
+	return fs.Stat(v.fsys, v.name(name))
+}
+
+func (v FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if v.fsys == nil {
+		return os.ReadDir(name)
+	}
+
+	return fs.ReadDir(v.fsys, v.name(name))
+}
+
+// Converts an OS path into a valid `fs.FS` path: slash separated, cleaned and
+// unrooted.
+func (v FS) name(name string) string {
+	name = path.Clean(filepath.ToSlash(name))
+	for len(name) > 0 && name[0] == '/' {
+		name = name[1:]
+	}
+	if name == "" {
+		return "."
+	}
+
+	return name
+}
@@ -0,0 +1,206 @@
+// Package skal compiles and runs Skal programs from Go.
+//
+// Sources are read from an `fs.FS`, so programs (and their imports) can be
+// compiled from memory, an embedded file system or disk alike:
+//
+//	res, err := skal.Compile(ctx, os.DirFS("./scripts"), "main.sk", skal.Options{})
+//	if err != nil {
+//		for _, d := range res.Diagnostics {
+//			log.Println(d.Plain())
+//		}
+//		return err
+//	}
+//
+// Nothing in this package writes to stdout or exits the process: problems are
+// returned as structured diagnostics.
+//
+// Scripts executed by Run are sandboxed: they can't reach outside of the
+// script unless Options.Libs opens the host libraries which do (files, the
+// environment, processes, the network). A script can't exit the host process
+// unless LibOS is opened.
+package skal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	compiler "github.com/illbjorn/skal/internal/skal"
+	"github.com/illbjorn/skal/internal/skal/exec"
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/internal/skal/srcmap"
+)
+
+// The entrypoint path of `CompileString`.
+const stringEntry = "main.sk"
+
+var errNilFS = errors.New("skal: nil fs.FS")
+
+type (
+	// Diagnostic is a single problem reported while compiling.
+	Diagnostic = sklog.Diagnostic
+	// Fix is a machine-applicable edit resolving a diagnostic.
+	Fix = sklog.Fix
+	// Error is returned by a compile which reported errors, it lists every
+	// diagnostic reported.
+	Error = sklog.Error
+	// RuntimeError is returned by Run for errors raised by the executed script,
+	// its Diagnostic is positioned in the Skal source.
+	RuntimeError = exec.Error
+	// SourceMap maps lines of compiled Lua back to the Skal source.
+	SourceMap = srcmap.Map
+	// Libs selects the host libraries opened for scripts executed by Run.
+	//
+	// Scripts always have the libraries which can't reach outside of them: the
+	// base library (less `dofile` and `loadfile`), `string`, `table`, `math`,
+	// `coroutine`, the time fns of `os` (`clock`, `date`, `difftime`, `time`),
+	// `io.write` (to the writer given to Run) and the Skal `conv` library.
+	Libs = exec.Libs
+)
+
+const (
+	// All of `os`: the environment, files, processes and `os.exit`, which exits
+	// the host process.
+	LibOS = exec.LibOS
+	// All of `io`, along with `dofile` and `loadfile`: files and processes.
+	LibIO = exec.LibIO
+	// The `debug` library.
+	LibDebug = exec.LibDebug
+	// `require` and `package`: loading Lua modules from disk.
+	LibPackage = exec.LibPackage
+	// The Skal `http` library: requests to the network.
+	LibHTTP = exec.LibHTTP
+	// Every library: the script has all the access of the host process.
+	LibAll = exec.LibAll
+)
+
+// Options holds the settings of a compilation.
+type Options struct {
+	// Module search roots within the compiled fs.FS, consulted in order after
+	// the directory of the entry file when resolving imports.
+	Paths []string
+	// Paths of declaration files (or directories containing them), within the
+	// compiled fs.FS, to load in addition to the bundled standard library
+	// declarations.
+	Decls []string
+	// Name of the Lua runtime compiled output targets: lua5.1 (the default),
+	// lua5.2, lua5.3, lua5.4, luajit or luau. Run always targets Lua 5.1.
+	Target string
+	// If set, the Lua returns a table of the entry file's `pub` symbols (to be
+	// loaded with `require`), rather than running as an app. Ignored by Run.
+	Lib bool
+	// If set, the compiled modules are optimized: constants are folded, dead
+	// code removed, small fns and enum members inlined, and repeated host fn
+	// lookups cached in locals.
+	Optimize bool
+	// If set, the Lua is minified: locals are renamed to short names, and
+	// comments and whitespace dropped. The source map still applies.
+	Minify bool
+	// If set, the comments of the source are kept in the Lua, those leading
+	// `pub` fns and structs as LuaLS doc comments. Ignored if Minify is set.
+	Comments bool
+	// The host libraries scripts executed by Run may use, beyond those always
+	// available (see Libs). None by default, combine them to open several:
+	// `LibOS | LibIO`. Ignored by Compile.
+	Libs Libs
+}
+
+// Result is the outcome of a compilation.
+type Result struct {
+	// The compiled Lua, set by Compile and CompileString if the compile
+	// succeeded.
+	Lua []byte
+	// The source map of Lua.
+	SourceMap *SourceMap
+	// Every diagnostic reported, including warnings, in the order reported.
+	Diagnostics []Diagnostic
+}
+
+// Compile compiles the Skal source file `entry` of `fsys`, along with the
+// modules it imports (also read from `fsys`).
+//
+// The Result is always returned, if any errors are reported the error is an
+// *Error.
+func Compile(ctx context.Context, fsys fs.FS, entry string, opts Options) (*Result, error) {
+	if fsys == nil {
+		return &Result{}, errNilFS
+	}
+
+	diags := sklog.NewDiagnostics()
+
+	lua, m, err := compiler.Build(ctx, entry, opts.internal(fsys, diags, nil))
+
+	return &Result{Lua: lua, SourceMap: m, Diagnostics: diags.List()}, err
+}
+
+// CompileString compiles a single Skal source file held in memory. Imports of
+// other modules are unavailable.
+func CompileString(ctx context.Context, src string, opts Options) (*Result, error) {
+	return Compile(ctx, stringFS(src), stringEntry, opts)
+}
+
+// Run compiles the Skal source file `entry` of `fsys` and executes it, writing
+// the output of the script to `stdout`. The script is stopped if `ctx` is
+// canceled. It may only use the host libraries opened by `opts.Libs`.
+//
+// If the compile fails nothing is executed and the error is an *Error,
+// otherwise the error is that of the executed script: a *RuntimeError for
+// errors raised by the script.
+func Run(ctx context.Context, fsys fs.FS, entry string, stdout io.Writer, opts Options) (*Result, error) {
+	if fsys == nil {
+		return &Result{}, errNilFS
+	}
+
+	diags := sklog.NewDiagnostics()
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	err := compiler.Run(ctx, entry, opts.internal(fsys, diags, stdout))
+
+	return &Result{Diagnostics: diags.List()}, err
+}
+
+func (opts Options) internal(fsys fs.FS, diags *sklog.Diagnostics, stdout io.Writer) compiler.Options {
+	return compiler.Options{
+		Paths:       opts.Paths,
+		Decls:       opts.Decls,
+		Diagnostics: diags,
+		Target:      opts.Target,
+		Lib:         opts.Lib,
+		Optimize:    opts.Optimize,
+		Minify:      opts.Minify,
+		Comments:    opts.Comments,
+		FS:          fsys,
+		Stdout:      stdout,
+		Libs:        opts.Libs,
+	}
+}
+
+// A file system holding only the source compiled by CompileString, at
+// stringEntry.
+type stringFS string
+
+func (src stringFS) Open(name string) (fs.File, error) {
+	if name != stringEntry {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return stringFile{strings.NewReader(string(src))}, nil
+}
+
+// The source of a stringFS, which is its own fs.FileInfo.
+type stringFile struct {
+	*strings.Reader
+}
+
+func (f stringFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f stringFile) Close() error               { return nil }
+func (f stringFile) Name() string               { return stringEntry }
+func (f stringFile) Mode() fs.FileMode          { return 0o444 }
+func (f stringFile) ModTime() time.Time         { return time.Time{} }
+func (f stringFile) IsDir() bool                { return false }
+func (f stringFile) Sys() any                   { return nil }
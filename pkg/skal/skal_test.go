@@ -0,0 +1,147 @@
+package skal_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/illbjorn/skal/internal/skal/sklog"
+	"github.com/illbjorn/skal/pkg/skal"
+)
+
+func TestCompile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"game/main.sk":     {Data: []byte("import 'util'\nprint(double(2))\n")},
+		"game/lib/util.sk": {Data: []byte("pub fn double(n) {\n  return n * 2\n}\n")},
+	}
+
+	res, err := skal.Compile(context.Background(), fsys, "game/main.sk", skal.Options{Paths: []string{"game/lib"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Diagnostics) != 0 {
+		t.Errorf("diagnostics = %v, want none", res.Diagnostics)
+	}
+	if !bytes.Contains(res.Lua, []byte("double")) {
+		t.Errorf("the Lua lacks the imported module:\n%s", res.Lua)
+	}
+	if res.SourceMap == nil {
+		t.Error("no source map")
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	res, err := skal.CompileString(context.Background(), "let count = 1\nprint(cuont)\n", skal.Options{})
+
+	var cerr *skal.Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("CompileString() = %v, want an *Error", err)
+	}
+	if res.Lua != nil {
+		t.Errorf("failed compile returned Lua:\n%s", res.Lua)
+	}
+
+	// The error lists the diagnostics reported, as does the Result.
+	for _, list := range [][]skal.Diagnostic{cerr.Diagnostics, res.Diagnostics} {
+		if len(list) != 1 {
+			t.Fatalf("diagnostics = %v, want 1", list)
+		}
+		d := list[0]
+		if d.Code != sklog.CodeUndefinedRef || d.File != "main.sk" || d.Line != 2 || d.ColStart != 7 {
+			t.Errorf("got %s at %s:%d:%d, want %s at main.sk:2:7",
+				d.Code, d.File, d.Line, d.ColStart, sklog.CodeUndefinedRef)
+		}
+	}
+}
+
+func TestCompileNilFS(t *testing.T) {
+	if _, err := skal.Compile(context.Background(), nil, "main.sk", skal.Options{}); err == nil {
+		t.Error("compiled a nil fs.FS")
+	}
+}
+
+// Scripts only reach outside of themselves through the libraries opened.
+func TestRunLibs(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "host.lua")
+	if err := os.WriteFile(script, []byte("return 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		src  string
+		libs skal.Libs
+	}{
+		{name: "os", src: "print(os.getenv('HOME'))", libs: skal.LibOS},
+		{name: "os.exit", src: "os.exit(1)", libs: skal.LibOS},
+		{name: "io", src: "print(io.open('main.sk'))", libs: skal.LibIO},
+		{name: "dofile", src: "dofile('" + filepath.ToSlash(script) + "')", libs: skal.LibIO},
+		{name: "require", src: "require('os')", libs: skal.LibPackage},
+		{name: "debug", src: "print(debug.traceback())", libs: skal.LibDebug},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"main.sk": {Data: []byte(c.src)}}
+
+			_, err := skal.Run(context.Background(), fsys, "main.sk", nil, skal.Options{})
+			var rerr *skal.RuntimeError
+			if !errors.As(err, &rerr) {
+				t.Fatalf("Run() = %v, want a *RuntimeError", err)
+			}
+			if d := rerr.Diagnostic; d.File != "main.sk" || d.Line != 1 {
+				t.Errorf("raised at %s:%d, want main.sk:1", d.File, d.Line)
+			}
+
+			// os.exit would exit the test binary.
+			if c.name == "os.exit" {
+				return
+			}
+			if _, err := skal.Run(context.Background(), fsys, "main.sk", nil, skal.Options{Libs: c.libs}); err != nil {
+				t.Errorf("Run() with the library opened = %v", err)
+			}
+		})
+	}
+}
+
+func TestRunAlwaysOpen(t *testing.T) {
+	fsys := fstest.MapFS{"main.sk": {Data: []byte(
+		"io.write(string.upper('a'), math.floor(1.5))\nprint(type(os.time()), table.concat(['b', 'c']))\n",
+	)}}
+
+	out := new(strings.Builder)
+	if _, err := skal.Run(context.Background(), fsys, "main.sk", out, skal.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "A1number\tbc\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func ExampleCompileString() {
+	res, err := skal.CompileString(context.Background(), "print(undefined)", skal.Options{})
+	if err != nil {
+		for _, d := range res.Diagnostics {
+			fmt.Println(d.Code, d.Line, d.ColStart)
+		}
+	}
+	// Output: SK0301 1 7
+}
+
+func ExampleRun() {
+	fsys := fstest.MapFS{
+		"main.sk":  {Data: []byte("import 'greet'\nhello('world')\n")},
+		"greet.sk": {Data: []byte("pub fn hello(name) {\n  print('hello, ' .. name)\n}\n")},
+	}
+
+	if _, err := skal.Run(context.Background(), fsys, "main.sk", os.Stdout, skal.Options{}); err != nil {
+		fmt.Println(err)
+	}
+	// Output: hello, world
+}